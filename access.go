@@ -0,0 +1,325 @@
+package meerkat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// A Role determines what a user may do.
+type Role string
+
+const (
+	// RoleViewer may view dashboards in folders they have access to.
+	RoleViewer Role = "viewer"
+	// RoleEditor may additionally change dashboards in folders
+	// where they are listed as an editor.
+	RoleEditor Role = "editor"
+	// RoleAdmin may view and change everything, and manage users.
+	RoleAdmin Role = "admin"
+)
+
+func (r Role) valid() bool {
+	return r == RoleViewer || r == RoleEditor || r == RoleAdmin
+}
+
+// User is an account which may log in to Meerkat.
+type User struct {
+	Name         string   `json:"name"`
+	PasswordHash string   `json:"passwordHash,omitempty"`
+	Role         Role     `json:"role"`
+	Groups       []string `json:"groups,omitempty"`
+}
+
+// FolderACL lists who may view and edit the dashboards in a folder.
+// Entries are either user names or group names prefixed with "@",
+// for example "@noc".
+// A folder with no Viewers may be viewed by anyone.
+// Only admins may edit dashboards in a folder with no Editors.
+type FolderACL struct {
+	Folder  string   `json:"folder"`
+	Viewers []string `json:"viewers,omitempty"`
+	Editors []string `json:"editors,omitempty"`
+}
+
+// Access is the persisted set of users and folder permissions.
+type Access struct {
+	Users   []User      `json:"users"`
+	Folders []FolderACL `json:"folders"`
+}
+
+// AccessControl checks user permissions against an Access list
+// stored in a file. It is safe for concurrent use.
+// To create an AccessControl, use LoadAccessControl.
+type AccessControl struct {
+	mu     sync.RWMutex
+	name   string
+	access Access
+}
+
+// LoadAccessControl reads the access list from the named file.
+// If the file does not exist, the access list is empty;
+// the file is created when users or folder permissions are first stored.
+func LoadAccessControl(name string) (*AccessControl, error) {
+	ac := &AccessControl{name: name}
+	b, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ac, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &ac.access); err != nil {
+		return nil, fmt.Errorf("decode access list %s: %w", name, err)
+	}
+	return ac, nil
+}
+
+// save writes a to the access list file, then makes it the access list
+// checked, so changes which could not be stored are never applied.
+// ac.mu must be held for writing.
+func (ac *AccessControl) save(a Access) error {
+	buf, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(ac.name, buf, 0600); err != nil {
+		return fmt.Errorf("write access list: %w", err)
+	}
+	ac.access = a
+	return nil
+}
+
+// HasUsers reports whether any users are stored.
+func (ac *AccessControl) HasUsers() bool {
+	if ac == nil {
+		return false
+	}
+	ac.mu.RLock()
+	defer ac.mu.RUnlock()
+	return len(ac.access.Users) > 0
+}
+
+// Authenticate returns the stored user with the given name if password matches.
+func (ac *AccessControl) Authenticate(name, password string) (User, bool) {
+	if ac == nil {
+		return User{}, false
+	}
+	ac.mu.RLock()
+	defer ac.mu.RUnlock()
+	for _, u := range ac.access.Users {
+		if u.Name != name {
+			continue
+		}
+		if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) != nil {
+			return User{}, false
+		}
+		return u, true
+	}
+	// Spend the same time hashing as if the user existed,
+	// so that valid user names can't be discovered by timing.
+	bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return User{}, false
+}
+
+// Snapshot returns a copy of the access list with password hashes removed.
+func (ac *AccessControl) Snapshot() Access {
+	if ac == nil {
+		return Access{}
+	}
+	ac.mu.RLock()
+	defer ac.mu.RUnlock()
+	var snap Access
+	for _, u := range ac.access.Users {
+		u.PasswordHash = ""
+		u.Groups = append([]string(nil), u.Groups...)
+		snap.Users = append(snap.Users, u)
+	}
+	for _, f := range ac.access.Folders {
+		f.Viewers = append([]string(nil), f.Viewers...)
+		f.Editors = append([]string(nil), f.Editors...)
+		snap.Folders = append(snap.Folders, f)
+	}
+	return snap
+}
+
+// PutUser creates or replaces the user u.
+// If password is empty, an existing user's password is kept.
+func (ac *AccessControl) PutUser(u User, password string) error {
+	if u.Name == "" {
+		return errors.New("empty user name")
+	}
+	if !u.Role.valid() {
+		return fmt.Errorf("unknown role %q", u.Role)
+	}
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	i := ac.userIndex(u.Name)
+	if password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return fmt.Errorf("hash password: %w", err)
+		}
+		u.PasswordHash = string(hash)
+	} else if i >= 0 {
+		u.PasswordHash = ac.access.Users[i].PasswordHash
+	} else {
+		return fmt.Errorf("new user %s requires a password", u.Name)
+	}
+	a := ac.access
+	a.Users = append([]User(nil), a.Users...)
+	if i >= 0 {
+		a.Users[i] = u
+	} else {
+		a.Users = append(a.Users, u)
+		sort.Slice(a.Users, func(i, j int) bool {
+			return a.Users[i].Name < a.Users[j].Name
+		})
+	}
+	return ac.save(a)
+}
+
+// DeleteUser removes the named user.
+func (ac *AccessControl) DeleteUser(name string) error {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	i := ac.userIndex(name)
+	if i < 0 {
+		return fmt.Errorf("delete user %s: %w", name, fs.ErrNotExist)
+	}
+	a := ac.access
+	a.Users = append(append([]User(nil), a.Users[:i]...), a.Users[i+1:]...)
+	return ac.save(a)
+}
+
+func (ac *AccessControl) userIndex(name string) int {
+	for i, u := range ac.access.Users {
+		if u.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// SetFolder stores the permissions for a folder.
+// Setting an ACL with no viewers and no editors removes it.
+func (ac *AccessControl) SetFolder(acl FolderACL) error {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	var folders []FolderACL
+	for _, f := range ac.access.Folders {
+		if f.Folder != acl.Folder {
+			folders = append(folders, f)
+		}
+	}
+	if len(acl.Viewers) > 0 || len(acl.Editors) > 0 {
+		folders = append(folders, acl)
+	}
+	sort.Slice(folders, func(i, j int) bool { return folders[i].Folder < folders[j].Folder })
+	a := ac.access
+	a.Folders = folders
+	return ac.save(a)
+}
+
+func (ac *AccessControl) folder(name string) (FolderACL, bool) {
+	for _, f := range ac.access.Folders {
+		if f.Folder == name {
+			return f, true
+		}
+	}
+	return FolderACL{}, false
+}
+
+// CanView reports whether u may view dashboards in folder.
+// A nil u is an anonymous user.
+func (ac *AccessControl) CanView(u *User, folder string) bool {
+	if u != nil && u.Role == RoleAdmin {
+		return true
+	}
+	if ac == nil {
+		return true
+	}
+	ac.mu.RLock()
+	defer ac.mu.RUnlock()
+	acl, ok := ac.folder(folder)
+	if !ok || len(acl.Viewers) == 0 {
+		return true
+	}
+	return u != nil && (u.listed(acl.Viewers) || u.listed(acl.Editors))
+}
+
+// CanEdit reports whether u may change dashboards in folder.
+// A nil u is an anonymous user, who may never edit.
+func (ac *AccessControl) CanEdit(u *User, folder string) bool {
+	if u == nil {
+		return false
+	}
+	if u.Role == RoleAdmin {
+		return true
+	}
+	if u.Role != RoleEditor || ac == nil {
+		return false
+	}
+	ac.mu.RLock()
+	defer ac.mu.RUnlock()
+	acl, ok := ac.folder(folder)
+	return ok && u.listed(acl.Editors)
+}
+
+// CanEditAny reports whether u may change dashboards in at least one folder.
+func (ac *AccessControl) CanEditAny(u *User) bool {
+	if u == nil {
+		return false
+	}
+	if u.Role == RoleAdmin {
+		return true
+	}
+	if u.Role != RoleEditor || ac == nil {
+		return false
+	}
+	ac.mu.RLock()
+	defer ac.mu.RUnlock()
+	for _, acl := range ac.access.Folders {
+		if u.listed(acl.Editors) {
+			return true
+		}
+	}
+	return false
+}
+
+// listed reports whether u is named in entries either by name or by one of its groups.
+func (u *User) listed(entries []string) bool {
+	for _, e := range entries {
+		if group, ok := strings.CutPrefix(e, "@"); ok {
+			for _, g := range u.Groups {
+				if g == group {
+					return true
+				}
+			}
+		} else if e == u.Name {
+			return true
+		}
+	}
+	return false
+}
+
+type userKey struct{}
+
+// NewUserContext returns a copy of ctx carrying u.
+func NewUserContext(ctx context.Context, u *User) context.Context {
+	return context.WithValue(ctx, userKey{}, u)
+}
+
+// UserFromContext returns the user stored in ctx by NewUserContext.
+// It returns nil for anonymous requests.
+func UserFromContext(ctx context.Context) *User {
+	u, _ := ctx.Value(userKey{}).(*User)
+	return u
+}
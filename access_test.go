@@ -0,0 +1,100 @@
+package meerkat
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestAccessControl(t *testing.T) {
+	ac, err := LoadAccessControl(filepath.Join(t.TempDir(), "access.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ac.SetFolder(FolderACL{Folder: "noc", Editors: []string{"@noc"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ac.SetFolder(FolderACL{Folder: "secret", Viewers: []string{"alice"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	alice := &User{Name: "alice", Role: RoleEditor, Groups: []string{"noc"}}
+	bob := &User{Name: "bob", Role: RoleViewer, Groups: []string{"noc"}}
+	admin := &User{Name: "root", Role: RoleAdmin}
+
+	tests := []struct {
+		user    *User
+		folder  string
+		canView bool
+		canEdit bool
+	}{
+		{nil, "noc", true, false},
+		{nil, "secret", false, false},
+		{alice, "noc", true, true},
+		{alice, "network", true, false},
+		{alice, "secret", true, false},
+		{bob, "noc", true, false},
+		{bob, "secret", false, false},
+		{admin, "secret", true, true},
+	}
+	for _, tt := range tests {
+		name := "anonymous"
+		if tt.user != nil {
+			name = tt.user.Name
+		}
+		if got := ac.CanView(tt.user, tt.folder); got != tt.canView {
+			t.Errorf("%s CanView %s = %v, want %v", name, tt.folder, got, tt.canView)
+		}
+		if got := ac.CanEdit(tt.user, tt.folder); got != tt.canEdit {
+			t.Errorf("%s CanEdit %s = %v, want %v", name, tt.folder, got, tt.canEdit)
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "access.json")
+	ac, err := LoadAccessControl(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ac.PutUser(User{Name: "alice", Role: RoleEditor}, "password1"); err != nil {
+		t.Fatal(err)
+	}
+	// reload from disk to check the user was persisted.
+	ac, err = LoadAccessControl(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ac.Authenticate("alice", "wrong"); ok {
+		t.Error("authenticated with wrong password")
+	}
+	u, ok := ac.Authenticate("alice", "password1")
+	if !ok {
+		t.Fatal("failed to authenticate with correct password")
+	}
+	if u.Role != RoleEditor {
+		t.Errorf("got role %s, want %s", u.Role, RoleEditor)
+	}
+	if err := ac.PutUser(User{Name: "bob", Role: "superuser"}, "x"); err == nil {
+		t.Error("stored user with unknown role")
+	}
+}
+
+func TestAccessControlSaveFailure(t *testing.T) {
+	// The access list can't be written to a missing directory.
+	ac, err := LoadAccessControl(filepath.Join(t.TempDir(), "missing", "access.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ac.PutUser(User{Name: "alice", Role: RoleAdmin}, "password1"); err == nil {
+		t.Fatal("stored user without writing access list")
+	}
+	if ac.HasUsers() {
+		t.Error("user added although access list was not written")
+	}
+	if err := ac.SetFolder(FolderACL{Folder: "secret", Viewers: []string{"alice"}}); err == nil {
+		t.Fatal("stored folder without writing access list")
+	}
+	if !ac.CanView(nil, "secret") {
+		t.Error("folder restricted although access list was not written")
+	}
+}
@@ -0,0 +1,209 @@
+package meerkat
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An ArchiveRecord is the state of the elements of a dashboard at one time.
+type ArchiveRecord struct {
+	Time time.Time `json:"time"`
+	// States holds the states of the objects shown by elements,
+	// keyed by element ID, as in Snapshot.
+	States map[string]ObjectState `json:"states"`
+}
+
+// An Archive records the states of dashboards over time,
+// so they can be looked up and replayed later.
+// Records are stored as lines of JSON in a file per dashboard per day
+// (in UTC), such as "network/2024-01-02.jsonl" under its directory.
+type Archive struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewArchive returns an Archive storing records under dir,
+// which is created when the first record is made.
+func NewArchive(dir string) *Archive {
+	return &Archive{dir: dir}
+}
+
+const (
+	archiveDay = "2006-01-02"
+	archiveExt = ".jsonl"
+)
+
+func (a *Archive) dayFile(slug string, t time.Time) string {
+	return filepath.Join(a.dir, slug, t.UTC().Format(archiveDay)+archiveExt)
+}
+
+// Record adds rec to the archive of the dashboard slug.
+func (a *Archive) Record(slug string, rec ArchiveRecord) error {
+	if !validName(slug) {
+		return fmt.Errorf("archive %q: invalid dashboard slug", slug)
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	name := a.dayFile(slug, rec.Time)
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return fmt.Errorf("archive %s: %w", slug, err)
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("archive %s: %w", slug, err)
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("archive %s: %w", slug, err)
+	}
+	return f.Close()
+}
+
+// days returns the names of the files archiving slug, oldest first.
+func (a *Archive) days(slug string) ([]string, error) {
+	if !validName(slug) {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(filepath.Join(a.dir, slug))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var days []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), archiveExt) {
+			days = append(days, e.Name())
+		}
+	}
+	sort.Strings(days)
+	return days, nil
+}
+
+// readDay returns the records in the named file archiving slug.
+// Lines which cannot be decoded, such as one cut short by a crash,
+// are skipped.
+func (a *Archive) readDay(slug, day string) ([]ArchiveRecord, error) {
+	f, err := os.Open(filepath.Join(a.dir, slug, day))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var records []ArchiveRecord
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 16<<20)
+	for sc.Scan() {
+		var rec ArchiveRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			continue
+		}
+		records = append(records, rec)
+	}
+	if err := sc.Err(); err != nil {
+		return records, fmt.Errorf("read archive of %s: %w", slug, err)
+	}
+	return records, nil
+}
+
+// At returns the last record of the dashboard slug made at or before t.
+// If there is none, the returned error wraps fs.ErrNotExist.
+func (a *Archive) At(slug string, t time.Time) (ArchiveRecord, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	days, err := a.days(slug)
+	if err != nil {
+		return ArchiveRecord{}, err
+	}
+	last := filepath.Base(a.dayFile(slug, t))
+	for i := len(days) - 1; i >= 0; i-- {
+		if days[i] > last {
+			continue
+		}
+		records, err := a.readDay(slug, days[i])
+		if err != nil {
+			return ArchiveRecord{}, err
+		}
+		for j := len(records) - 1; j >= 0; j-- {
+			if !records[j].Time.After(t) {
+				return records[j], nil
+			}
+		}
+	}
+	return ArchiveRecord{}, fmt.Errorf("no record of %s at %s: %w", slug, t.Format(time.RFC3339), fs.ErrNotExist)
+}
+
+// Between returns the records of the dashboard slug made from from
+// until to, inclusive, oldest first.
+func (a *Archive) Between(slug string, from, to time.Time) ([]ArchiveRecord, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	days, err := a.days(slug)
+	if err != nil {
+		return nil, err
+	}
+	first, last := filepath.Base(a.dayFile(slug, from)), filepath.Base(a.dayFile(slug, to))
+	var between []ArchiveRecord
+	for _, day := range days {
+		if day < first || day > last {
+			continue
+		}
+		records, err := a.readDay(slug, day)
+		if err != nil {
+			return between, err
+		}
+		for _, rec := range records {
+			if !rec.Time.Before(from) && !rec.Time.After(to) {
+				between = append(between, rec)
+			}
+		}
+	}
+	return between, nil
+}
+
+// Prune deletes the records of every dashboard made on days before
+// that of t. Records made on the same day as t are kept.
+func (a *Archive) Prune(t time.Time) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	entries, err := os.ReadDir(a.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	keep := t.UTC().Format(archiveDay) + archiveExt
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		slug := e.Name()
+		days, err := a.days(slug)
+		if err != nil {
+			return err
+		}
+		for _, day := range days {
+			if day >= keep {
+				break
+			}
+			if err := os.Remove(filepath.Join(a.dir, slug, day)); err != nil {
+				return fmt.Errorf("prune archive of %s: %w", slug, err)
+			}
+		}
+		// Only succeeds if every day has been pruned.
+		os.Remove(filepath.Join(a.dir, slug))
+	}
+	return nil
+}
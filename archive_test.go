@@ -0,0 +1,77 @@
+package meerkat
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestArchive(t *testing.T) {
+	dir := t.TempDir()
+	a := NewArchive(dir)
+	start := time.Date(2024, 1, 1, 23, 58, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		rec := ArchiveRecord{
+			Time:   start.Add(time.Duration(i) * time.Minute),
+			States: map[string]ObjectState{"router": {State: i % 3}},
+		}
+		if err := a.Record("network", rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A line cut short by a crash is skipped.
+	f, err := os.OpenFile(filepath.Join(dir, "network", "2024-01-02.jsonl"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"time": "2024-01-02T00:03:00Z", "sta`)
+	f.Close()
+
+	rec, err := a.At("network", start.Add(90*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rec.Time.Equal(start.Add(time.Minute)) || rec.States["router"].State != 1 {
+		t.Errorf("got record %+v at 23:59:30, want the one from 23:59", rec)
+	}
+	// The last record of a day is found from the next.
+	rec, err = a.At("network", time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rec.Time.Equal(start.Add(3 * time.Minute)) {
+		t.Errorf("got record from %s, want the last", rec.Time)
+	}
+	if _, err := a.At("network", start.Add(-time.Second)); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("record before archiving started: got error %v, want %v", err, fs.ErrNotExist)
+	}
+	if _, err := a.At("other", start); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("record of unarchived dashboard: got error %v, want %v", err, fs.ErrNotExist)
+	}
+
+	records, err := a.Between("network", start.Add(time.Minute), start.Add(2*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || !records[0].Time.Equal(start.Add(time.Minute)) || !records[1].Time.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("got records %+v, want those from 23:59 and 00:00", records)
+	}
+
+	if err := a.Prune(time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	records, err = a.Between("network", start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || !records[0].Time.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("after pruning got records %+v, want those from 2024-01-02", records)
+	}
+
+	if err := a.Record("../escape", ArchiveRecord{Time: start}); err == nil {
+		t.Error("recorded dashboard with invalid slug")
+	}
+}
@@ -0,0 +1,26 @@
+// Package auth authenticates Meerkat users against external identity
+// providers such as an LDAP directory or an OpenID Connect provider.
+//
+// Backends return an Identity: the user's name and the groups they
+// belong to in the provider. Mapping groups onto Meerkat roles is left
+// to the caller.
+//
+// Stand-in providers for testing are available in the authtest package.
+package auth
+
+import "errors"
+
+// Identity is an authenticated user as known to an identity provider.
+type Identity struct {
+	Name   string
+	Groups []string
+}
+
+// ErrInvalidCredentials is returned by a PasswordBackend when the
+// user does not exist or the password is wrong.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// A PasswordBackend verifies a user name and password.
+type PasswordBackend interface {
+	Authenticate(username, password string) (Identity, error)
+}
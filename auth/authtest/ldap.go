@@ -0,0 +1,251 @@
+// Package authtest provides stand-in identity providers for testing
+// the backends in package auth without a real LDAP directory or
+// OpenID Connect provider.
+package authtest
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// LDAPUser is an entry in an LDAPServer's directory.
+type LDAPUser struct {
+	DN       string
+	UID      string
+	Password string
+	// MemberOf lists the DNs of the groups the user belongs to.
+	MemberOf []string
+}
+
+// LDAPServer is a minimal LDAP server listening on the loopback interface.
+// It supports simple binds and searches over its users' uid, memberOf
+// and objectClass attributes, which is all that auth.LDAP needs.
+type LDAPServer struct {
+	// URL of the server, for example ldap://127.0.0.1:40389.
+	URL string
+	// BindDN and BindPassword are the credentials of a service
+	// account allowed to bind in addition to the users.
+	BindDN       string
+	BindPassword string
+
+	users []LDAPUser
+	ln    net.Listener
+	wg    sync.WaitGroup
+}
+
+// NewLDAPServer starts and returns a new LDAPServer serving users.
+// The caller should call Close when finished.
+func NewLDAPServer(bindDN, bindPassword string, users ...LDAPUser) *LDAPServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("authtest: listen: " + err.Error())
+	}
+	s := &LDAPServer{
+		URL:          "ldap://" + ln.Addr().String(),
+		BindDN:       bindDN,
+		BindPassword: bindPassword,
+		users:        users,
+		ln:           ln,
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Close shuts down the server and waits for open connections to finish.
+func (s *LDAPServer) Close() {
+	s.ln.Close()
+	s.wg.Wait()
+}
+
+func (s *LDAPServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.handle(conn)
+		}()
+	}
+}
+
+// LDAP protocol operations, from RFC 4511 section 4.2.
+const (
+	opBindRequest       = 0
+	opBindResponse      = 1
+	opUnbindRequest     = 2
+	opSearchRequest     = 3
+	opSearchResultEntry = 4
+	opSearchResultDone  = 5
+	opExtendedResponse  = 24
+)
+
+const (
+	resultSuccess            = 0
+	resultProtocolError      = 2
+	resultInvalidCredentials = 49
+)
+
+func (s *LDAPServer) handle(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	for {
+		p, err := ber.ReadPacket(r)
+		if err != nil || len(p.Children) < 2 {
+			return
+		}
+		id, _ := p.Children[0].Value.(int64)
+		op := p.Children[1]
+		var replies []*ber.Packet
+		switch op.Tag {
+		case opBindRequest:
+			replies = append(replies, result(id, opBindResponse, s.bind(op)))
+		case opUnbindRequest:
+			return
+		case opSearchRequest:
+			replies = append(s.search(id, op), result(id, opSearchResultDone, resultSuccess))
+		default:
+			// Extended operations such as StartTLS are unsupported.
+			replies = append(replies, result(id, opExtendedResponse, resultProtocolError))
+		}
+		for _, reply := range replies {
+			if _, err := conn.Write(reply.Bytes()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *LDAPServer) bind(op *ber.Packet) int {
+	if len(op.Children) < 3 {
+		return resultProtocolError
+	}
+	dn, _ := op.Children[1].Value.(string)
+	password := op.Children[2].Data.String()
+	if dn == "" && password == "" {
+		return resultSuccess
+	}
+	if s.BindDN != "" && strings.EqualFold(dn, s.BindDN) && password == s.BindPassword {
+		return resultSuccess
+	}
+	for _, u := range s.users {
+		if strings.EqualFold(dn, u.DN) && password == u.Password {
+			return resultSuccess
+		}
+	}
+	return resultInvalidCredentials
+}
+
+func (s *LDAPServer) search(id int64, op *ber.Packet) []*ber.Packet {
+	if len(op.Children) < 8 {
+		return nil
+	}
+	base, _ := op.Children[0].Value.(string)
+	filter := op.Children[6]
+	var wanted []string
+	for _, a := range op.Children[7].Children {
+		if name, ok := a.Value.(string); ok {
+			wanted = append(wanted, name)
+		}
+	}
+
+	var entries []*ber.Packet
+	for _, u := range s.users {
+		if !strings.HasSuffix(strings.ToLower(u.DN), strings.ToLower(base)) {
+			continue
+		}
+		attrs := map[string][]string{
+			"uid":         {u.UID},
+			"memberof":    u.MemberOf,
+			"objectclass": {"person"},
+		}
+		if !match(filter, attrs) {
+			continue
+		}
+		entries = append(entries, entry(id, u.DN, attrs, wanted))
+	}
+	return entries
+}
+
+// match reports whether the search filter f matches an entry with attrs.
+// Only the and, or, not, equality and present filters are supported.
+func match(f *ber.Packet, attrs map[string][]string) bool {
+	switch f.Tag {
+	case 0: // and
+		for _, c := range f.Children {
+			if !match(c, attrs) {
+				return false
+			}
+		}
+		return true
+	case 1: // or
+		for _, c := range f.Children {
+			if match(c, attrs) {
+				return true
+			}
+		}
+		return false
+	case 2: // not
+		return len(f.Children) == 1 && !match(f.Children[0], attrs)
+	case 3: // equalityMatch
+		if len(f.Children) != 2 {
+			return false
+		}
+		name, _ := f.Children[0].Value.(string)
+		value, _ := f.Children[1].Value.(string)
+		for _, v := range attrs[strings.ToLower(name)] {
+			if strings.EqualFold(v, value) {
+				return true
+			}
+		}
+		return false
+	case 7: // present
+		return len(attrs[strings.ToLower(f.Data.String())]) > 0
+	}
+	return false
+}
+
+func envelope(id int64, op *ber.Packet) *ber.Packet {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "Message ID"))
+	p.AppendChild(op)
+	return p
+}
+
+func result(id int64, op ber.Tag, code int) *ber.Packet {
+	r := ber.Encode(ber.ClassApplication, ber.TypeConstructed, op, nil, "Result")
+	r.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "Result Code"))
+	r.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	r.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return envelope(id, r)
+}
+
+func entry(id int64, dn string, attrs map[string][]string, wanted []string) *ber.Packet {
+	e := ber.Encode(ber.ClassApplication, ber.TypeConstructed, opSearchResultEntry, nil, "Search Result Entry")
+	e.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "Object Name"))
+	list := ber.NewSequence("Attributes")
+	for _, name := range wanted {
+		values := attrs[strings.ToLower(name)]
+		if len(values) == 0 {
+			continue
+		}
+		attr := ber.NewSequence("Attribute")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, v := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "Value"))
+		}
+		attr.AppendChild(set)
+		list.AppendChild(attr)
+	}
+	e.AppendChild(list)
+	return envelope(id, e)
+}
@@ -0,0 +1,208 @@
+package authtest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// OIDCUser is a user known to an OIDCProvider.
+type OIDCUser struct {
+	Name   string
+	Groups []string
+}
+
+// OIDCProvider is a minimal OpenID Connect provider supporting the
+// authorization code flow with RS256-signed ID tokens.
+//
+// There is no login form: the authorization endpoint immediately
+// redirects back to the client, as if the user named by User
+// already had a session with the provider.
+type OIDCProvider struct {
+	*httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	mu sync.Mutex
+	// User is the name of the user logged in at the provider.
+	// If empty, authorization requests are denied.
+	User  string
+	users map[string]OIDCUser
+	codes map[string]grant
+	key   *rsa.PrivateKey
+}
+
+type grant struct {
+	user        OIDCUser
+	nonce       string
+	redirectURI string
+}
+
+const keyID = "authtest"
+
+// NewOIDCProvider starts and returns a new OIDCProvider accepting the
+// given client credentials. The first of users, if any, is logged in.
+// The caller should call Close when finished.
+func NewOIDCProvider(clientID, clientSecret string, users ...OIDCUser) *OIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic("authtest: generate key: " + err.Error())
+	}
+	p := &OIDCProvider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		users:        make(map[string]OIDCUser),
+		codes:        make(map[string]grant),
+		key:          key,
+	}
+	for _, u := range users {
+		p.users[u.Name] = u
+	}
+	if len(users) > 0 {
+		p.User = users[0].Name
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	mux.HandleFunc("/jwks", p.jwks)
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+// Login sets the user logged in at the provider.
+func (p *OIDCProvider) Login(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.User = name
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (p *OIDCProvider) discovery(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 p.URL,
+		"authorization_endpoint": p.URL + "/authorize",
+		"token_endpoint":         p.URL + "/token",
+		"jwks_uri":               p.URL + "/jwks",
+	})
+}
+
+func (p *OIDCProvider) authorize(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	if q.Get("client_id") != p.ClientID || q.Get("response_type") != "code" {
+		http.Error(w, "bad authorization request", http.StatusBadRequest)
+		return
+	}
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || !redirect.IsAbs() {
+		http.Error(w, "bad redirect_uri", http.StatusBadRequest)
+		return
+	}
+	back := redirect.Query()
+	back.Set("state", q.Get("state"))
+
+	p.mu.Lock()
+	user, ok := p.users[p.User]
+	if ok {
+		code := randomString()
+		p.codes[code] = grant{user: user, nonce: q.Get("nonce"), redirectURI: redirect.String()}
+		back.Set("code", code)
+	} else {
+		back.Set("error", "access_denied")
+	}
+	p.mu.Unlock()
+
+	redirect.RawQuery = back.Encode()
+	http.Redirect(w, req, redirect.String(), http.StatusFound)
+}
+
+func (p *OIDCProvider) token(w http.ResponseWriter, req *http.Request) {
+	id, secret, ok := req.BasicAuth()
+	if ok {
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	}
+	if !ok || id != p.ClientID || secret != p.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if req.PostFormValue("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+	p.mu.Lock()
+	g, ok := p.codes[req.PostFormValue("code")]
+	delete(p.codes, req.PostFormValue("code"))
+	p.mu.Unlock()
+	if !ok || g.redirectURI != req.PostFormValue("redirect_uri") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := map[string]any{
+		"iss":                p.URL,
+		"sub":                g.user.Name,
+		"aud":                p.ClientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"nonce":              g.nonce,
+		"preferred_username": g.user.Name,
+		"groups":             g.user.Groups,
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     p.sign(claims),
+	})
+}
+
+// sign returns claims as a JWT signed with RS256.
+func (p *OIDCProvider) sign(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": keyID})
+	body, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic("authtest: sign token: " + err.Error())
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func (p *OIDCProvider) jwks(w http.ResponseWriter, req *http.Request) {
+	pub := p.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyID,
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("authtest: read random: " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"crypto/tls"
+	"fmt"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// LDAP authenticates users by binding to an LDAP directory.
+//
+// Users are first looked up by searching BaseDN with UserFilter,
+// using the BindDN account if one is set. The password is then
+// verified by binding as the user's entry.
+type LDAP struct {
+	// URL of the directory, for example ldaps://ldap.example.com.
+	URL string
+	// BindDN and BindPassword are the credentials used to search
+	// for users. If BindDN is empty, the search is anonymous.
+	BindDN       string
+	BindPassword string
+	// BaseDN is where to search for users,
+	// for example ou=people,dc=example,dc=com.
+	BaseDN string
+	// UserFilter selects a user's entry. The first %s is replaced
+	// by the escaped user name. The default is (uid=%s).
+	UserFilter string
+	// GroupAttribute is the attribute of a user's entry listing the
+	// groups they belong to. The default is memberOf.
+	// Values which are distinguished names are reduced to the value
+	// of their first component; "cn=noc,ou=groups,dc=example,dc=com"
+	// becomes "noc".
+	GroupAttribute string
+	// StartTLS upgrades plain ldap:// connections to TLS.
+	StartTLS bool
+	// InsecureTLS skips verification of the directory's certificate.
+	InsecureTLS bool
+}
+
+func (l *LDAP) userFilter() string {
+	if l.UserFilter == "" {
+		return "(uid=%s)"
+	}
+	return l.UserFilter
+}
+
+func (l *LDAP) groupAttribute() string {
+	if l.GroupAttribute == "" {
+		return "memberOf"
+	}
+	return l.GroupAttribute
+}
+
+func (l *LDAP) dial() (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: l.InsecureTLS}
+	conn, err := ldap.DialURL(l.URL, ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	if l.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("start tls: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// Authenticate implements PasswordBackend.
+func (l *LDAP) Authenticate(username, password string) (Identity, error) {
+	// An empty password is an unauthenticated bind in LDAP,
+	// which most servers accept for any DN.
+	if username == "" || password == "" {
+		return Identity{}, ErrInvalidCredentials
+	}
+	conn, err := l.dial()
+	if err != nil {
+		return Identity{}, fmt.Errorf("connect to %s: %w", l.URL, err)
+	}
+	defer conn.Close()
+
+	if l.BindDN != "" {
+		if err := conn.Bind(l.BindDN, l.BindPassword); err != nil {
+			return Identity{}, fmt.Errorf("bind as %s: %w", l.BindDN, err)
+		}
+	}
+	filter := fmt.Sprintf(l.userFilter(), ldap.EscapeFilter(username))
+	search := ldap.NewSearchRequest(l.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		filter, []string{l.groupAttribute()}, nil)
+	result, err := conn.Search(search)
+	if err != nil {
+		return Identity{}, fmt.Errorf("search for user %s: %w", username, err)
+	}
+	if len(result.Entries) != 1 {
+		return Identity{}, ErrInvalidCredentials
+	}
+	entry := result.Entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return Identity{}, ErrInvalidCredentials
+		}
+		return Identity{}, fmt.Errorf("bind as %s: %w", entry.DN, err)
+	}
+
+	id := Identity{Name: username}
+	for _, v := range entry.GetAttributeValues(l.groupAttribute()) {
+		id.Groups = append(id.Groups, groupName(v))
+	}
+	return id, nil
+}
+
+// groupName returns the value of the first component of the
+// distinguished name dn, or dn unchanged if it is not a DN.
+func groupName(dn string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) == 0 || len(parsed.RDNs[0].Attributes) == 0 {
+		return dn
+	}
+	return parsed.RDNs[0].Attributes[0].Value
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/meerkat-dashboard/meerkat/auth/authtest"
+)
+
+func TestLDAP(t *testing.T) {
+	srv := authtest.NewLDAPServer("cn=meerkat,dc=example,dc=com", "service",
+		authtest.LDAPUser{
+			DN:       "uid=alice,ou=people,dc=example,dc=com",
+			UID:      "alice",
+			Password: "wonderland",
+			MemberOf: []string{"cn=noc,ou=groups,dc=example,dc=com", "cn=ops,ou=groups,dc=example,dc=com"},
+		},
+		authtest.LDAPUser{
+			DN:       "uid=bob,ou=people,dc=example,dc=com",
+			UID:      "bob",
+			Password: "builder",
+		},
+	)
+	defer srv.Close()
+
+	l := &LDAP{
+		URL:          srv.URL,
+		BindDN:       "cn=meerkat,dc=example,dc=com",
+		BindPassword: "service",
+		BaseDN:       "ou=people,dc=example,dc=com",
+	}
+	id, err := l.Authenticate("alice", "wonderland")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Identity{Name: "alice", Groups: []string{"noc", "ops"}}
+	if !reflect.DeepEqual(id, want) {
+		t.Errorf("got identity %+v, want %+v", id, want)
+	}
+
+	if _, err := l.Authenticate("bob", "builder"); err != nil {
+		t.Errorf("authenticate bob: %v", err)
+	}
+
+	for _, tt := range []struct{ user, password string }{
+		{"alice", "wrong"},
+		{"alice", ""},
+		{"mallory", "wonderland"},
+		{"*", "wonderland"},
+	} {
+		_, err := l.Authenticate(tt.user, tt.password)
+		if !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("authenticate %q with password %q: got error %v, want %v", tt.user, tt.password, err, ErrInvalidCredentials)
+		}
+	}
+
+	l.BindPassword = "wrong"
+	if _, err := l.Authenticate("alice", "wonderland"); err == nil || errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("bad service account password: got error %v, want bind error", err)
+	}
+}
@@ -0,0 +1,324 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OIDC authenticates users with the OpenID Connect authorization code flow.
+//
+// Users are sent to the provider with the URL from AuthCodeURL.
+// The provider redirects back to RedirectURL with a code,
+// which is passed to Exchange to retrieve and verify the user's ID token.
+// Only ID tokens signed with RS256 are accepted.
+type OIDC struct {
+	// Issuer is the provider's issuer URL. The provider's
+	// configuration is discovered from
+	// Issuer + "/.well-known/openid-configuration".
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the provider sends users after they
+	// have authenticated, for example
+	// https://meerkat.example.com/login/oidc/callback.
+	RedirectURL string
+	// Scopes requested in addition to "openid".
+	// The default is profile and email.
+	Scopes []string
+	// UsernameClaim is the ID token claim holding the user's name.
+	// The default is preferred_username.
+	UsernameClaim string
+	// GroupsClaim is the ID token claim listing the user's groups.
+	// The default is groups.
+	GroupsClaim string
+	// InsecureTLS skips verification of the provider's certificate.
+	InsecureTLS bool
+
+	mu       sync.Mutex
+	metadata *providerMetadata
+	keys     map[string]*rsa.PublicKey
+}
+
+type providerMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+func (o *OIDC) client() *http.Client {
+	c := &http.Client{Timeout: 10 * time.Second}
+	if o.InsecureTLS {
+		c.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+	return c
+}
+
+func (o *OIDC) getJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := o.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("get %s: %s", u, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// discover returns the provider's configuration, fetching it on first use.
+func (o *OIDC) discover(ctx context.Context) (*providerMetadata, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.metadata != nil {
+		return o.metadata, nil
+	}
+	var md providerMetadata
+	u := strings.TrimSuffix(o.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := o.getJSON(ctx, u, &md); err != nil {
+		return nil, fmt.Errorf("discover provider: %w", err)
+	}
+	if md.Issuer != o.Issuer {
+		return nil, fmt.Errorf("discover provider: issuer %q does not match configured issuer %q", md.Issuer, o.Issuer)
+	}
+	o.metadata = &md
+	return o.metadata, nil
+}
+
+// AuthCodeURL returns the provider URL to send users to for logging in.
+// The state and nonce must be random values remembered for the
+// callback; state is returned as a query parameter to RedirectURL
+// and nonce must be passed to Exchange.
+func (o *OIDC) AuthCodeURL(ctx context.Context, state, nonce string) (string, error) {
+	md, err := o.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	scopes := o.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"profile", "email"}
+	}
+	q := url.Values{
+		"response_type": {"code"},
+		"client_id":     {o.ClientID},
+		"redirect_uri":  {o.RedirectURL},
+		"scope":         {strings.Join(append([]string{"openid"}, scopes...), " ")},
+		"state":         {state},
+		"nonce":         {nonce},
+	}
+	u, err := url.Parse(md.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("parse authorization endpoint: %w", err)
+	}
+	if u.RawQuery != "" {
+		u.RawQuery += "&"
+	}
+	u.RawQuery += q.Encode()
+	return u.String(), nil
+}
+
+// Exchange redeems an authorization code for an ID token,
+// verifies the token and returns the identity it describes.
+func (o *OIDC) Exchange(ctx context.Context, code, nonce string) (Identity, error) {
+	md, err := o.discover(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {o.RedirectURL},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, md.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(o.ClientID), url.QueryEscape(o.ClientSecret))
+	resp, err := o.client().Do(req)
+	if err != nil {
+		return Identity{}, fmt.Errorf("exchange code: %w", err)
+	}
+	defer resp.Body.Close()
+	var token struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return Identity{}, fmt.Errorf("exchange code: decode response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Identity{}, fmt.Errorf("exchange code: %s: %s", resp.Status, token.Error)
+	}
+	if token.IDToken == "" {
+		return Identity{}, errors.New("exchange code: no id_token in response")
+	}
+	claims, err := o.verify(ctx, md, token.IDToken, nonce)
+	if err != nil {
+		return Identity{}, fmt.Errorf("verify id token: %w", err)
+	}
+	return o.identity(claims)
+}
+
+func (o *OIDC) identity(claims map[string]any) (Identity, error) {
+	nameClaim := o.UsernameClaim
+	if nameClaim == "" {
+		nameClaim = "preferred_username"
+	}
+	groupsClaim := o.GroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+	name, _ := claims[nameClaim].(string)
+	if name == "" {
+		return Identity{}, fmt.Errorf("no %s claim in id token", nameClaim)
+	}
+	id := Identity{Name: name}
+	groups, _ := claims[groupsClaim].([]any)
+	for _, g := range groups {
+		if s, ok := g.(string); ok {
+			id.Groups = append(id.Groups, strings.TrimPrefix(s, "/"))
+		}
+	}
+	return id, nil
+}
+
+// verify checks the signature and standard claims of the ID token raw,
+// returning its claims.
+func (o *OIDC) verify(ctx context.Context, md *providerMetadata, raw, nonce string) (map[string]any, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("decode header: %w", err)
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", header.Alg)
+	}
+	key, err := o.key(ctx, md, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("decode signature: %w", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return nil, fmt.Errorf("bad signature: %w", err)
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("decode claims: %w", err)
+	}
+	if iss, _ := claims["iss"].(string); iss != md.Issuer {
+		return nil, fmt.Errorf("issuer %q does not match %q", iss, md.Issuer)
+	}
+	if !audienceContains(claims["aud"], o.ClientID) {
+		return nil, fmt.Errorf("token not issued for client %s", o.ClientID)
+	}
+	exp, _ := claims["exp"].(float64)
+	if time.Now().After(time.Unix(int64(exp), 0).Add(time.Minute)) {
+		return nil, errors.New("token expired")
+	}
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return nil, errors.New("nonce mismatch")
+	}
+	return claims, nil
+}
+
+func audienceContains(aud any, clientID string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientID
+	case []any:
+		for _, a := range v {
+			if a == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// key returns the provider's signing key with ID kid.
+// The provider's key set is refetched when kid is unknown,
+// so that key rotation is picked up.
+func (o *OIDC) key(ctx context.Context, md *providerMetadata, kid string) (*rsa.PublicKey, error) {
+	o.mu.Lock()
+	k, ok := o.keys[kid]
+	o.mu.Unlock()
+	if ok {
+		return k, nil
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := o.getJSON(ctx, md.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("fetch signing keys: %w", err)
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	o.mu.Lock()
+	o.keys = keys
+	o.mu.Unlock()
+	if k, ok := keys[kid]; ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/meerkat-dashboard/meerkat/auth/authtest"
+)
+
+// login follows the provider's authorization redirect,
+// returning the query parameters passed back to the client.
+func login(t *testing.T, authURL string) url.Values {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	loc, err := resp.Location()
+	if err != nil {
+		t.Fatalf("no redirect from provider: %v", err)
+	}
+	return loc.Query()
+}
+
+func TestOIDC(t *testing.T) {
+	provider := authtest.NewOIDCProvider("meerkat", "secret",
+		authtest.OIDCUser{Name: "alice", Groups: []string{"/noc", "ops"}},
+	)
+	defer provider.Close()
+
+	o := &OIDC{
+		Issuer:       provider.URL,
+		ClientID:     "meerkat",
+		ClientSecret: "secret",
+		RedirectURL:  "http://meerkat.example.com/login/oidc/callback",
+	}
+	ctx := context.Background()
+	authURL, err := o.AuthCodeURL(ctx, "state1", "nonce1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := login(t, authURL)
+	if q.Get("state") != "state1" {
+		t.Errorf("got state %q, want %q", q.Get("state"), "state1")
+	}
+	id, err := o.Exchange(ctx, q.Get("code"), "nonce1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Identity{Name: "alice", Groups: []string{"noc", "ops"}}
+	if !reflect.DeepEqual(id, want) {
+		t.Errorf("got identity %+v, want %+v", id, want)
+	}
+
+	// codes may only be used once
+	if _, err := o.Exchange(ctx, q.Get("code"), "nonce1"); err == nil {
+		t.Error("code exchanged twice")
+	}
+
+	authURL, err = o.AuthCodeURL(ctx, "state2", "nonce2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q = login(t, authURL)
+	if _, err := o.Exchange(ctx, q.Get("code"), "other"); err == nil {
+		t.Error("token with mismatched nonce accepted")
+	}
+
+	bad := &OIDC{
+		Issuer:       provider.URL,
+		ClientID:     "meerkat",
+		ClientSecret: "wrong",
+		RedirectURL:  o.RedirectURL,
+	}
+	authURL, err = bad.AuthCodeURL(ctx, "state3", "nonce3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q = login(t, authURL)
+	if _, err := bad.Exchange(ctx, q.Get("code"), "nonce3"); err == nil {
+		t.Error("code exchanged with wrong client secret")
+	}
+}
@@ -0,0 +1,114 @@
+package meerkat
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// A Backend is a monitoring system whose objects are shown by the
+// elements of dashboards, such as Icinga or Prometheus.
+//
+// Backends describe objects and their changes in the terms of Object
+// and Event, so dashboards show them the same way whichever system they
+// are from. The Icinga backend is in the icinga package; others are
+// available in the subdirectories of the backend directory.
+// Implementations must be safe for concurrent use.
+type Backend interface {
+	// Objects returns the objects selected by q.
+	// Errors which should be passed on to clients,
+	// such as a missing object, are returned as a *BackendError.
+	Objects(ctx context.Context, q ObjectQuery) ([]Object, error)
+	// Status returns the status of the backend.
+	Status(ctx context.Context) (BackendInfo, error)
+	// Events returns a stream of changes to objects, ending when ctx
+	// is done or the stream is closed. Backends sending events for
+	// every object may ignore queries; others watch only the objects
+	// selected by queries.
+	Events(ctx context.Context, queries []ObjectQuery) (EventStream, error)
+}
+
+// An ObjectQuery selects objects from a backend.
+type ObjectQuery struct {
+	// Type is the type of the objects, such as "hosts" or "alerts":
+	// the plural of the object type of the elements showing them.
+	Type string `json:"type"`
+	// Name selects the object with the name, or objects of groups
+	// with the name. If Name and Filter are empty, all objects of
+	// Type are selected.
+	Name string `json:"name,omitempty"`
+	// Filter is an expression in the language of the backend
+	// selecting objects, such as an Icinga filter or Prometheus
+	// label matchers.
+	Filter string `json:"filter,omitempty"`
+	// NamesOnly reports that only the names of the objects are needed,
+	// as when listing them for a user to choose from.
+	NamesOnly bool `json:"-"`
+}
+
+// An Object is a thing monitored by a backend, such as an Icinga host
+// or service, or the alerts of a Prometheus alerting rule.
+type Object struct {
+	// Name identifies the object within its backend,
+	// for example "web!http" for an Icinga service.
+	Name string `json:"name"`
+	// Type is the type of the object, such as "Host" or "Alert".
+	Type string `json:"type"`
+	ObjectState
+	// StateType is 1 when the state is hard, confirmed by repeated
+	// checks, and 0 when it is soft.
+	StateType int `json:"state_type"`
+}
+
+// Types of events.
+const (
+	// EventCheckResult reports the object's latest state,
+	// which may be unchanged.
+	EventCheckResult = "CheckResult"
+	// EventStateChange reports that the object's state has changed.
+	EventStateChange            = "StateChange"
+	EventAcknowledgementSet     = "AcknowledgementSet"
+	EventAcknowledgementCleared = "AcknowledgementCleared"
+)
+
+// An Event is a change to an object.
+// Acknowledgement events only set the name and type of Object.
+type Event struct {
+	Type   string
+	Object Object
+}
+
+// An EventStream reads events from a backend.
+type EventStream interface {
+	// Next returns the next event, blocking until there is one.
+	// It returns io.EOF once the stream has ended.
+	Next() (Event, error)
+	Close() error
+}
+
+// BackendInfo is the status of a backend.
+type BackendInfo struct {
+	Version string
+	// Start is when the backend was last started or reloaded.
+	// Objects may have changed since they were last requested
+	// if it is later than when they were.
+	Start time.Time
+}
+
+// A BackendError is an error response from a backend,
+// such as for a missing object or an invalid query.
+type BackendError struct {
+	// StatusCode is the HTTP status code describing the error,
+	// such as 404 for a missing object.
+	StatusCode int `json:"error"`
+	// Status describes the error, for example "No objects found.".
+	Status string `json:"status"`
+}
+
+func (e *BackendError) Error() string {
+	if e.Status == "" {
+		return fmt.Sprintf("backend: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("backend: %d %s", e.StatusCode, e.Status)
+}
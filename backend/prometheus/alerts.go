@@ -0,0 +1,224 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/meerkat-dashboard/meerkat"
+)
+
+// An alert is an active alert from Prometheus or Alertmanager.
+type alert struct {
+	Labels      map[string]string
+	Annotations map[string]string
+	// Pending alerts are waiting for their rule's condition
+	// to have held for long enough to fire.
+	Pending bool
+	// Suppressed alerts are silenced or inhibited in Alertmanager.
+	Suppressed bool
+}
+
+// alerts returns the active alerts.
+func (b *Backend) alerts(ctx context.Context) ([]alert, error) {
+	if b.AlertmanagerURL != "" {
+		var resp []struct {
+			Labels      map[string]string `json:"labels"`
+			Annotations map[string]string `json:"annotations"`
+			Status      struct {
+				State string `json:"state"`
+			} `json:"status"`
+		}
+		if err := b.get(ctx, b.AlertmanagerURL, "/api/v2/alerts", nil, &resp); err != nil {
+			return nil, fmt.Errorf("alertmanager: %w", err)
+		}
+		alerts := make([]alert, len(resp))
+		for i, a := range resp {
+			alerts[i] = alert{Labels: a.Labels, Annotations: a.Annotations, Suppressed: a.Status.State == "suppressed"}
+		}
+		return alerts, nil
+	}
+	var data struct {
+		Alerts []struct {
+			Labels      map[string]string `json:"labels"`
+			Annotations map[string]string `json:"annotations"`
+			State       string            `json:"state"`
+		} `json:"alerts"`
+	}
+	if err := b.query(ctx, "/api/v1/alerts", nil, &data); err != nil {
+		return nil, err
+	}
+	alerts := make([]alert, len(data.Alerts))
+	for i, a := range data.Alerts {
+		alerts[i] = alert{Labels: a.Labels, Annotations: a.Annotations, Pending: a.State == "pending"}
+	}
+	return alerts, nil
+}
+
+// severityState returns the state of an alert with the given severity
+// label: warning for "warning", OK for "info" and "none",
+// and critical for any other.
+func severityState(severity string) int {
+	switch strings.ToLower(severity) {
+	case "warning", "warn":
+		return 1
+	case "info", "none":
+		return 0
+	}
+	return 2
+}
+
+// alertObject returns the object selected by q: the alerts of the
+// rule named q.Name, or those matching the label matchers q.Filter.
+// The object is in the worst state of the firing alerts, or of the
+// pending alerts as a soft state if none are firing.
+// It is acknowledged if all alerts in that state are suppressed.
+func (b *Backend) alertObject(ctx context.Context, q meerkat.ObjectQuery) (meerkat.Object, error) {
+	var matchers []matcher
+	name := q.Name
+	if name != "" {
+		matchers = []matcher{{name: "alertname", op: "=", value: name}}
+	} else {
+		name = q.Filter
+		var err error
+		matchers, err = parseMatchers(q.Filter)
+		if err != nil {
+			return meerkat.Object{}, &meerkat.BackendError{StatusCode: http.StatusBadRequest, Status: err.Error()}
+		}
+	}
+	alerts, err := b.alerts(ctx)
+	if err != nil {
+		return meerkat.Object{}, err
+	}
+
+	obj := meerkat.Object{Name: name, Type: "Alert", StateType: 1}
+	obj.Output = "No alerts"
+	var selected []alert
+	var firing, pending int
+	for _, a := range alerts {
+		if !matchAll(matchers, a.Labels) {
+			continue
+		}
+		selected = append(selected, a)
+		if a.Pending {
+			pending++
+		} else {
+			firing++
+		}
+	}
+	if len(selected) > 0 {
+		worst := selected[0]
+		for _, a := range selected[1:] {
+			if worse(a, worst) {
+				worst = a
+			}
+		}
+		obj.State = severityState(worst.Labels["severity"])
+		if worst.Pending {
+			obj.StateType = 0
+		}
+		obj.Output = alertOutput(worst)
+		obj.Acknowledged = true
+		for _, a := range selected {
+			if !worse(worst, a) && !a.Suppressed {
+				obj.Acknowledged = false
+			}
+		}
+	}
+	obj.PerfData = map[string]string{
+		"firing":  strconv.Itoa(firing),
+		"pending": strconv.Itoa(pending),
+	}
+	return obj, nil
+}
+
+// worse reports whether a is worse than b:
+// firing rather than pending, or of a worse severity.
+func worse(a, b alert) bool {
+	if a.Pending != b.Pending {
+		return !a.Pending
+	}
+	return severityState(a.Labels["severity"]) > severityState(b.Labels["severity"])
+}
+
+// alertOutput describes a, by its summary or description
+// annotation if it has one.
+func alertOutput(a alert) string {
+	for _, k := range []string{"summary", "description", "message"} {
+		if s := a.Annotations[k]; s != "" {
+			return s
+		}
+	}
+	return a.Labels["alertname"]
+}
+
+// A matcher selects alerts by the value of a label,
+// as in Prometheus label matchers such as job=~"node.*".
+type matcher struct {
+	name  string
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+func (m matcher) match(labels map[string]string) bool {
+	v := labels[m.name]
+	switch m.op {
+	case "=":
+		return v == m.value
+	case "!=":
+		return v != m.value
+	case "=~":
+		return m.re.MatchString(v)
+	case "!~":
+		return !m.re.MatchString(v)
+	}
+	return false
+}
+
+func matchAll(matchers []matcher, labels map[string]string) bool {
+	for _, m := range matchers {
+		if !m.match(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+var matcherPattern = regexp.MustCompile(`^\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*(=~|!~|!=|=)\s*("(?:[^"\\]|\\.)*")\s*(?:,|$)`)
+
+// parseMatchers parses label matchers separated by commas,
+// optionally enclosed in braces, such as {job="node", env!="test"}.
+func parseMatchers(s string) ([]matcher, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+		s = s[1 : len(s)-1]
+	}
+	var matchers []matcher
+	for strings.TrimSpace(s) != "" {
+		m := matcherPattern.FindStringSubmatch(s)
+		if m == nil {
+			return nil, fmt.Errorf("invalid label matchers at %q", s)
+		}
+		value, err := strconv.Unquote(m[3])
+		if err != nil {
+			return nil, fmt.Errorf("invalid label value %s: %w", m[3], err)
+		}
+		mt := matcher{name: m[1], op: m[2], value: value}
+		if mt.op == "=~" || mt.op == "!~" {
+			// Prometheus anchors regular expressions.
+			if mt.re, err = regexp.Compile("^(?:" + value + ")$"); err != nil {
+				return nil, fmt.Errorf("invalid regular expression %q: %w", value, err)
+			}
+		}
+		matchers = append(matchers, mt)
+		s = s[len(m[0]):]
+	}
+	if len(matchers) == 0 {
+		return nil, fmt.Errorf("no label matchers")
+	}
+	return matchers, nil
+}
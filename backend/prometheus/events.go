@@ -0,0 +1,115 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/meerkat-dashboard/meerkat"
+)
+
+// Events implements meerkat.Backend.
+// The objects selected by queries are evaluated at the backend's
+// Interval, sending an event for each object which has changed.
+// Queries the backend rejects, such as invalid expressions, are skipped.
+func (b *Backend) Events(ctx context.Context, queries []meerkat.ObjectQuery) (meerkat.EventStream, error) {
+	interval := b.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s := &eventStream{
+		b:       b,
+		ctx:     ctx,
+		cancel:  cancel,
+		queries: queries,
+		ticker:  time.NewTicker(interval),
+		last:    make(map[string]meerkat.Object),
+	}
+	// The first evaluation is what later ones are compared with.
+	if err := s.poll(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	s.pending = nil
+	return s, nil
+}
+
+type eventStream struct {
+	b       *Backend
+	ctx     context.Context
+	cancel  context.CancelFunc
+	queries []meerkat.ObjectQuery
+	ticker  *time.Ticker
+	// last holds the objects as last evaluated, by name.
+	last    map[string]meerkat.Object
+	pending []meerkat.Event
+	closed  atomic.Bool
+}
+
+func (s *eventStream) Next() (meerkat.Event, error) {
+	for len(s.pending) == 0 {
+		select {
+		case <-s.ctx.Done():
+			if s.closed.Load() {
+				return meerkat.Event{}, io.EOF
+			}
+			return meerkat.Event{}, s.ctx.Err()
+		case <-s.ticker.C:
+		}
+		if err := s.poll(); err != nil {
+			return meerkat.Event{}, err
+		}
+	}
+	e := s.pending[0]
+	s.pending = s.pending[1:]
+	return e, nil
+}
+
+func (s *eventStream) Close() error {
+	s.closed.Store(true)
+	s.cancel()
+	s.ticker.Stop()
+	return nil
+}
+
+// poll evaluates the objects selected by the stream's queries,
+// queueing an event for each which has changed.
+func (s *eventStream) poll() error {
+	for _, q := range s.queries {
+		objects, err := s.b.Objects(s.ctx, q)
+		var e *meerkat.BackendError
+		if errors.As(err, &e) && e.StatusCode < http.StatusInternalServerError {
+			continue
+		} else if err != nil {
+			return err
+		}
+		for _, obj := range objects {
+			if t := change(s.last[obj.Name], obj); t != "" {
+				s.pending = append(s.pending, meerkat.Event{Type: t, Object: obj})
+			}
+			s.last[obj.Name] = obj
+		}
+	}
+	return nil
+}
+
+// change returns the type of the event reporting the change
+// from the object old to obj, or "" if it is unchanged.
+func change(old, obj meerkat.Object) string {
+	switch {
+	case old.State != obj.State || old.StateType != obj.StateType:
+		return meerkat.EventStateChange
+	case old.Acknowledged != obj.Acknowledged && obj.Acknowledged:
+		return meerkat.EventAcknowledgementSet
+	case old.Acknowledged != obj.Acknowledged:
+		return meerkat.EventAcknowledgementCleared
+	case old.Output != obj.Output || !maps.Equal(old.PerfData, obj.PerfData):
+		return meerkat.EventCheckResult
+	}
+	return ""
+}
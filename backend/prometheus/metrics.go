@@ -0,0 +1,82 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/meerkat-dashboard/meerkat"
+)
+
+// metricObject returns the object named by the PromQL expression expr,
+// with the values of the series it evaluates to as performance data,
+// keyed by their labels, such as {instance="web:9100"}. The value of a
+// series without labels, or of a scalar, is keyed "value".
+// The object is OK, or unknown if there are no series.
+func (b *Backend) metricObject(ctx context.Context, expr string) (meerkat.Object, error) {
+	var data struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	}
+	if err := b.query(ctx, "/api/v1/query", url.Values{"query": {expr}}, &data); err != nil {
+		return meerkat.Object{}, err
+	}
+	values := make(map[string]string)
+	switch data.ResultType {
+	case "vector":
+		var vector []struct {
+			Metric map[string]string `json:"metric"`
+			Value  [2]any            `json:"value"`
+		}
+		if err := json.Unmarshal(data.Result, &vector); err != nil {
+			return meerkat.Object{}, fmt.Errorf("decode result of %s: %w", expr, err)
+		}
+		for _, sample := range vector {
+			values[labelString(sample.Metric)] = fmt.Sprint(sample.Value[1])
+		}
+	case "scalar", "string":
+		var sample [2]any
+		if err := json.Unmarshal(data.Result, &sample); err != nil {
+			return meerkat.Object{}, fmt.Errorf("decode result of %s: %w", expr, err)
+		}
+		values["value"] = fmt.Sprint(sample[1])
+	default:
+		return meerkat.Object{}, fmt.Errorf("unsupported result type %q of %s", data.ResultType, expr)
+	}
+
+	obj := meerkat.Object{Name: expr, Type: "Metric", StateType: 1}
+	obj.PerfData = values
+	switch len(values) {
+	case 0:
+		obj.State = 3
+		obj.Output = "No data"
+	case 1:
+		for _, v := range values {
+			obj.Output = v
+		}
+	default:
+		obj.Output = strconv.Itoa(len(values)) + " series"
+	}
+	return obj, nil
+}
+
+// labelString returns labels other than the metric name in the
+// Prometheus format, such as {instance="web:9100",job="node"},
+// or "value" if there are none.
+func labelString(labels map[string]string) string {
+	var pairs []string
+	for k, v := range labels {
+		if k != "__name__" {
+			pairs = append(pairs, k+"="+strconv.Quote(v))
+		}
+	}
+	if len(pairs) == 0 {
+		return "value"
+	}
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ",") + "}"
+}
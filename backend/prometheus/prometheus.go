@@ -0,0 +1,264 @@
+// Package prometheus provides a meerkat.Backend showing the alerts and
+// query results of Prometheus, with alerts optionally from Alertmanager.
+//
+// Elements show two types of objects. Alerts are named by the name of
+// their alerting rule, or selected by label matchers such as
+// {team="network", severity=~"critical|warning"}; each is shown as one
+// object in the worst state of the alerts selected. Metrics are named by
+// a PromQL expression, with the values of its series as performance data.
+//
+// Prometheus has no event stream, so changes are found by evaluating the
+// objects watched by an event stream at an interval.
+// A stand-in server for testing is available in the promtest package.
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/meerkat-dashboard/meerkat"
+)
+
+// Object types.
+const (
+	TypeAlerts  = "alerts"
+	TypeMetrics = "metrics"
+)
+
+// DefaultInterval is the default interval between evaluations
+// of the objects watched by event streams.
+const DefaultInterval = 15 * time.Second
+
+// Backend is a meerkat.Backend showing objects from Prometheus.
+type Backend struct {
+	// URLs are the base URLs of Prometheus servers with the same
+	// rules and targets, such as a highly available pair. Requests go
+	// to the server which last responded, failing over to the others
+	// in turn when it cannot be reached or responds with a server error.
+	URLs []string
+	// AlertmanagerURL is the base URL of an Alertmanager. If set,
+	// alerts are from Alertmanager, and silenced or inhibited alerts
+	// are shown as acknowledged. Otherwise they are from Prometheus.
+	AlertmanagerURL string
+	Username        string
+	Password        string
+	// HTTPClient is used to make requests.
+	// If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+	// Interval is the interval between evaluations of the objects
+	// watched by event streams. If zero, DefaultInterval is used.
+	Interval time.Duration
+
+	mu      sync.Mutex
+	current int
+}
+
+// Current returns the index in URLs of the server requests are sent to
+// first: the last one to respond.
+func (b *Backend) Current() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.current
+}
+
+func (b *Backend) client() *http.Client {
+	if b.HTTPClient != nil {
+		return b.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// get requests path relative to base with params,
+// decoding the JSON response into v.
+func (b *Backend) get(ctx context.Context, base, path string, params url.Values, v any) error {
+	u := strings.TrimSuffix(base, "/") + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if b.Username != "" || b.Password != "" {
+		req.SetBasicAuth(b.Username, b.Password)
+	}
+	resp, err := b.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		e := &meerkat.BackendError{StatusCode: resp.StatusCode}
+		// Prometheus describes errors in JSON; Alertmanager in text.
+		var r struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &r) == nil && r.Error != "" {
+			e.Status = r.Error
+		} else {
+			e.Status = strings.Trim(strings.TrimSpace(string(body)), `"`)
+		}
+		return e
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("decode response to %s: %w", path, err)
+	}
+	return nil
+}
+
+// failedOver reports whether err from a server means
+// the request should be tried at another.
+func failedOver(err error) bool {
+	var e *meerkat.BackendError
+	if errors.As(err, &e) {
+		return e.StatusCode >= 500
+	}
+	return err != nil
+}
+
+// query requests path from Prometheus with params, decoding the data
+// of the response into v.
+func (b *Backend) query(ctx context.Context, path string, params url.Values, v any) error {
+	if len(b.URLs) == 0 {
+		return errors.New("prometheus: no URLs")
+	}
+	var resp struct {
+		Data json.RawMessage `json:"data"`
+	}
+	start := b.Current()
+	var errs []error
+	for i := range b.URLs {
+		n := (start + i) % len(b.URLs)
+		err := b.get(ctx, b.URLs[n], path, params, &resp)
+		if !failedOver(err) {
+			b.mu.Lock()
+			b.current = n
+			b.mu.Unlock()
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal(resp.Data, v)
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return fmt.Errorf("all %d servers failed: %w", len(errs), errors.Join(errs...))
+}
+
+// Objects implements meerkat.Backend.
+func (b *Backend) Objects(ctx context.Context, q meerkat.ObjectQuery) ([]meerkat.Object, error) {
+	expr := q.Name
+	if expr == "" {
+		expr = q.Filter
+	}
+	switch {
+	case q.Type == TypeAlerts && expr == "":
+		return b.alertRules(ctx)
+	case q.Type == TypeAlerts:
+		obj, err := b.alertObject(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		return []meerkat.Object{obj}, nil
+	case q.Type == TypeMetrics && expr == "":
+		return b.metricNames(ctx)
+	case q.Type == TypeMetrics:
+		obj, err := b.metricObject(ctx, expr)
+		if err != nil {
+			return nil, err
+		}
+		return []meerkat.Object{obj}, nil
+	}
+	return nil, &meerkat.BackendError{StatusCode: http.StatusBadRequest, Status: fmt.Sprintf("unsupported object type %q", q.Type)}
+}
+
+// alertRules returns an object named by each alerting rule,
+// in no particular state.
+func (b *Backend) alertRules(ctx context.Context) ([]meerkat.Object, error) {
+	var data struct {
+		Groups []struct {
+			Rules []struct {
+				Name string `json:"name"`
+			} `json:"rules"`
+		} `json:"groups"`
+	}
+	if err := b.query(ctx, "/api/v1/rules", url.Values{"type": {"alert"}}, &data); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var objects []meerkat.Object
+	for _, g := range data.Groups {
+		for _, r := range g.Rules {
+			if !seen[r.Name] {
+				seen[r.Name] = true
+				objects = append(objects, meerkat.Object{Name: r.Name, Type: "Alert"})
+			}
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+	return objects, nil
+}
+
+// metricNames returns an object named by each metric,
+// in no particular state.
+func (b *Backend) metricNames(ctx context.Context) ([]meerkat.Object, error) {
+	var names []string
+	if err := b.query(ctx, "/api/v1/label/__name__/values", nil, &names); err != nil {
+		return nil, err
+	}
+	objects := make([]meerkat.Object, len(names))
+	for i, name := range names {
+		objects[i] = meerkat.Object{Name: name, Type: "Metric"}
+	}
+	return objects, nil
+}
+
+// Status implements meerkat.Backend.
+// The start time is the later of when Prometheus and Alertmanager,
+// if used, were started.
+func (b *Backend) Status(ctx context.Context) (meerkat.BackendInfo, error) {
+	var runtime struct {
+		StartTime time.Time `json:"startTime"`
+	}
+	if err := b.query(ctx, "/api/v1/status/runtimeinfo", nil, &runtime); err != nil {
+		return meerkat.BackendInfo{}, err
+	}
+	var build struct {
+		Version string `json:"version"`
+	}
+	if err := b.query(ctx, "/api/v1/status/buildinfo", nil, &build); err != nil {
+		return meerkat.BackendInfo{}, err
+	}
+	info := meerkat.BackendInfo{Version: build.Version, Start: runtime.StartTime}
+	if b.AlertmanagerURL != "" {
+		var status struct {
+			Uptime time.Time `json:"uptime"`
+		}
+		if err := b.get(ctx, b.AlertmanagerURL, "/api/v2/status", nil, &status); err != nil {
+			return meerkat.BackendInfo{}, fmt.Errorf("alertmanager: %w", err)
+		}
+		if status.Uptime.After(info.Start) {
+			info.Start = status.Uptime
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,179 @@
+package prometheus_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/meerkat-dashboard/meerkat"
+	"github.com/meerkat-dashboard/meerkat/backend/prometheus"
+	"github.com/meerkat-dashboard/meerkat/backend/prometheus/promtest"
+)
+
+func newTestBackend(t *testing.T) (*prometheus.Backend, *promtest.Server) {
+	t.Helper()
+	srv := promtest.NewServer()
+	t.Cleanup(srv.Close)
+	return &prometheus.Backend{URLs: []string{srv.URL}}, srv
+}
+
+func getObject(t *testing.T, b *prometheus.Backend, q meerkat.ObjectQuery) meerkat.Object {
+	t.Helper()
+	objects, err := b.Objects(context.Background(), q)
+	if err != nil {
+		t.Fatalf("get %+v: %v", q, err)
+	}
+	if len(objects) != 1 {
+		t.Fatalf("get %+v: got %d objects, want 1", q, len(objects))
+	}
+	return objects[0]
+}
+
+func TestAlerts(t *testing.T) {
+	b, srv := newTestBackend(t)
+	srv.AddRules("DiskFull")
+	srv.SetAlerts(
+		promtest.Alert{Labels: map[string]string{"alertname": "HostDown", "severity": "critical", "team": "network"}, Annotations: map[string]string{"summary": "router is down"}},
+		promtest.Alert{Labels: map[string]string{"alertname": "HighLoad", "severity": "warning", "team": "network"}, Suppressed: true},
+		promtest.Alert{Labels: map[string]string{"alertname": "HighLoad", "severity": "critical", "team": "web"}, Pending: true},
+	)
+
+	tests := []struct {
+		q      meerkat.ObjectQuery
+		state  int
+		soft   bool
+		output string
+	}{
+		{q: meerkat.ObjectQuery{Type: "alerts", Name: "HostDown"}, state: 2, output: "router is down"},
+		{q: meerkat.ObjectQuery{Type: "alerts", Name: "HighLoad"}, state: 1, output: "HighLoad"},
+		{q: meerkat.ObjectQuery{Type: "alerts", Filter: `{team="web"}`}, state: 2, soft: true, output: "HighLoad"},
+		{q: meerkat.ObjectQuery{Type: "alerts", Filter: `team="network", severity!~"crit.*"`}, state: 1, output: "HighLoad"},
+		{q: meerkat.ObjectQuery{Type: "alerts", Name: "DiskFull"}, state: 0, output: "No alerts"},
+	}
+	for _, tt := range tests {
+		obj := getObject(t, b, tt.q)
+		if obj.State != tt.state || (obj.StateType == 0) != tt.soft || obj.Output != tt.output {
+			t.Errorf("%+v: got state %d, type %d, output %q; want %d, soft %v, %q", tt.q, obj.State, obj.StateType, obj.Output, tt.state, tt.soft, tt.output)
+		}
+	}
+
+	// Alertmanager reports which alerts are silenced,
+	// but not which are pending.
+	b.AlertmanagerURL = srv.URL
+	if obj := getObject(t, b, meerkat.ObjectQuery{Type: "alerts", Name: "HighLoad"}); obj.State != 1 || !obj.Acknowledged {
+		t.Errorf("HighLoad from alertmanager: got state %d, acknowledged %v; want 1, true", obj.State, obj.Acknowledged)
+	}
+	if obj := getObject(t, b, meerkat.ObjectQuery{Type: "alerts", Name: "HostDown"}); obj.Acknowledged {
+		t.Error("HostDown from alertmanager is acknowledged")
+	}
+
+	objects, err := b.Objects(context.Background(), meerkat.ObjectQuery{Type: "alerts", NamesOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 3 || objects[0].Name != "DiskFull" {
+		t.Errorf("got alert rules %+v, want DiskFull, HighLoad and HostDown", objects)
+	}
+
+	_, err = b.Objects(context.Background(), meerkat.ObjectQuery{Type: "alerts", Filter: `team=network`})
+	var e *meerkat.BackendError
+	if !errors.As(err, &e) || e.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid matchers: got error %v, want status %d", err, http.StatusBadRequest)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	b, srv := newTestBackend(t)
+	srv.SetQuery(`sum(up)`, promtest.Sample{Value: 3})
+	srv.SetQuery(`node_load1`,
+		promtest.Sample{Labels: map[string]string{"__name__": "node_load1", "instance": "web:9100"}, Value: 0.5},
+		promtest.Sample{Labels: map[string]string{"__name__": "node_load1", "instance": "db:9100"}, Value: 2.25},
+	)
+
+	obj := getObject(t, b, meerkat.ObjectQuery{Type: "metrics", Name: "sum(up)"})
+	if obj.State != 0 || obj.Output != "3" || obj.PerfData["value"] != "3" {
+		t.Errorf("sum(up): got state %d, output %q, perfdata %v", obj.State, obj.Output, obj.PerfData)
+	}
+	obj = getObject(t, b, meerkat.ObjectQuery{Type: "metrics", Name: "node_load1"})
+	if obj.PerfData[`{instance="db:9100"}`] != "2.25" || len(obj.PerfData) != 2 {
+		t.Errorf("node_load1: got perfdata %v", obj.PerfData)
+	}
+	if obj := getObject(t, b, meerkat.ObjectQuery{Type: "metrics", Name: "absent_metric"}); obj.State != 3 {
+		t.Errorf("query without series: got state %d, want 3", obj.State)
+	}
+
+	objects, err := b.Objects(context.Background(), meerkat.ObjectQuery{Type: "metrics", NamesOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0].Name != "node_load1" {
+		t.Errorf("got metric names %+v, want node_load1", objects)
+	}
+	if _, err := b.Objects(context.Background(), meerkat.ObjectQuery{Type: "hosts", Name: "web"}); err == nil {
+		t.Error("no error getting hosts")
+	}
+}
+
+func TestEvents(t *testing.T) {
+	b, srv := newTestBackend(t)
+	b.Interval = 10 * time.Millisecond
+	srv.SetQuery(`sum(up)`, promtest.Sample{Value: 3})
+
+	ctx := context.Background()
+	stream, err := b.Events(ctx, []meerkat.ObjectQuery{
+		{Type: "alerts", Name: "HostDown"},
+		{Type: "metrics", Name: "sum(up)"},
+		// Rejected by the backend, so ignored.
+		{Type: "alerts", Filter: "{"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	srv.SetAlerts(promtest.Alert{Labels: map[string]string{"alertname": "HostDown", "severity": "critical"}})
+	e, err := stream.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Type != meerkat.EventStateChange || e.Object.Name != "HostDown" || e.Object.State != 2 {
+		t.Errorf("got event %+v, want HostDown changing to critical", e)
+	}
+
+	srv.SetQuery(`sum(up)`, promtest.Sample{Value: 2})
+	e, err = stream.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Type != meerkat.EventCheckResult || e.Object.Output != "2" {
+		t.Errorf("got event %+v, want new result of sum(up)", e)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	b, srv := newTestBackend(t)
+	b.URLs = []string{down.URL, srv.URL}
+	start := time.Date(2023, 5, 1, 9, 0, 0, 0, time.UTC)
+	srv.SetStart(start)
+
+	info, err := b.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Start.Equal(start) || info.Version == "" {
+		t.Errorf("got status %+v, want start %v", info, start)
+	}
+	if b.Current() != 1 {
+		t.Errorf("requests sent to server %d, want 1", b.Current())
+	}
+
+	b.AlertmanagerURL = down.URL
+	if _, err := b.Status(context.Background()); err == nil {
+		t.Error("no error with alertmanager down")
+	}
+}
@@ -0,0 +1,235 @@
+// Package promtest provides a stand-in for the HTTP APIs of Prometheus
+// and Alertmanager, for testing programs using them without either.
+//
+// A Server serves the parts of both APIs used by Meerkat at once, so it
+// may be used as both the Prometheus and the Alertmanager of a backend.
+// Queries are not evaluated: the results of expressions are set with
+// SetQuery, and other expressions evaluate to no series.
+package promtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// An Alert is an active alert.
+type Alert struct {
+	Labels      map[string]string
+	Annotations map[string]string
+	// Pending alerts are reported as pending by Prometheus.
+	// Alertmanager does not report them.
+	Pending bool
+	// Suppressed alerts are reported as suppressed by Alertmanager.
+	Suppressed bool
+}
+
+// A Sample is a series in the result of an instant query.
+type Sample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// Server is a fake Prometheus and Alertmanager.
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	alerts  []Alert
+	rules   []string
+	queries map[string][]Sample
+	start   time.Time
+}
+
+// NewServer starts and returns a new Server.
+// The caller should call Close when finished, to shut it down.
+func NewServer() *Server {
+	s := &Server{
+		queries: make(map[string][]Sample),
+		start:   time.Now().UTC().Truncate(time.Second),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/query", s.handleQuery)
+	mux.HandleFunc("/api/v1/alerts", s.handleAlerts)
+	mux.HandleFunc("/api/v1/rules", s.handleRules)
+	mux.HandleFunc("/api/v1/label/__name__/values", s.handleNames)
+	mux.HandleFunc("/api/v1/status/runtimeinfo", s.handleRuntimeInfo)
+	mux.HandleFunc("/api/v1/status/buildinfo", s.handleBuildInfo)
+	mux.HandleFunc("/api/v2/alerts", s.handleAlertmanagerAlerts)
+	mux.HandleFunc("/api/v2/status", s.handleAlertmanagerStatus)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// SetAlerts replaces the active alerts with alerts. The rules named
+// by their alertname labels are added to those reported.
+func (s *Server) SetAlerts(alerts ...Alert) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.alerts = alerts
+	for _, a := range alerts {
+		s.addRule(a.Labels["alertname"])
+	}
+}
+
+// AddRules adds alerting rules with the given names.
+func (s *Server) AddRules(names ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, name := range names {
+		s.addRule(name)
+	}
+}
+
+func (s *Server) addRule(name string) {
+	for _, r := range s.rules {
+		if r == name {
+			return
+		}
+	}
+	s.rules = append(s.rules, name)
+	sort.Strings(s.rules)
+}
+
+// SetQuery sets the series the PromQL expression expr evaluates to.
+// Expressions which are not set evaluate to no series.
+func (s *Server) SetQuery(expr string, samples ...Sample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queries[expr] = samples
+}
+
+// SetStart sets when Prometheus and Alertmanager were started,
+// as if they had been restarted.
+func (s *Server) SetStart(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.start = t
+}
+
+// writeData writes data in the envelope of Prometheus API responses.
+func writeData(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"status": "success", "data": data})
+}
+
+func writeError(w http.ResponseWriter, code int, errorType, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{"status": "error", "errorType": errorType, "error": msg})
+}
+
+func (s *Server) handleQuery(w http.ResponseWriter, req *http.Request) {
+	expr := req.FormValue("query")
+	if expr == "" {
+		writeError(w, http.StatusBadRequest, "bad_data", "invalid parameter \"query\": empty query")
+		return
+	}
+	now := float64(time.Now().Unix())
+	s.mu.Lock()
+	samples := s.queries[expr]
+	s.mu.Unlock()
+	result := []any{}
+	for _, sample := range samples {
+		labels := sample.Labels
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		result = append(result, map[string]any{
+			"metric": labels,
+			"value":  []any{now, strconv.FormatFloat(sample.Value, 'f', -1, 64)},
+		})
+	}
+	writeData(w, map[string]any{"resultType": "vector", "result": result})
+}
+
+func (s *Server) handleAlerts(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	alerts := []any{}
+	for _, a := range s.alerts {
+		state := "firing"
+		if a.Pending {
+			state = "pending"
+		}
+		alerts = append(alerts, map[string]any{
+			"labels":      a.Labels,
+			"annotations": a.Annotations,
+			"state":       state,
+		})
+	}
+	writeData(w, map[string]any{"alerts": alerts})
+}
+
+func (s *Server) handleRules(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rules := []any{}
+	for _, name := range s.rules {
+		rules = append(rules, map[string]any{"name": name, "type": "alerting"})
+	}
+	writeData(w, map[string]any{"groups": []any{map[string]any{"name": "promtest", "rules": rules}}})
+}
+
+func (s *Server) handleNames(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := make(map[string]bool)
+	names := []string{}
+	for _, samples := range s.queries {
+		for _, sample := range samples {
+			if name := sample.Labels["__name__"]; name != "" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	writeData(w, names)
+}
+
+func (s *Server) handleRuntimeInfo(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeData(w, map[string]any{"startTime": s.start})
+}
+
+func (s *Server) handleBuildInfo(w http.ResponseWriter, req *http.Request) {
+	writeData(w, map[string]any{"version": "2.45.0"})
+}
+
+func (s *Server) handleAlertmanagerAlerts(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	alerts := []any{}
+	for _, a := range s.alerts {
+		if a.Pending {
+			continue
+		}
+		state := "active"
+		if a.Suppressed {
+			state = "suppressed"
+		}
+		alerts = append(alerts, map[string]any{
+			"labels":      a.Labels,
+			"annotations": a.Annotations,
+			"status":      map[string]any{"state": state},
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alerts)
+}
+
+func (s *Server) handleAlertmanagerStatus(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"uptime":      s.start,
+		"versionInfo": map[string]string{"version": "0.26.0"},
+	})
+}
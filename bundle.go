@@ -0,0 +1,334 @@
+package meerkat
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A Bundle is a dashboard packaged with the uploaded assets it references,
+// for moving dashboards between Meerkat instances.
+//
+// Bundles are stored as zip archives holding the dashboard in the file
+// dashboard.json and each asset at its path under the data directory,
+// such as dashboards-background/map.png.
+type Bundle struct {
+	Dashboard Dashboard
+	// Assets holds the contents of each asset,
+	// keyed by its reference in the dashboard.
+	Assets map[string][]byte
+}
+
+// Directories under the data directory which hold uploaded assets.
+// Dashboards reference assets by URL path, for example
+// "/dashboards-background/map.png".
+var assetDirs = []string{"dashboards-background", "dashboards-sound"}
+
+const bundleDashboardFile = "dashboard.json"
+
+// Limits on what is read from a bundle, so that a small archive
+// can't expand to fill memory or disk when imported.
+const (
+	// maxAssetSize is the largest asset read from a bundle.
+	maxAssetSize = 64 << 20
+	// maxBundleContents is the largest total size of the files in a bundle.
+	maxBundleContents = 256 << 20
+	// maxBundleFiles is the most files a bundle may hold.
+	maxBundleFiles = 1000
+)
+
+// isAssetRef reports whether ref refers to an uploaded asset.
+func isAssetRef(ref string) bool {
+	dir, name, ok := strings.Cut(strings.TrimPrefix(ref, "/"), "/")
+	if !ok || !strings.HasPrefix(ref, "/") || !fs.ValidPath(name) || strings.Contains(name, "/") {
+		return false
+	}
+	for _, d := range assetDirs {
+		if dir == d {
+			return true
+		}
+	}
+	return false
+}
+
+// assetFields returns pointers to each field of d which may reference an asset.
+func assetFields(d *Dashboard) []*string {
+	fields := []*string{
+		&d.Background,
+		&d.OkSound, &d.WarningSound, &d.CriticalSound, &d.UnknownSound, &d.UpSound, &d.DownSound,
+	}
+	for i := range d.Elements {
+		o := &d.Elements[i].Options
+		fields = append(fields,
+			&o.Image, &o.Source, &o.AudioSource,
+			&o.OkSound, &o.WarningSound, &o.UnknownSound, &o.CriticalSound, &o.UpSound, &o.DownSound,
+			&o.Svg, &o.OkSvg, &o.WarningSvg, &o.UnknownSvg, &o.CriticalSvg,
+		)
+	}
+	return fields
+}
+
+// AssetRefs returns the uploaded assets referenced by d, in lexical order.
+func AssetRefs(d *Dashboard) []string {
+	seen := make(map[string]bool)
+	var refs []string
+	for _, f := range assetFields(d) {
+		if isAssetRef(*f) && !seen[*f] {
+			seen[*f] = true
+			refs = append(refs, *f)
+		}
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+// WriteBundle writes d and the assets it references from dataDir to w
+// as a zip archive. Referenced assets missing from dataDir are left out;
+// they are reported when the bundle is imported.
+func WriteBundle(w io.Writer, d *Dashboard, dataDir string) error {
+	zw := zip.NewWriter(w)
+	b, err := encodeDashboard(d)
+	if err != nil {
+		return err
+	}
+	f, err := zw.Create(bundleDashboardFile)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		return err
+	}
+	for _, ref := range AssetRefs(d) {
+		data, err := os.ReadFile(filepath.Join(dataDir, filepath.FromSlash(ref)))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("read asset: %w", err)
+		}
+		f, err := zw.Create(strings.TrimPrefix(ref, "/"))
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// ReadBundle reads a bundle from the zip archive in data.
+func ReadBundle(data []byte) (*Bundle, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("read bundle: %w", err)
+	}
+	if len(zr.File) > maxBundleFiles {
+		return nil, fmt.Errorf("read bundle: more than %d files", maxBundleFiles)
+	}
+	bundle := &Bundle{Assets: make(map[string][]byte)}
+	var found bool
+	var total int
+	for _, f := range zr.File {
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		ref := "/" + f.Name
+		if f.Name != bundleDashboardFile && !isAssetRef(ref) {
+			return nil, fmt.Errorf("read bundle: unexpected file %s", f.Name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("read bundle: %w", err)
+		}
+		b, err := io.ReadAll(io.LimitReader(rc, maxAssetSize+1))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read bundle: %s: %w", f.Name, err)
+		}
+		if len(b) > maxAssetSize {
+			return nil, fmt.Errorf("read bundle: %s larger than %d bytes", f.Name, maxAssetSize)
+		}
+		total += len(b)
+		if total > maxBundleContents {
+			return nil, fmt.Errorf("read bundle: contents larger than %d bytes", maxBundleContents)
+		}
+		if f.Name == bundleDashboardFile {
+			bundle.Dashboard, err = decodeDashboard(b)
+			if err != nil {
+				return nil, fmt.Errorf("read bundle: %w", err)
+			}
+			found = true
+			continue
+		}
+		bundle.Assets[ref] = b
+	}
+	if !found {
+		return nil, fmt.Errorf("read bundle: no %s", bundleDashboardFile)
+	}
+	if TitleToSlug(bundle.Dashboard.Title) == "" {
+		return nil, errors.New("read bundle: dashboard has no title")
+	}
+	return bundle, nil
+}
+
+// AssetAction describes what importing a bundle does with one of its assets.
+type AssetAction string
+
+const (
+	// AssetCreate writes the asset under its original name.
+	AssetCreate AssetAction = "create"
+	// AssetExists skips the asset as an identical file already exists.
+	AssetExists AssetAction = "exists"
+	// AssetRename writes the asset under a new name, as a different
+	// file already exists under the original name.
+	AssetRename AssetAction = "rename"
+)
+
+// AssetImport reports how one asset of a bundle is imported.
+type AssetImport struct {
+	Path string `json:"path"`
+	// NewPath is the reference to the asset after importing.
+	NewPath string      `json:"newPath"`
+	Action  AssetAction `json:"action"`
+	Size    int         `json:"size"`
+}
+
+// An ImportPlan reports the changes importing a bundle would make.
+// It is created with PlanImport.
+type ImportPlan struct {
+	Title string `json:"title"`
+	Slug  string `json:"slug"`
+	// OriginalSlug differs from Slug when a dashboard with the
+	// bundled dashboard's slug already exists. The imported
+	// dashboard is then retitled to avoid replacing it.
+	OriginalSlug string        `json:"originalSlug"`
+	Folder       string        `json:"folder"`
+	Assets       []AssetImport `json:"assets"`
+	// Missing lists assets referenced by the dashboard which are
+	// neither in the bundle nor already present.
+	Missing []string `json:"missing"`
+
+	// Dashboard is the dashboard to save, with asset references rewritten.
+	Dashboard Dashboard `json:"-"`
+}
+
+// PlanImport determines how b would be imported into an instance
+// keeping dashboards in s and assets in dataDir. Nothing is written.
+func PlanImport(b *Bundle, s Store, dataDir string) (*ImportPlan, error) {
+	d := b.Dashboard
+	d.Elements = append([]Element(nil), b.Dashboard.Elements...)
+	plan := &ImportPlan{
+		OriginalSlug: TitleToSlug(d.Title),
+		Folder:       d.Folder,
+		Assets:       []AssetImport{},
+		Missing:      []string{},
+	}
+
+	title := d.Title
+	for n := 2; ; n++ {
+		_, err := LoadDashboard(s, TitleToSlug(title))
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		title = fmt.Sprintf("%s %d", d.Title, n)
+	}
+	d.Title = title
+	d.Slug = TitleToSlug(title)
+	plan.Title, plan.Slug = d.Title, d.Slug
+
+	refs := make([]string, 0, len(b.Assets))
+	for ref := range b.Assets {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	taken := make(map[string]bool)
+	for _, ref := range refs {
+		taken[ref] = true
+	}
+	renamed := make(map[string]string)
+	for _, ref := range refs {
+		data := b.Assets[ref]
+		a := AssetImport{Path: ref, NewPath: ref, Action: AssetCreate, Size: len(data)}
+		existing, err := os.ReadFile(filepath.Join(dataDir, filepath.FromSlash(ref)))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return nil, fmt.Errorf("read existing asset: %w", err)
+		case bytes.Equal(existing, data):
+			a.Action = AssetExists
+		default:
+			a.Action = AssetRename
+			a.NewPath, err = freeAssetRef(ref, dataDir, taken)
+			if err != nil {
+				return nil, err
+			}
+			taken[a.NewPath] = true
+			renamed[ref] = a.NewPath
+		}
+		plan.Assets = append(plan.Assets, a)
+	}
+
+	for _, f := range assetFields(&d) {
+		if newRef, ok := renamed[*f]; ok {
+			*f = newRef
+		}
+	}
+	for _, ref := range AssetRefs(&b.Dashboard) {
+		if _, ok := b.Assets[ref]; ok {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dataDir, filepath.FromSlash(ref))); err != nil {
+			plan.Missing = append(plan.Missing, ref)
+		}
+	}
+	plan.Dashboard = d
+	return plan, nil
+}
+
+// freeAssetRef returns a reference like ref, such as
+// "/dashboards-background/map-2.png" for "/dashboards-background/map.png",
+// naming a file absent from dataDir and not in taken.
+func freeAssetRef(ref, dataDir string, taken map[string]bool) (string, error) {
+	ext := path.Ext(ref)
+	base := strings.TrimSuffix(ref, ext)
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s-%d%s", base, n, ext)
+		if taken[candidate] {
+			continue
+		}
+		_, err := os.Stat(filepath.Join(dataDir, filepath.FromSlash(candidate)))
+		if errors.Is(err, fs.ErrNotExist) {
+			return candidate, nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+}
+
+// WriteAssets writes the assets of b to dataDir as planned.
+// Saving the planned dashboard is left to the caller.
+func (p *ImportPlan) WriteAssets(b *Bundle, dataDir string) error {
+	for _, a := range p.Assets {
+		if a.Action == AssetExists {
+			continue
+		}
+		name := filepath.Join(dataDir, filepath.FromSlash(a.NewPath))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(name, b.Assets[a.Path], 0644); err != nil {
+			return fmt.Errorf("write asset %s: %w", a.NewPath, err)
+		}
+	}
+	return nil
+}
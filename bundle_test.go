@@ -0,0 +1,171 @@
+package meerkat
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, name, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBundle(t *testing.T) {
+	src := t.TempDir()
+	writeTestFile(t, filepath.Join(src, "dashboards-background", "map.png"), "map")
+	writeTestFile(t, filepath.Join(src, "dashboards-background", "logo.png"), "logo")
+	writeTestFile(t, filepath.Join(src, "dashboards-sound", "alarm.mp3"), "alarm")
+	d := &Dashboard{
+		Title:         "Network",
+		Background:    "/dashboards-background/map.png",
+		CriticalSound: "/dashboards-sound/alarm.mp3",
+		Elements: []Element{
+			{Type: "image", Options: Options{Image: "/dashboards-background/logo.png"}},
+			{Type: "image", Options: Options{Image: "https://example.com/remote.png"}},
+			{Type: "audio", Options: Options{AudioSource: "/dashboards-sound/gone.mp3"}},
+			{Type: "static-svg", Options: Options{Svg: "check-circle"}},
+		},
+	}
+	var buf bytes.Buffer
+	if err := WriteBundle(&buf, d, src); err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := ReadBundle(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bundle.Assets) != 3 {
+		t.Errorf("got %d assets in bundle, want 3", len(bundle.Assets))
+	}
+
+	dst := t.TempDir()
+	s := DirStore(dst)
+	if _, err := SaveDashboard(s, "network", &Dashboard{Title: "Network"}); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(dst, "dashboards-background", "map.png"), "a different map")
+	writeTestFile(t, filepath.Join(dst, "dashboards-background", "logo.png"), "logo")
+	plan, err := PlanImport(bundle, s, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Slug != "network-2" || plan.OriginalSlug != "network" {
+		t.Errorf("imported to slug %q from %q, want network-2 from network", plan.Slug, plan.OriginalSlug)
+	}
+	want := []AssetImport{
+		{Path: "/dashboards-background/logo.png", NewPath: "/dashboards-background/logo.png", Action: AssetExists, Size: 4},
+		{Path: "/dashboards-background/map.png", NewPath: "/dashboards-background/map-2.png", Action: AssetRename, Size: 3},
+		{Path: "/dashboards-sound/alarm.mp3", NewPath: "/dashboards-sound/alarm.mp3", Action: AssetCreate, Size: 5},
+	}
+	if !reflect.DeepEqual(plan.Assets, want) {
+		t.Errorf("got assets %+v, want %+v", plan.Assets, want)
+	}
+	if !reflect.DeepEqual(plan.Missing, []string{"/dashboards-sound/gone.mp3"}) {
+		t.Errorf("got missing assets %v", plan.Missing)
+	}
+	if plan.Dashboard.Background != "/dashboards-background/map-2.png" {
+		t.Errorf("background not rewritten: %s", plan.Dashboard.Background)
+	}
+	if bundle.Dashboard.Background != d.Background {
+		t.Errorf("planning modified bundle")
+	}
+	if _, err := os.Stat(filepath.Join(dst, "dashboards-sound", "alarm.mp3")); err == nil {
+		t.Errorf("planning import wrote asset")
+	}
+
+	if err := plan.WriteAssets(bundle, dst); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"dashboards-background/map.png":   "a different map",
+		"dashboards-background/map-2.png": "map",
+		"dashboards-sound/alarm.mp3":      "alarm",
+	} {
+		b, err := os.ReadFile(filepath.Join(dst, name))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if string(b) != want {
+			t.Errorf("%s: got %q, want %q", name, b, want)
+		}
+	}
+}
+
+func TestReadBundleRejectsOtherFiles(t *testing.T) {
+	for _, name := range []string{"../dashboards-access.json", "dashboards/other.json", "dashboards-background/../x"} {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for _, n := range []string{bundleDashboardFile, name} {
+			f, err := zw.Create(n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Write([]byte(`{"title": "Test"}`))
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadBundle(buf.Bytes()); err == nil {
+			t.Errorf("nil error reading bundle containing %s", name)
+		}
+	}
+	if _, err := ReadBundle([]byte("not a zip")); err == nil {
+		t.Error("nil error reading invalid bundle")
+	}
+}
+
+func TestReadBundleLimits(t *testing.T) {
+	tests := map[string]func(zw *zip.Writer) error{
+		"too many files": func(zw *zip.Writer) error {
+			for i := 0; i < maxBundleFiles; i++ {
+				if _, err := zw.Create(fmt.Sprintf("dashboards-background/%d.png", i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		// Zeros compress well, so this archive is small.
+		"contents too large": func(zw *zip.Writer) error {
+			zeros := make([]byte, maxAssetSize)
+			for i := 0; i*maxAssetSize <= maxBundleContents; i++ {
+				f, err := zw.Create(fmt.Sprintf("dashboards-background/%d.png", i))
+				if err != nil {
+					return err
+				}
+				if _, err := f.Write(zeros); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	for name, fill := range tests {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		f, err := zw.Create(bundleDashboardFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(`{"title": "Test"}`))
+		if err := fill(zw); err != nil {
+			t.Fatal(err)
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadBundle(buf.Bytes()); err == nil {
+			t.Errorf("%s: nil error reading bundle", name)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/meerkat-dashboard/meerkat"
+)
+
+var access *meerkat.AccessControl
+
+const accessFile = "dashboards-access.json"
+
+func getAccessHandler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(access.Snapshot()); err != nil {
+		log.Println("encode access list:", err)
+	}
+}
+
+func putUserHandler(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		Password string       `json:"password"`
+		Role     meerkat.Role `json:"role"`
+		Groups   []string     `json:"groups"`
+	}
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		http.Error(w, "decode user: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	user := meerkat.User{Name: chi.URLParam(req, "name"), Role: body.Role, Groups: body.Groups}
+	if err := access.PutUser(user, body.Password); err != nil {
+		http.Error(w, "store user: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	sessions.revoke(user.Name)
+	log.Printf("User %s stored by %s\n", user.Name, meerkat.UserFromContext(req.Context()).Name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func deleteUserHandler(w http.ResponseWriter, req *http.Request) {
+	name := chi.URLParam(req, "name")
+	err := access.DeleteUser(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.Error(w, "no such user "+name, http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "delete user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sessions.revoke(name)
+	log.Printf("User %s deleted by %s\n", name, meerkat.UserFromContext(req.Context()).Name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func putFolderHandler(w http.ResponseWriter, req *http.Request) {
+	var acl meerkat.FolderACL
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&acl); err != nil {
+		http.Error(w, "decode folder permissions: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	acl.Folder = chi.URLParam(req, "folder")
+	if err := access.SetFolder(acl); err != nil {
+		http.Error(w, "store folder permissions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Permissions for folder %q stored by %s\n", acl.Folder, meerkat.UserFromContext(req.Context()).Name)
+	w.WriteHeader(http.StatusNoContent)
+}
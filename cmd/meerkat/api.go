@@ -0,0 +1,861 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/meerkat-dashboard/meerkat"
+	"github.com/r3labs/sse/v2"
+)
+
+// apiPrefix is the path of the versioned API for managing dashboards.
+const apiPrefix = "/api/v1/dashboards"
+
+// maxDashboardSize limits the size of dashboards sent in request bodies.
+const maxDashboardSize = 16 << 20
+
+// apiRoutes registers the handlers of the versioned API on r.
+// Anonymous users receive JSON errors rather than requireLogin's,
+// so the handlers check all permissions themselves.
+func apiRoutes(r chi.Router) {
+	r.Get("/", apiListDashboards)
+	r.Post("/", apiCreateDashboard)
+	r.Get("/{slug}", apiGetDashboard)
+	r.Put("/{slug}", apiReplaceDashboard)
+	r.Patch("/{slug}", apiPatchDashboard)
+	r.Delete("/{slug}", apiDeleteDashboard)
+	r.Post("/{slug}/clone", apiCloneDashboard)
+	r.Post("/{slug}/instances", apiCreateInstance)
+	r.Get("/{slug}/view", apiViewDashboard)
+	r.Get("/{slug}/shares", apiListShares)
+	r.Post("/{slug}/shares", apiCreateShare)
+	r.Delete("/{slug}/shares/{id}", apiDeleteShare)
+	r.Get("/{slug}/archive", apiListArchive)
+	r.Get("/{slug}/archive/{time}", apiArchiveAt)
+	r.Route("/{slug}/elements", elementRoutes)
+}
+
+var (
+	errDenied   = errors.New("permission denied")
+	errModified = errors.New("dashboard has been changed since it was loaded")
+)
+
+// An apiError is an error reported to clients with a specific HTTP status code.
+type apiError struct {
+	status int
+	msg    string
+}
+
+func (e *apiError) Error() string { return e.msg }
+
+func badRequest(format string, args ...any) error {
+	return &apiError{http.StatusBadRequest, fmt.Sprintf(format, args...)}
+}
+
+// errorStatus returns the HTTP status code to report err with.
+func errorStatus(req *http.Request, err error) int {
+	var aerr *apiError
+	var fields meerkat.ValidationError
+	switch {
+	case errors.As(err, &aerr):
+		return aerr.status
+	case errors.As(err, &fields):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, errDenied):
+		if meerkat.UserFromContext(req.Context()) == nil {
+			return http.StatusUnauthorized
+		}
+		return http.StatusForbidden
+	case errors.Is(err, errModified):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, fs.ErrNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, fs.ErrExist):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// writeError responds with err as a JSON object.
+// Invalid dashboards are described field by field, as by invalidDashboard.
+func writeError(w http.ResponseWriter, req *http.Request, err error) {
+	status := errorStatus(req, err)
+	body := struct {
+		Error  string               `json:"error"`
+		Fields []meerkat.FieldError `json:"fields,omitempty"`
+	}{Error: err.Error()}
+	var fields meerkat.ValidationError
+	switch {
+	case errors.As(err, &fields):
+		body.Error = "invalid dashboard"
+		body.Fields = fields
+	case status == http.StatusUnauthorized:
+		body.Error = "login required"
+	case status == http.StatusInternalServerError:
+		log.Printf("%s %s: %v", req.Method, req.URL.Path, err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Println("encode response:", err)
+	}
+}
+
+// legacyError responds with err to requests to the routes which predate
+// the versioned API, in plain text as those routes always have.
+func legacyError(w http.ResponseWriter, req *http.Request, err error) {
+	var fields meerkat.ValidationError
+	switch {
+	case errors.Is(err, errDenied):
+		denied(w, req)
+	case errors.As(err, &fields):
+		invalidDashboard(w, err)
+	case errors.Is(err, errModified):
+		http.Error(w, err.Error()+"; reload to see the latest version", http.StatusConflict)
+	default:
+		status := errorStatus(req, err)
+		if status == http.StatusInternalServerError {
+			log.Printf("%s %s: %v", req.Method, req.URL.Path, err)
+		}
+		http.Error(w, err.Error(), status)
+	}
+}
+
+// loadDashboard returns the dashboard slug, which the user making req
+// must be allowed to view.
+func loadDashboard(req *http.Request, slug string) (meerkat.Dashboard, error) {
+	dashboard, err := meerkat.LoadDashboard(store, slug)
+	if errors.Is(err, fs.ErrNotExist) {
+		return dashboard, fmt.Errorf("no dashboard %s: %w", slug, fs.ErrNotExist)
+	} else if err != nil {
+		return dashboard, err
+	}
+	if !canView(req, dashboard.Folder) && !sharedWith(req, slug) {
+		return dashboard, errDenied
+	}
+	return dashboard, nil
+}
+
+// readBody reads the body of req, which may be no larger than
+// the largest dashboard. what describes the body in errors.
+func readBody(req *http.Request, what string) ([]byte, error) {
+	defer req.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(req.Body, maxDashboardSize+1))
+	if err != nil {
+		return nil, badRequest("read %s: %v", what, err)
+	}
+	if len(b) > maxDashboardSize {
+		return nil, &apiError{http.StatusRequestEntityTooLarge, what + " too large"}
+	}
+	return b, nil
+}
+
+// readDashboard decodes the dashboard in the body of req.
+// Dashboards in older formats are migrated to the current one.
+func readDashboard(req *http.Request) (meerkat.Dashboard, error) {
+	b, err := readBody(req, "dashboard")
+	if err != nil {
+		return meerkat.Dashboard{}, err
+	}
+	dashboard, _, err := meerkat.MigrateDashboard(b)
+	if err != nil {
+		return meerkat.Dashboard{}, badRequest("%v", err)
+	}
+	return dashboard, nil
+}
+
+// setDimensions records the size of the dashboard's background image.
+func setDimensions(dashboard *meerkat.Dashboard) error {
+	if dashboard.Background == "" {
+		return nil
+	}
+	width, height, err := imageDimensions(dashboard.Background)
+	if err != nil {
+		return badRequest("read background image %s dimensions: %v", dashboard.Background, err)
+	}
+	dashboard.Height = strconv.Itoa(height)
+	dashboard.Width = strconv.Itoa(width)
+	return nil
+}
+
+// createDashboard stores a new dashboard under the slug of its title,
+// failing if there already is a dashboard with that slug.
+// It returns the dashboard as stored.
+func createDashboard(req *http.Request, dashboard *meerkat.Dashboard, message string) ([]byte, error) {
+	if !canEdit(req, dashboard.Folder) {
+		return nil, errDenied
+	}
+	if err := dashboard.Validate(); err != nil {
+		return nil, err
+	}
+	slug := meerkat.TitleToSlug(dashboard.Title)
+	if slug == "" {
+		return nil, badRequest("empty slug from title %q", dashboard.Title)
+	} else if slug == "edit" || slug == "view" {
+		return nil, badRequest("reserved title %q for backwards compatibility", dashboard.Title)
+	}
+	if err := checkTemplate(req, slug, dashboard); err != nil {
+		return nil, err
+	}
+	if err := setDimensions(dashboard); err != nil {
+		return nil, err
+	}
+
+	saveLock.Lock()
+	defer saveLock.Unlock()
+	_, err := store.Get(meerkat.DashboardKey(slug))
+	if err == nil {
+		return nil, fmt.Errorf("dashboard %s already exists: %w", slug, fs.ErrExist)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	b, err := saveDashboard(req, slug, dashboard, message)
+	if err != nil {
+		return nil, fmt.Errorf("create dashboard %s: %w", slug, err)
+	}
+	updateDashboardCache(slug)
+	log.Printf("Created dashboard %s\n", slug)
+	return b, nil
+}
+
+// replaceDashboard stores dashboard in place of the existing dashboard slug.
+// If ifMatch is not empty, the existing dashboard must match it as
+// described by etagMatches. It returns the dashboard as stored.
+func replaceDashboard(req *http.Request, slug string, dashboard *meerkat.Dashboard, ifMatch, message string) ([]byte, error) {
+	if !canEdit(req, dashboard.Folder) {
+		return nil, errDenied
+	}
+	if err := dashboard.Validate(); err != nil {
+		return nil, err
+	}
+	if err := setDimensions(dashboard); err != nil {
+		return nil, err
+	}
+	return modifyDashboard(req, slug, ifMatch, message, func(d *meerkat.Dashboard) error {
+		*d = *dashboard
+		return nil
+	})
+}
+
+// modifyDashboard changes the existing dashboard slug with modify, then
+// stores the result. The dashboard is read, modified and stored while
+// holding saveLock, so concurrent changes are never lost.
+// If ifMatch is not empty, the existing dashboard must match it as
+// described by etagMatches. It returns the dashboard as stored.
+func modifyDashboard(req *http.Request, slug, ifMatch, message string, modify func(*meerkat.Dashboard) error) ([]byte, error) {
+	saveLock.Lock()
+	defer saveLock.Unlock()
+	b, err := store.Get(meerkat.DashboardKey(slug))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no dashboard %s: %w", slug, fs.ErrNotExist)
+	} else if err != nil {
+		return nil, fmt.Errorf("read dashboard: %w", err)
+	}
+	if ifMatch != "" && !etagMatches(ifMatch, meerkat.ETag(b)) {
+		return nil, fmt.Errorf("dashboard %s: %w", slug, errModified)
+	}
+	dashboard, _, err := meerkat.MigrateDashboard(b)
+	if err != nil {
+		return nil, err
+	}
+	if !canEdit(req, dashboard.Folder) {
+		return nil, errDenied
+	}
+	background := dashboard.Background
+	if err := modify(&dashboard); err != nil {
+		return nil, err
+	}
+	if !canEdit(req, dashboard.Folder) {
+		return nil, errDenied
+	}
+	if err := dashboard.Validate(); err != nil {
+		return nil, err
+	}
+	// Dashboards are stored under the slug of their title.
+	// Renaming also moves their history, so is left to the info page.
+	if meerkat.TitleToSlug(dashboard.Title) != slug {
+		return nil, badRequest("title %q would rename dashboard %s", dashboard.Title, slug)
+	}
+	if err := checkTemplate(req, slug, &dashboard); err != nil {
+		return nil, err
+	}
+	if dashboard.Background != background {
+		if err := setDimensions(&dashboard); err != nil {
+			return nil, err
+		}
+	}
+	b, err = saveDashboard(req, slug, &dashboard, message)
+	if err != nil {
+		return nil, err
+	}
+	updateDashboardCache(slug)
+	log.Printf("Updated dashboard %s\n", slug)
+	return b, nil
+}
+
+// renameDashboard changes the dashboard slug with modify, like
+// modifyDashboard, then moves it and its history to the slug of its
+// new title. Templates with instances can't be renamed, as their
+// instances name them by slug.
+func renameDashboard(req *http.Request, slug, message string, modify func(*meerkat.Dashboard) error) error {
+	saveLock.Lock()
+	defer saveLock.Unlock()
+	b, err := store.Get(meerkat.DashboardKey(slug))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("no dashboard %s: %w", slug, fs.ErrNotExist)
+	} else if err != nil {
+		return fmt.Errorf("read dashboard: %w", err)
+	}
+	dashboard, _, err := meerkat.MigrateDashboard(b)
+	if err != nil {
+		return err
+	}
+	if !canEdit(req, dashboard.Folder) {
+		return errDenied
+	}
+	background := dashboard.Background
+	if err := modify(&dashboard); err != nil {
+		return err
+	}
+	if !canEdit(req, dashboard.Folder) {
+		return errDenied
+	}
+	if err := dashboard.Validate(); err != nil {
+		return err
+	}
+	newSlug := meerkat.TitleToSlug(dashboard.Title)
+	if newSlug == "" {
+		return badRequest("empty slug from title %q", dashboard.Title)
+	} else if newSlug == "edit" || newSlug == "view" {
+		return badRequest("reserved title %q for backwards compatibility", dashboard.Title)
+	}
+	instances, err := instancesOf(slug)
+	if err != nil {
+		return err
+	}
+	if len(instances) > 0 {
+		return &apiError{http.StatusConflict, fmt.Sprintf("dashboard %s is the template of %s, so can't be renamed", slug, strings.Join(instances, ", "))}
+	}
+	if err := checkTemplate(req, newSlug, &dashboard); err != nil {
+		return err
+	}
+	_, err = store.Get(meerkat.DashboardKey(newSlug))
+	if err == nil {
+		return fmt.Errorf("dashboard %s already exists: %w", newSlug, fs.ErrExist)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if dashboard.Background != background {
+		if err := setDimensions(&dashboard); err != nil {
+			return err
+		}
+	}
+
+	// Move the history first, so that the revision saved below
+	// follows the earlier ones.
+	if err := history.Rename(slug, newSlug); err != nil {
+		log.Printf("move history of %s to %s: %v", slug, newSlug, err)
+	}
+	if _, err := saveDashboard(req, newSlug, &dashboard, message); err != nil {
+		if err := history.Rename(newSlug, slug); err != nil {
+			log.Printf("move history of %s back to %s: %v", newSlug, slug, err)
+		}
+		return fmt.Errorf("rename dashboard %s: %w", slug, err)
+	}
+	if err := meerkat.DeleteDashboard(store, slug); err != nil {
+		log.Printf("remove dashboard %s after renaming it to %s: %v", slug, newSlug, err)
+	}
+	// Share tokens name the dashboard by slug, so stop working anyway,
+	// and must not grant access to a new dashboard with the old slug.
+	if err := meerkat.DeleteShares(store, slug); err != nil {
+		log.Printf("revoke shares of renamed dashboard %s: %v\n", slug, err)
+	}
+	mapLock.Lock()
+	cache.Del(slug)
+	server.RemoveStream(slug)
+	delete(dashboardCache, slug)
+	mapLock.Unlock()
+	updateDashboardCache(newSlug)
+	log.Printf("Renamed dashboard %s to %s\n", slug, newSlug)
+	return nil
+}
+
+// checkTemplate checks that the template of the dashboard to be stored
+// as slug, if it has one, exists, may be viewed by the user making req,
+// and is not itself an instance. Templates cannot become instances.
+func checkTemplate(req *http.Request, slug string, dashboard *meerkat.Dashboard) error {
+	if dashboard.Template == "" {
+		return nil
+	}
+	invalid := func(format string, args ...any) error {
+		return meerkat.ValidationError{{Element: -1, Field: "template", Reason: fmt.Sprintf(format, args...)}}
+	}
+	template, err := meerkat.LoadDashboard(store, dashboard.Template)
+	if errors.Is(err, fs.ErrNotExist) {
+		return invalid("no dashboard %s", dashboard.Template)
+	} else if err != nil {
+		return fmt.Errorf("load template: %w", err)
+	}
+	if !canView(req, template.Folder) {
+		return errDenied
+	}
+	if template.Template != "" {
+		return invalid("%s is itself an instance of %s", template.Slug, template.Template)
+	}
+	instances, err := instancesOf(slug)
+	if err != nil {
+		return err
+	}
+	if len(instances) > 0 {
+		return invalid("%s is the template of %s", slug, strings.Join(instances, ", "))
+	}
+	return nil
+}
+
+// instancesOf returns the slugs of the instances of the template slug.
+// Dashboards which can't be decoded are skipped, so that they may
+// still be deleted; they can't be shown as instances anyway.
+func instancesOf(slug string) ([]string, error) {
+	prefix := dashboardDir + "/"
+	keys, err := store.List(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("list dashboards: %w", err)
+	}
+	var instances []string
+	for _, key := range keys {
+		name, ok := strings.CutSuffix(strings.TrimPrefix(key, prefix), ".json")
+		if !ok || strings.Contains(name, "/") {
+			continue
+		}
+		b, err := store.Get(key)
+		if err != nil {
+			return nil, fmt.Errorf("read dashboard: %w", err)
+		}
+		d, _, err := meerkat.MigrateDashboard(b)
+		if err != nil {
+			continue
+		}
+		if d.Template == slug {
+			instances = append(instances, name)
+		}
+	}
+	return instances, nil
+}
+
+// instantiateDashboard creates an instance of the template src, in the
+// template's folder, with the given title and variables.
+// It returns the slug of the instance and the instance as stored.
+func instantiateDashboard(req *http.Request, src, title string, variables map[string]string) (string, []byte, error) {
+	if title == "" {
+		return "", nil, badRequest("empty title")
+	}
+	template, err := loadDashboard(req, src)
+	if err != nil {
+		return "", nil, err
+	}
+	dashboard := meerkat.Dashboard{
+		Title:       title,
+		Folder:      template.Folder,
+		Description: template.Description,
+		Template:    src,
+		Variables:   variables,
+	}
+	b, err := createDashboard(req, &dashboard, "Created from template "+template.Title)
+	if err != nil {
+		return "", nil, err
+	}
+	return dashboard.Slug, b, nil
+}
+
+// cloneDashboard stores a copy of the dashboard src with a new title.
+// It returns the slug of the copy and the copy as stored.
+func cloneDashboard(req *http.Request, src, title string) (string, []byte, error) {
+	if title == "" {
+		return "", nil, badRequest("empty title")
+	}
+	dashboard, err := loadDashboard(req, src)
+	if err != nil {
+		return "", nil, err
+	}
+	srcTitle := dashboard.Title
+	dashboard.Title = title
+	b, err := createDashboard(req, &dashboard, "Cloned from "+srcTitle)
+	if err != nil {
+		return "", nil, err
+	}
+	log.Printf("Cloned dashboard %s from %s\n", dashboard.Slug, src)
+	return dashboard.Slug, b, nil
+}
+
+// deleteDashboard removes the dashboard slug and its cached state.
+func deleteDashboard(req *http.Request, slug string) error {
+	mapLock.Lock()
+	defer mapLock.Unlock()
+	b, err := store.Get(meerkat.DashboardKey(slug))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("no dashboard %s: %w", slug, fs.ErrNotExist)
+	} else if err != nil {
+		return fmt.Errorf("read dashboard: %w", err)
+	}
+	if dashboard, _, err := meerkat.MigrateDashboard(b); err != nil {
+		// Dashboards which can't be decoded have no folder
+		// to check, so only admins may delete them.
+		if u := meerkat.UserFromContext(req.Context()); u == nil || u.Role != meerkat.RoleAdmin {
+			return errDenied
+		}
+	} else if !canEdit(req, dashboard.Folder) {
+		return errDenied
+	}
+	instances, err := instancesOf(slug)
+	if err != nil {
+		return err
+	}
+	if len(instances) > 0 {
+		return &apiError{http.StatusConflict, fmt.Sprintf("dashboard %s is the template of %s", slug, strings.Join(instances, ", "))}
+	}
+	err = meerkat.DeleteDashboard(store, slug)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("no dashboard %s: %w", slug, fs.ErrNotExist)
+	} else if err != nil {
+		return fmt.Errorf("remove dashboard: %w", err)
+	}
+	// Shares must not grant access to a new dashboard with the same slug.
+	if err := meerkat.DeleteShares(store, slug); err != nil {
+		log.Printf("revoke shares of deleted dashboard %s: %v\n", slug, err)
+	}
+
+	cache.Del(slug)
+	server.RemoveStream(slug)
+	delete(dashboardCache, slug)
+	log.Printf("Deleted dashboard %s\n", slug)
+	return nil
+}
+
+// notifyViewers tells clients viewing the dashboard slug to reload it.
+func notifyViewers(slug string) {
+	server.Publish("updates", &sse.Event{
+		Data: []byte(slug),
+	})
+}
+
+// writeDashboard responds with the stored dashboard b and its ETag.
+func writeDashboard(w http.ResponseWriter, slug string, b []byte, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", meerkat.ETag(b))
+	if status == http.StatusCreated {
+		w.Header().Set("Location", path.Join(apiPrefix, slug))
+	}
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+// A dashboardSummary describes a dashboard in dashboard listings.
+type dashboardSummary struct {
+	Slug        string `json:"slug"`
+	Title       string `json:"title"`
+	Folder      string `json:"folder"`
+	Description string `json:"description"`
+	Elements    int    `json:"elements"`
+	Template    string `json:"template,omitempty"`
+}
+
+// apiListDashboards lists the dashboards the user may view.
+// If the folder parameter is present, only dashboards in that
+// folder are listed; an empty folder lists those in no folder.
+// Likewise the template parameter lists only instances of a template.
+func apiListDashboards(w http.ResponseWriter, req *http.Request) {
+	dashboards, err := meerkat.LoadDashboards(store)
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	q := req.URL.Query()
+	summaries := []dashboardSummary{}
+	for _, d := range dashboards {
+		if q.Has("folder") && d.Folder != q.Get("folder") {
+			continue
+		}
+		if q.Has("template") && d.Template != q.Get("template") {
+			continue
+		}
+		if !canView(req, d.Folder) {
+			continue
+		}
+		summaries = append(summaries, dashboardSummary{
+			Slug:        d.Slug,
+			Title:       d.Title,
+			Folder:      d.Folder,
+			Description: d.Description,
+			Elements:    len(d.Elements),
+			Template:    d.Template,
+		})
+	}
+	writeJSON(w, summaries)
+}
+
+func apiGetDashboard(w http.ResponseWriter, req *http.Request) {
+	slug := chi.URLParam(req, "slug")
+	if _, err := loadDashboard(req, slug); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	b, err := store.Get(meerkat.DashboardKey(slug))
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	if match := req.Header.Get("If-None-Match"); match != "" && etagMatches(match, meerkat.ETag(b)) {
+		w.Header().Set("ETag", meerkat.ETag(b))
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeDashboard(w, slug, b, http.StatusOK)
+}
+
+func apiCreateDashboard(w http.ResponseWriter, req *http.Request) {
+	dashboard, err := readDashboard(req)
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	b, err := createDashboard(req, &dashboard, revisionMessage(req, "Created dashboard"))
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	writeDashboard(w, dashboard.Slug, b, http.StatusCreated)
+}
+
+func apiReplaceDashboard(w http.ResponseWriter, req *http.Request) {
+	slug := chi.URLParam(req, "slug")
+	dashboard, err := readDashboard(req)
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	b, err := replaceDashboard(req, slug, &dashboard, req.Header.Get("If-Match"), revisionMessage(req, "Edited dashboard"))
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	notifyViewers(slug)
+	writeDashboard(w, slug, b, http.StatusOK)
+}
+
+// apiPatchDashboard changes a dashboard with the patch in the request body.
+func apiPatchDashboard(w http.ResponseWriter, req *http.Request) {
+	slug := chi.URLParam(req, "slug")
+	if _, err := loadDashboard(req, slug); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	patch, err := readPatch(req)
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	b, err := modifyDashboard(req, slug, req.Header.Get("If-Match"), revisionMessage(req, "Edited dashboard"), func(d *meerkat.Dashboard) error {
+		return patchJSON(d, patch)
+	})
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	notifyViewers(slug)
+	writeDashboard(w, slug, b, http.StatusOK)
+}
+
+// readPatch reads the patch in the body of req, returning a function
+// applying it to a document decoded from JSON. JSON Patch documents
+// (RFC 6902) are recognised by their content type; all other bodies
+// are read as JSON merge patches (RFC 7396).
+func readPatch(req *http.Request) (func(doc any) (any, error), error) {
+	b, err := readBody(req, "patch")
+	if err != nil {
+		return nil, err
+	}
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json-patch+json":
+		ops, err := meerkat.DecodePatch(b)
+		if err != nil {
+			return nil, badRequest("%v", err)
+		}
+		return func(doc any) (any, error) {
+			doc, err := meerkat.ApplyPatch(doc, ops)
+			if err != nil {
+				return nil, &apiError{http.StatusConflict, err.Error()}
+			}
+			return doc, nil
+		}, nil
+	case "", "application/json", "application/merge-patch+json":
+		var patch any
+		if err := json.Unmarshal(b, &patch); err != nil {
+			return nil, badRequest("decode patch: %v", err)
+		}
+		return func(doc any) (any, error) {
+			return mergePatch(doc, patch), nil
+		}, nil
+	}
+	return nil, &apiError{http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported patch type %s", mediaType)}
+}
+
+// patchJSON applies patch to the JSON encoding of v,
+// then decodes the result into v.
+func patchJSON[T any](v *T, patch func(any) (any, error)) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var doc any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	doc, err = patch(doc)
+	if err != nil {
+		return err
+	}
+	if b, err = json.Marshal(doc); err != nil {
+		return err
+	}
+	var patched T
+	if err := json.Unmarshal(b, &patched); err != nil {
+		return badRequest("patched document: %v", err)
+	}
+	*v = patched
+	return nil
+}
+
+// mergePatch returns target modified by patch as described by RFC 7396.
+func mergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = make(map[string]any)
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
+
+func apiDeleteDashboard(w http.ResponseWriter, req *http.Request) {
+	if err := deleteDashboard(req, chi.URLParam(req, "slug")); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func apiCloneDashboard(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		Title string `json:"title"`
+	}
+	defer req.Body.Close()
+	if err := json.NewDecoder(io.LimitReader(req.Body, maxDashboardSize)).Decode(&body); err != nil {
+		writeError(w, req, badRequest("decode request: %v", err))
+		return
+	}
+	slug, b, err := cloneDashboard(req, chi.URLParam(req, "slug"), body.Title)
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	writeDashboard(w, slug, b, http.StatusCreated)
+}
+
+// apiCreateInstance creates an instance of a template with the title
+// and variables in the request body, such as
+// {"title": "Sydney", "variables": {"hostgroup": "syd"}}.
+func apiCreateInstance(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		Title     string            `json:"title"`
+		Variables map[string]string `json:"variables"`
+	}
+	if err := decodeBody(req, &body); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	slug, b, err := instantiateDashboard(req, chi.URLParam(req, "slug"), body.Title, body.Variables)
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	writeDashboard(w, slug, b, http.StatusCreated)
+}
+
+// apiViewDashboard responds with a dashboard as it is shown: instances
+// with the elements of their template, and placeholders replaced by the
+// values of their variables. Query parameters named after a variable
+// override its stored value. The Meerkat-Stream header names the event
+// stream of changes to the state of the elements shown.
+func apiViewDashboard(w http.ResponseWriter, req *http.Request) {
+	slug := chi.URLParam(req, "slug")
+	q := req.URL.Query()
+	// Shared dashboards are only shown as they were shared.
+	if sharedWith(req, slug) {
+		q = nil
+	}
+	dashboard, stream, err := viewDashboard(req, slug, q)
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	w.Header().Set("Meerkat-Stream", stream)
+	writeJSON(w, dashboard)
+}
+
+// viewDashboard returns the dashboard slug as it is shown with the
+// variables in q, tracking the state of its elements. It also returns
+// the name of the view's event stream; see watchView.
+func viewDashboard(req *http.Request, slug string, q url.Values) (meerkat.Dashboard, string, error) {
+	dashboard, err := loadDashboard(req, slug)
+	if err != nil {
+		return dashboard, "", err
+	}
+	dashboard, err = meerkat.ResolveDashboard(store, dashboard)
+	if err != nil {
+		return dashboard, "", err
+	}
+	vars := make(map[string]string)
+	for _, name := range dashboard.Placeholders() {
+		if q.Has(name) {
+			vars[name] = q.Get(name)
+		}
+	}
+	if err := meerkat.ValidateVariables(vars); err != nil {
+		return dashboard, "", err
+	}
+	stream, err := watchView(dashboard, vars)
+	if err != nil {
+		return dashboard, "", err
+	}
+	return dashboard.Expand(vars), stream, nil
+}
+
+// revisionMessage returns the revision message requested by the
+// client in the message query parameter, or def if there is none.
+func revisionMessage(req *http.Request, def string) string {
+	if msg := req.URL.Query().Get("message"); msg != "" {
+		return msg
+	}
+	return def
+}
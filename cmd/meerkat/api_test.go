@@ -0,0 +1,616 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/meerkat-dashboard/meerkat"
+	"github.com/r3labs/sse/v2"
+)
+
+// newAPITestServer serves the versioned API from an empty store.
+func newAPITestServer(t *testing.T) http.Handler {
+	t.Helper()
+	oldStore, oldHistory, oldServer, oldSigner, oldArchive := store, history, server, shareSigner, archive
+	t.Cleanup(func() {
+		store, history, server, shareSigner, archive = oldStore, oldHistory, oldServer, oldSigner, oldArchive
+	})
+	shareSigner = meerkat.NewShareSigner([]byte("0123456789abcdef0123456789abcdef"))
+	store = meerkat.DirStore(t.TempDir())
+	history = meerkat.NewHistory(store)
+	archive = meerkat.NewArchive(t.TempDir())
+	server = sse.New()
+	t.Cleanup(server.Close)
+	server.CreateStream("updates")
+	dashboardCache = make(map[string]map[string]ElementStore)
+	dashboardSync.Range(func(key, value any) bool {
+		dashboardSync.Delete(key)
+		return true
+	})
+	playlistStates = make(map[string]*playlistState)
+	kioskStatuses = make(map[string]kioskStatus)
+
+	r := chi.NewRouter()
+	r.Use(identify)
+	r.Route(apiPrefix, apiRoutes)
+	r.Route(playlistsPath, playlistRoutes)
+	r.Route(kiosksPath, kioskRoutes)
+	r.Get("/{slug}/snapshot.{format}", snapshotHandler)
+	return r
+}
+
+func apiRequest(h http.Handler, method, path, body string, header ...string) *httptest.ResponseRecorder {
+	var req *http.Request
+	if body == "" {
+		req = httptest.NewRequest(method, path, nil)
+	} else {
+		req = httptest.NewRequest(method, path, strings.NewReader(body))
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPI(t *testing.T) {
+	h := newAPITestServer(t)
+
+	rec := apiRequest(h, http.MethodPost, apiPrefix, `{"title": "Network", "folder": "ops"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: got status %d: %s", rec.Code, rec.Body)
+	}
+	if loc := rec.Header().Get("Location"); loc != apiPrefix+"/network" {
+		t.Errorf("create: got location %q", loc)
+	}
+	rec = apiRequest(h, http.MethodPost, apiPrefix, `{"title": "Network"}`)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("create duplicate: got status %d, want %d", rec.Code, http.StatusConflict)
+	}
+	rec = apiRequest(h, http.MethodPost, apiPrefix+"/network/clone", `{"title": "Servers"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("clone: got status %d: %s", rec.Code, rec.Body)
+	}
+	rec = apiRequest(h, http.MethodPost, apiPrefix, `{"title": "Other"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: got status %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = apiRequest(h, http.MethodGet, apiPrefix+"?folder=ops", "")
+	var list []dashboardSummary
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Slug != "network" || list[1].Slug != "servers" {
+		t.Errorf("list folder ops: got %+v", list)
+	}
+
+	rec = apiRequest(h, http.MethodGet, apiPrefix+"/network", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get: got status %d", rec.Code)
+	}
+	etag := rec.Header().Get("ETag")
+	rec = apiRequest(h, http.MethodPut, apiPrefix+"/network", `{"title": "Network", "folder": "ops", "description": "core"}`, "If-Match", etag)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("replace: got status %d: %s", rec.Code, rec.Body)
+	}
+	rec = apiRequest(h, http.MethodPut, apiPrefix+"/network", `{"title": "Network"}`, "If-Match", etag)
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("replace stale version: got status %d, want %d", rec.Code, http.StatusPreconditionFailed)
+	}
+
+	rec = apiRequest(h, http.MethodPatch, apiPrefix+"/network", `{"description": null, "globalMute": true}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("patch: got status %d: %s", rec.Code, rec.Body)
+	}
+	d, err := meerkat.LoadDashboard(store, "network")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Description != "" || !d.GlobalMute || d.Folder != "ops" {
+		t.Errorf("patch: got dashboard %+v", d)
+	}
+
+	// Titles may change, but not the slug derived from them.
+	rec = apiRequest(h, http.MethodPatch, apiPrefix+"/network", `{"title": "NETWORK"}`)
+	if rec.Code != http.StatusOK {
+		t.Errorf("patch title: got status %d: %s", rec.Code, rec.Body)
+	}
+	rec = apiRequest(h, http.MethodPut, apiPrefix+"/network", `{"title": "Other"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("rename with put: got status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	rec = apiRequest(h, http.MethodPatch, apiPrefix+"/network", `{"title": "Third"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("rename with patch: got status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if d, err := meerkat.LoadDashboard(store, "network"); err != nil || d.Slug != "network" {
+		t.Errorf("after rejected renames: got slug %q, error %v", d.Slug, err)
+	}
+
+	rec = apiRequest(h, http.MethodPatch, apiPrefix+"/network", `{"description": "big"}`+strings.Repeat(" ", maxDashboardSize))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("patch too large: got status %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+
+	rec = apiRequest(h, http.MethodDelete, apiPrefix+"/network", "")
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("delete: got status %d", rec.Code)
+	}
+	rec = apiRequest(h, http.MethodGet, apiPrefix+"/network", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("get deleted dashboard: got status %d", rec.Code)
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	h := newAPITestServer(t)
+	tests := []struct {
+		method, path, body string
+		status             int
+	}{
+		{http.MethodPost, apiPrefix, `not json`, http.StatusBadRequest},
+		{http.MethodPost, apiPrefix, `{"title": "Edit"}`, http.StatusBadRequest},
+		{http.MethodPost, apiPrefix, `{"title": "Bad", "elements": [{"type": "nope"}]}`, http.StatusUnprocessableEntity},
+		{http.MethodGet, apiPrefix + "/missing", ``, http.StatusNotFound},
+		{http.MethodPut, apiPrefix + "/missing", `{"title": "Missing"}`, http.StatusNotFound},
+		{http.MethodPost, apiPrefix + "/missing/clone", `{"title": "Copy"}`, http.StatusNotFound},
+		{http.MethodDelete, apiPrefix + "/missing", ``, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := apiRequest(h, tt.method, tt.path, tt.body)
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: got status %d, want %d", tt.method, tt.path, rec.Code, tt.status)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s %s: got content type %q, want application/json", tt.method, tt.path, ct)
+		}
+		var body struct {
+			Error string
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Error == "" {
+			t.Errorf("%s %s: no error in response: %v", tt.method, tt.path, err)
+		}
+	}
+}
+
+func TestAPIElements(t *testing.T) {
+	h := newAPITestServer(t)
+	rec := apiRequest(h, http.MethodPost, apiPrefix, `{"title": "Test", "elements": [{"id": "a", "type": "static-text", "options": {"text": "one"}}]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: got status %d: %s", rec.Code, rec.Body)
+	}
+	elements := apiPrefix + "/test/elements"
+
+	rec = apiRequest(h, http.MethodPost, elements, `{"type": "clock"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("add: got status %d: %s", rec.Code, rec.Body)
+	}
+	var added meerkat.Element
+	if err := json.NewDecoder(rec.Body).Decode(&added); err != nil {
+		t.Fatal(err)
+	}
+	if added.ID == "" || rec.Header().Get("Location") != elements+"/"+added.ID {
+		t.Errorf("add: got ID %q, location %q", added.ID, rec.Header().Get("Location"))
+	}
+	rec = apiRequest(h, http.MethodPost, elements+"?index=0", `{"id": "b", "type": "image"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("add at index: got status %d: %s", rec.Code, rec.Body)
+	}
+	rec = apiRequest(h, http.MethodPost, elements, `{"id": "b", "type": "image"}`)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("add existing element: got status %d, want %d", rec.Code, http.StatusConflict)
+	}
+	rec = apiRequest(h, http.MethodPost, elements, `{"type": "image"}`+strings.Repeat(" ", maxDashboardSize))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("add element too large: got status %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+
+	order := func() []string {
+		t.Helper()
+		d, err := meerkat.LoadDashboard(store, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, e := range d.Elements {
+			ids = append(ids, e.ID)
+		}
+		return ids
+	}
+	if got := order(); !reflect.DeepEqual(got, []string{"b", "a", added.ID}) {
+		t.Errorf("got elements %v after adding", got)
+	}
+	rec = apiRequest(h, http.MethodPost, elements+"/b/move", `{"index": 2}`)
+	if rec.Code != http.StatusOK {
+		t.Errorf("move: got status %d: %s", rec.Code, rec.Body)
+	}
+	if got := order(); !reflect.DeepEqual(got, []string{"a", added.ID, "b"}) {
+		t.Errorf("got elements %v after moving", got)
+	}
+	rec = apiRequest(h, http.MethodPost, elements+"/reorder", `["b", "a"]`)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("reorder with missing element: got status %d, want %d", rec.Code, http.StatusConflict)
+	}
+	rec = apiRequest(h, http.MethodPost, elements+"/reorder", `["b", "a", "`+added.ID+`"]`)
+	if rec.Code != http.StatusOK {
+		t.Errorf("reorder: got status %d: %s", rec.Code, rec.Body)
+	}
+	if got := order(); !reflect.DeepEqual(got, []string{"b", "a", added.ID}) {
+		t.Errorf("got elements %v after reordering", got)
+	}
+
+	rec = apiRequest(h, http.MethodPatch, elements+"/a", `[{"op": "test", "path": "/options/text", "value": "one"}, {"op": "replace", "path": "/options/text", "value": "two"}]`, "Content-Type", "application/json-patch+json")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("JSON patch element: got status %d: %s", rec.Code, rec.Body)
+	}
+	rec = apiRequest(h, http.MethodPatch, elements+"/a", `[{"op": "test", "path": "/options/text", "value": "one"}]`, "Content-Type", "application/json-patch+json")
+	if rec.Code != http.StatusConflict {
+		t.Errorf("failed JSON patch test: got status %d, want %d", rec.Code, http.StatusConflict)
+	}
+	rec = apiRequest(h, http.MethodPatch, apiPrefix+"/test", `[{"op": "add", "path": "/elements/1/options/linkURL", "value": "https://example.com"}]`, "Content-Type", "application/json-patch+json")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("JSON patch dashboard: got status %d: %s", rec.Code, rec.Body)
+	}
+	rec = apiRequest(h, http.MethodGet, elements+"/a", "")
+	var a meerkat.Element
+	if err := json.NewDecoder(rec.Body).Decode(&a); err != nil {
+		t.Fatal(err)
+	}
+	if a.ID != "a" || a.Options.Text != "two" || a.Options.LinkUrl != "https://example.com" {
+		t.Errorf("got element %+v after patches", a)
+	}
+	rec = apiRequest(h, http.MethodPatch, elements+"/a", `{"rect": {"x": 10}}`, "Content-Type", "application/merge-patch+json")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("merge patch element: got status %d: %s", rec.Code, rec.Body)
+	}
+	rec = apiRequest(h, http.MethodPatch, elements+"/a", `text`, "Content-Type", "text/plain")
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("patch as text: got status %d, want %d", rec.Code, http.StatusUnsupportedMediaType)
+	}
+
+	rec = apiRequest(h, http.MethodPut, elements+"/a", `{"type": "nope"}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("replace with invalid element: got status %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+	rec = apiRequest(h, http.MethodDelete, elements+"/a", "")
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("delete: got status %d: %s", rec.Code, rec.Body)
+	}
+	rec = apiRequest(h, http.MethodGet, elements+"/a", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("get deleted element: got status %d", rec.Code)
+	}
+}
+
+func TestAPITemplates(t *testing.T) {
+	h := newAPITestServer(t)
+	rec := apiRequest(h, http.MethodPost, apiPrefix, `{"title": "Site", "folder": "sites", "variables": {"group": "all"}, "elements": [{"id": "a", "type": "check-card", "options": {"objectType": "hostgroup", "objectName": "${group}"}}]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create template: got status %d: %s", rec.Code, rec.Body)
+	}
+	rec = apiRequest(h, http.MethodPost, apiPrefix+"/site/instances", `{"title": "Sydney", "variables": {"group": "syd"}}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create instance: got status %d: %s", rec.Code, rec.Body)
+	}
+
+	view := func(path string) (meerkat.Dashboard, string) {
+		t.Helper()
+		rec := apiRequest(h, http.MethodGet, path, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("get %s: got status %d: %s", path, rec.Code, rec.Body)
+		}
+		var d meerkat.Dashboard
+		if err := json.NewDecoder(rec.Body).Decode(&d); err != nil {
+			t.Fatal(err)
+		}
+		return d, rec.Header().Get("Meerkat-Stream")
+	}
+	d, stream := view(apiPrefix + "/sydney/view")
+	if len(d.Elements) != 1 || d.Elements[0].Options.ObjectName != "syd" || d.Folder != "sites" || stream != "sydney" {
+		t.Errorf("got view %+v on stream %q", d, stream)
+	}
+	d, stream = view(apiPrefix + "/site/view?group=mel&unused=1")
+	if d.Elements[0].Options.ObjectName != "mel" || stream != "site?group=mel" {
+		t.Errorf("got object name %q on stream %q", d.Elements[0].Options.ObjectName, stream)
+	}
+	if got := dashboardCache[stream]["a"].Name; got != "mel" {
+		t.Errorf("view with variables cached element showing %q, want mel", got)
+	}
+
+	rec = apiRequest(h, http.MethodPatch, apiPrefix+"/site", `[{"op": "replace", "path": "/elements/0/options/objectName", "value": "${group}-web"}]`, "Content-Type", "application/json-patch+json")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("patch template: got status %d: %s", rec.Code, rec.Body)
+	}
+	if got := dashboardCache["sydney"]["a"].Name; got != "syd-web" {
+		t.Errorf("instance cached element showing %q after editing template, want syd-web", got)
+	}
+	if _, ok := dashboardCache["site?group=mel"]; ok {
+		t.Errorf("view with variables still cached after editing template")
+	}
+
+	rec = apiRequest(h, http.MethodGet, apiPrefix+"?template=site", "")
+	var list []dashboardSummary
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Slug != "sydney" {
+		t.Errorf("list instances: got %+v", list)
+	}
+
+	tests := []struct {
+		method, path, body string
+		status             int
+	}{
+		{http.MethodDelete, apiPrefix + "/site", ``, http.StatusConflict},
+		{http.MethodPost, apiPrefix, `{"title": "Missing", "template": "nope"}`, http.StatusUnprocessableEntity},
+		{http.MethodPost, apiPrefix, `{"title": "Nested", "template": "sydney"}`, http.StatusUnprocessableEntity},
+		{http.MethodPost, apiPrefix, `{"title": "Own", "template": "site", "elements": [{"type": "clock"}]}`, http.StatusUnprocessableEntity},
+		{http.MethodPatch, apiPrefix + "/site", `{"template": "sydney"}`, http.StatusUnprocessableEntity},
+		{http.MethodGet, apiPrefix + "/site/view?group=a%0Ab", ``, http.StatusUnprocessableEntity},
+		{http.MethodGet, apiPrefix + "/site/view?group=" + strings.Repeat("a", 257), ``, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		rec := apiRequest(h, tt.method, tt.path, tt.body)
+		if rec.Code != tt.status {
+			t.Errorf("%s %s %s: got status %d, want %d: %s", tt.method, tt.path, tt.body, rec.Code, tt.status, rec.Body)
+		}
+	}
+}
+
+func TestAPIViewLimits(t *testing.T) {
+	h := newAPITestServer(t)
+	rec := apiRequest(h, http.MethodPost, apiPrefix, `{"title": "Site", "elements": [{"id": "a", "type": "check-card", "options": {"objectType": "hostgroup", "objectName": "${group}"}}]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create template: got status %d: %s", rec.Code, rec.Body)
+	}
+	views := func() int {
+		mapLock.RLock()
+		defer mapLock.RUnlock()
+		n := 0
+		for key := range dashboardCache {
+			if strings.HasPrefix(key, "site?") {
+				n++
+			}
+		}
+		return n
+	}
+	view := func(group string) int {
+		return apiRequest(h, http.MethodGet, apiPrefix+"/site/view?group="+group, "").Code
+	}
+
+	for i := 0; i < maxViews; i++ {
+		if code := view(fmt.Sprint("g", i)); code != http.StatusOK {
+			t.Fatalf("view %d: got status %d", i, code)
+		}
+	}
+	// Views no one watches make way for new ones.
+	if code := view("new"); code != http.StatusOK {
+		t.Fatalf("view beyond limit with none watched: got status %d", code)
+	}
+	if n := views(); n != 1 {
+		t.Errorf("got %d views after dropping unwatched ones, want 1", n)
+	}
+	for i := 0; i < maxViews-1; i++ {
+		view(fmt.Sprint("g", i))
+	}
+	dashboardSync.Range(func(key, value any) bool {
+		d := value.(Dashboard)
+		d.CurrentlyOpenBy = []string{"192.0.2.1:1234"}
+		dashboardSync.Store(key, d)
+		return true
+	})
+	if code := view("more"); code != http.StatusServiceUnavailable {
+		t.Errorf("view beyond limit with all watched: got status %d, want %d", code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestEventStreamForgetsViews(t *testing.T) {
+	h := newAPITestServer(t)
+	rec := apiRequest(h, http.MethodPost, apiPrefix, `{"title": "Site", "elements": [{"id": "a", "type": "check-card", "options": {"objectType": "hostgroup", "objectName": "${group}"}}]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create template: got status %d: %s", rec.Code, rec.Body)
+	}
+	createDashboardCache()
+	r := chi.NewRouter()
+	r.Use(identify)
+	createEventStream(r)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	cached := func(key string) bool {
+		mapLock.RLock()
+		defer mapLock.RUnlock()
+		_, ok := dashboardCache[key]
+		return ok
+	}
+	watched := func(key string) bool {
+		d, ok := dashboardSync.Load(key)
+		return ok && len(d.(Dashboard).CurrentlyOpenBy) > 0
+	}
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		for i := 0; !cond(); i++ {
+			if i == 100 {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// Following the stream of a view not yet tracked, such as when
+	// reconnecting after it was forgotten, tracks it again.
+	const key = "site?group=mel"
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events?stream="+url.QueryEscape(key), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("follow view stream: got status %d", resp.StatusCode)
+	}
+	waitFor("viewer to be recorded", func() bool { return watched(key) })
+	if !cached(key) {
+		t.Fatalf("view %s not tracked while watched", key)
+	}
+	cancel()
+	waitFor("view to be forgotten", func() bool { return !cached(key) })
+}
+
+func TestRestoreRevision(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(identify)
+	r.Post("/dashboard/{slug}/revisions/{id}/restore", restoreRevisionHandler)
+	r.Mount("/", newAPITestServer(t))
+	for _, req := range []struct{ method, path, body string }{
+		{http.MethodPost, apiPrefix, `{"title": "Base"}`},
+		{http.MethodPost, apiPrefix, `{"title": "Sydney", "template": "base"}`},
+		{http.MethodPut, apiPrefix + "/sydney", `{"title": "Sydney"}`},
+		{http.MethodPost, apiPrefix, `{"title": "Melbourne", "template": "sydney"}`},
+	} {
+		if rec := apiRequest(r, req.method, req.path, req.body); rec.Code >= 300 {
+			t.Fatalf("%s %s: got status %d: %s", req.method, req.path, rec.Code, rec.Body)
+		}
+	}
+	restore := func(id int, header ...string) int {
+		t.Helper()
+		return apiRequest(r, http.MethodPost, fmt.Sprintf("/dashboard/sydney/revisions/%d/restore", id), "", header...).Code
+	}
+	// Sydney is now the template of Melbourne, so can't become an instance again.
+	if code := restore(1); code != http.StatusUnprocessableEntity {
+		t.Errorf("restore instance over template: got status %d, want %d", code, http.StatusUnprocessableEntity)
+	}
+	if code := restore(2, "If-Match", `"stale"`); code != http.StatusConflict {
+		t.Errorf("restore with stale ETag: got status %d, want %d", code, http.StatusConflict)
+	}
+	for _, slug := range []string{"melbourne", "sydney"} {
+		if rec := apiRequest(r, http.MethodDelete, apiPrefix+"/"+slug, ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("delete %s: got status %d: %s", slug, rec.Code, rec.Body)
+		}
+	}
+	if code := restore(2); code != http.StatusNoContent {
+		t.Fatalf("restore deleted dashboard: got status %d, want %d", code, http.StatusNoContent)
+	}
+	if rec := apiRequest(r, http.MethodGet, apiPrefix+"/sydney", ""); rec.Code != http.StatusOK {
+		t.Errorf("get restored dashboard: got status %d", rec.Code)
+	}
+}
+
+func TestImportChecksTemplate(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(identify)
+	r.Post("/dashboard/import", handleImportDashboard)
+	r.Mount("/", newAPITestServer(t))
+	if rec := apiRequest(r, http.MethodPost, apiPrefix, `{"title": "Site"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("create template: got status %d: %s", rec.Code, rec.Body)
+	}
+	for template, status := range map[string]int{"missing": http.StatusUnprocessableEntity, "site": http.StatusCreated} {
+		var buf bytes.Buffer
+		if err := meerkat.WriteBundle(&buf, &meerkat.Dashboard{Title: "Sydney", Template: template}, t.TempDir()); err != nil {
+			t.Fatal(err)
+		}
+		rec := apiRequest(r, http.MethodPost, "/dashboard/import", buf.String(), "Content-Type", "application/zip")
+		if rec.Code != status {
+			t.Errorf("import instance of %s: got status %d, want %d: %s", template, rec.Code, status, rec.Body)
+		}
+	}
+}
+
+func TestDeleteUndecodableDashboard(t *testing.T) {
+	h := newAPITestServer(t)
+	if err := store.Put(meerkat.DashboardKey("broken"), []byte("{")); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	access, err = meerkat.LoadAccessControl(filepath.Join(t.TempDir(), accessFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { access = nil }()
+	for _, u := range []meerkat.User{{Name: "admin", Role: meerkat.RoleAdmin}, {Name: "alice", Role: meerkat.RoleEditor}} {
+		if err := access.PutUser(u, "hunter2"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	del := func(cookie *http.Cookie) int {
+		req := httptest.NewRequest(http.MethodDelete, apiPrefix+"/broken", nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := del(nil); code != http.StatusUnauthorized {
+		t.Errorf("anonymous delete: got status %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := del(login(t, "alice", "hunter2")); code != http.StatusForbidden {
+		t.Errorf("editor delete: got status %d, want %d", code, http.StatusForbidden)
+	}
+	if code := del(login(t, "admin", "hunter2")); code != http.StatusNoContent {
+		t.Errorf("admin delete: got status %d, want %d", code, http.StatusNoContent)
+	}
+}
+
+func TestEditInfo(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(identify)
+	r.Post("/{slug}/info", handleEditInfo)
+	r.Mount("/", newAPITestServer(t))
+	if rec := apiRequest(r, http.MethodPost, apiPrefix, `{"title": "Site"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("create template: got status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := apiRequest(r, http.MethodPost, apiPrefix+"/site/instances", `{"title": "Sydney"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("create instance: got status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := apiRequest(r, http.MethodPost, apiPrefix, `{"title": "Melbourne"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("create dashboard: got status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := apiRequest(r, http.MethodPost, apiPrefix+"/sydney/shares", `{}`); rec.Code != http.StatusCreated {
+		t.Fatalf("share instance: got status %d: %s", rec.Code, rec.Body)
+	}
+	tests := []struct {
+		slug, title string
+		status      int
+	}{
+		// Instances name their template by slug.
+		{"site", "Region", http.StatusConflict},
+		{"sydney", "Melbourne", http.StatusConflict},
+		{"sydney", "Perth", http.StatusFound},
+	}
+	for _, tt := range tests {
+		form := url.Values{"title": {tt.title}}
+		rec := apiRequest(r, http.MethodPost, "/"+tt.slug+"/info", form.Encode(), "Content-Type", "application/x-www-form-urlencoded")
+		if rec.Code != tt.status {
+			t.Errorf("retitle %s to %s: got status %d, want %d: %s", tt.slug, tt.title, rec.Code, tt.status, rec.Body)
+		}
+	}
+	for slug, status := range map[string]int{"site": http.StatusOK, "sydney": http.StatusNotFound, "perth": http.StatusOK} {
+		if rec := apiRequest(r, http.MethodGet, apiPrefix+"/"+slug, ""); rec.Code != status {
+			t.Errorf("get %s: got status %d, want %d", slug, rec.Code, status)
+		}
+	}
+	if shares, err := meerkat.LoadShares(store, "sydney"); err != nil || len(shares) > 0 {
+		t.Errorf("got %d shares of old slug after rename, error %v, want none", len(shares), err)
+	}
+	revs, err := history.Revisions("perth")
+	if err != nil || len(revs) != 2 {
+		t.Errorf("got %d revisions of renamed dashboard, error %v, want 2", len(revs), err)
+	}
+}
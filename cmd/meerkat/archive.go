@@ -0,0 +1,150 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/meerkat-dashboard/meerkat"
+)
+
+var archive *meerkat.Archive
+
+// maxArchiveRange is the longest span of records
+// which may be requested at once.
+const maxArchiveRange = 24 * time.Hour
+
+// dashboardStates returns the states of the objects shown by the elements
+// of the dashboard slug last seen by Meerkat, keyed by element ID.
+func dashboardStates(slug string) map[string]meerkat.ObjectState {
+	states := make(map[string]meerkat.ObjectState)
+	v, ok := dashboardSync.Load(slug)
+	if !ok {
+		return states
+	}
+	dashboard := v.(Dashboard)
+	for _, element := range cachedElements(slug) {
+		if result, ok := worstResult(element, dashboard); ok {
+			states[element.ID] = objectState(result)
+		}
+	}
+	return states
+}
+
+// archived returns the slugs of the cached dashboards selected by conf.
+func archived(conf ArchiveConfig) []string {
+	var slugs []string
+	dashboardSync.Range(func(key, _ any) bool {
+		slug := key.(string)
+		if slices.Contains(conf.Dashboards, "*") || slices.Contains(conf.Dashboards, slug) {
+			slugs = append(slugs, slug)
+		}
+		return true
+	})
+	slices.Sort(slugs)
+	return slugs
+}
+
+// recordStates adds the current states of the dashboards selected by
+// conf to the archive.
+func recordStates(conf ArchiveConfig, now time.Time) {
+	for _, slug := range archived(conf) {
+		rec := meerkat.ArchiveRecord{Time: now.UTC().Truncate(time.Second), States: dashboardStates(slug)}
+		if err := archive.Record(slug, rec); err != nil {
+			log.Println("Error archiving dashboard states:", err)
+		}
+	}
+}
+
+// runArchive records the states of the dashboards selected by conf
+// every conf.Interval seconds, and deletes records older than
+// conf.RetentionDays once a day.
+func runArchive(conf ArchiveConfig) {
+	retention := time.Duration(conf.RetentionDays) * 24 * time.Hour
+	var pruned time.Time
+	for now := range time.Tick(time.Duration(conf.Interval) * time.Second) {
+		recordStates(conf, now)
+		if now.Sub(pruned) < 24*time.Hour {
+			continue
+		}
+		if err := archive.Prune(now.Add(-retention)); err != nil {
+			log.Println("Error pruning dashboard state archive:", err)
+		}
+		pruned = now
+	}
+}
+
+// apiListArchive returns the archived states of a dashboard between the
+// times given in the from and to query parameters, in RFC 3339 format.
+// The default is the last hour.
+func apiListArchive(w http.ResponseWriter, req *http.Request) {
+	slug := chi.URLParam(req, "slug")
+	if _, err := loadDashboard(req, slug); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	to, err := timeParam(req, "to", time.Now())
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	from, err := timeParam(req, "from", to.Add(-time.Hour))
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	if to.Before(from) {
+		writeError(w, req, badRequest("to is before from"))
+		return
+	}
+	if to.Sub(from) > maxArchiveRange {
+		writeError(w, req, badRequest("range longer than %s", maxArchiveRange))
+		return
+	}
+	records, err := archive.Between(slug, from, to)
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	if records == nil {
+		records = []meerkat.ArchiveRecord{}
+	}
+	writeJSON(w, records)
+}
+
+// apiArchiveAt returns the archived states of a dashboard at a time,
+// in RFC 3339 format: those of the last record made at or before it.
+func apiArchiveAt(w http.ResponseWriter, req *http.Request) {
+	slug := chi.URLParam(req, "slug")
+	if _, err := loadDashboard(req, slug); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	t, err := time.Parse(time.RFC3339, chi.URLParam(req, "time"))
+	if err != nil {
+		writeError(w, req, badRequest("parse time: %v", err))
+		return
+	}
+	rec, err := archive.At(slug, t)
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	writeJSON(w, rec)
+}
+
+// timeParam returns the time in RFC 3339 format in the query parameter
+// name of req, or def if there is none.
+func timeParam(req *http.Request, name string, def time.Time) (time.Time, error) {
+	v := req.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return t, badRequest("parse %s: %v", name, err)
+	}
+	return t, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/meerkat-dashboard/meerkat"
+)
+
+func TestArchive(t *testing.T) {
+	h := newAPITestServer(t)
+	for _, title := range []string{"Network", "Servers"} {
+		body := `{"title": "` + title + `", "elements": [
+			{"id": "router", "type": "check-card", "rect": {"w": 50, "h": 50},
+				"options": {"objectType": "host", "objectName": "router"}}
+		]}`
+		if rec := apiRequest(h, http.MethodPost, apiPrefix, body); rec.Code != http.StatusCreated {
+			t.Fatalf("create: got status %d: %s", rec.Code, rec.Body)
+		}
+	}
+	order := meerkat.Order{Ok: 6, Warning: 4, Critical: 0, Unknown: 2, CriticalAck: 1, WarningAck: 5, UnknownAck: 3}
+	setState := func(state int) {
+		for _, slug := range []string{"network", "servers"} {
+			dashboardSync.Store(slug, Dashboard{Slug: slug, Order: order})
+			dashboardCache[slug] = map[string]ElementStore{
+				"router": {ID: "router", Type: "host", LastEvent: Result{Name: "router", Attrs: Attr{State: state}}},
+			}
+		}
+	}
+	conf := ArchiveConfig{Dashboards: []string{"network"}}
+	start := time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)
+	setState(0)
+	recordStates(conf, start)
+	setState(2)
+	recordStates(conf, start.Add(time.Minute))
+
+	rec := apiRequest(h, http.MethodGet, apiPrefix+"/network/archive?from=2024-01-01T02:00:00Z&to=2024-01-01T04:00:00Z", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list archive: got status %d: %s", rec.Code, rec.Body)
+	}
+	var records []meerkat.ArchiveRecord
+	if err := json.Unmarshal(rec.Body.Bytes(), &records); err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].States["router"].State != 0 || records[1].States["router"].State != 2 {
+		t.Errorf("got records %+v", records)
+	}
+
+	rec = apiRequest(h, http.MethodGet, apiPrefix+"/network/archive/2024-01-01T03:00:30Z", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get archive at time: got status %d: %s", rec.Code, rec.Body)
+	}
+	var at meerkat.ArchiveRecord
+	if err := json.Unmarshal(rec.Body.Bytes(), &at); err != nil {
+		t.Fatal(err)
+	}
+	if !at.Time.Equal(start) || at.States["router"].State != 0 {
+		t.Errorf("got record %+v at 03:00:30, want the one from 03:00", at)
+	}
+
+	tests := []struct {
+		target string
+		want   int
+	}{
+		{apiPrefix + "/network/archive/2024-01-01T02:00:00Z", http.StatusNotFound},
+		{apiPrefix + "/network/archive/yesterday", http.StatusBadRequest},
+		{apiPrefix + "/servers/archive/2024-01-01T03:00:30Z", http.StatusNotFound},
+		{apiPrefix + "/missing/archive", http.StatusNotFound},
+		{apiPrefix + "/network/archive?from=2024-01-01T00:00:00Z&to=2024-01-03T00:00:00Z", http.StatusBadRequest},
+		{apiPrefix + "/network/archive?from=2024-01-02T00:00:00Z&to=2024-01-01T00:00:00Z", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rec := apiRequest(h, http.MethodGet, tt.target, ""); rec.Code != tt.want {
+			t.Errorf("get %s: got status %d, want %d", tt.target, rec.Code, tt.want)
+		}
+	}
+
+	// The host is down now, but was up at 03:00.
+	rec = apiRequest(h, http.MethodGet, "/network/snapshot.svg?at=2024-01-01T03:00:30Z", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get archived snapshot: got status %d: %s", rec.Code, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), ">UP</tspan>") {
+		t.Errorf("archived snapshot does not show the host up:\n%s", rec.Body)
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/meerkat-dashboard/meerkat"
+	"github.com/meerkat-dashboard/meerkat/auth"
+)
+
+const sessionCookie = "meerkat_session"
+
+// sessionLifetime is how long a login remains valid without logging in again.
+const sessionLifetime = 12 * time.Hour
+
+type session struct {
+	user   meerkat.User
+	expiry time.Time
+}
+
+// sessionStore holds the login sessions of users who have
+// authenticated to edit dashboards. It is safe for concurrent use.
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]session
+}
+
+var sessions = &sessionStore{sessions: make(map[string]session)}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// create starts a new session for user, returning its ID.
+func (s *sessionStore) create(user meerkat.User) (string, error) {
+	id, err := newSessionID()
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, v := range s.sessions {
+		if time.Now().After(v.expiry) {
+			delete(s.sessions, k)
+		}
+	}
+	user.PasswordHash = ""
+	s.sessions[id] = session{user: user, expiry: time.Now().Add(sessionLifetime)}
+	return id, nil
+}
+
+// lookup returns the session with the given ID.
+// Expired sessions are removed and not returned.
+func (s *sessionStore) lookup(id string) (session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return session{}, false
+	}
+	if time.Now().After(sess.expiry) {
+		delete(s.sessions, id)
+		return session{}, false
+	}
+	return sess, true
+}
+
+func (s *sessionStore) remove(id string) {
+	s.mu.Lock()
+	delete(s.sessions, id)
+	s.mu.Unlock()
+}
+
+// revoke ends all sessions of the named user, so that changes to
+// or removal of the user take effect immediately.
+func (s *sessionStore) revoke(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, sess := range s.sessions {
+		if sess.user.Name == name {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// authEnabled reports whether editing requires logging in.
+// Authentication is only enforced once an admin account is configured,
+// users have been stored in the access list or single sign-on is configured.
+func authEnabled() bool {
+	return (config.AdminUsername != "" && config.AdminPassword != "") || access.HasUsers() ||
+		config.LDAP != nil || config.OIDC != nil
+}
+
+// checkAdminPassword reports whether username and password match the
+// configured admin credentials.
+func checkAdminPassword(username, password string) bool {
+	if config.AdminUsername == "" || config.AdminPassword == "" {
+		return false
+	}
+	userOK := subtle.ConstantTimeCompare([]byte(username), []byte(config.AdminUsername)) == 1
+	passOK := subtle.ConstantTimeCompare([]byte(password), []byte(config.AdminPassword)) == 1
+	return userOK && passOK
+}
+
+// authenticate returns the user matching username and password,
+// either the configured admin, a user from the access list
+// or a user from the LDAP directory.
+func authenticate(username, password string) (meerkat.User, bool) {
+	if checkAdminPassword(username, password) {
+		return meerkat.User{Name: username, Role: meerkat.RoleAdmin}, true
+	}
+	if user, ok := access.Authenticate(username, password); ok {
+		return user, true
+	}
+	if config.LDAP == nil {
+		return meerkat.User{}, false
+	}
+	id, err := config.LDAP.Authenticate(username, password)
+	if err != nil {
+		if !errors.Is(err, auth.ErrInvalidCredentials) {
+			log.Println("ldap:", err)
+		}
+		return meerkat.User{}, false
+	}
+	return ssoUser(id), true
+}
+
+// anonymousAdmin is the identity given to every request when
+// authentication is disabled.
+var anonymousAdmin = &meerkat.User{Name: "anonymous", Role: meerkat.RoleAdmin}
+
+// identify stores the user making the request, if any, in the request context.
+// See meerkat.UserFromContext. Requests not made by a user but by a kiosk,
+// for what it shows, carry the kiosk instead; see kioskAllows.
+func identify(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Has(shareParam) {
+			serveShared(w, req, next)
+			return
+		}
+		if !authEnabled() {
+			req = req.WithContext(meerkat.NewUserContext(req.Context(), anonymousAdmin))
+		} else if sess, ok := requestSession(req); ok {
+			req = req.WithContext(meerkat.NewUserContext(req.Context(), &sess.user))
+		} else if k, ok := requestKiosk(req); ok {
+			if share, ok := kioskAllows(req, &k); ok {
+				ctx := meerkat.NewKioskContext(req.Context(), &k)
+				if share != nil {
+					ctx = meerkat.NewShareContext(ctx, share)
+				}
+				req = req.WithContext(ctx)
+			}
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// requestSession returns the valid session, if any, referenced by the
+// request's session cookie.
+func requestSession(req *http.Request) (session, bool) {
+	cookie, err := req.Cookie(sessionCookie)
+	if err != nil {
+		return session{}, false
+	}
+	return sessions.lookup(cookie.Value)
+}
+
+// requireLogin wraps next so that it is only served to clients with a valid session.
+// Browsers navigating to a page are redirected to the login page;
+// other requests, such as those made by the editor, receive a 401 Unauthorized response.
+// Requests must have passed through identify beforehand.
+func requireLogin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if meerkat.UserFromContext(req.Context()) != nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+		unauthorized(w, req)
+	})
+}
+
+// requireAdmin is like requireLogin but only serves users with the admin role.
+func requireAdmin(next http.Handler) http.Handler {
+	return requireLogin(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if meerkat.UserFromContext(req.Context()).Role != meerkat.RoleAdmin {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, req)
+	}))
+}
+
+// requireEditor is like requireLogin but only serves users who may
+// edit dashboards in at least one folder.
+func requireEditor(next http.Handler) http.Handler {
+	return requireLogin(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !access.CanEditAny(meerkat.UserFromContext(req.Context())) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, req)
+	}))
+}
+
+func unauthorized(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodGet && strings.Contains(req.Header.Get("Accept"), "text/html") {
+		q := url.Values{"next": []string{req.URL.RequestURI()}}
+		http.Redirect(w, req, "/login?"+q.Encode(), http.StatusFound)
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+// denied responds to a request for something the requesting user may not access.
+// Anonymous users are asked to log in.
+func denied(w http.ResponseWriter, req *http.Request) {
+	if meerkat.UserFromContext(req.Context()) == nil {
+		unauthorized(w, req)
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+}
+
+// canView and canEdit report whether the user making req may view
+// or change dashboards in folder.
+func canView(req *http.Request, folder string) bool {
+	return access.CanView(meerkat.UserFromContext(req.Context()), folder)
+}
+
+func canEdit(req *http.Request, folder string) bool {
+	return access.CanEdit(meerkat.UserFromContext(req.Context()), folder)
+}
+
+func handleLogin(w http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		http.Error(w, "parse form: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	username := req.PostForm.Get("username")
+	next := safeRedirect(req.PostForm.Get("next"))
+	if !authEnabled() {
+		http.Redirect(w, req, next, http.StatusFound)
+		return
+	}
+	user, ok := authenticate(username, req.PostForm.Get("password"))
+	if !ok {
+		log.Printf("Failed login for user %q from %s\n", username, req.RemoteAddr)
+		q := url.Values{"next": []string{next}, "failed": []string{"1"}}
+		http.Redirect(w, req, "/login?"+q.Encode(), http.StatusFound)
+		return
+	}
+	startSession(w, req, user, next)
+}
+
+// startSession logs in user, then redirects the client to next.
+func startSession(w http.ResponseWriter, req *http.Request, user meerkat.User, next string) {
+	id, err := sessions.create(user)
+	if err != nil {
+		log.Println("create session:", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     "/",
+		Expires:  time.Now().Add(sessionLifetime),
+		HttpOnly: true,
+		Secure:   config.SSLEnable,
+		SameSite: http.SameSiteLaxMode,
+	})
+	log.Printf("User %s logged in from %s\n", user.Name, req.RemoteAddr)
+	http.Redirect(w, req, next, http.StatusFound)
+}
+
+func handleLogout(w http.ResponseWriter, req *http.Request) {
+	if cookie, err := req.Cookie(sessionCookie); err == nil {
+		sessions.remove(cookie.Value)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   config.SSLEnable,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, req, "/", http.StatusFound)
+}
+
+// safeRedirect returns target if it is a path on this server,
+// otherwise the root path. This prevents the login form from
+// redirecting users to other sites.
+func safeRedirect(target string) string {
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return "/"
+	}
+	return target
+}
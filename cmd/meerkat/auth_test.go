@@ -0,0 +1,194 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/meerkat-dashboard/meerkat"
+)
+
+func TestRequireLogin(t *testing.T) {
+	config.AdminUsername = "admin"
+	config.AdminPassword = "hunter2"
+	defer func() {
+		config.AdminUsername = ""
+		config.AdminPassword = ""
+	}()
+
+	protected := identify(requireLogin(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("secret"))
+	})))
+
+	req := httptest.NewRequest(http.MethodPost, "/dashboard/test", nil)
+	rec := httptest.NewRecorder()
+	protected.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous POST: got status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/test/edit", nil)
+	req.Header.Set("Accept", "text/html")
+	rec = httptest.NewRecorder()
+	protected.ServeHTTP(rec, req)
+	if rec.Code != http.StatusFound || !strings.HasPrefix(rec.Header().Get("Location"), "/login?") {
+		t.Errorf("anonymous page view: got status %d location %q, want redirect to login", rec.Code, rec.Header().Get("Location"))
+	}
+
+	form := url.Values{"username": {"admin"}, "password": {"wrong"}}
+	req = httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	handleLogin(rec, req)
+	if len(rec.Result().Cookies()) > 0 {
+		t.Fatalf("session cookie set after login with bad password")
+	}
+
+	form.Set("password", "hunter2")
+	req = httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	handleLogin(rec, req)
+	cookies := rec.Result().Cookies()
+	if len(cookies) == 0 {
+		t.Fatalf("no session cookie set after login")
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/dashboard/test", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	protected.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("logged in POST: got status %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestSafeRedirect(t *testing.T) {
+	tests := map[string]string{
+		"/test/edit":          "/test/edit",
+		"":                    "/",
+		"https://example.com": "/",
+		"//example.com":       "/",
+		"/\\example.com":      "/",
+	}
+	for target, want := range tests {
+		if got := safeRedirect(target); got != want {
+			t.Errorf("safeRedirect(%q) = %q, want %q", target, got, want)
+		}
+	}
+}
+
+func login(t *testing.T, username, password string) *http.Cookie {
+	t.Helper()
+	form := url.Values{"username": {username}, "password": {password}}
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handleLogin(rec, req)
+	cookies := rec.Result().Cookies()
+	if len(cookies) == 0 {
+		t.Fatalf("no session cookie set after login as %s", username)
+	}
+	return cookies[0]
+}
+
+func TestDeletedUserLoggedOut(t *testing.T) {
+	var err error
+	access, err = meerkat.LoadAccessControl(filepath.Join(t.TempDir(), accessFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { access = nil }()
+	for _, name := range []string{"admin", "alice"} {
+		if err := access.PutUser(meerkat.User{Name: name, Role: meerkat.RoleAdmin}, "hunter2"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	admin := login(t, "admin", "hunter2")
+	alice := login(t, "alice", "hunter2")
+
+	r := chi.NewRouter()
+	r.Use(identify)
+	r.With(requireAdmin).Get("/api/access", getAccessHandler)
+	r.With(requireAdmin).Delete("/api/access/users/{name}", deleteUserHandler)
+	request := func(method, path string, cookie *http.Cookie) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := request(http.MethodGet, "/api/access", alice); code != http.StatusOK {
+		t.Fatalf("alice before deletion: got status %d, want %d", code, http.StatusOK)
+	}
+	if code := request(http.MethodDelete, "/api/access/users/alice", admin); code != http.StatusNoContent {
+		t.Fatalf("delete alice: got status %d, want %d", code, http.StatusNoContent)
+	}
+	if code := request(http.MethodGet, "/api/access", alice); code != http.StatusUnauthorized {
+		t.Errorf("alice after deletion: got status %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := request(http.MethodGet, "/api/access", admin); code != http.StatusOK {
+		t.Errorf("admin after deleting alice: got status %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestCacheViewers(t *testing.T) {
+	h := newAPITestServer(t)
+	for _, body := range []string{`{"title": "Ops", "folder": "ops"}`, `{"title": "Network"}`} {
+		if rec := apiRequest(h, http.MethodPost, apiPrefix, body); rec.Code != http.StatusCreated {
+			t.Fatalf("create dashboard: got status %d: %s", rec.Code, rec.Body)
+		}
+	}
+	if rec := apiRequest(h, http.MethodPost, playlistsPath, `{"title": "Tour", "folder": "ops", "entries": [{"slug": "ops", "dwell": 30}]}`); rec.Code != http.StatusCreated {
+		t.Fatalf("create playlist: got status %d: %s", rec.Code, rec.Body)
+	}
+	createDashboardCache()
+	var err error
+	access, err = meerkat.LoadAccessControl(filepath.Join(t.TempDir(), accessFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { access = nil }()
+	if err := access.PutUser(meerkat.User{Name: "alice", Role: meerkat.RoleViewer}, "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := access.SetFolder(meerkat.FolderACL{Folder: "ops", Viewers: []string{"alice"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := chi.NewRouter()
+	r.Use(identify)
+	r.Get("/api/cache/{slug}", getCacheDashboardHandler)
+	r.Get("/api/cache", getCacheHandler)
+	r.Get("/api/objects", getObjectHandler)
+	r.Get("/api/all", getAllHandler)
+	createEventStream(r)
+	request := func(target string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	for _, target := range []string{"/api/cache/ops", "/events?stream=ops", "/events?stream=playlist/tour", "/api/objects?type=hosts&name=router&title=/ops/view", "/api/all?type=hosts&title=/ops/edit"} {
+		if rec := request(target, nil); rec.Code != http.StatusUnauthorized {
+			t.Errorf("anonymous %s: got status %d, want %d", target, rec.Code, http.StatusUnauthorized)
+		}
+	}
+	if rec := request("/api/cache/network", nil); rec.Code != http.StatusOK {
+		t.Errorf("anonymous unrestricted dashboard: got status %d, want %d", rec.Code, http.StatusOK)
+	}
+	rec := request("/api/cache", nil)
+	if !strings.Contains(rec.Body.String(), `"network"`) || strings.Contains(rec.Body.String(), `"ops"`) {
+		t.Errorf("anonymous cache listing: got %s, want only network", rec.Body)
+	}
+	if rec := request("/api/cache/ops", login(t, "alice", "hunter2")); rec.Code != http.StatusOK {
+		t.Errorf("viewer of ops: got status %d, want %d", rec.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/meerkat-dashboard/meerkat"
+	"github.com/meerkat-dashboard/meerkat/backend/prometheus"
+	"github.com/meerkat-dashboard/meerkat/icinga"
+	"github.com/r3labs/sse/v2"
+)
+
+// defaultBackend names the Icinga environment configured by the
+// Icinga settings at the top level of the configuration.
+const defaultBackend = "icinga"
+
+// Types of backends.
+const (
+	typeIcinga     = "icinga"
+	typePrometheus = "prometheus"
+)
+
+// A backend is a monitoring system whose objects are shown on dashboards.
+type backend struct {
+	name string
+	// typ is the type of the backend, such as "icinga".
+	typ string
+	api meerkat.Backend
+	// urls are the endpoints of api, in the order they are tried.
+	urls []string
+	// current returns the index in urls of the endpoint in use.
+	current func() int
+	// eventTimeout is how long the event stream may be silent
+	// before it is reconnected. Zero means forever.
+	eventTimeout time.Duration
+
+	mu        sync.Mutex
+	status    string
+	requests  []Requests
+	events    []Events
+	lastEvent int64
+}
+
+// backends holds the configured backends by name.
+var backends map[string]*backend
+
+// newBackend returns the backend named name configured by conf,
+// failing over between its endpoints.
+func newBackend(name string, conf BackendConfig) (*backend, error) {
+	b := &backend{name: name, typ: conf.Type, urls: conf.URLs}
+	password, err := conf.password()
+	if err != nil {
+		return nil, err
+	}
+	client, err := newHTTPClient(conf)
+	if err != nil {
+		return nil, err
+	}
+	switch conf.Type {
+	case typePrometheus:
+		p := &prometheus.Backend{
+			URLs:            conf.URLs,
+			AlertmanagerURL: conf.AlertmanagerURL,
+			Username:        conf.Username,
+			Password:        password,
+			HTTPClient:      client,
+			Interval:        time.Duration(conf.PollInterval) * time.Second,
+		}
+		b.api, b.current = p, p.Current
+	default:
+		b.typ = typeIcinga
+		endpoints := make([]icinga.API, len(conf.URLs))
+		for i, u := range conf.URLs {
+			endpoints[i] = &icinga.Client{URL: u, Username: conf.Username, Password: password, HTTPClient: client}
+		}
+		f := icinga.NewFailover(endpoints...)
+		// Groups and filters shown by dashboards are resolved to their
+		// members as often as the objects shown are checked for changes.
+		b.api = &icinga.Backend{API: f, Queue: "meerkat", Interval: resubscribeInterval}
+		b.current = f.Current
+		b.eventTimeout = time.Duration(conf.EventTimeout) * time.Second
+	}
+	return b, nil
+}
+
+// password returns the password configured by conf: read from
+// PasswordFile or PasswordEnv if either is set, or else Password.
+func (conf BackendConfig) password() (string, error) {
+	switch {
+	case conf.PasswordFile != "":
+		b, err := os.ReadFile(dataPath(conf.PasswordFile))
+		if err != nil {
+			return "", fmt.Errorf("read password: %w", err)
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	case conf.PasswordEnv != "":
+		password, ok := os.LookupEnv(conf.PasswordEnv)
+		if !ok {
+			return "", fmt.Errorf("password environment variable %s not set", conf.PasswordEnv)
+		}
+		return password, nil
+	}
+	return conf.Password, nil
+}
+
+// newHTTPClient returns a client for the API of the backend configured
+// by conf. All requests to the backend, including its event stream,
+// share the client's transport.
+func newHTTPClient(conf BackendConfig) (*http.Client, error) {
+	tlsConfig, err := conf.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+		TLSClientConfig:     tlsConfig,
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// tlsConfig returns the TLS configuration for connections to the
+// backend configured by conf, with its client certificate and CAs.
+func (conf BackendConfig) tlsConfig() (*tls.Config, error) {
+	c := &tls.Config{
+		InsecureSkipVerify: conf.InsecureTLS,
+		ServerName:         conf.ServerName,
+	}
+	if conf.CAFile != "" {
+		b, err := os.ReadFile(dataPath(conf.CAFile))
+		if err != nil {
+			return nil, fmt.Errorf("read CA certificates: %w", err)
+		}
+		c.RootCAs = x509.NewCertPool()
+		if !c.RootCAs.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no CA certificates in %s", conf.CAFile)
+		}
+	}
+	if conf.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(dataPath(conf.CertFile), dataPath(conf.KeyFile))
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+	return c, nil
+}
+
+// lookupBackend returns the backend named name.
+func lookupBackend(name string) (*backend, error) {
+	b, ok := backends[name]
+	if !ok {
+		return nil, badRequest("unknown backend %q", name)
+	}
+	return b, nil
+}
+
+// backendNames returns the names of the backends other than the default one.
+func backendNames() []string {
+	var names []string
+	for name := range backends {
+		if name != defaultBackend {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// dashboardBackend returns the name of the backend the objects of the
+// dashboard slug are from, unless its elements name their own.
+func dashboardBackend(slug string) string {
+	if v, ok := dashboardSync.Load(slug); ok && v.(Dashboard).Backend != "" {
+		return v.(Dashboard).Backend
+	}
+	return defaultBackend
+}
+
+// objectKey returns the key of the object name from the backend
+// in the object cache. Objects of different backends may share names.
+func objectKey(backend, name string) string {
+	if backend == "" || backend == defaultBackend {
+		return name
+	}
+	return backend + "\x00" + name
+}
+
+// message returns the message of kind, such as "icinga-error", sent to
+// viewers on the updates stream about the backend.
+// Messages about backends other than the default one name them,
+// as in "icinga-error:apac".
+func (b *backend) message(kind string) string {
+	if b.name == defaultBackend {
+		return kind
+	}
+	return kind + ":" + b.name
+}
+
+// setWorking tells viewers the backend is available.
+func (b *backend) setWorking() {
+	server.Publish("updates", &sse.Event{
+		Data: []byte(b.message("icinga-success")),
+	})
+	b.mu.Lock()
+	b.status = "working"
+	b.mu.Unlock()
+}
+
+// sendError tells viewers the backend is unavailable.
+func (b *backend) sendError() {
+	log.Printf("Sending %s backend error to clients.\n", b.name)
+	server.Publish("updates", &sse.Event{
+		Data: []byte(b.message("icinga-error")),
+	})
+	b.mu.Lock()
+	b.status = "failed"
+	b.mu.Unlock()
+}
+
+// recordRequest adds a request to the backend made for the dashboard
+// at dashboardTitle to the recent history reported by the status API.
+// err is the error returned by the request, if any.
+func (b *backend) recordRequest(call, dashboardTitle string, err error) {
+	code := http.StatusOK
+	var e *meerkat.BackendError
+	if errors.As(err, &e) {
+		code = e.StatusCode
+	} else if err != nil {
+		log.Printf("API error from %s: %v\n", b.name, err)
+		code = 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.requests) >= 100 {
+		b.requests = b.requests[1:]
+	}
+	b.requests = append(b.requests, Requests{CallMade: call, CallTime: time.Now().UnixMilli(), Dashboard: dashboardTitle, StatusCode: code})
+}
+
+// addEvent adds an event about the object name to the recent history
+// reported by the status API. Events older than a minute are dropped.
+func (b *backend) addEvent(name string, eventType string) {
+	now := time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.events = append(b.recentEvents(now), Events{Name: name, EventType: eventType, ReceivedTime: now.UnixMilli()})
+	b.lastEvent = now.UnixMilli()
+}
+
+// recentEvents returns the events received in the minute before now.
+// b.mu must be held.
+func (b *backend) recentEvents(now time.Time) []Events {
+	oneMinuteAgo := now.Add(-time.Minute).UnixMilli()
+	i := sort.Search(len(b.events), func(i int) bool { return b.events[i].ReceivedTime >= oneMinuteAgo })
+	return b.events[i:]
+}
+
+// report returns the status of the backend.
+func (b *backend) report() BackendStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var s BackendStatus
+	s.Type = b.typ
+	s.Status = b.status
+	s.Endpoints = b.urls
+	if n := b.current(); n < len(b.urls) {
+		s.ActiveEndpoint = b.urls[n]
+	}
+	s.Connections.APICalls.RecentRequestCount = len(b.requests)
+	s.Connections.APICalls.RecentHistory = append([]Requests{}, b.requests...)
+	events := b.recentEvents(time.Now())
+	s.Connections.EventStreams.LastEventReceived = int(b.lastEvent)
+	s.Connections.EventStreams.ReceivedEventCount = len(events)
+	s.Connections.EventStreams.RecentHistory = append([]Events{}, events...)
+	return s
+}
+
+// objects returns the objects selected by q,
+// requested for the dashboard at dashboardTitle.
+func (b *backend) objects(ctx context.Context, q meerkat.ObjectQuery, dashboardTitle string) ([]meerkat.Object, error) {
+	params := url.Values{}
+	if q.Name != "" {
+		params.Set("name", q.Name)
+	}
+	if q.Filter != "" {
+		params.Set("filter", q.Filter)
+	}
+	call := "objects/" + q.Type
+	if len(params) > 0 {
+		call += "?" + params.Encode()
+	}
+	if config.IcingaDebug {
+		icingaLog.Printf("Requesting %s from %s for %s\n", call, b.name, dashboardTitle)
+	}
+	objects, err := b.api.Objects(ctx, q)
+	b.recordRequest(call, dashboardTitle, err)
+	return objects, err
+}
+
+// objectResult converts obj to the result sent to dashboards.
+// Results are in the form of Icinga objects, which viewers understand
+// whichever backend they are from.
+func objectResult(obj meerkat.Object) Result {
+	ack := 0
+	if obj.Acknowledged {
+		ack = 1
+	}
+	return Result{
+		Attrs: Attr{
+			Name:            obj.Name,
+			Acknowledgement: ack,
+			LastCheckResults: LastCheckResult{
+				Output:          obj.Output,
+				PerformanceData: perfDataResult(obj.PerfData),
+				State:           obj.State,
+				Type:            obj.Type,
+			},
+			State:     obj.State,
+			StateType: obj.StateType,
+			Type:      obj.Type,
+		},
+		Name: obj.Name,
+		Type: obj.Type,
+	}
+}
+
+// perfDataResult returns the performance data m as sent to dashboards:
+// objects with label and value fields, ordered by label.
+func perfDataResult(m map[string]string) any {
+	if len(m) == 0 {
+		return nil
+	}
+	labels := make([]string, 0, len(m))
+	for label := range m {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	values := make([]any, len(labels))
+	for i, label := range labels {
+		values[i] = map[string]any{"label": label, "value": m[label]}
+	}
+	return values
+}
+
+// checkStart returns when the backend was last started or reloaded,
+// or the zero time if it is unavailable.
+func (b *backend) checkStart() time.Time {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	info, err := b.api.Status(ctx)
+	b.recordRequest("status", config.HTTPAddr, err)
+	if err != nil {
+		log.Printf("Failed to get status of %s: %v\n", b.name, err)
+		return time.Time{}
+	}
+	return info.Start
+}
+
+// watchStatus checks that the backend is running every 30 seconds,
+// telling viewers whether it is available. Dashboards are reloaded
+// when the backend has been restarted or reloaded.
+func (b *backend) watchStatus() {
+	var previousStart time.Time
+	var previousEndpoint int
+	for {
+		start := b.checkStart()
+		endpoint := b.current()
+		if !start.IsZero() {
+			b.setWorking()
+		} else {
+			b.sendError()
+		}
+		// Each endpoint of a cluster has its own start time.
+		if !previousStart.Equal(start) && !previousStart.IsZero() && !start.IsZero() && endpoint == previousEndpoint {
+			log.Printf("Backend %s reloaded prev: %v curr: %v\n", b.name, previousStart, start)
+			createDashboardCache()
+			UpdateAll()
+		}
+		previousStart, previousEndpoint = start, endpoint
+		time.Sleep(30 * time.Second)
+	}
+}
+
+// listen handles events streamed from the backend, reconnecting
+// 10 seconds after the stream is closed, or at once to watch
+// the objects shown by dashboards as they change.
+func (b *backend) listen() {
+	for {
+		err := EventListener(b)
+		if errors.Is(err, errResubscribe) {
+			continue
+		}
+		log.Printf("Disconnected from %s event stream waiting 10 seconds\n", b.name)
+		time.Sleep(10 * time.Second)
+	}
+}
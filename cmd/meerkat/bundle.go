@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/meerkat-dashboard/meerkat"
+)
+
+// maxBundleSize is the largest bundle accepted for import.
+const maxBundleSize = 256 << 20
+
+func handleExportDashboard(w http.ResponseWriter, req *http.Request) {
+	slug := chi.URLParam(req, "slug")
+	dashboard, err := meerkat.LoadDashboard(store, slug)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, req)
+		return
+	} else if err != nil {
+		http.Error(w, "read dashboard: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !canView(req, dashboard.Folder) {
+		denied(w, req)
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", slug+".meerkat.zip"))
+	if err := meerkat.WriteBundle(w, &dashboard, config.DataDirectory); err != nil {
+		log.Printf("export dashboard %s: %v", slug, err)
+	}
+}
+
+// readBundle reads a bundle from the request body, which is either
+// the zip archive itself or a form with the archive in the "bundle"
+// field, uploaded as a file or encoded in base64.
+func readBundle(w http.ResponseWriter, req *http.Request) (*meerkat.Bundle, error) {
+	req.Body = http.MaxBytesReader(w, req.Body, maxBundleSize)
+	var data []byte
+	var err error
+	switch {
+	case strings.HasPrefix(req.Header.Get("Content-Type"), "application/zip"):
+		data, err = io.ReadAll(req.Body)
+	case strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/form-data"):
+		if err := req.ParseMultipartForm(32 << 20); err != nil {
+			return nil, err
+		}
+		f, _, ferr := req.FormFile("bundle")
+		if ferr != nil {
+			data, err = base64.StdEncoding.DecodeString(req.FormValue("bundle"))
+			break
+		}
+		defer f.Close()
+		data, err = io.ReadAll(f)
+	default:
+		data, err = base64.StdEncoding.DecodeString(req.FormValue("bundle"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty bundle")
+	}
+	return meerkat.ReadBundle(data)
+}
+
+// handleImportDashboard imports a dashboard bundle, responding with the
+// import plan. If the "preview" parameter is true, nothing is written.
+// Forms submitted from the import page are redirected to the new dashboard.
+func handleImportDashboard(w http.ResponseWriter, req *http.Request) {
+	bundle, err := readBundle(w, req)
+	if err != nil {
+		http.Error(w, "read bundle: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !canEdit(req, bundle.Dashboard.Folder) {
+		denied(w, req)
+		return
+	}
+	if err := bundle.Dashboard.Validate(); err != nil {
+		invalidDashboard(w, err)
+		return
+	}
+	if len(bundle.Assets) > 0 && !access.CanEditAny(meerkat.UserFromContext(req.Context())) {
+		denied(w, req)
+		return
+	}
+
+	plan, err := meerkat.PlanImport(bundle, store, config.DataDirectory)
+	if err != nil {
+		http.Error(w, "plan import: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := checkTemplate(req, plan.Slug, &plan.Dashboard); err != nil {
+		legacyError(w, req, err)
+		return
+	}
+	if preview, _ := strconv.ParseBool(req.FormValue("preview")); preview {
+		writeJSON(w, plan)
+		return
+	}
+
+	if err := plan.WriteAssets(bundle, config.DataDirectory); err != nil {
+		msg := fmt.Sprintf("import %s: %v", plan.Slug, err)
+		log.Println(msg)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	// Another dashboard may have taken the planned slug since;
+	// createDashboard then fails rather than replacing it.
+	if _, err := createDashboard(req, &plan.Dashboard, "Imported from bundle"); err != nil {
+		legacyError(w, req, fmt.Errorf("import %s: %w", plan.Slug, err))
+		return
+	}
+	log.Printf("Imported dashboard %s with %d assets\n", plan.Slug, len(plan.Assets))
+
+	if strings.Contains(req.Header.Get("Accept"), "text/html") {
+		http.Redirect(w, req, path.Join("/", plan.Slug, "edit"), http.StatusSeeOther)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(plan); err != nil {
+		log.Println("encode response:", err)
+	}
+}
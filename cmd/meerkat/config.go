@@ -1,23 +1,63 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"regexp"
+
 	"github.com/BurntSushi/toml"
+	"github.com/meerkat-dashboard/meerkat/auth"
+	"github.com/meerkat-dashboard/meerkat/store/s3"
 )
 
 type Config struct {
 	HTTPAddr string
 
-	IcingaURL         string
-	IcingaUsername    string
-	IcingaPassword    string
-	IcingaInsecureTLS bool
+	IcingaURL string
+	// IcingaFailoverURLs lists other endpoints of the Icinga cluster
+	// at IcingaURL, such as a second master. Requests and the event
+	// stream fail over to them in order when IcingaURL is unavailable.
+	IcingaFailoverURLs []string
+	// IcingaUsername and IcingaPassword authenticate Meerkat to Icinga.
+	// They default to "meerkat" unless a client certificate is set.
+	IcingaUsername string
+	IcingaPassword string
+	// IcingaPasswordFile and IcingaPasswordEnv name a file holding the
+	// password, or an environment variable set to it, to keep it out of
+	// the configuration file.
+	IcingaPasswordFile string
+	IcingaPasswordEnv  string
+	IcingaInsecureTLS  bool
+	// IcingaCertFile and IcingaKeyFile are the client certificate and
+	// key presented to Icinga, as for an ApiUser with a client_cn.
+	IcingaCertFile string
+	IcingaKeyFile  string
+	// IcingaCAFile holds the certificates of the CAs trusted to issue
+	// the certificate of the Icinga API, such as Icinga's own CA.
+	// The default is the system's trusted CAs.
+	IcingaCAFile string
+	// IcingaServerName is the name the certificate of the Icinga API
+	// is verified against, if not the host in IcingaURL.
+	IcingaServerName string
 
 	IcingaEventTimeout int
 
+	// Backends configures Icinga environments other than the default
+	// one set above, and other monitoring systems such as Prometheus,
+	// keyed by name. Dashboards and elements choose the backend their
+	// objects are shown from by name.
+	Backends map[string]BackendConfig
+
 	SSLEnable bool
 	SSLCert   string
 	SSLKey    string
 
+	// DataDirectory holds dashboards, assets, logs and other files
+	// read and written by meerkat. Relative paths elsewhere in the
+	// configuration are resolved against it.
+	// The default is the current working directory.
+	DataDirectory string
+
 	LogFile      bool
 	LogConsole   bool
 	LogDirectory string
@@ -26,8 +66,120 @@ type Config struct {
 
 	AdminUsername string
 	AdminPassword string
+
+	// LDAP and OIDC configure single sign-on.
+	// Either may be left unset.
+	LDAP *auth.LDAP
+	OIDC *auth.OIDC
+	// GroupRoles maps groups from single sign-on providers
+	// to the roles "viewer", "editor" or "admin".
+	GroupRoles map[string]string
+
+	Storage StorageConfig
+
+	// Archive selects dashboards whose states are recorded over time.
+	Archive ArchiveConfig
+}
+
+// StorageConfig selects where dashboards and their history are kept.
+type StorageConfig struct {
+	// Type is "filesystem" (the default), "sqlite" or "s3".
+	Type string
+	// SQLitePath is the database file for the sqlite type.
+	SQLitePath string
+	S3         s3.Store
+}
+
+// ArchiveConfig configures the recording of the states of dashboards.
+type ArchiveConfig struct {
+	// Dashboards lists the slugs of the dashboards to record,
+	// or "*" for every dashboard. None are recorded by default.
+	Dashboards []string
+	// Interval is the number of seconds between records.
+	// The default is 60.
+	Interval int
+	// RetentionDays is the number of days records are kept for.
+	// The default is 30.
+	RetentionDays int
+}
+
+// BackendConfig configures a backend: an Icinga environment,
+// or a Prometheus server and its Alertmanager.
+type BackendConfig struct {
+	// Type is "icinga", the default, or "prometheus".
+	Type string
+	// URLs lists the endpoints of the API, such as each master of an
+	// Icinga cluster or each of a pair of Prometheus servers.
+	// Requests go to the first which is available.
+	URLs     []string
+	Username string
+	Password string
+	// PasswordFile and PasswordEnv name a file holding the password,
+	// or an environment variable set to it, instead of Password.
+	PasswordFile string
+	PasswordEnv  string
+	InsecureTLS  bool
+	// CertFile and KeyFile are the client certificate and key presented
+	// to the backend. CAFile holds the CAs trusted to issue its
+	// certificate, and ServerName the name it is verified against.
+	CertFile   string
+	KeyFile    string
+	CAFile     string
+	ServerName string
+	// EventTimeout is the number of seconds without events after
+	// which the Icinga event stream is reconnected.
+	// The default is IcingaEventTimeout.
+	EventTimeout int
+	// AlertmanagerURL is the URL of the Alertmanager API Prometheus
+	// sends alerts to, if any. Alerts silenced in Alertmanager are
+	// shown as acknowledged.
+	AlertmanagerURL string
+	// PollInterval is the number of seconds between evaluations of
+	// the Prometheus objects shown by dashboards, to find changes.
+	// The default is 15.
+	PollInterval int
+}
+
+// backendConfigs returns the configuration of each backend,
+// including the default Icinga environment, keyed by name.
+func (conf Config) backendConfigs() map[string]BackendConfig {
+	backends := map[string]BackendConfig{
+		defaultBackend: {
+			URLs:         append([]string{conf.IcingaURL}, conf.IcingaFailoverURLs...),
+			Username:     conf.IcingaUsername,
+			Password:     conf.IcingaPassword,
+			PasswordFile: conf.IcingaPasswordFile,
+			PasswordEnv:  conf.IcingaPasswordEnv,
+			InsecureTLS:  conf.IcingaInsecureTLS,
+			CertFile:     conf.IcingaCertFile,
+			KeyFile:      conf.IcingaKeyFile,
+			CAFile:       conf.IcingaCAFile,
+			ServerName:   conf.IcingaServerName,
+			EventTimeout: conf.IcingaEventTimeout,
+		},
+	}
+	for name, b := range conf.Backends {
+		if b.EventTimeout <= 0 {
+			b.EventTimeout = conf.IcingaEventTimeout
+		}
+		backends[name] = b
+	}
+	return backends
 }
 
+// validate reports an error in the credentials of the backend.
+func (b BackendConfig) validate() error {
+	if (b.CertFile == "") != (b.KeyFile == "") {
+		return errors.New("CertFile and KeyFile must be set together")
+	}
+	if b.PasswordFile != "" && b.PasswordEnv != "" {
+		return errors.New("only one of PasswordFile and PasswordEnv may be set")
+	}
+	return nil
+}
+
+var backendName = regexp.MustCompile("^[a-z0-9_-]+$")
+
 const defaultConfigPath string = "/etc/meerkat.toml"
 
 func LoadConfig(name string) (Config, error) {
@@ -44,17 +196,55 @@ func LoadConfig(name string) (Config, error) {
 	if conf.IcingaURL == "" {
 		conf.IcingaURL = "https://127.0.0.1:5665"
 	}
-	if conf.IcingaUsername == "" {
-		conf.IcingaUsername = "meerkat"
-	}
-	if conf.IcingaPassword == "" {
-		conf.IcingaPassword = "meerkat"
+	// Icinga authenticates clients presenting a certificate by it.
+	if conf.IcingaCertFile == "" {
+		if conf.IcingaUsername == "" {
+			conf.IcingaUsername = "meerkat"
+		}
+		if conf.IcingaPassword == "" && conf.IcingaPasswordFile == "" && conf.IcingaPasswordEnv == "" {
+			conf.IcingaPassword = "meerkat"
+		}
 	}
 
 	if conf.IcingaEventTimeout == 0 {
 		conf.IcingaEventTimeout = 30
 	}
+	for name, b := range conf.Backends {
+		if name == defaultBackend || !backendName.MatchString(name) {
+			return conf, fmt.Errorf("invalid backend name %q", name)
+		}
+		if len(b.URLs) == 0 {
+			return conf, fmt.Errorf("backend %s: no URLs", name)
+		}
+		switch b.Type {
+		case "", typeIcinga, typePrometheus:
+		default:
+			return conf, fmt.Errorf("backend %s: unknown type %q", name, b.Type)
+		}
+	}
+	for name, b := range conf.backendConfigs() {
+		if err := b.validate(); err != nil {
+			return conf, fmt.Errorf("backend %s: %w", name, err)
+		}
+	}
 
+	if conf.Storage.Type == "" {
+		conf.Storage.Type = "filesystem"
+	}
+	if conf.Storage.SQLitePath == "" {
+		conf.Storage.SQLitePath = "meerkat.db"
+	}
+
+	if conf.Archive.Interval <= 0 {
+		conf.Archive.Interval = 60
+	}
+	if conf.Archive.RetentionDays <= 0 {
+		conf.Archive.RetentionDays = 30
+	}
+
+	if conf.DataDirectory == "" {
+		conf.DataDirectory = "."
+	}
 	if conf.LogDirectory == "" {
 		conf.LogDirectory = "log/"
 	}
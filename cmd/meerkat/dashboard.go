@@ -2,7 +2,6 @@ package main
 
 import (
 	"bytes"
-	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,11 +12,10 @@ import (
 	"io/fs"
 	"log"
 	"net/http"
-	"net/url"
 	"os"
 	"path"
+	"path/filepath"
 	"reflect"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -43,30 +41,41 @@ type Dashboard struct {
 	Folder          string        `json:"folder"`
 	CurrentlyOpenBy []string      `json:"currently_open_by"`
 	Order           meerkat.Order `json:"order"`
+	// Template is the slug of the template the dashboard is
+	// an instance of, if any.
+	Template string `json:"template,omitempty"`
+	// Backend names the backend the dashboard's objects
+	// are from. Empty means the default one.
+	Backend string `json:"backend,omitempty"`
 }
 
 type Status struct {
 	Meerkat struct {
 		StartTime int64 `json:"start_time"`
 	} `json:"meerkat"`
-	Backends struct {
-		Icinga struct {
-			Type          string `json:"type"`
-			Status        string `json:"status"`
-			StatusMessage string `json:"status_message"`
-			Connections   struct {
-				APICalls struct {
-					RecentRequestCount int        `json:"recent_request_count"`
-					RecentHistory      []Requests `json:"recent_history"`
-				} `json:"api_calls"`
-				EventStreams struct {
-					LastEventReceived  int      `json:"last_event_received"`
-					ReceivedEventCount int      `json:"received_count_1min"`
-					RecentHistory      []Events `json:"recent_history"`
-				} `json:"event_streams"`
-			} `json:"connections"`
-		} `json:"icinga"`
-	} `json:"backends"`
+	// Backends holds the status of each backend by name.
+	Backends map[string]BackendStatus `json:"backends"`
+}
+
+type BackendStatus struct {
+	Type          string `json:"type"`
+	Status        string `json:"status"`
+	StatusMessage string `json:"status_message"`
+	// Endpoints are the URLs of the backend's API, in the order they are tried.
+	Endpoints []string `json:"endpoints"`
+	// ActiveEndpoint is the URL requests are currently sent to.
+	ActiveEndpoint string `json:"active_endpoint"`
+	Connections    struct {
+		APICalls struct {
+			RecentRequestCount int        `json:"recent_request_count"`
+			RecentHistory      []Requests `json:"recent_history"`
+		} `json:"api_calls"`
+		EventStreams struct {
+			LastEventReceived  int      `json:"last_event_received"`
+			ReceivedEventCount int      `json:"received_count_1min"`
+			RecentHistory      []Events `json:"recent_history"`
+		} `json:"event_streams"`
+	} `json:"connections"`
 }
 
 func UpdateHandler(w http.ResponseWriter, req *http.Request) {
@@ -85,13 +94,6 @@ func UpdateHandler(w http.ResponseWriter, req *http.Request) {
 	}
 	w.WriteHeader(200)
 }
-func SetWorking() {
-	server.Publish("updates", &sse.Event{
-		Data: []byte("icinga-success"),
-	})
-	status.Backends.Icinga.Status = "working"
-}
-
 func UpdateAll() {
 	log.Println("Updating all meerkat dashboards")
 	server.Publish("updates", &sse.Event{
@@ -99,14 +101,6 @@ func UpdateAll() {
 	})
 }
 
-func SendError() {
-	log.Println("Sending icinga backend error to clients.")
-	server.Publish("updates", &sse.Event{
-		Data: []byte("icinga-error"),
-	})
-	status.Backends.Icinga.Status = "failed"
-}
-
 func SendHeartbeat() {
 	server.Publish("updates", &sse.Event{
 		Data: []byte("heartbeat"),
@@ -115,139 +109,215 @@ func SendHeartbeat() {
 
 func handleListDashboard(w http.ResponseWriter, r *http.Request) {
 	slug := chi.URLParam(r, "slug")
-	http.ServeFile(w, r, path.Join("dashboards", slug+".json"))
+	dashboard, err := meerkat.LoadDashboard(store, slug)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, "read dashboard: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !canView(r, dashboard.Folder) {
+		denied(w, r)
+		return
+	}
+	b, err := store.Get(meerkat.DashboardKey(slug))
+	if err != nil {
+		http.Error(w, "read dashboard: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", meerkat.ETag(b))
+	http.ServeContent(w, r, slug+".json", time.Time{}, bytes.NewReader(b))
 }
 
+// handleCreateDashboard creates a dashboard from a form.
+// It predates apiCreateDashboard, which should be used instead.
 func handleCreateDashboard(w http.ResponseWriter, req *http.Request) {
 	if err := req.ParseForm(); err != nil {
-		msg := fmt.Sprintf("parse form: %v", err)
-		log.Println(msg)
-		http.Error(w, msg, http.StatusBadRequest)
+		legacyError(w, req, badRequest("parse form: %v", err))
 		return
 	}
 	dashboard, err := meerkat.ParseDashboardForm(req.PostForm)
 	if err != nil {
-		msg := fmt.Sprintf("parse dashboard from form: %v", err)
-		log.Println(msg)
-		http.Error(w, msg, http.StatusBadRequest)
+		legacyError(w, req, badRequest("parse dashboard from form: %v", err))
 		return
 	}
-	fpath := path.Join("dashboards", dashboard.Slug+".json")
-	if err := meerkat.CreateDashboard(fpath, &dashboard); err != nil {
-		msg := fmt.Sprintf("create dashboard %s: %v", dashboard.Slug, err)
-		log.Println(msg)
-		http.Error(w, msg, http.StatusInternalServerError)
+	if _, err := createDashboard(req, &dashboard, "Created dashboard"); err != nil {
+		legacyError(w, req, err)
 		return
 	}
-	updateDashboardCache(dashboard.Slug)
-	log.Printf("Created dashboard %s\n", fpath)
 	u := path.Join("/", dashboard.Slug, "edit")
 	http.Redirect(w, req, u, http.StatusFound)
 }
 
+// handleCloneDashboard clones the dashboard named in the src form field,
+// or creates an instance of it if the instance field is true.
+// It predates apiCloneDashboard, which should be used instead.
 func handleCloneDashboard(w http.ResponseWriter, req *http.Request) {
 	if err := req.ParseForm(); err != nil {
-		msg := fmt.Sprintf("parse form: %v", err)
-		log.Println(msg)
-		http.Error(w, msg, http.StatusBadRequest)
+		legacyError(w, req, badRequest("parse form: %v", err))
 		return
 	}
-	if req.PostForm.Get("title") == "" {
-		http.Error(w, "empty title", http.StatusBadRequest)
+	if req.PostForm.Get("src") == "" {
+		legacyError(w, req, badRequest("missing source dashboard slug"))
 		return
-	} else if req.PostForm.Get("src") == "" {
-		http.Error(w, "missing source dashboard slug", http.StatusBadRequest)
+	}
+	var slug string
+	var err error
+	if req.PostForm.Get("instance") == "true" {
+		var vars map[string]string
+		vars, err = meerkat.ParseVariables(req.PostForm.Get("variables"))
+		if err != nil {
+			legacyError(w, req, badRequest("%v", err))
+			return
+		}
+		slug, _, err = instantiateDashboard(req, req.PostForm.Get("src"), req.PostForm.Get("title"), vars)
+	} else {
+		slug, _, err = cloneDashboard(req, req.PostForm.Get("src"), req.PostForm.Get("title"))
+	}
+	if err != nil {
+		legacyError(w, req, err)
 		return
 	}
+	http.Redirect(w, req, path.Join("/", slug, "edit"), http.StatusFound)
+}
 
-	srcPath := path.Join("dashboards", req.PostForm.Get("src")+".json")
-	src, err := meerkat.ReadDashboard(srcPath)
+// handleEditInfo changes the dashboard's title, folder and other
+// settings shown on its info page to those in the submitted form.
+// Changing the title renames the dashboard; see renameDashboard.
+func handleEditInfo(w http.ResponseWriter, req *http.Request) {
+	slug := chi.URLParam(req, "slug")
+	if err := req.ParseForm(); err != nil {
+		legacyError(w, req, badRequest("parse form: %v", err))
+		return
+	}
+	info, err := meerkat.ParseDashboardForm(req.PostForm)
 	if err != nil {
-		msg := fmt.Sprintf("read source dashboard from %s: %v", srcPath, err)
-		log.Println(msg)
-		http.Error(w, msg, http.StatusInternalServerError)
+		legacyError(w, req, badRequest("parse dashboard form: %v", err))
 		return
 	}
-	dest := src
-	dest.Title = req.PostForm.Get("title")
-	dest.Slug = meerkat.TitleToSlug(dest.Title)
-	destPath := path.Join("dashboards", dest.Slug+".json")
-
-	if err := meerkat.CreateDashboard(destPath, &dest); err != nil {
-		msg := fmt.Sprintf("create dashboard from %s: %v", srcPath, err)
-		log.Println(msg)
-		http.Error(w, msg, http.StatusInternalServerError)
+	edit := func(d *meerkat.Dashboard) error {
+		d.Title = info.Title
+		d.Folder = info.Folder
+		d.Backend = info.Backend
+		d.Background = info.Background
+		d.Description = info.Description
+		d.GlobalMute = info.GlobalMute
+		d.OkSound = info.OkSound
+		d.WarningSound = info.WarningSound
+		d.CriticalSound = info.CriticalSound
+		d.UnknownSound = info.UnknownSound
+		d.UpSound = info.UpSound
+		d.DownSound = info.DownSound
+		d.Order = info.Order
+		d.Variables = info.Variables
+		return nil
+	}
+	if info.Slug == slug {
+		_, err = modifyDashboard(req, slug, "", "Edited dashboard info", edit)
+	} else {
+		err = renameDashboard(req, slug, "Edited dashboard info", edit)
+	}
+	if err != nil {
+		legacyError(w, req, err)
 		return
 	}
-	updateDashboardCache(dest.Slug)
-	log.Printf("Cloned dashboard %s\n", destPath)
-	new := path.Join("/", dest.Slug, "edit")
-	next := http.RedirectHandler(new, http.StatusFound)
-	next.ServeHTTP(w, req)
+	if info.Slug != slug {
+		notifyViewers(slug)
+	}
+	log.Printf("Dashboard info updated %s\n", info.Title)
+	http.Redirect(w, req, path.Join("/", info.Slug, "update"), http.StatusFound)
 }
 
+// handleUpdateDashboard replaces a dashboard with the one in the request body.
+// It predates apiReplaceDashboard, which should be used instead.
 func handleUpdateDashboard(w http.ResponseWriter, r *http.Request) {
 	slug := chi.URLParam(r, "slug")
-
-	_, err := meerkat.ReadDashboard(path.Join("dashboards", slug+".json"))
-	if errors.Is(err, fs.ErrNotExist) {
-		http.NotFound(w, r)
-		return
-	}
-
 	var dashboard meerkat.Dashboard
 	defer r.Body.Close()
 	if err := json.NewDecoder(r.Body).Decode(&dashboard); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if dashboard.Background != "" {
-		width, height, err := imageDimensions(dashboard.Background)
-		if err != nil {
-			msg := fmt.Sprintf("read background image %s dimensions: %v", dashboard.Background, err)
-			log.Println(msg)
-			http.Error(w, msg, http.StatusBadRequest)
-			return
+	b, err := replaceDashboard(r, slug, &dashboard, r.Header.Get("If-Match"), revisionMessage(r, "Edited dashboard"))
+	if err != nil {
+		legacyError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", meerkat.ETag(b))
+}
+
+// saveLock serialises checking a dashboard's ETag and saving it,
+// so that concurrent updates cannot both match the same version.
+var saveLock sync.Mutex
+
+// etagMatches reports whether the If-Match header value match
+// includes etag. Weak tags never match, as required by RFC 9110.
+func etagMatches(match, etag string) bool {
+	if strings.TrimSpace(match) == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(match, ",") {
+		if strings.TrimSpace(tag) == etag {
+			return true
 		}
-		dashboard.Height = strconv.Itoa(height)
-		dashboard.Width = strconv.Itoa(width)
 	}
+	return false
+}
 
-	err = meerkat.CreateDashboard(path.Join("dashboards", slug+".json"), &dashboard)
+// saveDashboard stores dashboard under slug, then records it in
+// the dashboard's history as a revision by the user making req.
+// It returns the dashboard as stored.
+// Failing to record the revision is logged but not returned, as the
+// dashboard itself has been saved.
+func saveDashboard(req *http.Request, slug string, dashboard *meerkat.Dashboard, message string) ([]byte, error) {
+	b, err := meerkat.SaveDashboard(store, slug, dashboard)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, err
+	}
+	var author string
+	if u := meerkat.UserFromContext(req.Context()); u != nil {
+		author = u.Name
+	}
+	if _, err := history.Record(slug, dashboard, author, message); err != nil {
+		log.Printf("record revision of %s: %v", slug, err)
+	}
+	return b, nil
+}
+
+// invalidDashboard responds with the fields of a dashboard which
+// failed validation, as reported by err.
+func invalidDashboard(w http.ResponseWriter, err error) {
+	var fields meerkat.ValidationError
+	if !errors.As(err, &fields) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
 		return
 	}
-	updateDashboardCache(slug)
-	log.Printf("Updated dashboard %s\n", path.Join("dashboards", slug+".json"))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	body := struct {
+		Error  string               `json:"error"`
+		Fields []meerkat.FieldError `json:"fields"`
+	}{"invalid dashboard", fields}
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Println("encode response:", err)
+	}
 }
 
+// handleDeleteDashboard deletes a dashboard then redirects to the index.
+// It predates apiDeleteDashboard, which should be used instead.
 func handleDeleteDashboard(w http.ResponseWriter, req *http.Request) {
-	mapLock.Lock()
-	defer mapLock.Unlock()
-	slug, _ := path.Split(req.URL.Path)
-	slug = path.Clean(slug)
-	fname := path.Join("dashboards", slug+".json")
-	err := os.Remove(fname)
-	if errors.Is(err, fs.ErrNotExist) {
-		http.Error(w, "no such dashboard "+fname, http.StatusNotFound)
-		return
-	} else if err != nil {
-		http.Error(w, "remove dashboard: "+err.Error(), http.StatusInternalServerError)
+	if err := deleteDashboard(req, chi.URLParam(req, "slug")); err != nil {
+		legacyError(w, req, err)
 		return
 	}
-
-	cache.Del(slug)
-	server.RemoveStream(slug)
-	delete(dashboardCache, slug)
-	log.Printf("Deleted dashboard %s\n", fname)
 	http.RedirectHandler("/", http.StatusFound).ServeHTTP(w, req)
 }
 
 func imageDimensions(ref string) (width, height int, err error) {
 	if strings.HasPrefix(ref, "/dashboards-background") {
-		// trim leading "/", files on disk are at "dashboards-data/example.png"
-		return imageDimensionsFile(strings.TrimPrefix(ref, "/"))
+		// trim leading "/", files on disk are at "<data>/dashboards-background/example.png"
+		return imageDimensionsFile(dataPath(filepath.FromSlash(strings.TrimPrefix(ref, "/"))))
 	}
 	return imageDimensionsURL(ref)
 }
@@ -313,44 +383,25 @@ type Result struct {
 	Name    string `json:"name"`
 	Type    string `json:"type"`
 	Element string `json:"element"`
+	// ElementID identifies the element an event is for,
+	// as two elements may show the same object.
+	ElementID string `json:"element_id,omitempty"`
 }
 
 type ObjectResults struct {
 	Results []Result `json:"results"`
 }
 
-type ErrorPage struct {
-	Error  int    `json:"error"`
-	Status string `json:"status"`
-}
-
 /*
-Converts an event object from icinga event stream into a regular icinga request object to be sent back to dashboard.
+Converts an event object from a backend's event stream into a regular request object to be sent back to dashboard.
 */
-func eventToRequest(event Event, objectName string, objectType string, elementName string) Result {
-	ack := 0
-	if event.Acknowledgement {
-		ack = 1
-	}
-
-	return Result{
-		Attrs: Attr{
-			Name:            objectName,
-			Acknowledgement: ack,
-			LastCheckResults: LastCheckResult{
-				Output:          event.CheckResult.Output,
-				PerformanceData: event.CheckResult.PerformanceData,
-				State:           event.CheckResult.State,
-				Type:            objectType,
-			},
-			State:     event.CheckResult.State,
-			StateType: event.CheckResult.VarsAfter.StateType,
-			Type:      objectType,
-		},
-		Name:    objectName,
-		Type:    objectType,
-		Element: elementName,
-	}
+func eventToRequest(event meerkat.Event, objectType string, elementName string) Result {
+	result := objectResult(event.Object)
+	result.Attrs.LastCheckResults.Type = objectType
+	result.Attrs.Type = objectType
+	result.Type = objectType
+	result.Element = elementName
+	return result
 }
 
 func getWorstObject(objects ObjectResults, dashboard Dashboard) Result {
@@ -366,10 +417,18 @@ func getWorstObject(objects ObjectResults, dashboard Dashboard) Result {
 	return worst
 }
 
+// querySlug returns the slug of the dashboard objects are requested for.
+// The title of the request is the path of the page making it,
+// as in "/my-network/view".
+func querySlug(req *http.Request) string {
+	slug, _, _ := strings.Cut(strings.TrimPrefix(req.URL.Query().Get("title"), "/"), "/")
+	return slug
+}
+
 /*
-getObjectHandler handles the requests to icinga to get the object data.
+getObjectHandler handles the requests to backends to get the object data.
 First it checks if the object is in the cache, if it is it returns the cached object.
-Otherwise it makes a request to the icinga API to get the object data.
+Otherwise it makes a request to the backend to get the object data.
 */
 func getObjectHandler(w http.ResponseWriter, r *http.Request) {
 	objectType := r.URL.Query().Get("type")
@@ -378,38 +437,54 @@ func getObjectHandler(w http.ResponseWriter, r *http.Request) {
 
 	dashboardTitle := r.URL.Query().Get("title")
 
-	requestURL := "/v1/objects/" + objectType
-	params := url.Values{}
+	query := meerkat.ObjectQuery{Type: objectType, Name: objectName, Filter: objectFilter}
 
 	name := ""
 
 	if objectName != "" {
-		params.Set(objectType, objectName)
 		name = objectName
 	}
 
 	if objectFilter != "" {
-		params.Set("filter", objectFilter)
 		name = objectFilter
 	}
 
-	slug := strings.Split(dashboardTitle, "/")[1]
+	slug := querySlug(r)
+	// Views of a dashboard with variables given in the query string
+	// have state of their own; see watchView.
+	if stream := r.URL.Query().Get("stream"); strings.HasPrefix(stream, slug+"?") {
+		slug = stream
+	}
+	if _, ok := dashboardSync.Load(slug); !ok {
+		http.Error(w, "no dashboard "+slug, http.StatusNotFound)
+		return
+	}
+	if !canViewCached(r, slug) {
+		denied(w, r)
+		return
+	}
+	backendName := r.URL.Query().Get("backend")
+	if backendName == "" {
+		backendName = dashboardBackend(slug)
+	}
+	b, err := lookupBackend(backendName)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
 	isCached := false
 	cachedResults := []Result{}
 
-	mapLock.RLock()
-	dashboardCacheCopy := dashboardCache
-	mapLock.RUnlock()
 	var worstObject Result
-	for _, element := range dashboardCacheCopy[slug] {
+	for _, element := range cachedElements(slug) {
 		if element.Name == name && len(element.Name) != 0 {
 
-			if !strings.Contains(objectType, element.Type) {
+			if !strings.Contains(objectType, element.Type) || element.backend() != b.name {
 				continue
 			}
 
 			for _, objectName := range element.Objects {
-				objectCache, found := cache.Get(objectName)
+				objectCache, found := cache.Get(objectKey(b.name, objectName))
 				if found {
 					object := objectCache.(Result)
 					object.Element = name
@@ -438,7 +513,7 @@ func getObjectHandler(w http.ResponseWriter, r *http.Request) {
 			Results: cachedResults,
 		}
 
-		b, err := json.Marshal(objects)
+		body, err := json.Marshal(objects)
 		if err != nil {
 			log.Printf("Error: %s\n", err)
 			return
@@ -448,115 +523,119 @@ func getObjectHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		w.Header().Set("content-type", "application/json")
 		w.Header().Set("x-meercat-cache", "HIT")
-		w.Write(b)
+		w.Write(body)
 	} else {
-		requestURL = requestURL + "?" + strings.ReplaceAll(params.Encode(), "+", "%20")
-
-		response, err := icingaRequest(requestURL, dashboardTitle)
-		if err != nil {
+		objects, err := b.objects(r.Context(), query, dashboardTitle)
+		var e *meerkat.BackendError
+		if errors.As(err, &e) {
+			handleError(w, e, dashboardTitle)
+			return
+		} else if err != nil {
 			log.Println("Error getting response:", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
-		w.WriteHeader(response.StatusCode)
 		w.Header().Set("content-type", "application/json")
 		w.Header().Set("x-meercat-cache", "MISS")
-		dec := json.NewDecoder(response.Body)
-		defer response.Body.Close()
-
-		if response.StatusCode == 200 {
-			var objects ObjectResults
-			err := dec.Decode(&objects)
-			if err != nil {
-				log.Println("Failed to decode response:", err)
-			}
-			worst := Result{}
+		results := make([]Result, len(objects))
+		for i, obj := range objects {
+			results[i] = objectResult(obj)
+		}
+		worst := Result{}
 
-			dashboard, ok := dashboardSync.Load(slug)
-			if ok {
-				d := dashboard.(Dashboard)
-				worst = getWorstObject(objects, d)
-			}
+		dashboard, ok := dashboardSync.Load(slug)
+		if ok {
+			d := dashboard.(Dashboard)
+			worst = getWorstObject(ObjectResults{Results: results}, d)
+		}
 
-			worstObjects := ObjectResults{
-				Results: []Result{worst},
-			}
-			if worst == (Result{}) {
-				worstObjects.Results = []Result{}
-			}
-			b, err := json.Marshal(worstObjects)
-			if err != nil {
-				log.Printf("Error: %s\n", err)
-				return
-			}
+		worstObjects := ObjectResults{
+			Results: []Result{worst},
+		}
+		if worst == (Result{}) {
+			worstObjects.Results = []Result{}
+		}
+		body, err := json.Marshal(worstObjects)
+		if err != nil {
+			log.Printf("Error: %s\n", err)
+			return
+		}
 
-			if len(name) != 0 {
-				mapLock.Lock()
-				for i, elementStore := range dashboardCache[slug] {
-					elementName := name
-					if elementStore.Type == "hostgroup" {
-						elementName = strings.TrimSuffix(objectFilter, "\" in host.groups")
-						elementName = strings.TrimPrefix(elementName, "\"")
-					} else if elementStore.Type == "servicegroup" {
-						elementName = strings.TrimSuffix(objectFilter, "\" in service.groups")
-						elementName = strings.TrimPrefix(elementName, "\"")
-					}
-					if elementStore.Name == elementName {
-						for _, result := range objects.Results {
-							if (strings.Contains(elementStore.Type, "host") && strings.Contains(result.Attrs.Name, "!")) || (strings.Contains(elementStore.Type, "service") && !strings.Contains(result.Attrs.Name, "!")) {
-								continue
-							}
-
-							cache.Set(result.Attrs.Name, result, 1)
-							cache.Wait()
-							if !slices.Contains(dashboardCache[slug][i].Objects, result.Attrs.Name) {
-								dashboardCache[slug][i].Objects = append(dashboardCache[slug][i].Objects, result.Attrs.Name)
-							}
+		if len(name) != 0 {
+			mapLock.Lock()
+			for id, elementStore := range dashboardCache[slug] {
+				if elementStore.backend() != b.name {
+					continue
+				}
+				elementName := name
+				if elementStore.Type == "hostgroup" {
+					elementName = strings.TrimSuffix(objectFilter, "\" in host.groups")
+					elementName = strings.TrimPrefix(elementName, "\"")
+				} else if elementStore.Type == "servicegroup" {
+					elementName = strings.TrimSuffix(objectFilter, "\" in service.groups")
+					elementName = strings.TrimPrefix(elementName, "\"")
+				}
+				if elementStore.Name == elementName {
+					for _, result := range results {
+						if (strings.Contains(elementStore.Type, "host") && strings.Contains(result.Attrs.Name, "!")) || (strings.Contains(elementStore.Type, "service") && !strings.Contains(result.Attrs.Name, "!")) {
+							continue
+						}
+
+						cache.Set(objectKey(b.name, result.Attrs.Name), result, 1)
+						cache.Wait()
+						if !slices.Contains(elementStore.Objects, result.Attrs.Name) {
+							elementStore.Objects = append(elementStore.Objects, result.Attrs.Name)
 						}
 					}
+					dashboardCache[slug][id] = elementStore
 				}
-				mapLock.Unlock()
 			}
-
-			w.Write(b)
-		} else {
-			handleError(w, dec, dashboardTitle)
+			mapLock.Unlock()
 		}
+
+		w.Write(body)
 	}
 }
 
-func handleError(w http.ResponseWriter, dec *json.Decoder, dashboardTitle string) {
-	var errorPage ErrorPage
-	err := dec.Decode(&errorPage)
-	if errorPage.Error >= 500 || errorPage.Error == 401 || errorPage.Error == 403 {
-		log.Printf("Bad response from icinga: %s %v %s", dashboardTitle, errorPage.Error, errorPage.Status)
+// handleError passes on an error response from a backend
+// to a request made for the dashboard at dashboardTitle.
+func handleError(w http.ResponseWriter, e *meerkat.BackendError, dashboardTitle string) {
+	if e.StatusCode >= 500 || e.StatusCode == 401 || e.StatusCode == 403 {
+		log.Printf("Bad response from backend: %s %v %s", dashboardTitle, e.StatusCode, e.Status)
 	}
-	if err != nil {
-		log.Println("Failed to decode response:", err)
-	}
-	b, err := json.Marshal(errorPage)
+	b, err := json.Marshal(e)
 	if err != nil {
 		log.Printf("Error: %s\n", err)
 		return
 	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(e.StatusCode)
 	w.Write(b)
 }
 
-type RespProp struct {
-	Type      string `json:"type"`
-	Title     string `json:"title"`
-	Default   string `json:"default,omitempty"`
-	MinLength int    `json:"minLength,omitempty"`
+// getSchemaHandler serves the JSON Schema of dashboards.
+// Only the schema of the current version is available.
+func getSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	if v := chi.URLParam(r, "version"); v != "" && v != fmt.Sprintf("v%d", meerkat.SchemaVersion) {
+		http.Error(w, fmt.Sprintf("no schema for version %s; the current version is v%d", v, meerkat.SchemaVersion), http.StatusNotFound)
+		return
+	}
+	b, err := json.MarshalIndent(meerkat.DashboardSchema(), "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write(b)
 }
 
 func getStatusHandler(w http.ResponseWriter, r *http.Request) {
-	status.Backends.Icinga.Connections.APICalls.RecentRequestCount = len(requestList)
-	status.Backends.Icinga.Connections.APICalls.RecentHistory = requestList
-
-	events := getEvents()
-	status.Backends.Icinga.Connections.EventStreams.ReceivedEventCount = len(events)
-	status.Backends.Icinga.Connections.EventStreams.RecentHistory = events
+	status := status
+	status.Backends = make(map[string]BackendStatus, len(backends))
+	for name, b := range backends {
+		status.Backends[name] = b.report()
+	}
 
 	response := make(map[string]interface{})
 	v := reflect.ValueOf(status)
@@ -590,47 +669,46 @@ func getStatusHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// getCacheDashboardHandler serves the cached elements of a dashboard,
+// keyed by element ID.
 func getCacheDashboardHandler(w http.ResponseWriter, r *http.Request) {
-	paths := strings.Split(r.URL.Path, "/")
-	if len(paths) >= 3 {
-		slug := paths[3]
-		mapLock.RLock()
-		dashboardCacheCopy := dashboardCache
-		mapLock.RUnlock()
-
-		body, err := json.Marshal(dashboardCacheCopy[slug])
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-
-		if _, err := io.Copy(w, bytes.NewReader(body)); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-	} else {
-		http.Error(w, "Invalid path", http.StatusInternalServerError)
+	slug := chi.URLParam(r, "slug")
+	if _, ok := dashboardSync.Load(slug); ok && !canViewCached(r, slug) {
+		denied(w, r)
 		return
 	}
-}
-
-func getCacheHandler(w http.ResponseWriter, r *http.Request) {
 	mapLock.RLock()
-	dashboardCacheCopy := dashboardCache
+	elements, ok := dashboardCache[slug]
+	body, err := json.Marshal(elements)
 	mapLock.RUnlock()
-
-	body, err := json.Marshal(dashboardCacheCopy)
+	if !ok {
+		http.Error(w, "no cached dashboard "+slug, http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
 
-	if _, err := io.Copy(w, bytes.NewReader(body)); err != nil {
+func getCacheHandler(w http.ResponseWriter, r *http.Request) {
+	visible := make(map[string]map[string]ElementStore)
+	mapLock.RLock()
+	for key, elements := range dashboardCache {
+		if canViewCached(r, key) {
+			visible[key] = elements
+		}
+	}
+	body, err := json.Marshal(visible)
+	mapLock.RUnlock()
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
 }
 
 func clearCacheHandler(w http.ResponseWriter, r *http.Request) {
@@ -652,21 +730,56 @@ func clearCacheHandler(w http.ResponseWriter, r *http.Request) {
 func getAllHandler(w http.ResponseWriter, r *http.Request) {
 	objectType := r.URL.Query().Get("type")
 	dashboardTitle := r.URL.Query().Get("title")
-	response, err := icingaRequest("/v1/objects/"+objectType+"?attrs=name", dashboardTitle)
+	// Listing objects is for choosing what the elements
+	// of a dashboard show, so only its editors may.
+	slug := querySlug(r)
+	d, ok := dashboardSync.Load(slug)
+	if !ok {
+		http.Error(w, "no dashboard "+slug, http.StatusNotFound)
+		return
+	}
+	if !canEdit(r, d.(Dashboard).Folder) {
+		denied(w, r)
+		return
+	}
+	backendName := r.URL.Query().Get("backend")
+	if backendName == "" {
+		backendName = dashboardBackend(slug)
+	}
+	b, err := lookupBackend(backendName)
 	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	objects, err := b.objects(r.Context(), meerkat.ObjectQuery{Type: objectType, NamesOnly: true}, dashboardTitle)
+	var e *meerkat.BackendError
+	if errors.As(err, &e) {
+		handleError(w, e, dashboardTitle)
+		return
+	} else if err != nil {
 		log.Println("Error getting response:", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	defer response.Body.Close()
-	body, err := io.ReadAll(response.Body)
+	body, err := json.Marshal(map[string][]meerkat.Object{"results": objects})
 	if err != nil {
-		log.Println("Error reading response:", err)
+		log.Printf("Error: %s\n", err)
+		return
 	}
 	w.Header().Set("content-type", "application/json")
 	w.Write(body)
 }
 
+// getBackendsHandler serves the names of the backends
+// dashboards and elements may choose other than the default one.
+func getBackendsHandler(w http.ResponseWriter, r *http.Request) {
+	names := backendNames()
+	if names == nil {
+		names = []string{}
+	}
+	writeJSON(w, names)
+}
+
 // swapPath takes a file path to a dashboard from a previous Meerkat
 // release and returns a path in the newer format.
 // For example given the old path "/view/my-network", the new path is "/my-network/view".
@@ -674,108 +787,3 @@ func swapPath(old string) string {
 	new := path.Join("/", path.Base(old), path.Dir(old))
 	return new
 }
-
-type StatusCheck struct {
-	Results []struct {
-		Name     string `json:"name"`
-		Perfdata []any  `json:"perfdata"`
-		Status   struct {
-			IcingaApplication struct {
-				App struct {
-					EnableEventHandlers bool    `json:"enable_event_handlers"`
-					EnableFlapping      bool    `json:"enable_flapping"`
-					EnableHostChecks    bool    `json:"enable_host_checks"`
-					EnableNotifications bool    `json:"enable_notifications"`
-					EnablePerfdata      bool    `json:"enable_perfdata"`
-					EnableServiceChecks bool    `json:"enable_service_checks"`
-					Environment         string  `json:"environment"`
-					NodeName            string  `json:"node_name"`
-					Pid                 int     `json:"pid"`
-					ProgramStart        float64 `json:"program_start"`
-				} `json:"app"`
-			} `json:"icingaapplication"`
-		} `json:"status"`
-	} `json:"results"`
-}
-
-func addRequest(request Requests) {
-	if len(requestList) >= 100 {
-		requestList = requestList[1:]
-	}
-
-	requestList = append(requestList, request)
-}
-
-/*
-Makes a authenticated request to the icinga api and returns the response.
-*/
-func icingaRequest(apiPath string, dashboardTitle string) (*http.Response, error) {
-	client := &http.Client{}
-	if config.IcingaInsecureTLS {
-		client.Transport = &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
-	}
-	icingaURL, err := url.Parse(config.IcingaURL)
-	if err != nil {
-		log.Println("Failed to parse IcingaURL:", err)
-		return nil, err
-	}
-
-	pathURL, err := url.Parse(apiPath)
-	if err != nil {
-		log.Println("Failed to parse API Path:", err)
-		return nil, err
-	}
-	if config.IcingaDebug {
-		icingaLog.Printf("Requesting %s for %s\n", icingaURL.ResolveReference(pathURL).String(), dashboardTitle)
-	}
-	req, err := http.NewRequest("GET", icingaURL.ResolveReference(pathURL).String(), nil)
-	req.Header.Set("accept", "application/json")
-	req.SetBasicAuth(config.IcingaUsername, config.IcingaPassword)
-	if err != nil {
-		log.Println("Failed to create request:", err)
-		addRequest(Requests{CallMade: apiPath, CallTime: time.Now().UnixMilli(), Dashboard: dashboardTitle, StatusCode: 0})
-		return nil, err
-	}
-
-	res, err := client.Do(req)
-
-	if err != nil {
-		log.Println("Icinga2 API error:", err)
-		addRequest(Requests{CallMade: apiPath, CallTime: time.Now().UnixMilli(), Dashboard: dashboardTitle, StatusCode: 0})
-		return nil, err
-	}
-
-	addRequest(Requests{CallMade: apiPath, CallTime: time.Now().UnixMilli(), Dashboard: dashboardTitle, StatusCode: res.StatusCode})
-
-	return res, nil
-}
-
-/*
-Checks the status of the Icinga application used to check if icinga is running.
-*/
-func checkProgramStart() float64 {
-	var statusCheck StatusCheck
-	response, err := icingaRequest("/v1/status/IcingaApplication", config.HTTPAddr)
-	if err != nil {
-		// Handle error (for example, by logging it and returning a default value)
-		log.Println("Failed to make request:", err)
-		return 0
-	}
-	defer response.Body.Close()
-	b, err := io.ReadAll(response.Body)
-	if err != nil {
-		log.Println("Failed to read response:", err)
-		return 0
-	}
-	err = json.Unmarshal(b, &statusCheck)
-	if err != nil {
-		log.Println("Failed to unmarshall response:", err)
-		return 0
-	}
-	for _, v := range statusCheck.Results {
-		return v.Status.IcingaApplication.App.ProgramStart
-	}
-	return 0
-}
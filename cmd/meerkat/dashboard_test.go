@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/meerkat-dashboard/meerkat"
+	"github.com/r3labs/sse/v2"
+)
+
+func TestUpdateConflict(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Mkdir("dashboards", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := meerkat.CreateDashboard("dashboards/test.json", &meerkat.Dashboard{Title: "Test"}); err != nil {
+		t.Fatal(err)
+	}
+	server = sse.New()
+	defer server.Close()
+	dashboardCache = make(map[string]map[string]ElementStore)
+
+	r := chi.NewRouter()
+	r.Use(identify)
+	r.Get("/dashboard/{slug}", handleListDashboard)
+	r.Post("/dashboard/{slug}", handleUpdateDashboard)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/dashboard/test", nil))
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag in dashboard response")
+	}
+
+	save := func(body, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/dashboard/test", strings.NewReader(body))
+		req.Header.Set("If-Match", etag)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	// Two editors opened the same version; the first save wins.
+	first := save(`{"title": "Test", "description": "first"}`, etag)
+	if first.Code != http.StatusOK {
+		t.Fatalf("first save: got status %d: %s", first.Code, first.Body)
+	}
+	second := save(`{"title": "Test", "description": "second"}`, etag)
+	if second.Code != http.StatusConflict {
+		t.Errorf("second save: got status %d, want %d", second.Code, http.StatusConflict)
+	}
+	d, err := meerkat.ReadDashboard("dashboards/test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Description != "first" {
+		t.Errorf("got description %q after conflicting saves, want %q", d.Description, "first")
+	}
+
+	third := save(`{"title": "Test", "description": "third"}`, first.Header().Get("ETag"))
+	if third.Code != http.StatusOK {
+		t.Errorf("save with current ETag: got status %d, want %d", third.Code, http.StatusOK)
+	}
+
+	invalid := save(`{"title": "Test", "elements": [{"type": "check-card", "rect": {"w": -5}}]}`, third.Header().Get("ETag"))
+	if invalid.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("save invalid dashboard: got status %d, want %d", invalid.Code, http.StatusUnprocessableEntity)
+	}
+	var body struct {
+		Fields []meerkat.FieldError
+	}
+	if err := json.NewDecoder(invalid.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	want := []meerkat.FieldError{{Element: 0, Field: "rect.w", Reason: "must be at least 0, got -5"}}
+	if !reflect.DeepEqual(body.Fields, want) {
+		t.Errorf("got field errors %+v, want %+v", body.Fields, want)
+	}
+}
+
+func TestGetSchema(t *testing.T) {
+	r := chi.NewRouter()
+	r.Get("/api/schema/dashboard", getSchemaHandler)
+	r.Get("/api/schema/dashboard/{version}", getSchemaHandler)
+	for path, code := range map[string]int{
+		"/api/schema/dashboard":    http.StatusOK,
+		"/api/schema/dashboard/v4": http.StatusOK,
+		"/api/schema/dashboard/v3": http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != code {
+			t.Errorf("%s: got status %d, want %d", path, rec.Code, code)
+			continue
+		}
+		if code != http.StatusOK {
+			continue
+		}
+		var schema meerkat.Schema
+		if err := json.NewDecoder(rec.Body).Decode(&schema); err != nil {
+			t.Errorf("%s: decode schema: %v", path, err)
+		}
+		if schema.ID != meerkat.SchemaID {
+			t.Errorf("%s: got schema %s, want %s", path, schema.ID, meerkat.SchemaID)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Directories under the data directory holding dashboards and their assets.
+const (
+	dashboardDir  = "dashboards"
+	backgroundDir = "dashboards-background"
+	soundDir      = "dashboards-sound"
+	archiveDir    = "dashboards-archive"
+)
+
+// dataPath resolves name against the configured data directory.
+// Absolute names are returned unchanged.
+func dataPath(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(config.DataDirectory, name)
+}
+
+type dirState string
+
+const (
+	dirFound   dirState = "found"
+	dirCreated dirState = "created"
+	dirMissing dirState = "missing"
+)
+
+type dirCheck struct {
+	Path  string
+	State dirState
+	// Err is set when State is dirMissing.
+	Err error
+}
+
+// checkDirs ensures each of dirs exists under root, creating those
+// which do not. Root itself is never created; if it is missing then so
+// is every directory under it. This guards against silently starting an
+// empty instance in the wrong place.
+// The returned error is non-nil if any directory is missing.
+func checkDirs(root string, dirs ...string) ([]dirCheck, error) {
+	checks := make([]dirCheck, 0, len(dirs))
+	fi, err := os.Stat(root)
+	if err == nil && !fi.IsDir() {
+		err = fmt.Errorf("%s: not a directory", root)
+	}
+	if err != nil {
+		err = fmt.Errorf("data directory: %w", err)
+		for _, dir := range dirs {
+			checks = append(checks, dirCheck{filepath.Join(root, dir), dirMissing, err})
+		}
+		return checks, err
+	}
+
+	var errs []error
+	for _, dir := range dirs {
+		p := dir
+		if !filepath.IsAbs(dir) {
+			p = filepath.Join(root, dir)
+		}
+		c := dirCheck{Path: p, State: dirFound}
+		if fi, err := os.Stat(p); errors.Is(err, fs.ErrNotExist) {
+			c.State = dirCreated
+			c.Err = os.MkdirAll(p, 0755)
+		} else if err == nil && !fi.IsDir() {
+			c.Err = fmt.Errorf("%s: not a directory", p)
+		} else {
+			c.Err = err
+		}
+		if c.Err != nil {
+			c.State = dirMissing
+			errs = append(errs, c.Err)
+		}
+		checks = append(checks, c)
+	}
+	return checks, errors.Join(errs...)
+}
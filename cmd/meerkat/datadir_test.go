@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckDirs(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, dashboardDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, soundDir), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	checks, err := checkDirs(root, dashboardDir, backgroundDir, soundDir)
+	if err == nil {
+		t.Error("nil error checking directories with a file in place of a directory")
+	}
+	want := []dirState{dirFound, dirCreated, dirMissing}
+	if len(checks) != len(want) {
+		t.Fatalf("got %d checks, want %d", len(checks), len(want))
+	}
+	for i, c := range checks {
+		if c.State != want[i] {
+			t.Errorf("%s: got state %s, want %s", c.Path, c.State, want[i])
+		}
+	}
+	if fi, err := os.Stat(filepath.Join(root, backgroundDir)); err != nil || !fi.IsDir() {
+		t.Errorf("background directory not created: %v", err)
+	}
+
+	missing := filepath.Join(root, "missing")
+	checks, err = checkDirs(missing, dashboardDir)
+	if err == nil {
+		t.Error("nil error checking missing data directory")
+	}
+	if checks[0].State != dirMissing {
+		t.Errorf("got state %s for directory in missing data directory", checks[0].State)
+	}
+	if _, err := os.Stat(missing); err == nil {
+		t.Error("missing data directory was created")
+	}
+}
+
+func TestDataPath(t *testing.T) {
+	defer func(dir string) { config.DataDirectory = dir }(config.DataDirectory)
+	config.DataDirectory = "/var/lib/meerkat"
+	tests := map[string]string{
+		"dashboards-access.json": "/var/lib/meerkat/dashboards-access.json",
+		"log/":                   "/var/lib/meerkat/log",
+		"/etc/ssl/meerkat.pem":   "/etc/ssl/meerkat.pem",
+	}
+	for name, want := range tests {
+		if got := dataPath(name); got != want {
+			t.Errorf("dataPath(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -3,7 +3,8 @@ Meerkat is a web application providing a drag-and-drop dashboard interface for I
 
 Usage:
 
-	meerkat [-v] [-config path] [-ui path]
+	meerkat [-v] [-config path] [-data path] [-ui path]
+	meerkat [-config path] [-data path] migrate [-n] [-backup dir] [-report file]
 
 The following flags are understood:
 
@@ -11,11 +12,28 @@ The following flags are understood:
 		Print build version information.
 	-config path
 		Load configuration from the file at path.
+	-data path
+		Read and write dashboards and other files in the directory
+		at path, overriding DataDirectory in the configuration file.
 	-ui path
 		Serve the user interface bundle at path from the local
 		filesystem. The default is to serve the bundle
 		embedded in the binary.
 
+The migrate command upgrades stored dashboards to the current format.
+Dashboards in older formats are otherwise upgraded as they are read,
+but are only rewritten when next saved. Its flags are:
+
+	-n
+		Report the changes which would be made without writing them.
+	-backup dir
+		Copy each dashboard to dir before rewriting it.
+	-report file
+		Write a JSON report of every dashboard and the changes made
+		to file, or to the standard output if file is "-".
+
+Each migrated dashboard is also recorded as a new revision in its history.
+
 For a full configuration file reference, see [Configuration] on the Meerkat project website.
 
 [Configuration]: https://meerkat.run/configuration.html
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/meerkat-dashboard/meerkat"
+)
+
+// elementRoutes registers the handlers for the elements of a dashboard
+// on r, which is mounted at /api/v1/dashboards/{slug}/elements.
+func elementRoutes(r chi.Router) {
+	r.Get("/", apiListElements)
+	r.Post("/", apiAddElement)
+	r.Post("/reorder", apiReorderElements)
+	r.Get("/{id}", apiGetElement)
+	r.Put("/{id}", apiReplaceElement)
+	r.Patch("/{id}", apiPatchElement)
+	r.Delete("/{id}", apiDeleteElement)
+	r.Post("/{id}/move", apiMoveElement)
+}
+
+func noElement(req *http.Request, id string) error {
+	return fmt.Errorf("no element %s in dashboard %s: %w", id, chi.URLParam(req, "slug"), fs.ErrNotExist)
+}
+
+// decodeBody decodes the JSON request body into v.
+func decodeBody(req *http.Request, v any) error {
+	b, err := readBody(req, "request")
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return badRequest("decode request: %v", err)
+	}
+	return nil
+}
+
+// changeElements applies modify to the dashboard named in req's URL,
+// then notifies viewers of the change. It returns the modified dashboard.
+func changeElements(req *http.Request, message string, modify func(d *meerkat.Dashboard) error) (*meerkat.Dashboard, error) {
+	slug := chi.URLParam(req, "slug")
+	if _, err := loadDashboard(req, slug); err != nil {
+		return nil, err
+	}
+	b, err := modifyDashboard(req, slug, req.Header.Get("If-Match"), revisionMessage(req, message), modify)
+	if err != nil {
+		return nil, err
+	}
+	notifyViewers(slug)
+	modified, _, err := meerkat.MigrateDashboard(b)
+	return &modified, err
+}
+
+func writeElements(w http.ResponseWriter, elements []meerkat.Element) {
+	if elements == nil {
+		elements = []meerkat.Element{}
+	}
+	writeJSON(w, elements)
+}
+
+func apiListElements(w http.ResponseWriter, req *http.Request) {
+	dashboard, err := loadDashboard(req, chi.URLParam(req, "slug"))
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	writeElements(w, dashboard.Elements)
+}
+
+func apiGetElement(w http.ResponseWriter, req *http.Request) {
+	slug, id := chi.URLParam(req, "slug"), chi.URLParam(req, "id")
+	dashboard, err := loadDashboard(req, slug)
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	i := dashboard.ElementIndex(id)
+	if i < 0 {
+		writeError(w, req, noElement(req, id))
+		return
+	}
+	writeJSON(w, dashboard.Elements[i])
+}
+
+// apiAddElement adds the element in the request body to a dashboard.
+// It is placed above all others, or at the position given by the
+// index parameter. Elements without an ID are assigned one.
+func apiAddElement(w http.ResponseWriter, req *http.Request) {
+	var element meerkat.Element
+	if err := decodeBody(req, &element); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	if element.ID == "" {
+		element.ID = meerkat.NewElementID()
+	}
+	d, err := changeElements(req, "Added element "+element.ID, func(d *meerkat.Dashboard) error {
+		if d.ElementIndex(element.ID) >= 0 {
+			return fmt.Errorf("element %s already exists: %w", element.ID, fs.ErrExist)
+		}
+		i := len(d.Elements)
+		if s := req.URL.Query().Get("index"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 || n > len(d.Elements) {
+				return badRequest("index %q not between 0 and %d", s, len(d.Elements))
+			}
+			i = n
+		}
+		d.Elements = append(d.Elements[:i], append([]meerkat.Element{element}, d.Elements[i:]...)...)
+		return nil
+	})
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	element = d.Elements[d.ElementIndex(element.ID)]
+	w.Header().Set("Location", path.Join(apiPrefix, chi.URLParam(req, "slug"), "elements", element.ID))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(element); err != nil {
+		log.Println("encode response:", err)
+	}
+}
+
+// apiReplaceElement replaces an element with the one in the request body.
+// The element keeps its ID.
+func apiReplaceElement(w http.ResponseWriter, req *http.Request) {
+	var element meerkat.Element
+	if err := decodeBody(req, &element); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	id := chi.URLParam(req, "id")
+	element.ID = id
+	d, err := changeElements(req, "Edited element "+id, func(d *meerkat.Dashboard) error {
+		i := d.ElementIndex(id)
+		if i < 0 {
+			return noElement(req, id)
+		}
+		d.Elements[i] = element
+		return nil
+	})
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	writeJSON(w, d.Elements[d.ElementIndex(id)])
+}
+
+// apiPatchElement changes an element with the patch in the request body,
+// as read by readPatch. The element keeps its ID.
+func apiPatchElement(w http.ResponseWriter, req *http.Request) {
+	patch, err := readPatch(req)
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	id := chi.URLParam(req, "id")
+	d, err := changeElements(req, "Edited element "+id, func(d *meerkat.Dashboard) error {
+		i := d.ElementIndex(id)
+		if i < 0 {
+			return noElement(req, id)
+		}
+		if err := patchJSON(&d.Elements[i], patch); err != nil {
+			return err
+		}
+		d.Elements[i].ID = id
+		return nil
+	})
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	writeJSON(w, d.Elements[d.ElementIndex(id)])
+}
+
+func apiDeleteElement(w http.ResponseWriter, req *http.Request) {
+	id := chi.URLParam(req, "id")
+	_, err := changeElements(req, "Deleted element "+id, func(d *meerkat.Dashboard) error {
+		i := d.ElementIndex(id)
+		if i < 0 {
+			return noElement(req, id)
+		}
+		d.Elements = append(d.Elements[:i], d.Elements[i+1:]...)
+		return nil
+	})
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiMoveElement moves an element to the position in the dashboard's
+// elements given in the request body, such as {"index": 0}.
+// Elements are drawn in order, so later elements are drawn above earlier ones.
+func apiMoveElement(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		Index *int `json:"index"`
+	}
+	if err := decodeBody(req, &body); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	if body.Index == nil {
+		writeError(w, req, badRequest("missing index"))
+		return
+	}
+	id := chi.URLParam(req, "id")
+	d, err := changeElements(req, "Moved element "+id, func(d *meerkat.Dashboard) error {
+		i := d.ElementIndex(id)
+		if i < 0 {
+			return noElement(req, id)
+		}
+		to := *body.Index
+		if to < 0 || to >= len(d.Elements) {
+			return badRequest("index %d not between 0 and %d", to, len(d.Elements)-1)
+		}
+		element := d.Elements[i]
+		d.Elements = append(d.Elements[:i], d.Elements[i+1:]...)
+		d.Elements = append(d.Elements[:to], append([]meerkat.Element{element}, d.Elements[to:]...)...)
+		return nil
+	})
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	writeElements(w, d.Elements)
+}
+
+// apiReorderElements orders a dashboard's elements by the list of IDs
+// in the request body, which must list every element exactly once.
+func apiReorderElements(w http.ResponseWriter, req *http.Request) {
+	var ids []string
+	if err := decodeBody(req, &ids); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	d, err := changeElements(req, "Reordered elements", func(d *meerkat.Dashboard) error {
+		if len(ids) != len(d.Elements) {
+			return &apiError{http.StatusConflict, fmt.Sprintf("got %d element IDs, but the dashboard has %d elements", len(ids), len(d.Elements))}
+		}
+		reordered := make([]meerkat.Element, 0, len(ids))
+		seen := make(map[string]bool)
+		for _, id := range ids {
+			i := d.ElementIndex(id)
+			if i < 0 || seen[id] {
+				return &apiError{http.StatusConflict, fmt.Sprintf("element %s is not in the dashboard or is listed twice", id)}
+			}
+			seen[id] = true
+			reordered = append(reordered, d.Elements[i])
+		}
+		d.Elements = reordered
+		return nil
+	})
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	writeElements(w, d.Elements)
+}
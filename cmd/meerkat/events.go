@@ -1,78 +1,26 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"log"
-	"net"
 	"net/http"
+	"net/url"
 	"reflect"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/meerkat-dashboard/meerkat"
 	"github.com/r3labs/sse/v2"
 )
 
-type AcknowledgementSet struct {
-	Host    string
-	Service string
-	Author  string
-	Comment string
-	Notify  bool
-	Expiry  float64
-}
-
-type AcknowledgementCleared struct {
-	Host    string
-	Service string
-}
-
-type Event struct {
-	Acknowledgement bool        `json:"acknowledgement,omitempty"`
-	CheckResult     CheckResult `json:"check_result,omitempty"`
-	DowntimeDepth   int         `json:"downtime_depth,omitempty"`
-	Host            string      `json:"host,omitempty"`
-	Service         string      `json:"service,omitempty"`
-	Timestamp       float64     `json:"timestamp,omitempty"`
-	Type            string      `json:"type,omitempty"`
-}
-
-type CheckResult struct {
-	Active            bool        `json:"active,omitempty"`
-	CheckSource       string      `json:"check_source,omitempty"`
-	Command           interface{} `json:"command,omitempty"`
-	ExecutionEnd      float64     `json:"execution_end,omitempty"`
-	ExecutionStart    float64     `json:"execution_start,omitempty"`
-	ExitStatus        json.Number `json:"exit_status,omitempty"`
-	Output            string      `json:"output,omitempty"`
-	PerformanceData   interface{} `json:"performance_data,omitempty"`
-	PreviousHardState int         `json:"previous_hard_state,omitempty"`
-	ScheduleEnd       float64     `json:"schedule_end,omitempty"`
-	ScheduleStart     float64     `json:"schedule_start,omitempty"`
-	SchedulingSource  string      `json:"scheduling_source,omitempty"`
-	State             int         `json:"state,omitempty"`
-	TTL               int         `json:"ttl,omitempty"`
-	Type              string      `json:"type,omitempty"`
-	VarsAfter         struct {
-		Attempt   json.Number `json:"attempt,omitempty"`
-		Reachable bool        `json:"reachable,omitempty"`
-		State     int         `json:"state,omitempty"`
-		StateType int         `json:"state_type,omitempty"`
-	} `json:"vars_after,omitempty"`
-	VarsBefore *struct {
-		Attempt   json.Number `json:"attempt,omitempty"`
-		Reachable bool        `json:"reachable,omitempty"`
-		State     int         `json:"state,omitempty"`
-		StateType int         `json:"state_type,omitempty"`
-	} `json:"vars_before,omitempty"`
-}
-
 // Returns the priority of a results state based on the dashboard severity order configuration.
 func getPriority(result Result, dashboard Dashboard) int {
 	switch result.Attrs.State {
@@ -104,14 +52,31 @@ func (r Result) isWorse(result Result, dashboard Dashboard) bool {
 	return getPriority(r, dashboard) < getPriority(result, dashboard)
 }
 
+// worstResult returns the worst of the results last seen for the objects
+// shown by element, or false if none have been seen.
+func worstResult(element ElementStore, dashboard Dashboard) (Result, bool) {
+	worst := element.LastEvent
+	for _, name := range element.Objects {
+		if cache == nil {
+			break
+		}
+		if v, ok := cache.Get(objectKey(element.backend(), name)); ok {
+			if result := v.(Result); worst == (Result{}) || result.isWorse(worst, dashboard) {
+				worst = result
+			}
+		}
+	}
+	return worst, worst != (Result{})
+}
+
 /*
-This function is used to handle the event stream from Icinga.
+This function is used to handle the event stream from a backend.
 When an event is received compare the event with the objects in an element to get the worst result.
 If the worst result is worse than the last event, update the last event and send the event to the dashboard.
 */
-func handleKey(dashboard Dashboard, elementList []ElementStore, name string, event Event) {
-	for i, element := range elementList {
-		if (element.Type == "host" && event.Service != "") || (element.Type == "service" && event.Service == "") {
+func handleKey(dashboard Dashboard, elementList []ElementStore, name string, event meerkat.Event) {
+	for _, element := range elementList {
+		if (element.Type == "host" && event.Object.Type != "Host") || (element.Type == "service" && event.Object.Type != "Service") {
 			continue
 		}
 
@@ -121,7 +86,7 @@ func handleKey(dashboard Dashboard, elementList []ElementStore, name string, eve
 		for _, objectName := range element.Objects {
 			if objectName == name {
 				found = true
-				req := eventToRequest(event, name, element.Type, element.Name)
+				req := eventToRequest(event, element.Type, element.Name)
 
 				if worstObject == (Result{}) {
 					worstObject = req
@@ -130,11 +95,11 @@ func handleKey(dashboard Dashboard, elementList []ElementStore, name string, eve
 				}
 
 				results = []Result{worstObject}
-				cache.Set(objectName, req, 1)
+				cache.Set(objectKey(element.backend(), objectName), req, 1)
 				cache.Wait()
 			} else {
 				found = true
-				value, ok := cache.Get(objectName)
+				value, ok := cache.Get(objectKey(element.backend(), objectName))
 				if ok {
 					cachedObject := value.(Result)
 					cachedObject.Element = element.Name
@@ -149,7 +114,7 @@ func handleKey(dashboard Dashboard, elementList []ElementStore, name string, eve
 		}
 
 		// Prevents duplicate events being sent
-		if event.Type == "CheckResult" {
+		if event.Type == meerkat.EventCheckResult {
 			if worstObject.Attrs.Acknowledgement == element.LastEvent.Attrs.Acknowledgement {
 				if worstObject.Attrs.Name == element.LastEvent.Attrs.Name {
 					if worstObject.Attrs.State == element.LastEvent.Attrs.State {
@@ -162,14 +127,14 @@ func handleKey(dashboard Dashboard, elementList []ElementStore, name string, eve
 		}
 
 		if found && worstObject != (Result{}) {
+			worstObject.ElementID = element.ID
+			results = []Result{worstObject}
 			body, err := json.Marshal(results)
 			if err != nil {
 				log.Println(err)
 				continue
 			}
-			mapLock.Lock()
-			dashboardCache[dashboard.Slug][i].LastEvent = worstObject
-			mapLock.Unlock()
+			setLastEvent(dashboard.Slug, element.ID, worstObject)
 			if !testing.Testing() {
 				server.Publish(dashboard.Slug, &sse.Event{
 					Event: []byte(event.Type),
@@ -180,19 +145,18 @@ func handleKey(dashboard Dashboard, elementList []ElementStore, name string, eve
 	}
 }
 
-// This function is used to handle the AcknowledgementSet and AcknowledgementCleared events from Icinga.
+// This function is used to handle the AcknowledgementSet and AcknowledgementCleared events from a backend.
 func handleAcknowledge(dashboard Dashboard, elementList []ElementStore, name string, acknowledged int) {
-	for i, element := range elementList {
-		value, ok := cache.Get(name)
+	for _, element := range elementList {
+		value, ok := cache.Get(objectKey(element.backend(), name))
 		if ok {
 			req := value.(Result)
 			req.Attrs.Acknowledgement = acknowledged
 
 			if element.LastEvent.Name == name {
 				element.LastEvent.Attrs.Acknowledgement = acknowledged
-				mapLock.Lock()
-				dashboardCache[dashboard.Slug][i].LastEvent = element.LastEvent
-				mapLock.Unlock()
+				element.LastEvent.ElementID = element.ID
+				setLastEvent(dashboard.Slug, element.ID, element.LastEvent)
 
 				results := []Result{element.LastEvent}
 				body, err := json.Marshal(results)
@@ -212,188 +176,177 @@ func handleAcknowledge(dashboard Dashboard, elementList []ElementStore, name str
 				})
 			}
 
-			cache.Set(name, req, 1)
+			cache.Set(objectKey(element.backend(), name), req, 1)
 		}
 	}
 }
 
-// This function is used to handle the event stream from Icinga.
-func handleEvent(response string) error {
-	var name string
-	var event Event
-	var eventType int
+// handleEvent updates the dashboards open in browsers with an event
+// streamed from the backend b. Only elements showing objects from b
+// are updated.
+func handleEvent(b *backend, event meerkat.Event) {
+	name := event.Object.Name
+	ack := event.Type == meerkat.EventAcknowledgementSet || event.Type == meerkat.EventAcknowledgementCleared
 	var acknowledgement int
-	if strings.Contains(response, "AcknowledgementSet") {
-		eventType = 1
-		acknowledgement = 1
-		var ack AcknowledgementSet
-		err := json.Unmarshal([]byte(response), &ack)
-		if err != nil {
-			return err
-		}
-
-		name = ack.Host
-		if ack.Service != "" {
-			name = name + "!" + ack.Service
-		}
-
-		value, ok := cache.Get(name)
-		if ok {
-			req := value.(Result)
-			req.Attrs.Acknowledgement = 1
-			cache.Set(name, req, 1)
-			cache.Wait()
-		}
-	} else if strings.Contains(response, "AcknowledgementCleared") {
-		eventType = 1
-		acknowledgement = 0
-		var ack AcknowledgementCleared
-		err := json.Unmarshal([]byte(response), &ack)
-		if err != nil {
-			return err
-		}
-
-		name = ack.Host
-		if ack.Service != "" {
-			name = name + "!" + ack.Service
+	if ack {
+		if event.Type == meerkat.EventAcknowledgementSet {
+			acknowledgement = 1
 		}
-
-		value, ok := cache.Get(name)
+		value, ok := cache.Get(objectKey(b.name, name))
 		if ok {
 			req := value.(Result)
-			req.Attrs.Acknowledgement = 0
-			cache.Set(name, req, 1)
+			req.Attrs.Acknowledgement = acknowledgement
+			cache.Set(objectKey(b.name, name), req, 1)
 			cache.Wait()
 		}
-	} else {
-		eventType = 0
-		err := json.Unmarshal([]byte(response), &event)
-		if err != nil {
-			return err
-		}
-		name = event.Host
-
-		if event.Service != "" {
-			name = name + "!" + event.Service
-		}
 	}
-	mapLock.RLock()
-	dashboardCacheCopy := dashboardCache
-	mapLock.RUnlock()
-
 	var wg sync.WaitGroup
 
 	dashboardSync.Range(func(key, value interface{}) bool {
 		dashboard := value.(Dashboard)
 		if len(dashboard.CurrentlyOpenBy) > 0 {
+			var elementList []ElementStore
+			for _, element := range cachedElements(dashboard.Slug) {
+				if element.backend() == b.name {
+					elementList = append(elementList, element)
+				}
+			}
 			wg.Add(1)
 			go func(dashboard Dashboard, elementList []ElementStore) {
 				defer wg.Done()
-				if eventType == 0 {
-					handleKey(dashboard, elementList, name, event)
-				} else {
+				if ack {
 					handleAcknowledge(dashboard, elementList, name, acknowledgement)
+				} else {
+					handleKey(dashboard, elementList, name, event)
 				}
-			}(dashboard, dashboardCacheCopy[dashboard.Slug])
+			}(dashboard, elementList)
 		}
 		return true
 	})
 
 	wg.Wait()
 
-	if eventType == 0 {
-		addEvent(name, event.Type)
+	if !ack {
+		b.addEvent(name, event.Type)
 	}
-
-	return nil
 }
 
-func EventListener() {
-	log.Println("Subscribing to event streams")
-
-	client := &http.Client{
-		Transport: &http.Transport{
-			Dial: (&net.Dialer{
-				Timeout:   time.Second * 5,
-				KeepAlive: time.Second * 30,
-			}).Dial,
-			TLSHandshakeTimeout: time.Second * 5,
-			TLSClientConfig:     &tls.Config{InsecureSkipVerify: config.IcingaInsecureTLS},
-		},
-	}
+// errResubscribe is returned by EventListener when the objects shown
+// by dashboards have changed, so the backend's events should be
+// subscribed to again.
+var errResubscribe = errors.New("objects shown by dashboards changed")
 
-	var requestBody = []byte(`{ "types": [ "CheckResult", "StateChange", "AcknowledgementSet", "AcknowledgementCleared" ], "queue": "meerkat", "filter": ""}`)
+// resubscribeInterval is how often EventListener checks
+// whether the objects shown by dashboards have changed.
+var resubscribeInterval = 10 * time.Second
 
-	req, err := http.NewRequest("POST", config.IcingaURL+"/v1/events", bytes.NewBuffer(requestBody))
-	if err != nil {
-		log.Println("Error creating events request:", err)
-		return
-	}
-	req.Header.Set("Accept", "application/json")
-	req.SetBasicAuth(config.IcingaUsername, config.IcingaPassword)
-
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Println("Error sending events request:", err)
-		return
+// queries returns the queries selecting the objects
+// shown by the cached dashboards from the backend b.
+func (b *backend) queries() []meerkat.ObjectQuery {
+	var queries []meerkat.ObjectQuery
+	mapLock.RLock()
+	for _, elements := range dashboardCache {
+		for _, element := range elements {
+			if element.backend() != b.name || element.Type == "" || element.Name == "" {
+				continue
+			}
+			q := meerkat.ObjectQuery{Type: element.Type + "s", Name: element.Name}
+			if typ, ok := strings.CutSuffix(element.Type, "filter"); ok {
+				q = meerkat.ObjectQuery{Type: typ + "s", Filter: element.Name}
+			}
+			if !slices.Contains(queries, q) {
+				queries = append(queries, q)
+			}
+		}
 	}
-	defer resp.Body.Close()
+	mapLock.RUnlock()
+	sort.Slice(queries, func(i, j int) bool {
+		if queries[i].Type != queries[j].Type {
+			return queries[i].Type < queries[j].Type
+		}
+		if queries[i].Name != queries[j].Name {
+			return queries[i].Name < queries[j].Name
+		}
+		return queries[i].Filter < queries[j].Filter
+	})
+	return queries
+}
 
-	reader := bufio.NewReader(resp.Body)
+// EventListener handles events streamed from the backend b until the
+// stream is closed, or none is received for the backend's event timeout.
+// It returns errResubscribe if the stream was closed because the objects
+// shown by dashboards changed.
+func EventListener(b *backend) error {
+	log.Printf("Subscribing to %s event streams\n", b.name)
 
 	ctx, cancel := context.WithCancel(context.Background())
-	timer := time.NewTimer(time.Duration(config.IcingaEventTimeout) * time.Second)
-	events := make(chan string)
+	defer cancel()
+	queries := b.queries()
+	stream, err := b.api.Events(ctx, queries)
+	if err != nil {
+		log.Println("Error subscribing to event stream:", err)
+		return err
+	}
+	defer stream.Close()
+
+	// A timeout of zero means the stream may be silent for any time,
+	// as when a backend only sends events for changes it polls for.
+	timeout := b.eventTimeout
+	var timer *time.Timer
+	if timeout > 0 {
+		timer = time.AfterFunc(timeout, func() {
+			// Events are only sent for the objects shown by dashboards,
+			// which may not be checked for a while. The stream is only
+			// given up on if the backend is unavailable too.
+			if !b.checkStart().IsZero() {
+				timer.Reset(timeout)
+				return
+			}
+			log.Printf("Event stream from %s timed out\n", b.name)
+			b.sendError()
+			cancel()
+		})
+		defer timer.Stop()
+	}
 
+	var changed atomic.Bool
+	ticker := time.NewTicker(resubscribeInterval)
+	defer ticker.Stop()
+	watching := make(chan struct{})
+	defer func() {
+		cancel()
+		<-watching
+	}()
 	go func() {
+		defer close(watching)
 		for {
 			select {
 			case <-ctx.Done():
-				timer.Stop()
 				return
-			case <-timer.C:
-				log.Printf("Event stream timed out\n")
-				SendError()
-				cancel()
+			case <-ticker.C:
 			}
-		}
-	}()
-
-	go func() {
-		for {
-			line, err := reader.ReadBytes('\n')
-			if err != nil {
-				log.Println("Error reading event stream:", err)
+			if !slices.Equal(queries, b.queries()) {
+				log.Printf("Objects shown from %s changed, resubscribing\n", b.name)
+				changed.Store(true)
 				cancel()
+				return
 			}
-			if !timer.Stop() {
-				<-timer.C
-			}
-			timer.Reset(time.Duration(config.IcingaEventTimeout) * time.Second)
-
-			events <- string(line)
 		}
 	}()
-Loop:
 	for {
-		select {
-		case <-ctx.Done():
-			log.Println("Event stream closed")
-			break Loop
-		case event := <-events:
-			err = handleEvent(event)
-			if err != nil {
-				log.Println("Error decoding event:", err, event)
-				cancel()
-				break Loop
+		event, err := stream.Next()
+		if err != nil {
+			if changed.Load() {
+				return errResubscribe
 			}
-		case <-time.After(time.Duration(config.IcingaEventTimeout) * time.Second):
-			log.Println("Event stream timed out.")
-			break Loop
+			log.Println("Error reading event stream:", err)
+			return err
+		}
+		if timer != nil {
+			timer.Reset(timeout)
 		}
+		handleEvent(b, event)
 	}
-
-	log.Println("Event stream connection was closed")
 }
 
 type Events struct {
@@ -402,40 +355,66 @@ type Events struct {
 	ReceivedTime int64  `json:"received_time"`
 }
 
-type EventList struct {
-	sync.RWMutex
-	events []Events
-}
-
-func addEvent(event string, eventType string) {
-	eventList.Lock()
-	defer eventList.Unlock()
-	eventList.events = append(eventList.events, Events{Name: event, EventType: eventType, ReceivedTime: time.Now().UnixMilli()})
-	status.Backends.Icinga.Connections.EventStreams.LastEventReceived = int(time.Now().UnixMilli())
-}
-
-func getEvents() []Events {
-	eventList.RLock()
-	defer eventList.RUnlock()
-	values := make([]Events, len(eventList.events))
-	copy(values, eventList.events)
-	return values
+// canViewCached reports whether the user making req may follow the
+// state of the cached dashboard or dashboard view with the given key.
+func canViewCached(req *http.Request, key string) bool {
+	dashboard, ok := dashboardSync.Load(key)
+	if !ok {
+		return false
+	}
+	slug, _, _ := strings.Cut(key, "?")
+	return canView(req, dashboard.(Dashboard).Folder) || sharedWith(req, slug)
 }
 
 func createEventStream(r *chi.Mux) {
 	r.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		stream := r.URL.Query().Get("stream")
+		// Views with other variables are forgotten when no one watches
+		// them, such as while their viewers reconnect.
+		if slug, query, ok := strings.Cut(stream, "?"); ok {
+			if _, ok := dashboardSync.Load(stream); !ok {
+				q, err := url.ParseQuery(query)
+				if err == nil {
+					_, _, err = viewDashboard(r, slug, q)
+				}
+				if err != nil {
+					writeError(w, r, err)
+					return
+				}
+			}
+		}
+		// Playlist streams name the dashboards they show,
+		// so they are only followed by those who may view the playlist.
+		// The update stream carries nothing of any dashboard.
+		if name, ok := strings.CutPrefix(stream, playlistStream("")); ok {
+			if _, err := loadPlaylist(r, name); err != nil {
+				writeError(w, r, err)
+				return
+			}
+		} else if stream != "updates" {
+			if _, ok := dashboardSync.Load(stream); !ok {
+				http.Error(w, "no stream "+stream, http.StatusNotFound)
+				return
+			}
+			if !canViewCached(r, stream) {
+				denied(w, r)
+				return
+			}
+		}
 		go func() {
-			stream := r.URL.Query().Get("stream")
 			if stream != "update" {
+				mapLock.Lock()
 				dashboard, ok := dashboardSync.Load(stream)
 				if ok {
 					d := dashboard.(Dashboard)
 					d.CurrentlyOpenBy = append(d.CurrentlyOpenBy, r.RemoteAddr)
 					dashboardSync.Store(stream, d)
 				}
+				mapLock.Unlock()
 
 				<-r.Context().Done()
 
+				mapLock.Lock()
 				dashboard, ok = dashboardSync.Load(stream)
 				if ok {
 					d := dashboard.(Dashboard)
@@ -446,7 +425,12 @@ func createEventStream(r *chi.Mux) {
 							break
 						}
 					}
+					// Views with other variables are only tracked while watched.
+					if strings.Contains(stream, "?") && len(d.CurrentlyOpenBy) == 0 {
+						dropView(stream)
+					}
 				}
+				mapLock.Unlock()
 			} else {
 				<-r.Context().Done()
 			}
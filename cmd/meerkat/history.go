@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/meerkat-dashboard/meerkat"
+)
+
+var history *meerkat.History
+
+// revisionParams returns the dashboard slug and revision ID from the
+// request's URL, responding with an error if the ID is invalid or the
+// requesting user may not view the dashboard.
+func revisionParams(w http.ResponseWriter, req *http.Request) (slug string, id int, ok bool) {
+	slug = chi.URLParam(req, "slug")
+	if !canViewHistory(w, req, slug) {
+		return "", 0, false
+	}
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		http.Error(w, "bad revision id: "+err.Error(), http.StatusBadRequest)
+		return "", 0, false
+	}
+	return slug, id, true
+}
+
+// canViewHistory reports whether the user making req may view the
+// history of slug, responding with an error if not.
+// The history of deleted dashboards may only be viewed by admins.
+func canViewHistory(w http.ResponseWriter, req *http.Request, slug string) bool {
+	dashboard, err := meerkat.LoadDashboard(store, slug)
+	if errors.Is(err, fs.ErrNotExist) {
+		if u := meerkat.UserFromContext(req.Context()); u == nil || u.Role != meerkat.RoleAdmin {
+			http.NotFound(w, req)
+			return false
+		}
+		return true
+	} else if err != nil {
+		http.Error(w, "read dashboard: "+err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if !canView(req, dashboard.Folder) {
+		denied(w, req)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("encode response:", err)
+	}
+}
+
+func revisionError(w http.ResponseWriter, err error) {
+	if errors.Is(err, fs.ErrNotExist) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func listRevisionsHandler(w http.ResponseWriter, req *http.Request) {
+	slug := chi.URLParam(req, "slug")
+	if !canViewHistory(w, req, slug) {
+		return
+	}
+	revs, err := history.Revisions(slug)
+	if err != nil {
+		http.Error(w, "list revisions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, revs)
+}
+
+func getRevisionHandler(w http.ResponseWriter, req *http.Request) {
+	slug, id, ok := revisionParams(w, req)
+	if !ok {
+		return
+	}
+	rev, err := history.Revision(slug, id)
+	if err != nil {
+		revisionError(w, err)
+		return
+	}
+	writeJSON(w, rev)
+}
+
+// diffRevisionsHandler responds with the changes between the revisions
+// given by the "from" and "to" query parameters.
+// If "to" is omitted, the current dashboard is compared.
+func diffRevisionsHandler(w http.ResponseWriter, req *http.Request) {
+	slug := chi.URLParam(req, "slug")
+	if !canViewHistory(w, req, slug) {
+		return
+	}
+	from, err := strconv.Atoi(req.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, "bad from revision: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	a, err := history.Revision(slug, from)
+	if err != nil {
+		revisionError(w, err)
+		return
+	}
+
+	var b *meerkat.Dashboard
+	if to := req.URL.Query().Get("to"); to != "" {
+		id, err := strconv.Atoi(to)
+		if err != nil {
+			http.Error(w, "bad to revision: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		rev, err := history.Revision(slug, id)
+		if err != nil {
+			revisionError(w, err)
+			return
+		}
+		b = rev.Dashboard
+	} else {
+		current, err := meerkat.LoadDashboard(store, slug)
+		if err != nil {
+			revisionError(w, err)
+			return
+		}
+		b = &current
+	}
+
+	changes, err := meerkat.Diff(a.Dashboard, b)
+	if err != nil {
+		http.Error(w, "diff revisions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if changes == nil {
+		changes = []meerkat.Change{}
+	}
+	writeJSON(w, changes)
+}
+
+// restoreRevisionHandler saves a previous revision of a dashboard as its
+// current version, as any other change to it is saved. The restored
+// dashboard keeps its current title, so restoring never renames a
+// dashboard. Deleted dashboards are created again.
+func restoreRevisionHandler(w http.ResponseWriter, req *http.Request) {
+	slug, id, ok := revisionParams(w, req)
+	if !ok {
+		return
+	}
+	rev, err := history.Revision(slug, id)
+	if err != nil {
+		revisionError(w, err)
+		return
+	}
+	restored := *rev.Dashboard
+	msg := fmt.Sprintf("Restored revision %d", id)
+	_, err = meerkat.LoadDashboard(store, slug)
+	if errors.Is(err, fs.ErrNotExist) {
+		if meerkat.TitleToSlug(restored.Title) != slug {
+			legacyError(w, req, badRequest("revision %d of %s has title %q of another dashboard", id, slug, restored.Title))
+			return
+		}
+		_, err = createDashboard(req, &restored, msg)
+	} else {
+		_, err = modifyDashboard(req, slug, req.Header.Get("If-Match"), msg, func(d *meerkat.Dashboard) error {
+			title := d.Title
+			*d = restored
+			d.Title = title
+			return nil
+		})
+	}
+	if err != nil {
+		legacyError(w, req, fmt.Errorf("restore revision %d of %s: %w", id, slug, err))
+		return
+	}
+	notifyViewers(slug)
+	log.Printf("Restored revision %d of dashboard %s\n", id, slug)
+
+	if strings.Contains(req.Header.Get("Accept"), "text/html") {
+		http.Redirect(w, req, path.Join("/", slug, "history"), http.StatusSeeOther)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,10 +1,28 @@
 package main
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/dgraph-io/ristretto"
 	"github.com/meerkat-dashboard/meerkat"
+	"github.com/meerkat-dashboard/meerkat/icinga"
+	"github.com/meerkat-dashboard/meerkat/icinga/icingatest"
 )
 
 func TestEvents(t *testing.T) {
@@ -14,7 +32,7 @@ func TestEvents(t *testing.T) {
 
 	elementList := []ElementStore{}
 
-	element := ElementStore{Name: "test", Type: "service"}
+	element := ElementStore{ID: "e1", Name: "test", Type: "service"}
 	element.Objects = append(element.Objects, "service-test-1")
 	element.Objects = append(element.Objects, "service-test-2")
 	element.Objects = append(element.Objects, "service-test-3")
@@ -27,22 +45,403 @@ func TestEvents(t *testing.T) {
 		BufferItems: 64,
 	})
 
-	dashboardCache = make(map[string][]ElementStore)
-	dashboardCache[dashboard.Slug] = append(dashboardCache[dashboard.Slug], element)
+	dashboardCache = map[string]map[string]ElementStore{
+		dashboard.Slug: {element.ID: element},
+	}
 
-	event := Event{Acknowledgement: false, CheckResult: CheckResult{State: 2, Output: "", PerformanceData: []string{}}, DowntimeDepth: 0, Host: "test", Service: "service-test-1", Timestamp: 0, Type: "service"}
-	event.CheckResult.VarsAfter.StateType = 1
+	event := meerkat.Event{Type: meerkat.EventCheckResult, Object: meerkat.Object{Name: "service-test-1", Type: "Service", StateType: 1}}
+	event.Object.State = 2
 	handleKey(dashboard, elementList, "service-test-1", event)
 
-	event = Event{Acknowledgement: false, CheckResult: CheckResult{State: 0, Output: "", PerformanceData: []string{}}, DowntimeDepth: 0, Host: "test", Service: "service-test-2", Timestamp: 0, Type: "service"}
-	event.CheckResult.VarsAfter.StateType = 1
+	event = meerkat.Event{Type: meerkat.EventCheckResult, Object: meerkat.Object{Name: "service-test-2", Type: "Service", StateType: 1}}
 	handleKey(dashboard, elementList, "service-test-2", event)
 
-	event = Event{Acknowledgement: false, CheckResult: CheckResult{State: 0, Output: "", PerformanceData: []string{}}, DowntimeDepth: 0, Host: "test", Service: "service-test-3", Timestamp: 0, Type: "service"}
-	event.CheckResult.VarsAfter.StateType = 1
+	event = meerkat.Event{Type: meerkat.EventCheckResult, Object: meerkat.Object{Name: "service-test-3", Type: "Service", StateType: 1}}
 	handleKey(dashboard, elementList, "service-test-3", event)
 
-	if dashboardCache[dashboard.Slug][0].LastEvent.Name != "service-test-1" {
-		t.Errorf("Result was incorrect, got: %s, want: %s.", dashboardCache[dashboard.Slug][0].LastEvent.Name, "service-test-1")
+	last := dashboardCache[dashboard.Slug][element.ID].LastEvent
+	if last.Name != "service-test-1" {
+		t.Errorf("Result was incorrect, got: %s, want: %s.", last.Name, "service-test-1")
+	}
+	if last.ElementID != element.ID {
+		t.Errorf("got element ID %q in last event, want %q", last.ElementID, element.ID)
+	}
+}
+
+func TestElementStores(t *testing.T) {
+	previous := map[string]ElementStore{
+		"a": {ID: "a", Name: "web", Type: "host", Objects: []string{"web"}},
+		"b": {ID: "b", Name: "db", Type: "host", Objects: []string{"db"}},
+	}
+	dashboard := meerkat.Dashboard{Elements: []meerkat.Element{
+		{ID: "b", Options: meerkat.Options{ObjectName: "db2", ObjectType: "host"}},
+		{ID: "c", Type: "clock"},
+		{ID: "a", Options: meerkat.Options{ObjectName: "web", ObjectType: "host"}},
+	}}
+	got := elementStores(dashboard, previous)
+	if len(got) != 2 {
+		t.Fatalf("got %d elements, want 2: %v", len(got), got)
+	}
+	if len(got["a"].Objects) != 1 {
+		t.Errorf("unchanged element a lost its objects: %+v", got["a"])
+	}
+	if got["b"].Name != "db2" || len(got["b"].Objects) != 0 {
+		t.Errorf("changed element b kept stale state: %+v", got["b"])
+	}
+}
+
+// newIcingaTestServer starts a fake Icinga server and points the
+// default backend at it until the test ends.
+// Other backends may be added to backends by the test.
+func newIcingaTestServer(t *testing.T) *icingatest.Server {
+	t.Helper()
+	srv := icingatest.NewServer("meerkat", "meerkat")
+	t.Cleanup(srv.Close)
+	oldBackends, oldCache := backends, cache
+	t.Cleanup(func() {
+		backends, cache = oldBackends, oldCache
+	})
+	backends = map[string]*backend{
+		defaultBackend: newTestBackend(t, defaultBackend, srv.URL),
+	}
+	cache, _ = ristretto.NewCache(&ristretto.Config{NumCounters: 1e3, MaxCost: 1 << 20, BufferItems: 64})
+	return srv
+}
+
+// newTestBackend returns a backend named name
+// for fake Icinga servers at urls.
+func newTestBackend(t *testing.T, name string, urls ...string) *backend {
+	t.Helper()
+	b, err := newBackend(name, BackendConfig{URLs: urls, Username: "meerkat", Password: "meerkat", EventTimeout: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func getObjects(t *testing.T, query string) (ObjectResults, *httptest.ResponseRecorder) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	getObjectHandler(rec, httptest.NewRequest(http.MethodGet, "/api/objects?"+query, nil))
+	var objects ObjectResults
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &objects); err != nil {
+			t.Fatalf("decode objects: %v", err)
+		}
+	}
+	return objects, rec
+}
+
+func TestIcinga(t *testing.T) {
+	h := newAPITestServer(t)
+	srv := newIcingaTestServer(t)
+	srv.Add(icinga.Object{Name: "router", Type: "Host"})
+
+	body := `{"title": "Network", "elements": [{"id": "e1", "type": "check-card", "options": {"objectType": "host", "objectName": "router"}}]}`
+	if rec := apiRequest(h, http.MethodPost, apiPrefix, body); rec.Code != http.StatusCreated {
+		t.Fatalf("create dashboard: got status %d: %s", rec.Code, rec.Body)
+	}
+	createDashboardCache()
+	d, _ := dashboardSync.Load("network")
+	dashboard := d.(Dashboard)
+	dashboard.CurrentlyOpenBy = []string{"192.0.2.1:1234"}
+	dashboardSync.Store("network", dashboard)
+
+	objects, rec := getObjects(t, "type=hosts&name=router&title=/network/view")
+	if rec.Code != http.StatusOK || rec.Header().Get("x-meercat-cache") != "MISS" {
+		t.Fatalf("get router: got status %d, cache %q: %s", rec.Code, rec.Header().Get("x-meercat-cache"), rec.Body)
+	}
+	if len(objects.Results) != 1 || objects.Results[0].Name != "router" {
+		t.Errorf("got objects %+v, want router", objects.Results)
+	}
+	if _, rec := getObjects(t, "type=hosts&name=router&title=/network/view"); rec.Header().Get("x-meercat-cache") != "HIT" {
+		t.Errorf("object not cached after first request")
+	}
+	if _, rec := getObjects(t, "type=hosts&name=missing&title=/network/view"); rec.Code != http.StatusNotFound {
+		t.Errorf("get missing host: got status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	if backends[defaultBackend].checkStart().IsZero() {
+		t.Error("no program start from Icinga status")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		EventListener(backends[defaultBackend])
+		close(done)
+	}()
+	for i := 0; srv.Streams() == 0; i++ {
+		if i == 100 {
+			t.Fatal("event stream not opened")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	event := icinga.Event{Type: icinga.TypeCheckResult, Host: "router"}
+	event.CheckResult.State = 1
+	event.CheckResult.Output = "CRITICAL - Host Unreachable"
+	srv.Send(event)
+	for i := 0; cachedElements("network")[0].LastEvent.Attrs.State != 1; i++ {
+		if i == 100 {
+			t.Fatalf("element not updated by event: %+v", cachedElements("network")[0])
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	srv.Disconnect()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event listener still running after disconnect")
+	}
+}
+
+func TestIcingaResubscribe(t *testing.T) {
+	h := newAPITestServer(t)
+	srv := newIcingaTestServer(t)
+	srv.Add(icinga.Object{Name: "router", Type: "Host"}, icinga.Object{Name: "db", Type: "Host"})
+	body := `{"title": "Network", "elements": [{"id": "e1", "type": "check-card", "options": {"objectType": "host", "objectName": "router"}}]}`
+	if rec := apiRequest(h, http.MethodPost, apiPrefix, body); rec.Code != http.StatusCreated {
+		t.Fatalf("create dashboard: got status %d: %s", rec.Code, rec.Body)
+	}
+	createDashboardCache()
+	d, _ := dashboardSync.Load("network")
+	dashboard := d.(Dashboard)
+	dashboard.CurrentlyOpenBy = []string{"192.0.2.1:1234"}
+	dashboardSync.Store("network", dashboard)
+
+	oldInterval := resubscribeInterval
+	resubscribeInterval = 10 * time.Millisecond
+	defer func() { resubscribeInterval = oldInterval }()
+	b := backends[defaultBackend]
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for errors.Is(EventListener(b), errResubscribe) {
+		}
+	}()
+	defer func() {
+		srv.Disconnect()
+		<-done
+	}()
+	for i := 0; srv.Streams() == 0; i++ {
+		if i == 100 {
+			t.Fatal("event stream not opened")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// Not shown by any dashboard, so never sent.
+	srv.Send(icinga.Event{Type: icinga.TypeCheckResult, Host: "db"})
+	if n := b.report().Connections.EventStreams.ReceivedEventCount; n != 0 {
+		t.Errorf("received %d events for objects not shown", n)
+	}
+
+	mapLock.Lock()
+	dashboardCache["network"]["e2"] = ElementStore{ID: "e2", Name: "db", Type: "host", Backend: defaultBackend, Objects: []string{"db"}}
+	mapLock.Unlock()
+	event := icinga.Event{Type: icinga.TypeCheckResult, Host: "db"}
+	event.CheckResult.State = 2
+	for i := 0; cachedElements("network")[1].LastEvent.Name != "db"; i++ {
+		if i == 100 {
+			t.Fatal("no events for db after it was added to a dashboard")
+		}
+		srv.Send(event)
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestBackends(t *testing.T) {
+	h := newAPITestServer(t)
+	srv := newIcingaTestServer(t)
+	srv.Add(icinga.Object{Name: "router", Type: "Host"})
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	backends[defaultBackend] = newTestBackend(t, defaultBackend, down.URL, srv.URL)
+	apac := icingatest.NewServer("meerkat", "meerkat")
+	defer apac.Close()
+	router := icinga.Object{Name: "router", Type: "Host"}
+	router.Attrs.LastCheckResult.State = 2
+	apac.Add(router)
+	backends["apac"] = newTestBackend(t, "apac", apac.URL)
+
+	body := `{"title": "Network", "elements": [
+		{"id": "e1", "type": "check-card", "options": {"objectType": "host", "objectName": "router"}},
+		{"id": "e2", "type": "check-card", "options": {"objectType": "host", "objectName": "router", "backend": "apac"}}
+	]}`
+	if rec := apiRequest(h, http.MethodPost, apiPrefix, body); rec.Code != http.StatusCreated {
+		t.Fatalf("create dashboard: got status %d: %s", rec.Code, rec.Body)
+	}
+	createDashboardCache()
+
+	for _, tt := range []struct {
+		backend string
+		state   int
+	}{
+		{"", 0},
+		{"apac", 2},
+		// Objects from each backend are cached separately.
+		{"", 0},
+		{"apac", 2},
+	} {
+		objects, rec := getObjects(t, "type=hosts&name=router&title=/network/view&backend="+tt.backend)
+		if rec.Code != http.StatusOK || len(objects.Results) != 1 {
+			t.Fatalf("get router from backend %q: got status %d: %s", tt.backend, rec.Code, rec.Body)
+		}
+		if objects.Results[0].Attrs.State != tt.state {
+			t.Errorf("router from backend %q: got state %d, want %d", tt.backend, objects.Results[0].Attrs.State, tt.state)
+		}
+	}
+	if _, rec := getObjects(t, "type=hosts&name=router&title=/network/view&backend=emea"); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown backend: got status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	rec := httptest.NewRecorder()
+	getStatusHandler(rec, httptest.NewRequest(http.MethodGet, "/api/status", nil))
+	var got struct {
+		Backends map[string]BackendStatus `json:"backends"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Backends) != 2 {
+		t.Fatalf("got status of %d backends, want 2", len(got.Backends))
+	}
+	if s := got.Backends[defaultBackend]; s.ActiveEndpoint != srv.URL || len(s.Endpoints) != 2 {
+		t.Errorf("default backend: got active endpoint %q of %v, want %s", s.ActiveEndpoint, s.Endpoints, srv.URL)
+	}
+	if n := got.Backends["apac"].Connections.APICalls.RecentRequestCount; n != 1 {
+		t.Errorf("apac backend: got %d recent requests, want 1", n)
+	}
+
+	rec = httptest.NewRecorder()
+	getBackendsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/backends", nil))
+	if got := strings.TrimSpace(rec.Body.String()); got != `["apac"]` {
+		t.Errorf("got backends %s, want [\"apac\"]", got)
+	}
+}
+
+// writeTestCA writes a new CA certificate and a client certificate
+// it issued with the common name cn to dir, returning the CA and
+// the names of the client certificate and key files.
+func writeTestCA(t *testing.T, dir, cn string) (ca *x509.Certificate, certFile, keyFile string) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ca, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template = &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err = x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return ca, certFile, keyFile
+}
+
+func TestIcingaTLS(t *testing.T) {
+	dir := t.TempDir()
+	clientCA, certFile, keyFile := writeTestCA(t, dir, "meerkat")
+	pool := x509.NewCertPool()
+	pool.AddCert(clientCA)
+	srv := icingatest.NewTLSServer("meerkat", "secret", pool)
+	defer srv.Close()
+	srv.Add(icinga.Object{Name: "router", Type: "Host"})
+
+	caFile := filepath.Join(dir, "ca.crt")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	passwordFile := filepath.Join(dir, "password")
+	if err := os.WriteFile(passwordFile, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MEERKAT_TEST_ICINGA_PASSWORD", "secret")
+
+	tests := []struct {
+		name string
+		conf BackendConfig
+		// status is the status code of the error response, if any.
+		status int
+		fails  bool
+	}{
+		{name: "client certificate", conf: BackendConfig{CertFile: certFile, KeyFile: keyFile, CAFile: caFile}},
+		{name: "password file", conf: BackendConfig{Username: "meerkat", PasswordFile: passwordFile, CAFile: caFile}},
+		{name: "password environment", conf: BackendConfig{Username: "meerkat", PasswordEnv: "MEERKAT_TEST_ICINGA_PASSWORD", CAFile: caFile}},
+		// The test server's certificate is for example.com.
+		{name: "server name", conf: BackendConfig{CertFile: certFile, KeyFile: keyFile, CAFile: caFile, ServerName: "example.com"}},
+		{name: "wrong server name", conf: BackendConfig{CertFile: certFile, KeyFile: keyFile, CAFile: caFile, ServerName: "icinga.example.org"}, fails: true},
+		{name: "untrusted", conf: BackendConfig{CertFile: certFile, KeyFile: keyFile}, fails: true},
+		{name: "no credentials", conf: BackendConfig{CAFile: caFile}, status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		tt.conf.URLs = []string{srv.URL}
+		b, err := newBackend("icinga", tt.conf)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		_, err = b.api.Objects(context.Background(), meerkat.ObjectQuery{Type: "hosts", Name: "router"})
+		var e *meerkat.BackendError
+		switch {
+		case tt.status != 0:
+			if !errors.As(err, &e) || e.StatusCode != tt.status {
+				t.Errorf("%s: got error %v, want status %d", tt.name, err, tt.status)
+			}
+		case tt.fails && err == nil:
+			t.Errorf("%s: no error", tt.name)
+		case !tt.fails && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+
+	for _, conf := range []BackendConfig{
+		{CAFile: passwordFile},
+		{PasswordEnv: "MEERKAT_TEST_UNSET"},
+		{CertFile: certFile, KeyFile: passwordFile},
+	} {
+		if _, err := newBackend("icinga", conf); err == nil {
+			t.Errorf("no error from backend configured by %+v", conf)
+		}
+	}
+	if err := (BackendConfig{CertFile: certFile}).validate(); err == nil {
+		t.Error("no error validating certificate without key")
 	}
 }
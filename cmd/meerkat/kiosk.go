@@ -0,0 +1,405 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/meerkat-dashboard/meerkat"
+	"github.com/r3labs/sse/v2"
+)
+
+// kiosksPath is the path of the API for managing kiosks.
+const kiosksPath = "/api/v1/kiosks"
+
+// kioskHeartbeatInterval is how often kiosks report to Meerkat.
+// Kiosks which have not reported for twice as long are offline.
+const kioskHeartbeatInterval = 30 * time.Second
+
+// kioskCookie holds the name and token of the kiosk a browser is.
+// Heartbeats set it, so that the pages the kiosk shows are served to it;
+// see kioskAllows.
+const kioskCookie = "meerkat_kiosk"
+
+// kioskRoutes registers the kiosk API handlers on r.
+// Kiosks authenticate heartbeats with their token;
+// everything else is only for admins.
+func kioskRoutes(r chi.Router) {
+	r.Post("/{name}/heartbeat", kioskHeartbeat)
+	admin := r.With(requireAdmin)
+	admin.Get("/", apiListKiosks)
+	admin.Post("/", apiCreateKiosk)
+	admin.Get("/{name}", apiGetKiosk)
+	admin.Delete("/{name}", apiDeleteKiosk)
+	admin.Post("/{name}/token", apiNewKioskToken)
+	admin.Post("/{name}/command", apiKioskCommand)
+}
+
+// A kioskStatus is what a kiosk last reported about itself.
+type kioskStatus struct {
+	LastHeartbeat time.Time `json:"lastHeartbeat"`
+	// Showing is the path of the page the kiosk is showing,
+	// empty if it is blank.
+	Showing    string `json:"showing"`
+	UserAgent  string `json:"userAgent"`
+	Screen     string `json:"screen,omitempty"`
+	RemoteAddr string `json:"remoteAddr"`
+}
+
+// kioskStatuses holds the status of each kiosk which has reported
+// since Meerkat started, keyed by name. kioskLock also serialises
+// changes to stored kiosks.
+var (
+	kioskLock     sync.Mutex
+	kioskStatuses = make(map[string]kioskStatus)
+)
+
+// A kioskInfo describes a kiosk to admins.
+type kioskInfo struct {
+	meerkat.Kiosk
+	// Path is the page the kiosk has been told to show.
+	Path   string       `json:"path"`
+	Online bool         `json:"online"`
+	Status *kioskStatus `json:"status,omitempty"`
+}
+
+func newKioskInfo(k meerkat.Kiosk, now time.Time) kioskInfo {
+	info := kioskInfo{Kiosk: k, Path: k.Path()}
+	info.TokenHash = ""
+	if status, ok := kioskStatuses[k.Name]; ok {
+		info.Status = &status
+		info.Online = now.Sub(status.LastHeartbeat) < 2*kioskHeartbeatInterval
+	}
+	return info
+}
+
+// A kioskCommand tells a kiosk what to do.
+type kioskCommand struct {
+	Kiosk string `json:"kiosk"`
+	// Action is one of "show", to show the page at Path or nothing if
+	// Path is empty, or "reload", to reload the kiosk's page.
+	Action string `json:"action"`
+	Path   string `json:"path"`
+}
+
+// sendKioskCommand publishes cmd as a "kiosk" event on the updates
+// stream. Dashboard viewers only handle unnamed events, so ignore it.
+// Kiosks also learn what to show from heartbeats,
+// so those which miss the event catch up.
+func sendKioskCommand(cmd kioskCommand) {
+	if server == nil {
+		return
+	}
+	b, err := json.Marshal(cmd)
+	if err != nil {
+		log.Println("encode kiosk command:", err)
+		return
+	}
+	server.Publish("updates", &sse.Event{Event: []byte("kiosk"), Data: b})
+}
+
+// kioskHeartbeat records the status reported by a kiosk,
+// and responds with what it should show.
+func kioskHeartbeat(w http.ResponseWriter, req *http.Request) {
+	name := chi.URLParam(req, "name")
+	k, err := meerkat.LoadKiosk(store, name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		writeError(w, req, err)
+		return
+	}
+	token, _ := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if err != nil || !k.CheckToken(token) {
+		writeError(w, req, &apiError{http.StatusUnauthorized, "invalid kiosk name or token"})
+		return
+	}
+	var body struct {
+		Showing string `json:"showing"`
+		Screen  string `json:"screen"`
+	}
+	if err := decodeBody(req, &body); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	kioskLock.Lock()
+	kioskStatuses[name] = kioskStatus{
+		LastHeartbeat: time.Now(),
+		Showing:       body.Showing,
+		UserAgent:     req.UserAgent(),
+		Screen:        body.Screen,
+		RemoteAddr:    req.RemoteAddr,
+	}
+	kioskLock.Unlock()
+	http.SetCookie(w, &http.Cookie{
+		Name:     kioskCookie,
+		Value:    name + "." + token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   config.SSLEnable,
+		SameSite: http.SameSiteStrictMode,
+	})
+	writeJSON(w, struct {
+		Path      string `json:"path"`
+		Heartbeat int    `json:"heartbeat"`
+	}{k.Path(), int(kioskHeartbeatInterval.Seconds())})
+}
+
+// requestKiosk returns the kiosk named by the request's kiosk cookie,
+// if the cookie holds its token. Kiosks are looked up on each request,
+// so issuing a new token or deleting the kiosk takes effect at once.
+func requestKiosk(req *http.Request) (meerkat.Kiosk, bool) {
+	cookie, err := req.Cookie(kioskCookie)
+	if err != nil {
+		return meerkat.Kiosk{}, false
+	}
+	name, token, _ := strings.Cut(cookie.Value, ".")
+	k, err := meerkat.LoadKiosk(store, name)
+	if err != nil || !k.CheckToken(token) {
+		return meerkat.Kiosk{}, false
+	}
+	return k, true
+}
+
+// kioskAllows reports whether the kiosk k may make req: whether req is
+// for what k has been told to show. Kiosks showing a playlist may follow
+// it, and view each of its dashboards. Dashboards are viewed as if
+// shared with the kiosk, so kioskAllows also returns the share of the
+// dashboard req is for, if any; see shareAllows.
+func kioskAllows(req *http.Request, k *meerkat.Kiosk) (*meerkat.Share, bool) {
+	var slugs []string
+	switch {
+	case k.Blank:
+		return nil, false
+	case k.Playlist != "":
+		if req.Method == http.MethodGet || req.Method == http.MethodHead {
+			switch req.URL.Path {
+			case path.Join("/playlist", k.Playlist, "view"), path.Join(playlistsPath, k.Playlist, "position"):
+				return nil, true
+			case "/events":
+				if req.URL.Query().Get("stream") == playlistStream(k.Playlist) {
+					return nil, true
+				}
+			}
+		}
+		p, err := meerkat.LoadPlaylist(store, k.Playlist)
+		if err != nil {
+			return nil, false
+		}
+		for _, e := range p.Entries {
+			slugs = append(slugs, e.Slug)
+		}
+	case k.Dashboard != "":
+		slugs = []string{k.Dashboard}
+	}
+	for _, slug := range slugs {
+		share := &meerkat.Share{ID: "kiosk-" + k.Name, Dashboard: slug}
+		if shareAllows(req, share) {
+			return share, true
+		}
+	}
+	return nil, false
+}
+
+// kioskPlaying reports whether req was made by a kiosk showing the
+// playlist name.
+func kioskPlaying(req *http.Request, name string) bool {
+	k := meerkat.KioskFromContext(req.Context())
+	return k != nil && k.Playlist == name
+}
+
+func apiListKiosks(w http.ResponseWriter, req *http.Request) {
+	kiosks, err := meerkat.LoadKiosks(store)
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	now := time.Now()
+	kioskLock.Lock()
+	infos := []kioskInfo{}
+	for _, k := range kiosks {
+		infos = append(infos, newKioskInfo(k, now))
+	}
+	kioskLock.Unlock()
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	writeJSON(w, infos)
+}
+
+func apiGetKiosk(w http.ResponseWriter, req *http.Request) {
+	k, err := meerkat.LoadKiosk(store, chi.URLParam(req, "name"))
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	kioskLock.Lock()
+	info := newKioskInfo(k, time.Now())
+	kioskLock.Unlock()
+	writeJSON(w, info)
+}
+
+// apiCreateKiosk creates a kiosk, responding with its token.
+// The token is not stored, so cannot be retrieved later;
+// a new one can be issued with apiNewKioskToken.
+func apiCreateKiosk(w http.ResponseWriter, req *http.Request) {
+	var k meerkat.Kiosk
+	if err := decodeBody(req, &k); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	if err := checkKioskTarget(k.Dashboard, k.Playlist); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	token, err := k.NewToken()
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	kioskLock.Lock()
+	defer kioskLock.Unlock()
+	_, err = meerkat.LoadKiosk(store, k.Name)
+	if err == nil {
+		writeError(w, req, fmt.Errorf("kiosk %s already exists: %w", k.Name, fs.ErrExist))
+		return
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		writeError(w, req, err)
+		return
+	}
+	if err := meerkat.SaveKiosk(store, &k); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	log.Printf("Kiosk %s created by %s\n", k.Name, meerkat.UserFromContext(req.Context()).Name)
+	w.Header().Set("Location", path.Join(kiosksPath, k.Name))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, kioskToken(k, token))
+}
+
+// kioskToken describes the new token of a kiosk,
+// along with the address it should open to use it.
+func kioskToken(k meerkat.Kiosk, token string) any {
+	return struct {
+		kioskInfo
+		Token string `json:"token"`
+		URL   string `json:"url"`
+	}{newKioskInfo(k, time.Now()), token, "/kiosk/" + k.Name + "?token=" + token}
+}
+
+func apiNewKioskToken(w http.ResponseWriter, req *http.Request) {
+	kioskLock.Lock()
+	defer kioskLock.Unlock()
+	k, err := meerkat.LoadKiosk(store, chi.URLParam(req, "name"))
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	token, err := k.NewToken()
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	if err := meerkat.SaveKiosk(store, &k); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	log.Printf("New token for kiosk %s issued by %s\n", k.Name, meerkat.UserFromContext(req.Context()).Name)
+	writeJSON(w, kioskToken(k, token))
+}
+
+func apiDeleteKiosk(w http.ResponseWriter, req *http.Request) {
+	name := chi.URLParam(req, "name")
+	kioskLock.Lock()
+	defer kioskLock.Unlock()
+	if err := meerkat.DeleteKiosk(store, name); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	delete(kioskStatuses, name)
+	sendKioskCommand(kioskCommand{Kiosk: name, Action: "show"})
+	log.Printf("Kiosk %s deleted by %s\n", name, meerkat.UserFromContext(req.Context()).Name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiKioskCommand controls a kiosk remotely. The request body is one of:
+//
+//	{"action": "show", "dashboard": "network"}
+//	{"action": "show", "playlist": "noc-wall"}
+//	{"action": "blank"}
+//	{"action": "reload"}
+//
+// What a kiosk is told to show is stored, so it is shown again after
+// the kiosk restarts.
+func apiKioskCommand(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		Action    string `json:"action"`
+		Dashboard string `json:"dashboard"`
+		Playlist  string `json:"playlist"`
+	}
+	if err := decodeBody(req, &body); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	kioskLock.Lock()
+	defer kioskLock.Unlock()
+	k, err := meerkat.LoadKiosk(store, chi.URLParam(req, "name"))
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	switch body.Action {
+	case "reload":
+		sendKioskCommand(kioskCommand{Kiosk: k.Name, Action: "reload"})
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case "show":
+		if body.Dashboard == "" && body.Playlist == "" {
+			writeError(w, req, badRequest("show needs a dashboard or playlist"))
+			return
+		}
+		if err := checkKioskTarget(body.Dashboard, body.Playlist); err != nil {
+			writeError(w, req, err)
+			return
+		}
+		k.Dashboard, k.Playlist, k.Blank = body.Dashboard, body.Playlist, false
+	case "blank":
+		k.Blank = true
+	default:
+		writeError(w, req, badRequest("unknown action %q", body.Action))
+		return
+	}
+	if err := meerkat.SaveKiosk(store, &k); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	sendKioskCommand(kioskCommand{Kiosk: k.Name, Action: "show", Path: k.Path()})
+	log.Printf("Kiosk %s told to show %q by %s\n", k.Name, k.Path(), meerkat.UserFromContext(req.Context()).Name)
+	writeJSON(w, newKioskInfo(k, time.Now()))
+}
+
+// checkKioskTarget checks that the dashboard or playlist
+// a kiosk is to show exists.
+func checkKioskTarget(dashboard, playlist string) error {
+	if dashboard != "" {
+		if _, err := meerkat.LoadDashboard(store, dashboard); errors.Is(err, fs.ErrNotExist) {
+			return badRequest("no dashboard %s", dashboard)
+		} else if err != nil {
+			return err
+		}
+	}
+	if playlist != "" {
+		if _, err := meerkat.LoadPlaylist(store, playlist); errors.Is(err, fs.ErrNotExist) {
+			return badRequest("no playlist %s", playlist)
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
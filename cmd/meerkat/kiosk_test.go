@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/meerkat-dashboard/meerkat"
+)
+
+func TestKiosks(t *testing.T) {
+	r := newAPITestServer(t)
+
+	rec := apiRequest(r, http.MethodPost, apiPrefix, `{"title": "Network"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create dashboard: got status %d: %s", rec.Code, rec.Body)
+	}
+	rec = apiRequest(r, http.MethodPost, kiosksPath, `{"name": "lobby", "dashboard": "missing"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("create kiosk showing missing dashboard: got status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	rec = apiRequest(r, http.MethodPost, kiosksPath, `{"name": "lobby", "description": "Front door"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create kiosk: got status %d: %s", rec.Code, rec.Body)
+	}
+	var created struct {
+		Token     string `json:"token"`
+		URL       string `json:"url"`
+		TokenHash string `json:"tokenHash"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Token == "" || created.TokenHash != "" {
+		t.Fatalf("got token %q and hash %q, want only a token", created.Token, created.TokenHash)
+	}
+	rec = apiRequest(r, http.MethodPost, kiosksPath, `{"name": "lobby"}`)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("create duplicate kiosk: got status %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	heartbeat := kiosksPath + "/lobby/heartbeat"
+	body := `{"showing": "", "screen": "1920x1080"}`
+	rec = apiRequest(r, http.MethodPost, heartbeat, body, "Authorization", "Bearer wrong")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("heartbeat with wrong token: got status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	rec = apiRequest(r, http.MethodPost, kiosksPath+"/nobody/heartbeat", body, "Authorization", "Bearer "+created.Token)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("heartbeat of missing kiosk: got status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	rec = apiRequest(r, http.MethodPost, kiosksPath+"/lobby/command", `{"action": "show", "dashboard": "network"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("show dashboard: got status %d: %s", rec.Code, rec.Body)
+	}
+	rec = apiRequest(r, http.MethodPost, heartbeat, body, "Authorization", "Bearer "+created.Token, "User-Agent", "kiosk-browser")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("heartbeat: got status %d: %s", rec.Code, rec.Body)
+	}
+	var target struct{ Path string }
+	if err := json.Unmarshal(rec.Body.Bytes(), &target); err != nil {
+		t.Fatal(err)
+	}
+	if target.Path != "/network/view" {
+		t.Errorf("kiosk told to show %q, want /network/view", target.Path)
+	}
+
+	rec = apiRequest(r, http.MethodGet, kiosksPath+"/lobby", "")
+	var info kioskInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if !info.Online || info.Status == nil || info.Status.UserAgent != "kiosk-browser" || info.Status.Screen != "1920x1080" {
+		t.Errorf("got kiosk %+v, want online with reported status", info)
+	}
+
+	for _, cmd := range []string{`{"action": "explode"}`, `{"action": "show"}`} {
+		rec = apiRequest(r, http.MethodPost, kiosksPath+"/lobby/command", cmd)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("command %s: got status %d, want %d", cmd, rec.Code, http.StatusBadRequest)
+		}
+	}
+	rec = apiRequest(r, http.MethodPost, kiosksPath+"/lobby/command", `{"action": "blank"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("blank: got status %d: %s", rec.Code, rec.Body)
+	}
+	rec = apiRequest(r, http.MethodPost, heartbeat, body, "Authorization", "Bearer "+created.Token)
+	if err := json.Unmarshal(rec.Body.Bytes(), &target); err != nil {
+		t.Fatal(err)
+	}
+	if target.Path != "" {
+		t.Errorf("blanked kiosk told to show %q", target.Path)
+	}
+
+	rec = apiRequest(r, http.MethodPost, kiosksPath+"/lobby/token", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("new token: got status %d: %s", rec.Code, rec.Body)
+	}
+	rec = apiRequest(r, http.MethodPost, heartbeat, body, "Authorization", "Bearer "+created.Token)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("heartbeat with replaced token: got status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestKioskAccess(t *testing.T) {
+	r := newAPITestServer(t)
+	for _, body := range []string{`{"title": "Ops", "folder": "ops"}`, `{"title": "Secret", "folder": "ops"}`} {
+		if rec := apiRequest(r, http.MethodPost, apiPrefix, body); rec.Code != http.StatusCreated {
+			t.Fatalf("create dashboard: got status %d: %s", rec.Code, rec.Body)
+		}
+	}
+	if rec := apiRequest(r, http.MethodPost, playlistsPath, `{"title": "Tour", "folder": "ops", "entries": [{"slug": "secret", "dwell": 30}]}`); rec.Code != http.StatusCreated {
+		t.Fatalf("create playlist: got status %d: %s", rec.Code, rec.Body)
+	}
+	rec := apiRequest(r, http.MethodPost, kiosksPath, `{"name": "lobby", "dashboard": "ops"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create kiosk: got status %d: %s", rec.Code, rec.Body)
+	}
+	var created struct{ Token string }
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+
+	var err error
+	access, err = meerkat.LoadAccessControl(filepath.Join(t.TempDir(), accessFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { access = nil }()
+	if err := access.PutUser(meerkat.User{Name: "admin", Role: meerkat.RoleAdmin}, "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := access.SetFolder(meerkat.FolderACL{Folder: "ops", Viewers: []string{"admin"}}); err != nil {
+		t.Fatal(err)
+	}
+	admin := login(t, "admin", "hunter2")
+
+	var cookie *http.Cookie
+	heartbeat := func() {
+		t.Helper()
+		rec := apiRequest(r, http.MethodPost, kiosksPath+"/lobby/heartbeat", `{"showing": ""}`, "Authorization", "Bearer "+created.Token)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("heartbeat: got status %d: %s", rec.Code, rec.Body)
+		}
+		for _, c := range rec.Result().Cookies() {
+			if c.Name == kioskCookie {
+				cookie = c
+			}
+		}
+		if cookie == nil {
+			t.Fatal("heartbeat set no kiosk cookie")
+		}
+	}
+	get := func(target string, c *http.Cookie) int {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if c != nil {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	heartbeat()
+	tests := []struct {
+		target string
+		want   int
+	}{
+		{apiPrefix + "/ops/view", http.StatusOK},
+		{apiPrefix + "/ops", http.StatusUnauthorized},
+		{apiPrefix + "/secret/view", http.StatusUnauthorized},
+		{playlistsPath + "/tour/position", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if code := get(tt.target, cookie); code != tt.want {
+			t.Errorf("kiosk showing ops: get %s: got status %d, want %d", tt.target, code, tt.want)
+		}
+	}
+	if code := get(apiPrefix+"/ops/view", nil); code != http.StatusUnauthorized {
+		t.Errorf("anonymous: got status %d, want %d", code, http.StatusUnauthorized)
+	}
+	forged := &http.Cookie{Name: kioskCookie, Value: "lobby.forged"}
+	if code := get(apiPrefix+"/ops/view", forged); code != http.StatusUnauthorized {
+		t.Errorf("forged kiosk cookie: got status %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	rec = apiRequest(r, http.MethodPost, kiosksPath+"/lobby/command", `{"action": "show", "playlist": "tour"}`, "Cookie", admin.String())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("show playlist: got status %d: %s", rec.Code, rec.Body)
+	}
+	heartbeat()
+	tests = []struct {
+		target string
+		want   int
+	}{
+		{apiPrefix + "/secret/view", http.StatusOK},
+		{apiPrefix + "/ops/view", http.StatusUnauthorized},
+		{playlistsPath + "/tour", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if code := get(tt.target, cookie); code != tt.want {
+			t.Errorf("kiosk showing tour: get %s: got status %d, want %d", tt.target, code, tt.want)
+		}
+	}
+	// The playlist is not playing without Icinga, but the kiosk may ask.
+	if code := get(playlistsPath+"/tour/position", cookie); code == http.StatusUnauthorized {
+		t.Errorf("kiosk showing tour: position of tour refused")
+	}
+}
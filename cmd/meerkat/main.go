@@ -11,6 +11,9 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -25,76 +28,230 @@ var config Config
 var server *sse.Server
 var icingaLog log.Logger
 var status Status
-var requestList []Requests
-var dashboardCache map[string][]ElementStore
+
+// dashboardCache holds the elements showing Icinga objects on each
+// dashboard, keyed by dashboard slug then element ID.
+var dashboardCache map[string]map[string]ElementStore
 var mapLock = &sync.RWMutex{}
 var cache *ristretto.Cache
 
 type ElementStore struct {
-	Name      string   `json:"name"`
-	Type      string   `json:"type"`
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Backend names the backend the element's objects are
+	// from. Empty means the default one.
+	Backend   string   `json:"backend,omitempty"`
 	LastEvent Result   `json:"last_event"`
 	Objects   []string `json:"objects"`
 }
 
-var eventList EventList
+// backend returns the name of the backend the element's objects are from.
+func (e ElementStore) backend() string {
+	if e.Backend == "" {
+		return defaultBackend
+	}
+	return e.Backend
+}
+
+// elementStores returns the entries of dashboardCache for the elements
+// of dashboard. Entries in previous for elements showing the same
+// object as before are kept, so editing a dashboard keeps the state
+// of elements which are unchanged or only moved.
+func elementStores(dashboard meerkat.Dashboard, previous map[string]ElementStore) map[string]ElementStore {
+	elements := make(map[string]ElementStore)
+	for _, element := range dashboard.Elements {
+		if len(element.Options.ObjectName) == 0 {
+			continue
+		}
+		store := ElementStore{ID: element.ID, Name: element.Options.ObjectName, Type: element.Options.ObjectType, Backend: element.Options.Backend}
+		if store.Backend == "" {
+			store.Backend = dashboard.Backend
+		}
+		if old, ok := previous[element.ID]; ok && old.Name == store.Name && old.Type == store.Type && old.Backend == store.Backend {
+			store = old
+		}
+		elements[element.ID] = store
+	}
+	return elements
+}
+
+// cachedElements returns a copy of the cached elements of the
+// dashboard slug, ordered by ID.
+func cachedElements(slug string) []ElementStore {
+	mapLock.RLock()
+	defer mapLock.RUnlock()
+	elements := make([]ElementStore, 0, len(dashboardCache[slug]))
+	for _, element := range dashboardCache[slug] {
+		elements = append(elements, element)
+	}
+	sort.Slice(elements, func(i, j int) bool { return elements[i].ID < elements[j].ID })
+	return elements
+}
+
+// setLastEvent records result as the last event shown by the element id
+// of the dashboard slug, if the element is still cached.
+func setLastEvent(slug, id string, result Result) {
+	mapLock.Lock()
+	defer mapLock.Unlock()
+	if element, ok := dashboardCache[slug][id]; ok {
+		element.LastEvent = result
+		dashboardCache[slug][id] = element
+	}
+}
 
 func updateDashboardCache(slug string) {
 	mapLock.Lock()
 	defer mapLock.Unlock()
-	dashboard, err := meerkat.ReadDashboard(path.Join("dashboards", slug+".json"))
+	cacheDashboard(slug)
+	// Instances are shown with the elements of their template,
+	// so change along with it. Keys of views with other variables
+	// contain a query string; see watchView.
+	dashboardSync.Range(func(key, value interface{}) bool {
+		if d := value.(Dashboard); d.Template == slug && !strings.Contains(key.(string), "?") {
+			cacheDashboard(d.Slug)
+		}
+		return true
+	})
+}
 
-	dash, ok := dashboardSync.Load(slug)
-	if ok {
-		d := dash.(Dashboard)
-		d.Folder = dashboard.Folder
-		d.Title = dashboard.Title
-		d.Order = dashboard.Order
-		dashboardSync.Store(slug, d)
+// cacheDashboard updates the cached state of the dashboard slug.
+// Views of the dashboard with other values of its variables are
+// forgotten; their viewers reload the dashboard, tracking them again.
+// mapLock must be held.
+func cacheDashboard(slug string) {
+	dashboard, err := meerkat.LoadDashboard(store, slug)
+	if err == nil {
+		dashboard, err = meerkat.ResolveDashboard(store, dashboard)
 	}
-
 	if err != nil {
 		log.Println("Error reading dashboard:", err)
 		return
 	}
-	server.CreateStream(dashboard.Slug)
-	dashboardCache[dashboard.Slug] = nil
-	for _, element := range dashboard.Elements {
-		if len(element.Options.ObjectName) != 0 {
-			dashboardCache[dashboard.Slug] = append(dashboardCache[dashboard.Slug], ElementStore{Name: element.Options.ObjectName, Type: element.Options.ObjectType})
+
+	d := Dashboard{Slug: slug, CurrentlyOpenBy: []string{}}
+	if dash, ok := dashboardSync.Load(slug); ok {
+		d = dash.(Dashboard)
+	}
+	d.Folder = dashboard.Folder
+	d.Title = dashboard.Title
+	d.Order = dashboard.Order
+	d.Template = dashboard.Template
+	d.Backend = dashboard.Backend
+	dashboardSync.Store(slug, d)
+
+	for key := range dashboardCache {
+		if strings.HasPrefix(key, slug+"?") {
+			dropView(key)
 		}
 	}
+	server.CreateStream(slug)
+	dashboardCache[slug] = elementStores(dashboard.Expand(nil), dashboardCache[slug])
+}
+
+// maxViews limits the number of views of each dashboard with other
+// variables tracked at once, as anyone who may view a dashboard can
+// create them.
+const maxViews = 32
+
+// watchView tracks the state of the elements of a view of the resolved
+// dashboard d with the variables vars, which override those stored.
+// It returns the name of the event stream of the view: the dashboard's
+// slug if vars changes nothing, or one shared by views with the same
+// variables. Views with other variables are forgotten once their last
+// viewer leaves the event stream.
+func watchView(d meerkat.Dashboard, vars map[string]string) (string, error) {
+	overrides := make(url.Values)
+	for k, v := range vars {
+		if stored, ok := d.Variables[k]; !ok || stored != v {
+			overrides.Set(k, v)
+		}
+	}
+	if len(overrides) == 0 {
+		return d.Slug, nil
+	}
+	key := d.Slug + "?" + overrides.Encode()
+	mapLock.Lock()
+	defer mapLock.Unlock()
+	if _, ok := dashboardCache[key]; !ok {
+		var views, unwatched []string
+		for k := range dashboardCache {
+			if strings.HasPrefix(k, d.Slug+"?") {
+				views = append(views, k)
+				if v, ok := dashboardSync.Load(k); ok && len(v.(Dashboard).CurrentlyOpenBy) == 0 {
+					unwatched = append(unwatched, k)
+				}
+			}
+		}
+		if len(views) >= maxViews {
+			// Views never watched, such as those requested without
+			// following their event stream, make way for new ones.
+			if len(views)-len(unwatched) >= maxViews {
+				return "", &apiError{http.StatusServiceUnavailable, fmt.Sprintf("too many views of dashboard %s with other variables", d.Slug)}
+			}
+			for _, k := range unwatched {
+				dropView(k)
+			}
+		}
+		server.CreateStream(key)
+		dashboardCache[key] = elementStores(d.Expand(vars), nil)
+		dashboardSync.Store(key, Dashboard{
+			Title:           d.Title,
+			Slug:            key,
+			Folder:          d.Folder,
+			CurrentlyOpenBy: []string{},
+			Order:           d.Order,
+			Template:        d.Template,
+			Backend:         d.Backend,
+		})
+	}
+	return key, nil
+}
+
+// dropView stops tracking the view key of a dashboard with other variables.
+// mapLock must be held.
+func dropView(key string) {
+	delete(dashboardCache, key)
+	dashboardSync.Delete(key)
+	server.RemoveStream(key)
 }
 
 func createDashboardCache() {
 	mapLock.Lock()
 	defer mapLock.Unlock()
+	for key := range dashboardCache {
+		if strings.Contains(key, "?") {
+			server.RemoveStream(key)
+		}
+	}
 	dashboardSync.Range(func(key interface{}, value interface{}) bool {
 		dashboardSync.Delete(key)
 		return true
 	})
-	dashboards, err := meerkat.ReadDashboardDir("dashboards")
+	dashboards, err := meerkat.LoadDashboards(store)
 	if err != nil {
 		log.Println("Error reading dashboards:", err)
 		return
 	}
 	cache.Clear()
-	dashboardCache = make(map[string][]ElementStore)
+	dashboardCache = make(map[string]map[string]ElementStore)
 	for _, dashboard := range dashboards {
+		resolved, err := meerkat.ResolveDashboard(store, dashboard)
+		if err != nil {
+			log.Println("Error reading dashboard:", err)
+			continue
+		}
 		dashboardSync.Store(dashboard.Slug, Dashboard{
 			Title:           dashboard.Title,
 			Slug:            dashboard.Slug,
 			Folder:          dashboard.Folder,
 			CurrentlyOpenBy: []string{},
-			Order:           dashboard.Order,
+			Order:           resolved.Order,
+			Template:        dashboard.Template,
+			Backend:         resolved.Backend,
 		})
 		server.CreateStream(dashboard.Slug)
-
-		for _, element := range dashboard.Elements {
-			if len(element.Options.ObjectName) != 0 {
-				dashboardCache[dashboard.Slug] = append(dashboardCache[dashboard.Slug], ElementStore{Name: element.Options.ObjectName, Type: element.Options.ObjectType})
-			}
-		}
+		dashboardCache[dashboard.Slug] = elementStores(resolved.Expand(nil), nil)
 	}
 }
 
@@ -103,10 +260,15 @@ func main() {
 	configFile := flag.String("config", defaultConfigPath, "load configuration from this file")
 	vflag := flag.Bool("v", false, "build version information")
 	fflag := flag.String("ui", "", "user interface directory")
+	dflag := flag.String("data", "", "data directory, overriding DataDirectory in the config file")
 	flag.Parse()
 
 	if *vflag {
-		log.Println("Application Version:", meerkat.VersionString())
+		dir := *dflag
+		if dir == "" {
+			dir = "."
+		}
+		log.Println("Application Version:", meerkat.VersionString(dir))
 		log.Println(meerkat.BuildString())
 		return
 	}
@@ -123,26 +285,34 @@ func main() {
 	if err != nil {
 		log.Fatalln("parse icinga url:", err)
 	}
-
-	// Make directories for dashboards and related assets
-	if err := os.MkdirAll("dashboards", 0755); err != nil {
-		log.Fatalln("Error creating dashboards directory:", err)
+	if *dflag != "" {
+		config.DataDirectory = *dflag
 	}
-
-	if err := os.MkdirAll("dashboards-background", 0755); err != nil {
-		log.Fatalln("Error creating dashboards background directory:", err)
+	if config.DataDirectory, err = filepath.Abs(config.DataDirectory); err != nil {
+		log.Fatalln("resolve data directory:", err)
 	}
-
-	if err := os.MkdirAll("dashboards-sound", 0755); err != nil {
-		log.Fatalln("Error creating dashboards sound directory:", err)
+	log.Println("Using data directory", config.DataDirectory)
+	// Files such as certificates are relative to the data directory.
+	backends = make(map[string]*backend)
+	for name, conf := range config.backendConfigs() {
+		if backends[name], err = newBackend(name, conf); err != nil {
+			log.Fatalf("backend %s: %v", name, err)
+		}
 	}
-
-	if err := os.MkdirAll(config.LogDirectory, 0755); err != nil {
-		log.Fatalln("Error creating log directory:", err)
+	checks, err := checkDirs(config.DataDirectory, dashboardDir, backgroundDir, soundDir, config.LogDirectory)
+	for _, c := range checks {
+		if c.Err != nil {
+			log.Printf("Directory %s %s: %v\n", c.Path, c.State, c.Err)
+			continue
+		}
+		log.Printf("Directory %s %s\n", c.Path, c.State)
+	}
+	if err != nil {
+		log.Fatalln("check data directory:", err)
 	}
 
 	if config.LogFile {
-		f, err := os.OpenFile(config.LogDirectory+"meerkat.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+		f, err := os.OpenFile(filepath.Join(dataPath(config.LogDirectory), "meerkat.log"), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
 		if err != nil {
 			log.Fatalf("error opening file: %v", err)
 		}
@@ -156,7 +326,7 @@ func main() {
 	}
 
 	if config.IcingaDebug {
-		f, err := os.OpenFile(config.LogDirectory+"icinga_api.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+		f, err := os.OpenFile(filepath.Join(dataPath(config.LogDirectory), "icinga_api.log"), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
 		if err != nil {
 			log.Fatalf("error opening file: %v", err)
 		}
@@ -172,11 +342,57 @@ func main() {
 		}
 	}
 
+	access, err = meerkat.LoadAccessControl(dataPath(accessFile))
+	if err != nil {
+		log.Fatalln("load access list:", err)
+	}
+	store, err = openStore(config.Storage)
+	if err != nil {
+		log.Fatalln("open storage:", err)
+	}
+	log.Printf("Storing dashboards in %s storage\n", config.Storage.Type)
+	shareKey, err := meerkat.LoadShareKey(store)
+	if err != nil {
+		log.Fatalln("load share key:", err)
+	}
+	shareSigner = meerkat.NewShareSigner(shareKey)
+	history = meerkat.NewHistory(store)
+	archive = meerkat.NewArchive(dataPath(archiveDir))
+
+	switch flag.Arg(0) {
+	case "":
+	case "migrate":
+		if err := runMigrate(os.Stdout, flag.Args()[1:]); err != nil {
+			log.Fatalln("migrate:", err)
+		}
+		return
+	default:
+		log.Fatalf("unknown command %q\n", flag.Arg(0))
+	}
+	if !authEnabled() {
+		log.Println("Warning: no admin account or users configured; anyone may edit dashboards")
+	}
+
 	r := chi.NewRouter()
+	r.Use(identify)
+	// Routes which modify dashboards or assets are registered on
+	// edit, which requires users to be logged in. Handlers check
+	// per-folder permissions themselves.
+	edit := r.With(requireLogin)
+	admin := r.With(requireAdmin)
 	r.Get("/dashboard/{slug}", handleListDashboard)
-	r.Post("/dashboard", handleCreateDashboard)
-	r.Post("/dashboard/{slug}", handleUpdateDashboard)
-	r.Delete("/dashboard/{slug}", handleDeleteDashboard)
+	edit.Post("/dashboard", handleCreateDashboard)
+	edit.Post("/dashboard/{slug}", handleUpdateDashboard)
+	edit.Delete("/dashboard/{slug}", handleDeleteDashboard)
+	r.Get("/dashboard/{slug}/revisions", listRevisionsHandler)
+	r.Get("/dashboard/{slug}/revisions/{id}", getRevisionHandler)
+	r.Get("/dashboard/{slug}/diff", diffRevisionsHandler)
+	edit.Post("/dashboard/{slug}/revisions/{id}/restore", restoreRevisionHandler)
+	r.Get("/dashboard/{slug}/export", handleExportDashboard)
+	edit.Post("/dashboard/import", handleImportDashboard)
+	r.Route(apiPrefix, apiRoutes)
+	r.Route(playlistsPath, playlistRoutes)
+	r.Route(kiosksPath, kioskRoutes)
 
 	// Serve the Icinga API
 	if icingaURL.Host != "" {
@@ -186,29 +402,6 @@ func main() {
 		server.AutoStream = false
 		server.CreateStream("updates")
 
-		eventList = EventList{}
-		go func() {
-			for range time.Tick(time.Second) {
-				eventList.Lock()
-				oneMinuteAgo := time.Now().Add(-1 * time.Minute)
-				for i := len(eventList.events) - 1; i >= 0; i-- {
-					event := eventList.events[i]
-					if time.UnixMilli(event.ReceivedTime).Before(oneMinuteAgo) {
-						eventList.events = append(eventList.events[:i], eventList.events[i+1:]...)
-					}
-				}
-				eventList.Unlock()
-			}
-		}()
-
-		go func() {
-			for {
-				EventListener()
-				log.Println("Disconnected from event stream waiting 10 seconds")
-				time.Sleep(time.Second * 10)
-			}
-		}()
-
 		cache, err = ristretto.NewCache(&ristretto.Config{
 			NumCounters: 1e7,     // number of keys to track frequency of (10M).
 			MaxCost:     1 << 30, // maximum cost of cache (1GB).
@@ -219,79 +412,102 @@ func main() {
 		}
 
 		createDashboardCache()
+		// Backends send events only for the objects of cached dashboards.
+		for _, b := range backends {
+			go b.listen()
+		}
 		createEventStream(r)
+		if err := loadPlaylists(time.Now()); err != nil {
+			log.Println("Error loading playlists:", err)
+		}
+		go runPlaylists()
+		if len(config.Archive.Dashboards) > 0 {
+			go runArchive(config.Archive)
+		}
 	}
 
 	// Previous versions of meerkat served user-uploaded files from this directory.
 	// Keep serving them for backwards compatibility.
-	_, err = os.Stat("./dashboards-background")
+	_, err = os.Stat(dataPath(backgroundDir))
 	if err == nil {
-		r.Handle("/dashboards-background/*", http.StripPrefix("/dashboards-background/", http.FileServer(http.Dir("./dashboards-background"))))
+		r.Handle("/dashboards-background/*", http.StripPrefix("/dashboards-background/", http.FileServer(http.Dir(dataPath(backgroundDir)))))
 	}
 
-	_, err = os.Stat("./dashboards-sound")
+	_, err = os.Stat(dataPath(soundDir))
 	if err == nil {
-		r.Handle("/dashboards-sound/*", http.StripPrefix("/dashboards-sound/", http.FileServer(http.Dir("./dashboards-sound"))))
+		r.Handle("/dashboards-sound/*", http.StripPrefix("/dashboards-sound/", http.FileServer(http.Dir(dataPath(soundDir)))))
 	}
 
 	srv := ui.NewServer(nil)
 	if *fflag != "" {
 		srv = ui.NewServer(os.DirFS(path.Clean(*fflag)))
 	}
+	srv.Store = store
+	srv.DataDir = config.DataDirectory
+	srv.Access = access
+	srv.History = history
+	srv.Backends = backendNames()
+	if config.OIDC != nil {
+		srv.SSOLoginURL = "/login/oidc"
+	}
 	r.Get("/{slug}/view", srv.ViewHandler)
-	r.Get("/{slug}/edit", srv.EditHandler)
-	r.Get("/{slug}/delete", srv.DeletePage)
-	r.Post("/{slug}/delete", handleDeleteDashboard)
-	r.Get("/{slug}/info", srv.InfoPage)
-	r.Post("/{slug}/info", srv.EditInfoHandler)
-
-	r.Get("/api/all", getAllHandler)
-	r.Get("/api/objects", getObjectHandler)
+	r.Get("/{slug}/snapshot.{format}", snapshotHandler)
+	r.Get("/playlist/{name}/view", srv.PlaylistViewHandler)
+	r.Get("/kiosk/{name}", srv.KioskPage)
+	edit.Get("/{slug}/edit", srv.EditHandler)
+	edit.Get("/{slug}/delete", srv.DeletePage)
+	edit.Post("/{slug}/delete", handleDeleteDashboard)
+	edit.Get("/{slug}/info", srv.InfoPage)
+	edit.Post("/{slug}/info", handleEditInfo)
+	r.Get("/{slug}/history", srv.HistoryPage)
+
+	edit.Get("/api/all", getAllHandler)
+	r.With(requireShownObjects).Get("/api/objects", getObjectHandler)
+	r.Get("/api/backends", getBackendsHandler)
 	r.Get("/api/status", getStatusHandler)
-	r.Get("/api/cache/*", getCacheDashboardHandler)
+	r.Get("/api/schema/dashboard", getSchemaHandler)
+	r.Get("/api/schema/dashboard/{version}", getSchemaHandler)
+	r.Get("/api/cache/{slug}", getCacheDashboardHandler)
 	r.Get("/api/cache", getCacheHandler)
-	r.Delete("/api/cache", clearCacheHandler)
-
-	r.Get("/{slug}/update", UpdateHandler)
-
-	r.Post("/file/background", srv.UploadFileHandler("./dashboards-background", "image/"))
-	r.Delete("/file/background", srv.DeleteFileHandler("./dashboards-background"))
-	r.Post("/file/sound", srv.UploadFileHandler("./dashboards-sound", "audio/"))
-	r.Delete("/file/sound", srv.DeleteFileHandler("./dashboards-sound"))
-	r.Get("/file/sound", srv.GetSounds)
-
-	r.Get("/cache", srv.CachePage)
+	admin.Delete("/api/cache", clearCacheHandler)
+	admin.Get("/api/access", getAccessHandler)
+	admin.Put("/api/access/users/{name}", putUserHandler)
+	admin.Delete("/api/access/users/{name}", deleteUserHandler)
+	admin.Put("/api/access/folders/{folder}", putFolderHandler)
+
+	edit.Get("/{slug}/update", UpdateHandler)
+
+	edit.Post("/file/background", srv.UploadFileHandler(dataPath(backgroundDir), "image/"))
+	edit.Delete("/file/background", srv.DeleteFileHandler(dataPath(backgroundDir)))
+	edit.Post("/file/sound", srv.UploadFileHandler(dataPath(soundDir), "audio/"))
+	edit.Delete("/file/sound", srv.DeleteFileHandler(dataPath(soundDir)))
+	edit.Get("/file/sound", srv.GetSounds)
+
+	admin.Get("/cache", srv.CachePage)
+	admin.Get("/admin/access", srv.AccessPage)
+	admin.Get("/admin/kiosks", srv.KiosksPage)
 	r.Get("/view/*", oldPathHandler)
 	r.Get("/edit/*", oldPathHandler)
-	r.Get("/create", srv.CreatePage)
-	r.Post("/create", handleCreateDashboard)
-	r.Get("/clone", srv.ClonePage)
-	r.Post("/clone", handleCloneDashboard)
+	edit.Get("/create", srv.CreatePage)
+	edit.Post("/create", handleCreateDashboard)
+	edit.Get("/clone", srv.ClonePage)
+	edit.Post("/clone", handleCloneDashboard)
+	edit.Get("/import", srv.ImportPage)
+	edit.Post("/import", srv.ImportPage)
+	r.Get("/login", srv.LoginPage)
+	r.Post("/login", handleLogin)
+	r.Post("/logout", handleLogout)
+	r.Get("/login/oidc", handleOIDCLogin)
+	r.Get("/login/oidc/callback", handleOIDCCallback)
 	r.Get("/about", srv.AboutPage)
-	r.Get("/assets/backgrounds", srv.BackgroundPage)
-	r.Get("/assets/sounds", srv.SoundPage)
+	r.With(requireEditor).Get("/assets/backgrounds", srv.BackgroundPage)
+	r.With(requireEditor).Get("/assets/sounds", srv.SoundPage)
 	r.Get("/*", srv.FileServer().ServeHTTP)
 	r.Get("/", srv.RootHandler)
 
-	go func() {
-		var previousCheck float64
-		for {
-			currentCheck := checkProgramStart()
-			if currentCheck != 0 {
-				SetWorking()
-			} else {
-				log.Println("Icinga error current check is 0")
-				SendError()
-			}
-			if previousCheck != currentCheck && previousCheck != 0 && currentCheck != 0 {
-				log.Printf("Icinga reloaded prev: %v curr: %v\n", previousCheck, currentCheck)
-				createDashboardCache()
-				UpdateAll()
-			}
-			previousCheck = currentCheck
-			time.Sleep(30 * time.Second)
-		}
-	}()
+	for _, b := range backends {
+		go b.watchStatus()
+	}
 
 	go func() {
 		for {
@@ -301,22 +517,22 @@ func main() {
 	}()
 
 	status.Meerkat.StartTime = time.Now().UnixMilli()
-	status.Backends.Icinga.Type = "icinga"
 
 	if config.SSLEnable {
 		log.Printf("Starting https web server on https://%s\n", config.HTTPAddr)
 		if !config.LogConsole {
 			fmt.Printf("Starting https web server on https://%s\n", config.HTTPAddr)
 		}
-		_, err := os.Stat(config.SSLCert)
+		cert, key := dataPath(config.SSLCert), dataPath(config.SSLKey)
+		_, err := os.Stat(cert)
 		if os.IsNotExist(err) {
-			log.Fatalf("Invalid SSLCert Path %s does not exist\n", config.SSLCert)
+			log.Fatalf("Invalid SSLCert Path %s does not exist\n", cert)
 		}
-		_, err = os.Stat(config.SSLKey)
+		_, err = os.Stat(key)
 		if os.IsNotExist(err) {
-			log.Fatalf("Invalid SSLKey Path %s does not exist\n", config.SSLKey)
+			log.Fatalf("Invalid SSLKey Path %s does not exist\n", key)
 		}
-		log.Fatal(http.ListenAndServeTLS(config.HTTPAddr, config.SSLCert, config.SSLKey, r))
+		log.Fatal(http.ListenAndServeTLS(config.HTTPAddr, cert, key, r))
 	} else {
 		log.Printf("Starting http web server on http://%s\n", config.HTTPAddr)
 		if !config.LogConsole {
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/meerkat-dashboard/meerkat"
+)
+
+const migrateUsage = `usage: meerkat [flags] migrate [-n] [-backup dir] [-report file]
+
+Migrate upgrades every stored dashboard to the current schema version.
+`
+
+type migrateReport struct {
+	Slug string `json:"slug"`
+	*meerkat.MigrationResult
+	Error string `json:"error,omitempty"`
+}
+
+// runMigrate implements the migrate subcommand,
+// writing a summary of the dashboards migrated to w.
+func runMigrate(w io.Writer, args []string) error {
+	fset := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fset.Usage = func() {
+		fmt.Fprint(fset.Output(), migrateUsage)
+		fset.PrintDefaults()
+	}
+	dryRun := fset.Bool("n", false, "report changes without writing them")
+	backup := fset.String("backup", "", "copy dashboards to this directory before migrating them")
+	report := fset.String("report", "", "write a JSON report of all changes to this file, or - for standard output")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() > 0 {
+		fset.Usage()
+		return fmt.Errorf("unexpected arguments %v", fset.Args())
+	}
+	if *backup != "" && !*dryRun {
+		if err := os.MkdirAll(*backup, 0755); err != nil {
+			return err
+		}
+	}
+
+	keys, err := store.List("dashboards/")
+	if err != nil {
+		return fmt.Errorf("list dashboards: %w", err)
+	}
+	reports := []migrateReport{}
+	var migrated, failed int
+	for _, key := range keys {
+		slug, ok := strings.CutSuffix(strings.TrimPrefix(key, "dashboards/"), ".json")
+		if !ok || strings.Contains(slug, "/") {
+			continue
+		}
+		r := migrateReport{Slug: slug}
+		if err := migrateDashboard(slug, &r, *dryRun, *backup); err != nil {
+			r.Error = err.Error()
+			failed++
+			fmt.Fprintf(w, "%s: %v\n", slug, err)
+		} else if r.Changed() {
+			migrated++
+			fmt.Fprintf(w, "%s: schema version %d to %d\n", slug, r.From, r.To)
+		}
+		if r.MigrationResult != nil {
+			for _, note := range r.Notes {
+				fmt.Fprintf(w, "\t%s\n", note)
+			}
+		}
+		reports = append(reports, r)
+	}
+
+	verb := "Migrated"
+	if *dryRun {
+		verb = "Would migrate"
+	}
+	fmt.Fprintf(w, "%s %d of %d dashboards to schema version %d\n", verb, migrated, len(reports), meerkat.SchemaVersion)
+
+	if *report != "" {
+		b, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			return err
+		}
+		b = append(b, '\n')
+		if *report == "-" {
+			w.Write(b)
+		} else if err := os.WriteFile(*report, b, 0644); err != nil {
+			return fmt.Errorf("write report: %w", err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d dashboards could not be migrated", failed)
+	}
+	return nil
+}
+
+// migrateDashboard upgrades the stored dashboard slug, recording the
+// result in r. Unless dryRun is set, the original is first copied to
+// the backup directory, if any, and the upgraded dashboard is saved as
+// a new revision.
+func migrateDashboard(slug string, r *migrateReport, dryRun bool, backup string) error {
+	b, err := store.Get(meerkat.DashboardKey(slug))
+	if err != nil {
+		return err
+	}
+	d, result, err := meerkat.MigrateDashboard(b)
+	r.MigrationResult = result
+	if err != nil || !result.Changed() || dryRun {
+		return err
+	}
+	if backup != "" {
+		if err := os.WriteFile(filepath.Join(backup, slug+".json"), b, 0644); err != nil {
+			return fmt.Errorf("backup: %w", err)
+		}
+	}
+	if _, err := meerkat.SaveDashboard(store, slug, &d); err != nil {
+		return err
+	}
+	msg := fmt.Sprintf("Migrated from schema version %d", result.From)
+	if _, err := history.Record(slug, &d, "", msg); err != nil {
+		return fmt.Errorf("record revision: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/meerkat-dashboard/meerkat"
+)
+
+func TestMigrate(t *testing.T) {
+	dir := t.TempDir()
+	defer func(s meerkat.Store, h *meerkat.History) { store, history = s, h }(store, history)
+	store = meerkat.DirStore(dir)
+	history = meerkat.NewHistory(store)
+	old := []byte(`{"title": "Old", "elements": [{"type": "static-image", "options": {"image": "/dashboards-background/x.png"}}]}`)
+	if err := store.Put("dashboards/old.json", old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := meerkat.SaveDashboard(store, "current", &meerkat.Dashboard{Title: "Current"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runMigrate(&out, []string{"-n"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Would migrate 1 of 2 dashboards") {
+		t.Errorf("unexpected dry run output:\n%s", out.String())
+	}
+	if b, _ := store.Get("dashboards/old.json"); !bytes.Equal(b, old) {
+		t.Errorf("dry run modified dashboard: %s", b)
+	}
+
+	backup := filepath.Join(dir, "backup")
+	report := filepath.Join(dir, "report.json")
+	out.Reset()
+	if err := runMigrate(&out, []string{"-backup", backup, "-report", report}); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(filepath.Join(backup, "old.json")); err != nil || !bytes.Equal(b, old) {
+		t.Errorf("dashboard not backed up: %v", err)
+	}
+	d, err := meerkat.LoadDashboard(store, "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Elements[0].Type != "image" {
+		t.Errorf("dashboard not migrated: got element type %s", d.Elements[0].Type)
+	}
+	if revs, _ := history.Revisions("old"); len(revs) != 1 {
+		t.Errorf("got %d revisions after migrating, want 1", len(revs))
+	}
+
+	b, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reports []migrateReport
+	if err := json.Unmarshal(b, &reports); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 2 || reports[1].Slug != "old" || reports[1].From != 2 {
+		t.Errorf("unexpected report %s", b)
+	}
+
+	out.Reset()
+	if err := runMigrate(&out, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Migrated 0 of 2") {
+		t.Errorf("dashboards migrated twice:\n%s", out.String())
+	}
+}
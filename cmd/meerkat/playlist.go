@@ -0,0 +1,340 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/meerkat-dashboard/meerkat"
+	"github.com/r3labs/sse/v2"
+)
+
+// playlistsPath is the path of the API for managing playlists.
+const playlistsPath = "/api/v1/playlists"
+
+// playlistRoutes registers the playlist API handlers on r.
+func playlistRoutes(r chi.Router) {
+	r.Get("/", apiListPlaylists)
+	r.Post("/", apiCreatePlaylist)
+	r.Get("/{name}", apiGetPlaylist)
+	r.Put("/{name}", apiReplacePlaylist)
+	r.Delete("/{name}", apiDeletePlaylist)
+	r.Get("/{name}/position", apiPlaylistPosition)
+}
+
+// A playlistPosition is the dashboard a playlist is showing.
+type playlistPosition struct {
+	Index int    `json:"index"`
+	Slug  string `json:"slug"`
+	// Since is when the dashboard was first shown, in Unix milliseconds.
+	Since int64 `json:"since"`
+	// Held reports whether the dashboard is being shown past its
+	// dwell time as it shows a critical state.
+	Held bool `json:"held"`
+}
+
+type playlistState struct {
+	playlist meerkat.Playlist
+	position playlistPosition
+}
+
+// playlistStates holds the position of each playlist, keyed by name.
+// Screens showing a playlist follow it, so all show the same dashboard.
+var (
+	playlistLock   sync.Mutex
+	playlistStates = make(map[string]*playlistState)
+)
+
+// playlistStream returns the name of the event stream of the playlist name.
+func playlistStream(name string) string {
+	return "playlist/" + name
+}
+
+// loadPlaylists starts playing every stored playlist from its first dashboard.
+func loadPlaylists(now time.Time) error {
+	playlists, err := meerkat.LoadPlaylists(store)
+	if err != nil {
+		return err
+	}
+	for i := range playlists {
+		setPlaylist(playlists[i].Name, &playlists[i], now)
+	}
+	return nil
+}
+
+// setPlaylist plays p as the playlist name, or stops playing it if p is nil.
+// A playlist which is already playing keeps its position if it is still
+// showing the same dashboard; otherwise it starts from the beginning.
+// Playlists are only played while events are streamed from Icinga.
+func setPlaylist(name string, p *meerkat.Playlist, now time.Time) {
+	if server == nil {
+		return
+	}
+	playlistLock.Lock()
+	defer playlistLock.Unlock()
+	if p == nil {
+		delete(playlistStates, name)
+		return
+	}
+	server.CreateStream(playlistStream(name))
+	state, ok := playlistStates[name]
+	if ok {
+		i := state.position.Index
+		if i < len(p.Entries) && p.Entries[i].Slug == state.position.Slug {
+			state.playlist = *p
+			return
+		}
+	}
+	state = &playlistState{playlist: *p, position: playlistPosition{Since: now.UnixMilli()}}
+	if len(p.Entries) > 0 {
+		state.position.Slug = p.Entries[0].Slug
+	}
+	playlistStates[name] = state
+	publishPosition(name, state.position)
+}
+
+// advancePlaylists moves each playlist on to its next dashboard once
+// the current one has been shown for its dwell time, unless it is held
+// as it shows a critical state, and tells the screens showing it.
+func advancePlaylists(now time.Time) {
+	playlistLock.Lock()
+	defer playlistLock.Unlock()
+	for name, state := range playlistStates {
+		entries := state.playlist.Entries
+		if len(entries) == 0 {
+			continue
+		}
+		entry := entries[state.position.Index]
+		if now.Sub(time.UnixMilli(state.position.Since)) < time.Duration(entry.Dwell)*time.Second {
+			continue
+		}
+		if entry.HoldCritical && showsCritical(entry.Slug) {
+			if !state.position.Held {
+				state.position.Held = true
+				publishPosition(name, state.position)
+			}
+			continue
+		}
+		next := nextEntry(entries, state.position.Index)
+		state.position = playlistPosition{Index: next, Slug: entries[next].Slug, Since: now.UnixMilli()}
+		publishPosition(name, state.position)
+	}
+}
+
+// nextEntry returns the index of the entry after i,
+// skipping dashboards which no longer exist.
+func nextEntry(entries []meerkat.PlaylistEntry, i int) int {
+	for n := 1; n <= len(entries); n++ {
+		next := (i + n) % len(entries)
+		if _, ok := dashboardSync.Load(entries[next].Slug); ok {
+			return next
+		}
+	}
+	return (i + 1) % len(entries)
+}
+
+// showsCritical reports whether any element of the dashboard slug shows
+// a state at least as severe as critical in the dashboard's severity
+// order, as last seen by Meerkat.
+func showsCritical(slug string) bool {
+	v, ok := dashboardSync.Load(slug)
+	if !ok {
+		return false
+	}
+	dashboard := v.(Dashboard)
+	for _, element := range cachedElements(slug) {
+		if result, ok := worstResult(element, dashboard); ok && getPriority(result, dashboard) <= dashboard.Order.Critical {
+			return true
+		}
+	}
+	return false
+}
+
+func publishPosition(name string, position playlistPosition) {
+	b, err := json.Marshal(position)
+	if err != nil {
+		log.Println("encode playlist position:", err)
+		return
+	}
+	server.Publish(playlistStream(name), &sse.Event{Data: b})
+}
+
+// runPlaylists advances playlists every second.
+func runPlaylists() {
+	for now := range time.Tick(time.Second) {
+		advancePlaylists(now)
+	}
+}
+
+// loadPlaylist returns the playlist name, which the user making req
+// must be allowed to view.
+func loadPlaylist(req *http.Request, name string) (meerkat.Playlist, error) {
+	p, err := meerkat.LoadPlaylist(store, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return p, fmt.Errorf("no playlist %s: %w", name, fs.ErrNotExist)
+	} else if err != nil {
+		return p, err
+	}
+	if !canView(req, p.Folder) && !kioskPlaying(req, name) {
+		return p, errDenied
+	}
+	return p, nil
+}
+
+func writePlaylist(w http.ResponseWriter, b []byte, status int, name string) {
+	w.Header().Set("Content-Type", "application/json")
+	if status == http.StatusCreated {
+		w.Header().Set("Location", path.Join(playlistsPath, name))
+	}
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+// apiListPlaylists lists the playlists the user may view.
+func apiListPlaylists(w http.ResponseWriter, req *http.Request) {
+	playlists, err := meerkat.LoadPlaylists(store)
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	visible := []meerkat.Playlist{}
+	for _, p := range playlists {
+		if canView(req, p.Folder) {
+			visible = append(visible, p)
+		}
+	}
+	writeJSON(w, visible)
+}
+
+func apiGetPlaylist(w http.ResponseWriter, req *http.Request) {
+	p, err := loadPlaylist(req, chi.URLParam(req, "name"))
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	writeJSON(w, p)
+}
+
+func apiCreatePlaylist(w http.ResponseWriter, req *http.Request) {
+	var p meerkat.Playlist
+	if err := decodeBody(req, &p); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	if !canEdit(req, p.Folder) {
+		writeError(w, req, errDenied)
+		return
+	}
+	if err := p.Validate(); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	name := meerkat.TitleToSlug(p.Title)
+	saveLock.Lock()
+	defer saveLock.Unlock()
+	_, err := store.Get(meerkat.PlaylistKey(name))
+	if err == nil {
+		writeError(w, req, fmt.Errorf("playlist %s already exists: %w", name, fs.ErrExist))
+		return
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		writeError(w, req, err)
+		return
+	}
+	b, err := meerkat.SavePlaylist(store, &p)
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	setPlaylist(p.Name, &p, time.Now())
+	log.Printf("Created playlist %s\n", p.Name)
+	writePlaylist(w, b, http.StatusCreated, p.Name)
+}
+
+// apiReplacePlaylist replaces a playlist with the one in the request body.
+// Its title may change, but not the name derived from it.
+func apiReplacePlaylist(w http.ResponseWriter, req *http.Request) {
+	name := chi.URLParam(req, "name")
+	var p meerkat.Playlist
+	if err := decodeBody(req, &p); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	if err := p.Validate(); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	if meerkat.TitleToSlug(p.Title) != name {
+		writeError(w, req, badRequest("title %q would rename playlist %s", p.Title, name))
+		return
+	}
+	saveLock.Lock()
+	defer saveLock.Unlock()
+	old, err := loadPlaylist(req, name)
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	if !canEdit(req, old.Folder) || !canEdit(req, p.Folder) {
+		writeError(w, req, errDenied)
+		return
+	}
+	b, err := meerkat.SavePlaylist(store, &p)
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	setPlaylist(name, &p, time.Now())
+	log.Printf("Updated playlist %s\n", name)
+	writePlaylist(w, b, http.StatusOK, name)
+}
+
+func apiDeletePlaylist(w http.ResponseWriter, req *http.Request) {
+	name := chi.URLParam(req, "name")
+	saveLock.Lock()
+	defer saveLock.Unlock()
+	p, err := loadPlaylist(req, name)
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	if !canEdit(req, p.Folder) {
+		writeError(w, req, errDenied)
+		return
+	}
+	if err := meerkat.DeletePlaylist(store, name); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	setPlaylist(name, nil, time.Now())
+	log.Printf("Deleted playlist %s\n", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiPlaylistPosition responds with the dashboard a playlist is showing,
+// for screens to catch up with when they start following it.
+func apiPlaylistPosition(w http.ResponseWriter, req *http.Request) {
+	name := chi.URLParam(req, "name")
+	if _, err := loadPlaylist(req, name); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	playlistLock.Lock()
+	state, ok := playlistStates[name]
+	var position playlistPosition
+	if ok {
+		position = state.position
+	}
+	playlistLock.Unlock()
+	if !ok {
+		writeError(w, req, &apiError{http.StatusServiceUnavailable, fmt.Sprintf("playlist %s is not playing", name)})
+		return
+	}
+	writeJSON(w, position)
+}
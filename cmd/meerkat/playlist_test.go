@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+	"github.com/meerkat-dashboard/meerkat"
+)
+
+func TestPlaylists(t *testing.T) {
+	h := newAPITestServer(t)
+	oldCache := cache
+	t.Cleanup(func() { cache = oldCache })
+	cache, _ = ristretto.NewCache(&ristretto.Config{NumCounters: 1e3, MaxCost: 1 << 20, BufferItems: 64})
+	order := meerkat.Order{Ok: 6, Warning: 4, Critical: 0, Unknown: 2, CriticalAck: 1, WarningAck: 5, UnknownAck: 3}
+	for _, slug := range []string{"network", "servers"} {
+		dashboardSync.Store(slug, Dashboard{Slug: slug, Order: order})
+	}
+
+	body := `{"title": "NOC Wall", "folder": "ops", "entries": [
+		{"slug": "network", "dwell": 30, "holdCritical": true},
+		{"slug": "deleted", "dwell": 30},
+		{"slug": "servers", "dwell": 60}
+	]}`
+	rec := apiRequest(h, http.MethodPost, playlistsPath, body)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create playlist: got status %d: %s", rec.Code, rec.Body)
+	}
+	if loc := rec.Header().Get("Location"); loc != playlistsPath+"/noc-wall" {
+		t.Errorf("got location %q", loc)
+	}
+	rec = apiRequest(h, http.MethodPost, playlistsPath, body)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("create duplicate playlist: got status %d, want %d", rec.Code, http.StatusConflict)
+	}
+	rec = apiRequest(h, http.MethodPut, playlistsPath+"/noc-wall", `{"title": "Other", "entries": []}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("rename playlist: got status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	rec = apiRequest(h, http.MethodPost, playlistsPath, `{"title": "Bad", "entries": [{"slug": "network"}]}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("create invalid playlist: got status %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+
+	position := func() playlistPosition {
+		t.Helper()
+		rec := apiRequest(h, http.MethodGet, playlistsPath+"/noc-wall/position", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("get position: got status %d: %s", rec.Code, rec.Body)
+		}
+		var p playlistPosition
+		if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	start := time.UnixMilli(position().Since)
+	if p := position(); p.Slug != "network" {
+		t.Fatalf("playlist starts at %q, want network", p.Slug)
+	}
+
+	advancePlaylists(start.Add(10 * time.Second))
+	if p := position(); p.Slug != "network" {
+		t.Errorf("advanced to %q before dwell time elapsed", p.Slug)
+	}
+
+	dashboardCache["network"] = map[string]ElementStore{
+		"e1": {ID: "e1", LastEvent: Result{Name: "router", Attrs: Attr{State: 2}}},
+	}
+	advancePlaylists(start.Add(31 * time.Second))
+	if p := position(); p.Slug != "network" || !p.Held {
+		t.Errorf("got position %+v, want network held while critical", p)
+	}
+
+	dashboardCache["network"] = map[string]ElementStore{
+		"e1": {ID: "e1", LastEvent: Result{Name: "router", Attrs: Attr{State: 0}}},
+	}
+	now := start.Add(40 * time.Second)
+	advancePlaylists(now)
+	if p := position(); p.Slug != "servers" || p.Index != 2 || p.Held {
+		t.Errorf("got position %+v, want servers, skipping a deleted dashboard", p)
+	}
+	advancePlaylists(now.Add(61 * time.Second))
+	if p := position(); p.Slug != "network" {
+		t.Errorf("got position %+v, want to wrap around to network", p)
+	}
+
+	rec = apiRequest(h, http.MethodDelete, playlistsPath+"/noc-wall", "")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete playlist: got status %d: %s", rec.Code, rec.Body)
+	}
+	if _, ok := playlistStates["noc-wall"]; ok {
+		t.Error("deleted playlist is still playing")
+	}
+}
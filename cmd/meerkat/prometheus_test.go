@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/meerkat-dashboard/meerkat"
+	"github.com/meerkat-dashboard/meerkat/backend/prometheus"
+	"github.com/meerkat-dashboard/meerkat/backend/prometheus/promtest"
+)
+
+func TestPrometheus(t *testing.T) {
+	h := newAPITestServer(t)
+	newIcingaTestServer(t)
+	srv := promtest.NewServer()
+	defer srv.Close()
+	srv.SetAlerts(promtest.Alert{
+		Labels:      map[string]string{"alertname": "HostDown", "severity": "critical"},
+		Annotations: map[string]string{"summary": "router is down"},
+	})
+	srv.SetQuery("sum(up)", promtest.Sample{Value: 3})
+	b, err := newBackend("prom", BackendConfig{Type: typePrometheus, URLs: []string{srv.URL}, AlertmanagerURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.api.(*prometheus.Backend).Interval = 10 * time.Millisecond
+	backends["prom"] = b
+
+	body := `{"title": "Network", "backend": "prom", "elements": [
+		{"id": "e1", "type": "check-card", "options": {"objectType": "alert", "objectName": "HostDown"}},
+		{"id": "e2", "type": "check-card", "options": {"objectType": "metric", "objectName": "sum(up)"}}
+	]}`
+	if rec := apiRequest(h, http.MethodPost, apiPrefix, body); rec.Code != http.StatusCreated {
+		t.Fatalf("create dashboard: got status %d: %s", rec.Code, rec.Body)
+	}
+	createDashboardCache()
+	d, _ := dashboardSync.Load("network")
+	dashboard := d.(Dashboard)
+	dashboard.CurrentlyOpenBy = []string{"192.0.2.1:1234"}
+	dashboardSync.Store("network", dashboard)
+
+	objects, rec := getObjects(t, "type=alerts&name=HostDown&title=/network/view")
+	if rec.Code != http.StatusOK || len(objects.Results) != 1 {
+		t.Fatalf("get HostDown: got status %d: %s", rec.Code, rec.Body)
+	}
+	if r := objects.Results[0]; r.Attrs.State != 2 || r.Attrs.LastCheckResults.Output != "router is down" {
+		t.Errorf("HostDown: got state %d, output %q", r.Attrs.State, r.Attrs.LastCheckResults.Output)
+	}
+
+	want := []meerkat.ObjectQuery{{Type: "alerts", Name: "HostDown"}, {Type: "metrics", Name: "sum(up)"}}
+	if got := b.queries(); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("got queries %+v, want %+v", got, want)
+	}
+
+	oldInterval := resubscribeInterval
+	resubscribeInterval = 10 * time.Millisecond
+	defer func() { resubscribeInterval = oldInterval }()
+	done := make(chan error)
+	go func() {
+		done <- EventListener(b)
+	}()
+	// Wait for the first evaluation, which later ones are compared with.
+	time.Sleep(50 * time.Millisecond)
+	srv.SetAlerts()
+	for i := 0; cachedElements("network")[0].LastEvent.Name != "HostDown"; i++ {
+		if i == 100 {
+			t.Fatalf("element not updated by event: %+v", cachedElements("network")[0])
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if state := cachedElements("network")[0].LastEvent.Attrs.State; state != 0 {
+		t.Errorf("HostDown cleared: got state %d, want 0", state)
+	}
+
+	mapLock.Lock()
+	dashboardCache["network"]["e3"] = ElementStore{ID: "e3", Name: "DiskFull", Type: "alert", Backend: "prom"}
+	mapLock.Unlock()
+	select {
+	case err := <-done:
+		if !errors.Is(err, errResubscribe) {
+			t.Errorf("event listener returned %v, want %v", err, errResubscribe)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event listener did not resubscribe to new objects")
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/meerkat-dashboard/meerkat"
+)
+
+// shareParam is the query parameter share tokens are given in.
+const shareParam = "share"
+
+var shareSigner *meerkat.ShareSigner
+
+// serveShared serves req, made with the share token in its query string,
+// if the token is valid and grants access to what is requested.
+// Requests made with a share token are anonymous,
+// whether or not the client is logged in.
+func serveShared(w http.ResponseWriter, req *http.Request, next http.Handler) {
+	if shareSigner == nil {
+		http.Error(w, meerkat.ErrInvalidShare.Error(), http.StatusUnauthorized)
+		return
+	}
+	share, err := shareSigner.Verify(store, req.URL.Query().Get(shareParam), time.Now())
+	if errors.Is(err, meerkat.ErrInvalidShare) {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		log.Println("verify share token:", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if !shareAllows(req, &share) {
+		http.Error(w, "share token does not grant access to "+req.URL.Path, http.StatusForbidden)
+		return
+	}
+	next.ServeHTTP(w, req.WithContext(meerkat.NewShareContext(req.Context(), &share)))
+}
+
+// shareAllows reports whether share grants access to what req requests:
+// viewing its dashboard and its snapshots, following the dashboard's
+// event stream, and querying the objects its elements show.
+// In particular it does not grant access to the editor.
+func shareAllows(req *http.Request, share *meerkat.Share) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	slug := share.Dashboard
+	switch req.URL.Path {
+	case path.Join("/", slug, "view"), path.Join(apiPrefix, slug, "view"),
+		path.Join("/", slug, "snapshot.png"), path.Join("/", slug, "snapshot.svg"):
+		return true
+	case "/events":
+		return req.URL.Query().Get("stream") == slug
+	case "/api/objects":
+		// Shared dashboards are shown with their stored variables.
+		return shownObjects(req.URL.Query(), slug, false)
+	}
+	return false
+}
+
+// requireShownObjects wraps next so that object queries of anonymous
+// users are only served if they are for objects shown by the dashboard
+// they are made for, as made by its viewer. Logged-in users may query
+// other objects, as the editor does for elements not yet saved;
+// queries made with a share token are checked by shareAllows.
+func requireShownObjects(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if meerkat.UserFromContext(ctx) == nil && meerkat.ShareFromContext(ctx) == nil &&
+			!shownObjects(req.URL.Query(), querySlug(req), true) {
+			denied(w, req)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// shownObjects reports whether the object query q, as made by the
+// viewer, is for objects shown by the dashboard slug. If views is true,
+// the query may be for a view of the dashboard with other variables,
+// named by its stream; see watchView.
+func shownObjects(q url.Values, slug string, views bool) bool {
+	if q.Get("title") != path.Join("/", slug, "view") {
+		return false
+	}
+	vars := make(map[string]string)
+	if stream := q.Get("stream"); stream != "" && stream != slug {
+		query, ok := strings.CutPrefix(stream, slug+"?")
+		if !ok || !views {
+			return false
+		}
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			return false
+		}
+		for k := range values {
+			vars[k] = values.Get(k)
+		}
+	}
+	switch q.Get("type") {
+	case "hosts", "services", "hostgroups", "servicegroups", "alerts", "metrics":
+	default:
+		return false
+	}
+	dashboard, err := meerkat.LoadDashboard(store, slug)
+	if err != nil {
+		return false
+	}
+	dashboard, err = meerkat.ResolveDashboard(store, dashboard)
+	if err != nil {
+		return false
+	}
+	// Objects are shown from the element's backend,
+	// or the dashboard's if it names none.
+	backendOf := func(name string) string {
+		if name == "" {
+			name = dashboard.Backend
+		}
+		if name == "" {
+			return defaultBackend
+		}
+		return name
+	}
+	backend := backendOf(q.Get("backend"))
+	shown := make(map[string]bool)
+	for _, e := range dashboard.Expand(vars).Elements {
+		name := e.Options.ObjectName
+		if name == "" || backendOf(e.Options.Backend) != backend {
+			continue
+		}
+		// Groups are shown by querying their members.
+		shown[name] = true
+		shown[`"`+name+`" in host.groups`] = true
+		shown[`"`+name+`" in service.groups`] = true
+	}
+	for _, param := range []string{"name", "filter"} {
+		if v := q.Get(param); v != "" && !shown[v] {
+			return false
+		}
+	}
+	return q.Get("name") != "" || q.Get("filter") != ""
+}
+
+// sharedWith reports whether req was made with a share token
+// for the dashboard slug.
+func sharedWith(req *http.Request, slug string) bool {
+	share := meerkat.ShareFromContext(req.Context())
+	return share != nil && share.Dashboard == slug
+}
+
+// A shareLink describes a share along with the address to give out.
+type shareLink struct {
+	meerkat.Share
+	Expired bool   `json:"expired"`
+	Token   string `json:"token"`
+	URL     string `json:"url"`
+}
+
+func newShareLink(share meerkat.Share) shareLink {
+	token := shareSigner.Token(&share)
+	q := url.Values{shareParam: []string{token}}
+	return shareLink{
+		Share:   share,
+		Expired: share.Expired(time.Now()),
+		Token:   token,
+		URL:     path.Join("/", share.Dashboard, "view") + "?" + q.Encode(),
+	}
+}
+
+// editableDashboard returns the dashboard slug, which the user making
+// req must be allowed to change. Only they may share it.
+func editableDashboard(req *http.Request, slug string) (meerkat.Dashboard, error) {
+	dashboard, err := loadDashboard(req, slug)
+	if err != nil {
+		return dashboard, err
+	}
+	if !canEdit(req, dashboard.Folder) {
+		return dashboard, errDenied
+	}
+	return dashboard, nil
+}
+
+func apiListShares(w http.ResponseWriter, req *http.Request) {
+	slug := chi.URLParam(req, "slug")
+	if _, err := editableDashboard(req, slug); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	shares, err := meerkat.LoadShares(store, slug)
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	links := []shareLink{}
+	for _, share := range shares {
+		links = append(links, newShareLink(share))
+	}
+	writeJSON(w, links)
+}
+
+// apiCreateShare shares a dashboard. The request body may describe who
+// it is shared with and when the share expires, such as
+// {"note": "Acme Corp", "expires": "2024-07-01T00:00:00Z"}.
+func apiCreateShare(w http.ResponseWriter, req *http.Request) {
+	slug := chi.URLParam(req, "slug")
+	var body struct {
+		Note    string     `json:"note"`
+		Expires *time.Time `json:"expires"`
+	}
+	if err := decodeBody(req, &body); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	if _, err := editableDashboard(req, slug); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	if body.Expires != nil && !body.Expires.After(time.Now()) {
+		writeError(w, req, badRequest("expiry %s is in the past", body.Expires.Format(time.RFC3339)))
+		return
+	}
+	var user string
+	if u := meerkat.UserFromContext(req.Context()); u != nil {
+		user = u.Name
+	}
+	share, err := meerkat.NewShare(store, slug, body.Note, user, body.Expires)
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	log.Printf("Dashboard %s shared as %s by %s\n", slug, share.ID, user)
+	w.Header().Set("Location", path.Join(apiPrefix, slug, "shares", share.ID))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, newShareLink(share))
+}
+
+// apiDeleteShare revokes a share, so its token stops working.
+func apiDeleteShare(w http.ResponseWriter, req *http.Request) {
+	slug, id := chi.URLParam(req, "slug"), chi.URLParam(req, "id")
+	if _, err := editableDashboard(req, slug); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	if err := meerkat.DeleteShare(store, slug, id); errors.Is(err, fs.ErrNotExist) {
+		writeError(w, req, fmt.Errorf("no share %s of %s: %w", id, slug, fs.ErrNotExist))
+		return
+	} else if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	log.Printf("Share %s of dashboard %s revoked\n", id, slug)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/meerkat-dashboard/meerkat"
+)
+
+func TestShares(t *testing.T) {
+	h := newAPITestServer(t)
+	for _, body := range []string{`{"title": "Network", "folder": "ops"}`, `{"title": "Secret"}`} {
+		rec := apiRequest(h, http.MethodPost, apiPrefix, body)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("create dashboard: got status %d: %s", rec.Code, rec.Body)
+		}
+	}
+	rec := apiRequest(h, http.MethodPost, apiPrefix+"/network/shares", `{"note": "Acme", "expires": "2001-01-01T00:00:00Z"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("share with expiry in the past: got status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	rec = apiRequest(h, http.MethodPost, apiPrefix+"/network/shares", `{"note": "Acme"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("share dashboard: got status %d: %s", rec.Code, rec.Body)
+	}
+	var link shareLink
+	if err := json.Unmarshal(rec.Body.Bytes(), &link); err != nil {
+		t.Fatal(err)
+	}
+	share := "?" + url.Values{shareParam: []string{link.Token}}.Encode()
+
+	rec = apiRequest(h, http.MethodGet, apiPrefix+"/network/view"+share, "")
+	if rec.Code != http.StatusOK {
+		t.Errorf("view shared dashboard: got status %d: %s", rec.Code, rec.Body)
+	}
+	for _, path := range []string{apiPrefix + "/network", apiPrefix + "/secret/view", apiPrefix + "/network/shares", "/api/all", "/network/edit"} {
+		rec = apiRequest(h, http.MethodGet, path+share, "")
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("get %s with share token: got status %d, want %d", path, rec.Code, http.StatusForbidden)
+		}
+	}
+	rec = apiRequest(h, http.MethodGet, apiPrefix+"/network/view?share=forged", "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("view with forged token: got status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	rec = apiRequest(h, http.MethodGet, apiPrefix+"/network/shares", "")
+	var links []shareLink
+	if err := json.Unmarshal(rec.Body.Bytes(), &links); err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 1 || links[0].Token != link.Token {
+		t.Errorf("got shares %+v, want the one created", links)
+	}
+	rec = apiRequest(h, http.MethodDelete, apiPrefix+"/network/shares/"+link.ID, "")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("revoke share: got status %d: %s", rec.Code, rec.Body)
+	}
+	rec = apiRequest(h, http.MethodGet, apiPrefix+"/network/view"+share, "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("view with revoked token: got status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestShareAllowsPrometheus(t *testing.T) {
+	h := newAPITestServer(t)
+	dashboard := `{"title": "Alerts", "backend": "prom", "elements": [
+		{"id": "a", "type": "check-card", "options": {"objectType": "alert", "objectName": "HostDown"}},
+		{"id": "b", "type": "check-card", "options": {"objectType": "alertfilter", "objectName": "{team=\"network\"}"}},
+		{"id": "c", "type": "check-card", "options": {"objectType": "metric", "objectName": "sum(up)"}},
+		{"id": "d", "type": "check-card", "options": {"objectType": "host", "objectName": "router", "backend": "icinga"}}
+	]}`
+	if rec := apiRequest(h, http.MethodPost, apiPrefix, dashboard); rec.Code != http.StatusCreated {
+		t.Fatalf("create dashboard: got status %d: %s", rec.Code, rec.Body)
+	}
+	share := &meerkat.Share{ID: "x", Dashboard: "alerts"}
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"type=alerts&name=HostDown", true},
+		{"type=alerts&name=HostDown&backend=prom", true},
+		{"type=alerts&filter=" + url.QueryEscape(`{team="network"}`), true},
+		{"type=metrics&name=sum(up)", true},
+		{"type=alerts&name=HostDown&backend=icinga", false},
+		{"type=alerts&name=DiskFull", false},
+		{"type=hosts&name=router&backend=icinga", true},
+		{"type=hosts&name=router", false},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodGet, "/api/objects?"+tt.query+"&title=/alerts/view", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := shareAllows(req, share); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestShareAllows(t *testing.T) {
+	h := newAPITestServer(t)
+	dashboard := `{"title": "Network", "elements": [
+		{"id": "a", "type": "check-card", "options": {"objectType": "host", "objectName": "router"}},
+		{"id": "b", "type": "check-card", "options": {"objectType": "hostgroup", "objectName": "core"}}
+	]}`
+	if rec := apiRequest(h, http.MethodPost, apiPrefix, dashboard); rec.Code != http.StatusCreated {
+		t.Fatalf("create dashboard: got status %d: %s", rec.Code, rec.Body)
+	}
+	share := &meerkat.Share{ID: "x", Dashboard: "network"}
+	tests := []struct {
+		method string
+		target string
+		want   bool
+	}{
+		{http.MethodGet, "/network/view", true},
+		{http.MethodPost, "/network/view", false},
+		{http.MethodGet, "/network/edit", false},
+		{http.MethodGet, "/other/view", false},
+		{http.MethodGet, "/network/snapshot.png", true},
+		{http.MethodGet, "/other/snapshot.svg", false},
+		{http.MethodGet, "/events?stream=network", true},
+		{http.MethodGet, "/events?stream=updates", false},
+		{http.MethodGet, "/events?stream=other", false},
+		{http.MethodGet, "/api/objects?type=hosts&name=router&title=/network/view", true},
+		{http.MethodGet, "/api/objects?type=hostgroups&name=core&title=/network/view", true},
+		{http.MethodGet, "/api/objects?type=hosts&filter=%22core%22+in+host.groups&title=/network/view", true},
+		{http.MethodGet, "/api/objects?type=hosts&name=database&title=/network/view", false},
+		{http.MethodGet, "/api/objects?type=hosts&name=router&title=/other/view", false},
+		{http.MethodGet, "/api/objects?type=users&name=router&title=/network/view", false},
+		{http.MethodGet, "/api/objects?type=hosts&title=/network/view", false},
+		{http.MethodGet, "/api/all?type=hosts&title=/network/view", false},
+		{http.MethodGet, "/api/objects?type=hosts&name=router&backend=icinga&title=/network/view", true},
+		{http.MethodGet, "/api/objects?type=hosts&name=router&backend=other&title=/network/view", false},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, tt.target, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := shareAllows(req, share); got != tt.want {
+			t.Errorf("%s %s: got %v, want %v", tt.method, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestRequireShownObjects(t *testing.T) {
+	h := newAPITestServer(t)
+	dashboard := `{"title": "Site", "variables": {"site": "mel"}, "elements": [
+		{"id": "a", "type": "check-card", "options": {"objectType": "host", "objectName": "router-${site}"}}
+	]}`
+	if rec := apiRequest(h, http.MethodPost, apiPrefix, dashboard); rec.Code != http.StatusCreated {
+		t.Fatalf("create dashboard: got status %d: %s", rec.Code, rec.Body)
+	}
+	objects := requireShownObjects(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	tests := []struct {
+		query string
+		user  *meerkat.User
+		want  int
+	}{
+		{"type=hosts&name=router-mel&title=/site/view", nil, http.StatusOK},
+		{"type=hosts&name=router-syd&title=/site/view", nil, http.StatusUnauthorized},
+		{"type=hosts&name=router-syd&title=/site/view&stream=" + url.QueryEscape("site?site=syd"), nil, http.StatusOK},
+		{"type=hosts&name=router-mel&title=/site/view&stream=" + url.QueryEscape("site?site=syd"), nil, http.StatusUnauthorized},
+		{"type=hosts&name=router-syd&title=/site/view&stream=" + url.QueryEscape("other?site=syd"), nil, http.StatusUnauthorized},
+		{"type=hosts&name=database&title=/site/edit", nil, http.StatusUnauthorized},
+		{"type=hosts&name=database&title=/site/edit", &meerkat.User{Name: "alice", Role: meerkat.RoleViewer}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/objects?"+tt.query, nil)
+		if tt.user != nil {
+			req = req.WithContext(meerkat.NewUserContext(req.Context(), tt.user))
+		}
+		rec := httptest.NewRecorder()
+		objects.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s as %v: got status %d, want %d", tt.query, tt.user, rec.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/meerkat-dashboard/meerkat"
+	"github.com/meerkat-dashboard/meerkat/icinga"
+)
+
+// snapshotHandler serves an image of a dashboard showing the states of
+// its objects last seen by Meerkat, as a PNG or SVG image depending on
+// the format URL parameter. No browser is needed to render it, so it can
+// be embedded in emails, chat messages and reports.
+// If a time is given in the at query parameter, the dashboard is shown
+// with the states archived at that time instead.
+func snapshotHandler(w http.ResponseWriter, req *http.Request) {
+	slug := chi.URLParam(req, "slug")
+	format := chi.URLParam(req, "format")
+	if format != "png" && format != "svg" {
+		http.NotFound(w, req)
+		return
+	}
+	dashboard, err := loadDashboard(req, slug)
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	dashboard, err = meerkat.ResolveDashboard(store, dashboard)
+	if err != nil {
+		writeError(w, req, err)
+		return
+	}
+	snap := newSnapshot(dashboard.Expand(nil))
+	if at := req.URL.Query().Get("at"); at != "" {
+		t, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			writeError(w, req, badRequest("parse at: %v", err))
+			return
+		}
+		rec, err := archive.At(slug, t)
+		if err != nil {
+			writeError(w, req, err)
+			return
+		}
+		snap.States, snap.Time = rec.States, rec.Time
+	}
+
+	var buf bytes.Buffer
+	if format == "png" {
+		w.Header().Set("Content-Type", "image/png")
+		err = snap.WritePNG(&buf)
+	} else {
+		w.Header().Set("Content-Type", "image/svg+xml")
+		err = snap.WriteSVG(&buf)
+	}
+	if err != nil {
+		log.Printf("render snapshot of %s: %v", slug, err)
+		w.Header().Del("Content-Type")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(buf.Bytes())
+}
+
+// newSnapshot returns a snapshot of dashboard as it is now.
+// Only images stored by Meerkat are shown; others are left out
+// rather than fetched from wherever they are.
+func newSnapshot(dashboard meerkat.Dashboard) *meerkat.Snapshot {
+	snap := &meerkat.Snapshot{
+		Dashboard: dashboard,
+		Images:    make(map[string]image.Image),
+		Time:      time.Now(),
+	}
+	if dashboard.Background != "" {
+		img, err := localImage(dashboard.Background)
+		if err != nil {
+			log.Printf("snapshot of %s: load background: %v", dashboard.Slug, err)
+		}
+		snap.Background = img
+	}
+	for _, e := range dashboard.Elements {
+		if e.Type != "image" || e.Options.Image == "" {
+			continue
+		}
+		if img, err := localImage(e.Options.Image); err != nil {
+			log.Printf("snapshot of %s: load image: %v", dashboard.Slug, err)
+		} else if img != nil {
+			snap.Images[e.Options.Image] = img
+		}
+	}
+
+	snap.States = dashboardStates(dashboard.Slug)
+	return snap
+}
+
+// localImage decodes the image at ref if it is stored by Meerkat,
+// such as "/dashboards-background/network.png".
+// It returns a nil image for images stored elsewhere.
+// Images wider or taller than meerkat.MaxSnapshotSize are not decoded.
+func localImage(ref string) (image.Image, error) {
+	if !strings.HasPrefix(ref, "/"+backgroundDir+"/") {
+		return nil, nil
+	}
+	name := filepath.FromSlash(strings.TrimPrefix(ref, "/"))
+	if filepath.Dir(name) != backgroundDir {
+		return nil, fmt.Errorf("%s: not in %s", ref, backgroundDir)
+	}
+	f, err := os.Open(dataPath(name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", ref, err)
+	}
+	if config.Width > meerkat.MaxSnapshotSize || config.Height > meerkat.MaxSnapshotSize {
+		return nil, fmt.Errorf("%s: %dx%d image larger than %dx%d", ref, config.Width, config.Height, meerkat.MaxSnapshotSize, meerkat.MaxSnapshotSize)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", ref, err)
+	}
+	return img, nil
+}
+
+// objectState returns the state shown for result.
+func objectState(result Result) meerkat.ObjectState {
+	check := result.Attrs.LastCheckResults
+	return meerkat.ObjectState{
+		State:        result.Attrs.State,
+		Acknowledged: result.Attrs.Acknowledgement != 0,
+		Output:       check.Output,
+		PerfData:     icinga.PerfData(check.PerformanceData),
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/meerkat-dashboard/meerkat"
+)
+
+func TestSnapshot(t *testing.T) {
+	h := newAPITestServer(t)
+	body := `{"title": "Network", "width": "400", "height": "200", "elements": [
+		{"id": "router", "type": "check-card", "rect": {"x": 0, "y": 0, "w": 50, "h": 50},
+			"options": {"objectType": "service", "objectName": "router!ping", "objectAttr": "rta"}}
+	]}`
+	rec := apiRequest(h, http.MethodPost, apiPrefix, body)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: got status %d: %s", rec.Code, rec.Body)
+	}
+	order := meerkat.Order{Ok: 6, Warning: 4, Critical: 0, Unknown: 2, CriticalAck: 1, WarningAck: 5, UnknownAck: 3}
+	dashboardSync.Store("network", Dashboard{Slug: "network", Order: order})
+	result := Result{Name: "router!ping", Attrs: Attr{
+		State: 2,
+		LastCheckResults: LastCheckResult{
+			PerformanceData: []any{"rta=512.3ms;100;500", "pl=20%;10;50"},
+		},
+	}}
+	dashboardCache["network"] = map[string]ElementStore{
+		"router": {ID: "router", Type: "service", Objects: []string{"router!ping"}, LastEvent: result},
+	}
+
+	rec = apiRequest(h, http.MethodGet, "/network/snapshot.png", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get png: got status %d: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("got content type %q for png", ct)
+	}
+	img, err := png.Decode(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := color.NRGBA{0xff, 0x00, 0x19, 0xff}
+	if got := color.NRGBAModel.Convert(img.At(5, 5)); got != want {
+		t.Errorf("card is %v, want critical %v", got, want)
+	}
+
+	rec = apiRequest(h, http.MethodGet, "/network/snapshot.svg", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get svg: got status %d: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "image/svg+xml" {
+		t.Errorf("got content type %q for svg", ct)
+	}
+	if !strings.Contains(rec.Body.String(), ">512.3MS</tspan>") {
+		t.Errorf("svg does not show perfdata value:\n%s", rec.Body)
+	}
+
+	rec = apiRequest(h, http.MethodGet, "/network/snapshot.gif", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("get gif: got status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	rec = apiRequest(h, http.MethodGet, "/missing/snapshot.png", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("get missing dashboard: got status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestLocalImageTooLarge(t *testing.T) {
+	defer func(dir string) { config.DataDirectory = dir }(config.DataDirectory)
+	config.DataDirectory = t.TempDir()
+	if err := os.Mkdir(dataPath(backgroundDir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, width := range map[string]int{"small.png": 16, "huge.png": meerkat.MaxSnapshotSize + 1} {
+		f, err := os.Create(dataPath(filepath.Join(backgroundDir, name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = png.Encode(f, image.NewGray(image.Rect(0, 0, width, 1)))
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if img, err := localImage("/" + backgroundDir + "/small.png"); err != nil || img == nil {
+		t.Errorf("small image: got %v, %v", img, err)
+	}
+	if _, err := localImage("/" + backgroundDir + "/huge.png"); err == nil {
+		t.Error("huge image: got no error")
+	}
+}
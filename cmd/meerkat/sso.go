@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/meerkat-dashboard/meerkat"
+	"github.com/meerkat-dashboard/meerkat/auth"
+)
+
+// ssoUser returns the Meerkat user for an identity from a single
+// sign-on provider. The user is given the highest role mapped to
+// any of their groups in config.GroupRoles, or the viewer role
+// if none of their groups are mapped.
+func ssoUser(id auth.Identity) meerkat.User {
+	rank := map[meerkat.Role]int{meerkat.RoleViewer: 0, meerkat.RoleEditor: 1, meerkat.RoleAdmin: 2}
+	role := meerkat.RoleViewer
+	for _, g := range id.Groups {
+		r := meerkat.Role(config.GroupRoles[g])
+		if n, ok := rank[r]; ok && n > rank[role] {
+			role = r
+		}
+	}
+	return meerkat.User{Name: id.Name, Role: role, Groups: id.Groups}
+}
+
+// oidcLoginTimeout is how long users have to log in
+// at the OpenID Connect provider.
+const oidcLoginTimeout = 10 * time.Minute
+
+type pendingLogin struct {
+	nonce  string
+	next   string
+	expiry time.Time
+}
+
+// oidcStateCookie holds the state of a login started by a browser,
+// so that the callback completing it is only accepted from the same
+// browser. Otherwise anyone could log others in as themselves by
+// sending them the callback address of their own login.
+const oidcStateCookie = "meerkat_oidc_state"
+
+// pendingLogins holds OpenID Connect logins awaiting a callback
+// from the provider, keyed by state.
+var pendingLogins = struct {
+	sync.Mutex
+	m map[string]pendingLogin
+}{m: make(map[string]pendingLogin)}
+
+// handleOIDCLogin sends the user to the OpenID Connect provider to log in.
+func handleOIDCLogin(w http.ResponseWriter, req *http.Request) {
+	if config.OIDC == nil {
+		http.NotFound(w, req)
+		return
+	}
+	state, err := newSessionID()
+	if err != nil {
+		log.Println("create login state:", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	nonce, err := newSessionID()
+	if err != nil {
+		log.Println("create login nonce:", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	u, err := config.OIDC.AuthCodeURL(req.Context(), state, nonce)
+	if err != nil {
+		log.Println("oidc:", err)
+		http.Error(w, "single sign-on unavailable", http.StatusBadGateway)
+		return
+	}
+
+	pendingLogins.Lock()
+	for k, v := range pendingLogins.m {
+		if time.Now().After(v.expiry) {
+			delete(pendingLogins.m, k)
+		}
+	}
+	pendingLogins.m[state] = pendingLogin{
+		nonce:  nonce,
+		next:   safeRedirect(req.URL.Query().Get("next")),
+		expiry: time.Now().Add(oidcLoginTimeout),
+	}
+	pendingLogins.Unlock()
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/login/oidc",
+		MaxAge:   int(oidcLoginTimeout / time.Second),
+		HttpOnly: true,
+		Secure:   config.SSLEnable,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, req, u, http.StatusFound)
+}
+
+// handleOIDCCallback completes a login started by handleOIDCLogin.
+func handleOIDCCallback(w http.ResponseWriter, req *http.Request) {
+	if config.OIDC == nil {
+		http.NotFound(w, req)
+		return
+	}
+	q := req.URL.Query()
+	cookie, err := req.Cookie(oidcStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(q.Get("state"))) != 1 {
+		http.Error(w, "login was not started by this browser, please try again", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    "",
+		Path:     "/login/oidc",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   config.SSLEnable,
+		SameSite: http.SameSiteLaxMode,
+	})
+	pendingLogins.Lock()
+	pending, ok := pendingLogins.m[q.Get("state")]
+	delete(pendingLogins.m, q.Get("state"))
+	pendingLogins.Unlock()
+	if !ok || time.Now().After(pending.expiry) {
+		http.Error(w, "unknown or expired login, please try again", http.StatusBadRequest)
+		return
+	}
+	failed := "/login?" + url.Values{"next": {pending.next}, "failed": {"1"}}.Encode()
+	if e := q.Get("error"); e != "" {
+		log.Printf("Failed single sign-on login from %s: %s\n", req.RemoteAddr, e)
+		http.Redirect(w, req, failed, http.StatusFound)
+		return
+	}
+	id, err := config.OIDC.Exchange(req.Context(), q.Get("code"), pending.nonce)
+	if err != nil {
+		log.Printf("Failed single sign-on login from %s: %v\n", req.RemoteAddr, err)
+		http.Redirect(w, req, failed, http.StatusFound)
+		return
+	}
+	startSession(w, req, ssoUser(id), pending.next)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/meerkat-dashboard/meerkat"
+	"github.com/meerkat-dashboard/meerkat/auth"
+	"github.com/meerkat-dashboard/meerkat/auth/authtest"
+)
+
+func TestSSOUser(t *testing.T) {
+	config.GroupRoles = map[string]string{"noc": "editor", "ops": "admin", "staff": "viewer"}
+	defer func() { config.GroupRoles = nil }()
+
+	tests := []struct {
+		groups []string
+		want   meerkat.Role
+	}{
+		{nil, meerkat.RoleViewer},
+		{[]string{"unmapped"}, meerkat.RoleViewer},
+		{[]string{"staff", "noc"}, meerkat.RoleEditor},
+		{[]string{"ops", "noc"}, meerkat.RoleAdmin},
+	}
+	for _, tt := range tests {
+		u := ssoUser(auth.Identity{Name: "alice", Groups: tt.groups})
+		if u.Role != tt.want {
+			t.Errorf("groups %v: got role %s, want %s", tt.groups, u.Role, tt.want)
+		}
+	}
+}
+
+func TestOIDCLogin(t *testing.T) {
+	provider := authtest.NewOIDCProvider("meerkat", "secret", authtest.OIDCUser{Name: "alice", Groups: []string{"noc"}})
+	defer provider.Close()
+	config.OIDC = &auth.OIDC{
+		Issuer:       provider.URL,
+		ClientID:     "meerkat",
+		ClientSecret: "secret",
+		RedirectURL:  "http://meerkat.example.com/login/oidc/callback",
+	}
+	config.GroupRoles = map[string]string{"noc": "editor"}
+	defer func() {
+		config.OIDC = nil
+		config.GroupRoles = nil
+	}()
+
+	rec := httptest.NewRecorder()
+	handleOIDCLogin(rec, httptest.NewRequest(http.MethodGet, "/login/oidc?next=/noc/edit", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("start login: got status %d, want %d", rec.Code, http.StatusFound)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	callback, err := resp.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := rec.Result().Cookies()
+	if len(state) == 0 || state[0].Name != oidcStateCookie {
+		t.Fatal("no login state cookie set")
+	}
+	callbackRequest := func(withState bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+		if withState {
+			req.AddCookie(state[0])
+		}
+		rec := httptest.NewRecorder()
+		handleOIDCCallback(rec, req)
+		return rec
+	}
+
+	// A callback from another browser, such as one tricked into following
+	// the callback address of someone else's login, must fail.
+	if rec = callbackRequest(false); rec.Code != http.StatusBadRequest {
+		t.Errorf("callback without state cookie: got status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	rec = callbackRequest(true)
+	if rec.Header().Get("Location") != "/noc/edit" {
+		t.Errorf("callback redirected to %q, want %q", rec.Header().Get("Location"), "/noc/edit")
+	}
+	var session *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == sessionCookie {
+			session = c
+		}
+	}
+	if session == nil {
+		t.Fatal("no session cookie set after login")
+	}
+	sess, ok := sessions.lookup(session.Value)
+	if !ok {
+		t.Fatal("no session for cookie")
+	}
+	if sess.user.Name != "alice" || sess.user.Role != meerkat.RoleEditor {
+		t.Errorf("got user %s with role %s, want alice with role editor", sess.user.Name, sess.user.Role)
+	}
+
+	// replaying the callback must fail
+	if rec = callbackRequest(true); rec.Code != http.StatusBadRequest {
+		t.Errorf("replayed callback: got status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/meerkat-dashboard/meerkat"
+	"github.com/meerkat-dashboard/meerkat/store/sqlite"
+)
+
+// store holds all dashboards and their history.
+var store meerkat.Store = meerkat.DirStore(".")
+
+func openStore(conf StorageConfig) (meerkat.Store, error) {
+	switch conf.Type {
+	case "filesystem":
+		return meerkat.DirStore(config.DataDirectory), nil
+	case "sqlite":
+		return sqlite.Open(dataPath(conf.SQLitePath))
+	case "s3":
+		if conf.S3.Endpoint == "" || conf.S3.Bucket == "" {
+			return nil, errors.New("s3 storage requires an endpoint and bucket")
+		}
+		s := conf.S3
+		return &s, nil
+	}
+	return nil, fmt.Errorf("unknown storage type %q", conf.Type)
+}
@@ -1,6 +1,9 @@
 package meerkat
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -15,6 +18,9 @@ import (
 
 // Dashboard contains all information to render a dashboard
 type Dashboard struct {
+	// SchemaVersion is the version of the format the dashboard was
+	// stored in. See MigrateDashboard.
+	SchemaVersion int       `json:"schemaVersion"`
 	Title         string    `json:"title"`
 	Slug          string    `json:"slug"`
 	Background    string    `json:"background"`
@@ -31,6 +37,16 @@ type Dashboard struct {
 	DownSound     string    `json:"downSound"`
 	Elements      []Element `json:"elements"`
 	Order         Order     `json:"order"`
+	// Template is the slug of the dashboard this dashboard is an
+	// instance of, if any. See ResolveDashboard.
+	Template string `json:"template,omitempty"`
+	// Variables holds the values of variables referred to by
+	// placeholders, such as ${hostgroup}, in the options of elements.
+	Variables map[string]string `json:"variables,omitempty"`
+	// Backend names the backend the objects shown by elements
+	// are from, unless an element names its own.
+	// Empty means the default one.
+	Backend string `json:"backend,omitempty"`
 }
 
 type Order struct {
@@ -45,6 +61,10 @@ type Order struct {
 
 // Element contains any service/host information needed
 type Element struct {
+	// ID identifies the element within its dashboard. It is kept
+	// when the element is changed, moved or the dashboard is cloned.
+	// Elements without one are assigned one when the dashboard is saved.
+	ID       string  `json:"id"`
 	Type     string  `json:"type"`
 	Title    string  `json:"title"`
 	Rect     Rect    `json:"rect"`
@@ -56,6 +76,7 @@ type Options struct {
 	ObjectAttr                      string      `json:"objectAttr,omitempty"`
 	ObjectName                      string      `json:"objectName,omitempty"`
 	ObjectType                      string      `json:"objectType,omitempty"`
+	Backend                         string      `json:"backend,omitempty"`
 	TimeZone                        string      `json:"timeZone,omitempty"`
 	FontSize                        json.Number `json:"fontSize,omitempty"`
 	Image                           string      `json:"image,omitempty"`
@@ -110,9 +131,17 @@ type Rect struct {
 	H float64 `json:"h"`
 }
 
-// Stat satisfies the fs.File interface.
+// Stat returns information about the file d is kept in
+// by a DirStore of the current directory.
+//
+// Deprecated: Dashboards may be kept in any Store,
+// and a DirStore may be of any directory.
 func (d *Dashboard) Stat() (fs.FileInfo, error) {
-	return os.Stat(path.Join("dashboards", TitleToSlug(d.Title)+".json"))
+	name, err := DirStore(".").name("stat", DashboardKey(TitleToSlug(d.Title)))
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(name)
 }
 
 func ReadDirectory(dirname string) ([]string, error) {
@@ -127,31 +156,58 @@ func ReadDirectory(dirname string) ([]string, error) {
 	return list, nil
 }
 
+// ReadDashboard reads the dashboard from the named file.
+func ReadDashboard(name string) (Dashboard, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return Dashboard{}, err
+	}
+	d, err := decodeDashboard(b)
+	if err != nil {
+		return Dashboard{}, fmt.Errorf("%s: %w", name, err)
+	}
+	return d, nil
+}
+
+// ReadDashboardDir reads the dashboards in the directory dirname.
+//
+// Deprecated: Use LoadDashboards, which reads dashboards from any Store.
 func ReadDashboardDir(dirname string) ([]Dashboard, error) {
-	files, err := os.ReadDir(dirname)
+	s := DirStore(dirname)
+	keys, err := s.List("")
 	if err != nil {
 		return nil, err
 	}
 	var dashboards []Dashboard
-	for _, file := range files {
-		if !strings.HasSuffix(file.Name(), ".json") {
+	for _, key := range keys {
+		if !strings.HasSuffix(key, ".json") {
 			continue
 		}
-		d, err := ReadDashboard(path.Join("dashboards", file.Name()))
+		b, err := s.Get(key)
 		if err != nil {
 			return dashboards, err
 		}
+		d, err := decodeDashboard(b)
+		if err != nil {
+			return dashboards, fmt.Errorf("%s: %w", key, err)
+		}
 		dashboards = append(dashboards, d)
 	}
 	return dashboards, nil
 }
 
-func ReadDashboard(name string) (Dashboard, error) {
-	f, err := os.Open(name)
-	if err != nil {
-		return Dashboard{}, err
+// decodeDashboard decodes b, upgrading dashboards stored
+// in older formats to the current SchemaVersion.
+func decodeDashboard(b []byte) (Dashboard, error) {
+	d, err := decodeCurrentDashboard(b)
+	if err != nil || d.SchemaVersion == SchemaVersion {
+		return d, err
 	}
-	defer f.Close()
+	d, _, err = MigrateDashboard(b)
+	return d, err
+}
+
+func decodeCurrentDashboard(b []byte) (Dashboard, error) {
 	var dashboard Dashboard
 	dashboard.Order = Order{
 		Critical:    0,
@@ -162,32 +218,113 @@ func ReadDashboard(name string) (Dashboard, error) {
 		UnknownAck:  5,
 		Ok:          6,
 	}
-	if err := json.NewDecoder(f).Decode(&dashboard); err != nil {
-		return Dashboard{}, fmt.Errorf("decode dashboard %s: %w", f.Name(), err)
+	if err := json.Unmarshal(b, &dashboard); err != nil {
+		return Dashboard{}, fmt.Errorf("decode dashboard: %w", err)
 	}
 	dashboard.Slug = TitleToSlug(dashboard.Title)
+	assignElementIDs(dashboard.Elements)
 	return dashboard, nil
 }
 
+// assignElementIDs gives elements without an ID one derived from
+// their position, as migrateV3 does, so that they can be told apart
+// even if they were stored without IDs by other tools.
+func assignElementIDs(elements []Element) {
+	used := make(map[string]bool)
+	for _, e := range elements {
+		used[e.ID] = true
+	}
+	for i := range elements {
+		if elements[i].ID != "" {
+			continue
+		}
+		id := fmt.Sprintf("element-%d", i+1)
+		for n := 2; used[id]; n++ {
+			id = fmt.Sprintf("element-%d-%d", i+1, n)
+		}
+		used[id] = true
+		elements[i].ID = id
+	}
+}
+
+func encodeDashboard(dashboard *Dashboard) ([]byte, error) {
+	dashboard.SchemaVersion = SchemaVersion
+	for i := range dashboard.Elements {
+		if dashboard.Elements[i].ID == "" {
+			dashboard.Elements[i].ID = NewElementID()
+		}
+	}
+	return json.MarshalIndent(dashboard, "", "  ")
+}
+
 // CreateDashboard writes the provided dashboard to the named file.
-// The file is always truncated beforehand.
+// The dashboard is first written to a temporary file in the same
+// directory which then replaces the named file, so a failed write
+// never leaves a truncated dashboard behind.
 func CreateDashboard(name string, dashboard *Dashboard) error {
 	dashboard.Slug = TitleToSlug(dashboard.Title)
-	buf, err := json.MarshalIndent(&dashboard, "", "  ")
+	buf, err := encodeDashboard(dashboard)
 	if err != nil {
 		return err
 	}
-	f, err := os.Create(name)
-	if err != nil {
-		return fmt.Errorf("create dashboard file: %v", err)
-	}
-	defer f.Close()
-	if _, err := f.Write(buf); err != nil {
+	if err := writeFileAtomic(name, buf, 0644); err != nil {
 		return fmt.Errorf("write dashboard file: %w", err)
 	}
 	return nil
 }
 
+// writeFileAtomic writes data to the named file by way of a temporary
+// file which is renamed over name once its contents are on disk.
+func writeFileAtomic(name string, data []byte, perm fs.FileMode) error {
+	f, err := os.CreateTemp(path.Dir(name), "."+path.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+// NewElementID returns a random identifier for an element.
+func NewElementID() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("read random bytes: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// ElementIndex returns the index of the element with the given ID,
+// or -1 if there is none.
+func (d *Dashboard) ElementIndex(id string) int {
+	for i := range d.Elements {
+		if d.Elements[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// ETag returns a strong entity tag for the encoded dashboard b,
+// for use in the ETag and If-Match HTTP headers.
+func ETag(b []byte) string {
+	sum := sha256.Sum256(b)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
 func TitleToSlug(title string) string {
 	title = strings.ToLower(title)                // convert upper case to lower case
 	title = strings.TrimSpace(title)              // remove preceeding and trailing whitespace
@@ -220,6 +357,8 @@ func ParseDashboardForm(form url.Values) (Dashboard, error) {
 			dashboard.Description = v
 		case "folder":
 			dashboard.Folder = v
+		case "backend":
+			dashboard.Backend = v
 		case "globalMute":
 			dashboard.GlobalMute, _ = strconv.ParseBool(v)
 		case "okSound":
@@ -248,6 +387,12 @@ func ParseDashboardForm(form url.Values) (Dashboard, error) {
 			dashboard.Order.WarningAck, _ = strconv.Atoi(v)
 		case "unknown_ack":
 			dashboard.Order.UnknownAck, _ = strconv.Atoi(v)
+		case "variables":
+			vars, err := ParseVariables(v)
+			if err != nil {
+				return Dashboard{}, err
+			}
+			dashboard.Variables = vars
 		default:
 			return Dashboard{}, fmt.Errorf("unknown form parameter %s", k)
 		}
@@ -0,0 +1,82 @@
+package meerkat
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCreateDashboard(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "test.json")
+	d := &Dashboard{Title: "Test"}
+	if err := CreateDashboard(name, d); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Description = "changed"
+	if err := CreateDashboard(name, d); err != nil {
+		t.Fatal(err)
+	}
+	after, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ETag(before) == ETag(after) {
+		t.Error("ETag unchanged after dashboard changed")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		for _, e := range entries {
+			t.Log(e.Name())
+		}
+		t.Errorf("got %d files in dashboard directory, want only the dashboard", len(entries))
+	}
+	got, err := ReadDashboard(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Description != "changed" {
+		t.Errorf("got description %q, want %q", got.Description, "changed")
+	}
+}
+
+func TestReadDashboardDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, title := range []string{"Network", "Servers"} {
+		if err := CreateDashboard(filepath.Join(dir, TitleToSlug(title)+".json"), &Dashboard{Title: title}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dashboards, err := ReadDashboardDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dashboards) != 2 || dashboards[0].Slug != "network" || dashboards[1].Slug != "servers" {
+		t.Errorf("got dashboards %+v, want network and servers", dashboards)
+	}
+}
+
+func TestDecodeAssignsElementIDs(t *testing.T) {
+	b := []byte(fmt.Sprintf(`{"schemaVersion": %d, "title": "Test", "elements": [{"type": "clock"}, {"id": "element-1", "type": "clock"}, {"type": "clock"}]}`, SchemaVersion))
+	d, err := decodeDashboard(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, e := range d.Elements {
+		ids = append(ids, e.ID)
+	}
+	if want := []string{"element-1-2", "element-1", "element-3"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("got element IDs %q, want %q", ids, want)
+	}
+}
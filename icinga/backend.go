@@ -0,0 +1,346 @@
+package icinga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/meerkat-dashboard/meerkat"
+)
+
+// DefaultInterval is the default interval between resolving the
+// members of the groups and filter expressions watched by event streams.
+const DefaultInterval = time.Minute
+
+// Backend is a meerkat.Backend showing the objects of an Icinga API.
+type Backend struct {
+	API API
+	// Queue names the subscription to the event stream.
+	// Meerkat servers sharing a queue share its events.
+	Queue string
+	// Interval is the interval between resolving the members of the
+	// groups and filter expressions watched by event streams again,
+	// so that events are sent for objects which join them.
+	// If zero, DefaultInterval is used.
+	Interval time.Duration
+}
+
+// eventTypes are the types of the events streamed by Backend.
+var eventTypes = []string{
+	TypeCheckResult,
+	TypeStateChange,
+	TypeAcknowledgementSet,
+	TypeAcknowledgementCleared,
+}
+
+// backendError returns err as a *meerkat.BackendError
+// if it is a response from Icinga.
+func backendError(err error) error {
+	var e *Error
+	if errors.As(err, &e) {
+		return &meerkat.BackendError{StatusCode: e.StatusCode, Status: e.Status}
+	}
+	return err
+}
+
+// Objects implements meerkat.Backend.
+// Objects are selected by name with the parameter named by their type,
+// such as hosts=web, and by Icinga filter expressions.
+func (b *Backend) Objects(ctx context.Context, q meerkat.ObjectQuery) ([]meerkat.Object, error) {
+	params := url.Values{}
+	if q.Name != "" {
+		params.Set(q.Type, q.Name)
+	}
+	if q.Filter != "" {
+		params.Set("filter", q.Filter)
+	}
+	if q.NamesOnly {
+		params.Set("attrs", "name")
+	}
+	objects, err := b.API.Objects(ctx, q.Type, params)
+	if err != nil {
+		return nil, backendError(err)
+	}
+	results := make([]meerkat.Object, len(objects))
+	for i, obj := range objects {
+		results[i] = object(obj)
+	}
+	return results, nil
+}
+
+// object converts obj to a meerkat.Object.
+// Its state is that of its last check, so hosts are up
+// in states 0 and 1, as in check results sent on the event stream.
+func object(obj Object) meerkat.Object {
+	name := obj.Attrs.Name
+	if name == "" {
+		name = obj.Name
+	}
+	return meerkat.Object{
+		Name: name,
+		Type: obj.Type,
+		ObjectState: meerkat.ObjectState{
+			State:        obj.Attrs.LastCheckResult.State,
+			Acknowledged: obj.Attrs.Acknowledgement != 0,
+			Output:       obj.Attrs.LastCheckResult.Output,
+			PerfData:     PerfData(obj.Attrs.LastCheckResult.PerformanceData),
+		},
+		StateType: obj.Attrs.StateType,
+	}
+}
+
+// PerfData returns the labels and values of the performance data v
+// reported by Icinga: either strings such as "load1=0.5;5;10"
+// or objects with label and value fields.
+func PerfData(v any) map[string]string {
+	values, ok := v.([]any)
+	if !ok || len(values) == 0 {
+		return nil
+	}
+	m := make(map[string]string)
+	for _, value := range values {
+		switch value := value.(type) {
+		case string:
+			label, rest, ok := strings.Cut(value, "=")
+			if !ok {
+				continue
+			}
+			rest, _, _ = strings.Cut(rest, ";")
+			m[label] = rest
+		case map[string]any:
+			label, ok := value["label"].(string)
+			if !ok {
+				continue
+			}
+			switch n := value["value"].(type) {
+			case float64:
+				m[label] = strconv.FormatFloat(n, 'f', -1, 64)
+			default:
+				m[label] = fmt.Sprint(n)
+			}
+		}
+	}
+	return m
+}
+
+// Status implements meerkat.Backend.
+func (b *Backend) Status(ctx context.Context) (meerkat.BackendInfo, error) {
+	app, err := b.API.Status(ctx)
+	if err != nil {
+		return meerkat.BackendInfo{}, backendError(err)
+	}
+	sec, frac := math.Modf(app.ProgramStart)
+	return meerkat.BackendInfo{
+		Version: app.Version,
+		Start:   time.Unix(int64(sec), int64(frac*1e9)),
+	}, nil
+}
+
+// Events implements meerkat.Backend.
+// Only events for the objects selected by queries are sent by Icinga,
+// by a filter naming each of them; see EventFilter. The members of
+// groups and filter expressions are resolved every Interval, and the
+// subscription replaced by one with a new filter when they change.
+func (b *Backend) Events(ctx context.Context, queries []meerkat.ObjectQuery) (meerkat.EventStream, error) {
+	hosts, services, err := b.members(ctx, queries)
+	if err != nil {
+		return nil, err
+	}
+	filter := EventFilter(hosts, services)
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := b.subscribe(ctx, filter)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	s := &backendStream{stream: stream, cancel: cancel, watching: make(chan struct{})}
+	if !slices.ContainsFunc(queries, resolved) {
+		close(s.watching)
+		return s, nil
+	}
+	go s.watch(ctx, b, queries, filter)
+	return s, nil
+}
+
+// resolved reports whether the objects selected by q
+// are found by asking Icinga, rather than named by q.
+func resolved(q meerkat.ObjectQuery) bool {
+	return !(q.Type == "hosts" || q.Type == "services") || q.Filter != "" || q.Name == ""
+}
+
+// members returns the names of the hosts and services selected by queries.
+// Events name the members of groups, not the groups.
+func (b *Backend) members(ctx context.Context, queries []meerkat.ObjectQuery) (hosts, services []string, err error) {
+	for _, q := range queries {
+		if !resolved(q) {
+			if q.Type == "hosts" {
+				hosts = append(hosts, q.Name)
+			} else {
+				services = append(services, q.Name)
+			}
+			continue
+		}
+		switch q.Type {
+		case "hostgroups":
+			q = meerkat.ObjectQuery{Type: "hosts", Filter: quote(q.Name) + " in host.groups"}
+		case "servicegroups":
+			q = meerkat.ObjectQuery{Type: "services", Filter: quote(q.Name) + " in service.groups"}
+		}
+		q.NamesOnly = true
+		objects, err := b.Objects(ctx, q)
+		var e *meerkat.BackendError
+		if errors.As(err, &e) {
+			// Such as a group with no members.
+			continue
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("resolve %s: %w", q.Type, err)
+		}
+		for _, obj := range objects {
+			if obj.Type == "Service" {
+				services = append(services, obj.Name)
+			} else {
+				hosts = append(hosts, obj.Name)
+			}
+		}
+	}
+	return hosts, services, nil
+}
+
+func (b *Backend) subscribe(ctx context.Context, filter string) (*EventStream, error) {
+	sub := Subscription{Types: eventTypes, Queue: b.Queue, Filter: filter}
+	stream, err := b.API.Events(ctx, sub)
+	if err != nil {
+		return nil, backendError(err)
+	}
+	return stream, nil
+}
+
+// EventFilter returns a filter expression selecting the events for the
+// named hosts and services, such as web and web!http, for a Subscription.
+// If there are none, no events are selected.
+func EventFilter(hosts, services []string) string {
+	var clauses []string
+	if len(hosts) > 0 {
+		clauses = append(clauses, "(!event.service && event.host in "+stringArray(hosts)+")")
+	}
+	if len(services) > 0 {
+		clauses = append(clauses, `(event.service && (event.host + "!" + event.service) in `+stringArray(services)+")")
+	}
+	if len(clauses) == 0 {
+		return "false"
+	}
+	return strings.Join(clauses, " || ")
+}
+
+// stringArray returns an Icinga array literal of the strings in a,
+// sorted and without duplicates.
+func stringArray(a []string) string {
+	a = slices.Clone(a)
+	slices.Sort(a)
+	a = slices.Compact(a)
+	quoted := make([]string, len(a))
+	for i, s := range a {
+		quoted[i] = quote(s)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// quote returns s as an Icinga string literal.
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// backendStream is a meerkat.EventStream of the events on an EventStream,
+// which is replaced when the objects it is filtered to change.
+type backendStream struct {
+	mu       sync.Mutex
+	stream   *EventStream
+	closed   bool
+	cancel   context.CancelFunc
+	watching chan struct{} // closed when watch returns
+}
+
+// watch replaces the stream with one for the members of queries
+// whenever they no longer match filter, until the stream is closed.
+func (s *backendStream) watch(ctx context.Context, b *Backend, queries []meerkat.ObjectQuery, filter string) {
+	defer close(s.watching)
+	interval := b.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// Errors are left for the stream to report,
+		// if Icinga is unavailable.
+		hosts, services, err := b.members(ctx, queries)
+		if err != nil || EventFilter(hosts, services) == filter {
+			continue
+		}
+		filter = EventFilter(hosts, services)
+		stream, err := b.subscribe(ctx, filter)
+		if err != nil {
+			continue
+		}
+		s.mu.Lock()
+		old := s.stream
+		s.stream = stream
+		s.mu.Unlock()
+		// Subscribed before unsubscribing, so no events are missed.
+		old.Close()
+	}
+}
+
+func (s *backendStream) Next() (meerkat.Event, error) {
+	s.mu.Lock()
+	stream := s.stream
+	s.mu.Unlock()
+	e, err := stream.Next()
+	for err != nil {
+		s.mu.Lock()
+		replaced := s.stream != stream && !s.closed
+		stream = s.stream
+		s.mu.Unlock()
+		if !replaced {
+			return meerkat.Event{}, err
+		}
+		e, err = stream.Next()
+	}
+	obj := meerkat.Object{Name: e.ObjectName(), Type: "Host"}
+	if e.Service != "" {
+		obj.Type = "Service"
+	}
+	switch e.Type {
+	case TypeCheckResult, TypeStateChange:
+		obj.State = e.CheckResult.State
+		obj.StateType = e.CheckResult.VarsAfter.StateType
+		obj.Acknowledged = e.Acknowledgement
+		obj.Output = e.CheckResult.Output
+		obj.PerfData = PerfData(e.CheckResult.PerformanceData)
+	}
+	return meerkat.Event{Type: e.Type, Object: obj}, nil
+}
+
+func (s *backendStream) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.cancel()
+	<-s.watching
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stream.Close()
+}
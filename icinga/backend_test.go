@@ -0,0 +1,140 @@
+package icinga_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/meerkat-dashboard/meerkat"
+	"github.com/meerkat-dashboard/meerkat/icinga"
+)
+
+func TestBackend(t *testing.T) {
+	client, srv := newTestClient(t)
+	web := icinga.Object{Name: "web!http", Type: "Service"}
+	web.Attrs.LastCheckResult = icinga.LastCheckResult{
+		State:           1,
+		Output:          "HTTP WARNING",
+		PerformanceData: []any{"time=0.5s;1;2", map[string]any{"label": "size", "value": 512.0}},
+	}
+	srv.Add(web)
+	srv.SetProgramStart(1234.5)
+	b := &icinga.Backend{API: client, Queue: "test"}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objects, err := b.Objects(ctx, meerkat.ObjectQuery{Type: "services", Name: "web!http"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 {
+		t.Fatalf("got %d objects, want 1", len(objects))
+	}
+	obj := objects[0]
+	if obj.Name != "web!http" || obj.State != 1 || obj.PerfData["time"] != "0.5s" || obj.PerfData["size"] != "512" {
+		t.Errorf("got object %+v", obj)
+	}
+	_, err = b.Objects(ctx, meerkat.ObjectQuery{Type: "hosts", Name: "missing"})
+	var e *meerkat.BackendError
+	if !errors.As(err, &e) || e.StatusCode != http.StatusNotFound {
+		t.Errorf("missing host: got error %v, want status %d", err, http.StatusNotFound)
+	}
+
+	info, err := b.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Unix(1234, 5e8); !info.Start.Equal(want) {
+		t.Errorf("got start %v, want %v", info.Start, want)
+	}
+
+	stream, err := b.Events(ctx, []meerkat.ObjectQuery{
+		{Type: "services", Name: "web!http"},
+		{Type: "hostgroups", Name: "linux"},
+		// No members, so ignored.
+		{Type: "servicegroups", Name: "empty"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	go func() {
+		// Not selected, so never received.
+		srv.Send(icinga.Event{Type: icinga.TypeCheckResult, Host: "db"})
+		srv.Send(icinga.Event{Type: icinga.TypeCheckResult, Host: "web", Service: "ping"})
+		event := icinga.Event{Type: icinga.TypeStateChange, Host: "web", Service: "http"}
+		event.CheckResult.State = 2
+		srv.Send(event)
+		srv.Send(icinga.Event{Type: icinga.TypeCheckResult, Host: "web"})
+	}()
+	event, err := stream.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != meerkat.EventStateChange || event.Object.Name != "web!http" || event.Object.Type != "Service" || event.Object.State != 2 {
+		t.Errorf("got event %+v, want web!http changing to critical", event)
+	}
+	// web is a member of linux.
+	if event, err = stream.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if event.Object.Name != "web" || event.Object.Type != "Host" {
+		t.Errorf("got event %+v, want check result of host web", event)
+	}
+}
+
+func TestBackendNewMembers(t *testing.T) {
+	client, srv := newTestClient(t)
+	b := &icinga.Backend{API: client, Queue: "test", Interval: 10 * time.Millisecond}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stream, err := b.Events(ctx, []meerkat.ObjectQuery{{Type: "hostgroups", Name: "linux"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	// db joins linux after subscribing.
+	srv.Add(icinga.Object{Name: "db", Type: "Host", Attrs: icinga.Attrs{Groups: []string{"linux"}}})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+			srv.Send(icinga.Event{Type: icinga.TypeCheckResult, Host: "db"})
+		}
+	}()
+	event, err := stream.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Object.Name != "db" {
+		t.Errorf("got event %+v, want check result of new member db", event)
+	}
+}
+
+func TestEventFilter(t *testing.T) {
+	tests := []struct {
+		hosts, services []string
+		want            string
+	}{
+		{want: "false"},
+		{hosts: []string{"web", "db", "web"}, want: `(!event.service && event.host in ["db", "web"])`},
+		{
+			hosts:    []string{"web"},
+			services: []string{`web!say "hi"`},
+			want:     `(!event.service && event.host in ["web"]) || (event.service && (event.host + "!" + event.service) in ["web!say \"hi\""])`,
+		},
+	}
+	for _, tt := range tests {
+		if got := icinga.EventFilter(tt.hosts, tt.services); got != tt.want {
+			t.Errorf("EventFilter(%q, %q) = %s, want %s", tt.hosts, tt.services, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package icinga
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Event types which may be subscribed to.
+const (
+	TypeCheckResult            = "CheckResult"
+	TypeStateChange            = "StateChange"
+	TypeAcknowledgementSet     = "AcknowledgementSet"
+	TypeAcknowledgementCleared = "AcknowledgementCleared"
+)
+
+// A Subscription selects the events sent on an event stream.
+type Subscription struct {
+	// Types lists the types of events to receive, such as CheckResult.
+	Types []string `json:"types"`
+	// Queue names the subscription. Clients sharing a queue
+	// share its events, rather than each receiving them all.
+	Queue string `json:"queue"`
+	// Filter is an Icinga filter expression selecting events,
+	// for example `event.host == "web"`. Empty means all events.
+	Filter string `json:"filter,omitempty"`
+}
+
+// An Event is sent by Icinga on an event stream.
+// Which fields are set depends on its Type.
+type Event struct {
+	Type      string  `json:"type,omitempty"`
+	Host      string  `json:"host,omitempty"`
+	Service   string  `json:"service,omitempty"`
+	Timestamp float64 `json:"timestamp,omitempty"`
+
+	// Set for CheckResult and StateChange events.
+	Acknowledgement bool        `json:"acknowledgement,omitempty"`
+	CheckResult     CheckResult `json:"check_result,omitempty"`
+	DowntimeDepth   int         `json:"downtime_depth,omitempty"`
+
+	// Set for StateChange, AcknowledgementSet and AcknowledgementCleared events.
+	State     int `json:"state,omitempty"`
+	StateType int `json:"state_type,omitempty"`
+
+	// Set for AcknowledgementSet events.
+	Author  string  `json:"author,omitempty"`
+	Comment string  `json:"comment,omitempty"`
+	Notify  bool    `json:"notify,omitempty"`
+	Expiry  float64 `json:"expiry,omitempty"`
+}
+
+// ObjectName returns the name of the object the event is about:
+// the host name, or "host!service" for services.
+func (e Event) ObjectName() string {
+	if e.Service == "" {
+		return e.Host
+	}
+	return e.Host + "!" + e.Service
+}
+
+// A CheckResult is the result of a check of a host or service.
+type CheckResult struct {
+	Active            bool        `json:"active,omitempty"`
+	CheckSource       string      `json:"check_source,omitempty"`
+	Command           interface{} `json:"command,omitempty"`
+	ExecutionEnd      float64     `json:"execution_end,omitempty"`
+	ExecutionStart    float64     `json:"execution_start,omitempty"`
+	ExitStatus        json.Number `json:"exit_status,omitempty"`
+	Output            string      `json:"output,omitempty"`
+	PerformanceData   interface{} `json:"performance_data,omitempty"`
+	PreviousHardState int         `json:"previous_hard_state,omitempty"`
+	ScheduleEnd       float64     `json:"schedule_end,omitempty"`
+	ScheduleStart     float64     `json:"schedule_start,omitempty"`
+	SchedulingSource  string      `json:"scheduling_source,omitempty"`
+	State             int         `json:"state,omitempty"`
+	TTL               int         `json:"ttl,omitempty"`
+	Type              string      `json:"type,omitempty"`
+	VarsAfter         struct {
+		Attempt   json.Number `json:"attempt,omitempty"`
+		Reachable bool        `json:"reachable,omitempty"`
+		State     int         `json:"state,omitempty"`
+		StateType int         `json:"state_type,omitempty"`
+	} `json:"vars_after,omitempty"`
+	VarsBefore *struct {
+		Attempt   json.Number `json:"attempt,omitempty"`
+		Reachable bool        `json:"reachable,omitempty"`
+		State     int         `json:"state,omitempty"`
+		StateType int         `json:"state_type,omitempty"`
+	} `json:"vars_before,omitempty"`
+}
+
+// An EventStream reads events sent by Icinga, one JSON object per line.
+type EventStream struct {
+	body io.ReadCloser
+	r    *bufio.Reader
+}
+
+// Next returns the next event on the stream, blocking until one is sent.
+// It returns io.EOF once the stream is closed by Icinga.
+func (s *EventStream) Next() (Event, error) {
+	for {
+		line, err := s.r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) == 0 {
+			if err != nil {
+				return Event{}, err
+			}
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			return Event{}, fmt.Errorf("decode event: %w", err)
+		}
+		return e, nil
+	}
+}
+
+// Close closes the stream.
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
+
+// Events implements API.
+func (c *Client) Events(ctx context.Context, sub Subscription) (*EventStream, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/v1/events", sub)
+	if err != nil {
+		return nil, err
+	}
+	return &EventStream{body: resp.Body, r: bufio.NewReader(resp.Body)}, nil
+}
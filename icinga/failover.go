@@ -0,0 +1,117 @@
+package icinga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+)
+
+// Failover is an API sending requests to one of several endpoints
+// serving the same objects, such as the masters of an Icinga cluster.
+// Requests go to the endpoint which last responded. If it cannot be
+// reached or responds with a server error, the others are tried in turn.
+// Other errors, such as a missing object, are returned without failing over.
+type Failover struct {
+	endpoints []API
+
+	mu      sync.Mutex
+	current int
+}
+
+// NewFailover returns a Failover between endpoints,
+// starting with the first. At least one endpoint must be given.
+func NewFailover(endpoints ...API) *Failover {
+	if len(endpoints) == 0 {
+		panic("icinga: failover without endpoints")
+	}
+	return &Failover{endpoints: endpoints}
+}
+
+// Current returns the index of the endpoint requests are sent to first:
+// the last one to respond.
+func (f *Failover) Current() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.current
+}
+
+// failedOver reports whether err from an endpoint means
+// the request should be tried at another.
+func failedOver(err error) bool {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.StatusCode >= 500
+	}
+	return err != nil
+}
+
+// try calls fn with each endpoint, starting from the current one,
+// until one responds.
+func (f *Failover) try(ctx context.Context, fn func(API) error) error {
+	start := f.Current()
+	var errs []error
+	for i := range f.endpoints {
+		n := (start + i) % len(f.endpoints)
+		err := fn(f.endpoints[n])
+		if !failedOver(err) {
+			f.mu.Lock()
+			f.current = n
+			f.mu.Unlock()
+			return err
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return fmt.Errorf("all %d endpoints failed: %w", len(errs), errors.Join(errs...))
+}
+
+// Objects implements API.
+func (f *Failover) Objects(ctx context.Context, typ string, params url.Values) ([]Object, error) {
+	var objects []Object
+	err := f.try(ctx, func(api API) error {
+		var err error
+		objects, err = api.Objects(ctx, typ, params)
+		return err
+	})
+	return objects, err
+}
+
+// Status implements API.
+func (f *Failover) Status(ctx context.Context) (Application, error) {
+	var app Application
+	err := f.try(ctx, func(api API) error {
+		var err error
+		app, err = api.Status(ctx)
+		return err
+	})
+	return app, err
+}
+
+// Events implements API.
+func (f *Failover) Events(ctx context.Context, sub Subscription) (*EventStream, error) {
+	var stream *EventStream
+	err := f.try(ctx, func(api API) error {
+		var err error
+		stream, err = api.Events(ctx, sub)
+		return err
+	})
+	return stream, err
+}
+
+// Action implements API.
+func (f *Failover) Action(ctx context.Context, name string, params any) ([]ActionResult, error) {
+	var results []ActionResult
+	err := f.try(ctx, func(api API) error {
+		var err error
+		results, err = api.Action(ctx, name, params)
+		return err
+	})
+	return results, err
+}
@@ -0,0 +1,53 @@
+package icinga_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/meerkat-dashboard/meerkat/icinga"
+)
+
+func TestFailover(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "starting", http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+	up, _ := newTestClient(t)
+	other, srv := newTestClient(t)
+	srv.Add(icinga.Object{Name: "mail", Type: "Host"})
+
+	f := icinga.NewFailover(
+		&icinga.Client{URL: down.URL},
+		&icinga.Client{URL: unavailable.URL},
+		up,
+		other,
+	)
+	ctx := context.Background()
+	objects, err := f.Objects(ctx, "hosts", url.Values{"host": {"web"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || f.Current() != 2 {
+		t.Errorf("got %d objects from endpoint %d, want 1 from endpoint 2", len(objects), f.Current())
+	}
+	// Objects missing from the current endpoint are not looked for elsewhere.
+	_, err = f.Objects(ctx, "hosts", url.Values{"host": {"mail"}})
+	var e *icinga.Error
+	if !errors.As(err, &e) || e.StatusCode != http.StatusNotFound {
+		t.Errorf("got error %v, want status %d", err, http.StatusNotFound)
+	}
+	if f.Current() != 2 {
+		t.Errorf("failed over to endpoint %d after missing object", f.Current())
+	}
+
+	f = icinga.NewFailover(&icinga.Client{URL: down.URL}, &icinga.Client{URL: unavailable.URL})
+	if _, err := f.Status(ctx); !errors.As(err, &e) || e.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("all endpoints failing: got error %v, want one with status %d", err, http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,246 @@
+// Package icinga provides a client for the Icinga 2 REST API.
+// It covers the parts of the API used by Meerkat: querying objects
+// and the status of the Icinga application, subscribing to the event
+// stream and running actions.
+//
+// Programs should depend on the API interface, which is implemented by
+// Client, and by Failover for clusters with several endpoints.
+// Backend shows the objects of an API as a meerkat.Backend.
+// A stand-in Icinga server for testing is available in the icingatest package.
+package icinga
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// API is the interface to an Icinga 2 API server.
+type API interface {
+	// Objects returns the objects of a type, such as "hosts" or
+	// "services", selected by params. For example the parameter
+	// host=web selects the host named web; filter selects objects
+	// matching an Icinga filter expression.
+	Objects(ctx context.Context, typ string, params url.Values) ([]Object, error)
+	// Status returns the status of the Icinga application.
+	Status(ctx context.Context) (Application, error)
+	// Events subscribes to the event stream.
+	// The stream is closed when ctx is done or when it is closed by the caller.
+	Events(ctx context.Context, sub Subscription) (*EventStream, error)
+	// Action runs the named action, such as "acknowledge-problem",
+	// with params encoded as JSON in the request body.
+	Action(ctx context.Context, name string, params any) ([]ActionResult, error)
+}
+
+// Client is an API making requests to an Icinga 2 API server over HTTP.
+type Client struct {
+	// URL is the base URL of the API, for example
+	// https://icinga.example.com:5665.
+	URL string
+	// Username and Password authenticate requests with HTTP basic
+	// authentication. If Username is empty, none is sent; Icinga then
+	// authenticates the client by the certificate configured in
+	// HTTPClient's transport.
+	Username string
+	Password string
+
+	// HTTPClient is used to make requests.
+	// If nil, http.DefaultClient is used.
+	// It should not have a timeout, as the event stream is long-lived;
+	// requests are cancelled by their context instead.
+	HTTPClient *http.Client
+}
+
+func (c *Client) client() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// An Error is a response from Icinga with a status other than 200 OK.
+type Error struct {
+	StatusCode int `json:"error"`
+	// Status describes the error, for example "No objects found.".
+	Status string `json:"status"`
+}
+
+func (e *Error) Error() string {
+	if e.Status == "" {
+		return fmt.Sprintf("icinga: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("icinga: %d %s", e.StatusCode, e.Status)
+}
+
+// do sends a request for the API path relative to c.URL with body
+// encoded as JSON, returning the response if its status is 200 OK.
+// Other responses are returned as an *Error.
+func (c *Client) do(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.URL, "/")+path, r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	e := &Error{StatusCode: resp.StatusCode}
+	// The body is not always JSON, for example if a proxy responds.
+	json.NewDecoder(resp.Body).Decode(e)
+	e.StatusCode = resp.StatusCode
+	return nil, e
+}
+
+// get sends a GET request for path and decodes the JSON response into v.
+func (c *Client) get(ctx context.Context, path string, v any) error {
+	resp, err := c.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode response to %s: %w", path, err)
+	}
+	return nil
+}
+
+// An Object is a configuration object, such as a host or service,
+// with its runtime attributes.
+type Object struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Attrs Attrs  `json:"attrs"`
+}
+
+// Attrs are the attributes of an object shown by Meerkat.
+// Objects other than hosts and services, such as host groups,
+// have only some of them.
+type Attrs struct {
+	// Name is the full name of the object;
+	// for services it is "host!service".
+	Name            string          `json:"__name"`
+	Acknowledgement int             `json:"acknowledgement"`
+	LastCheckResult LastCheckResult `json:"last_check_result"`
+	State           int             `json:"state"`
+	StateType       int             `json:"state_type"`
+	Type            string          `json:"type"`
+	Groups          []string        `json:"groups,omitempty"`
+}
+
+// LastCheckResult is the result of the last check of a host or service.
+type LastCheckResult struct {
+	Output string `json:"output"`
+	// PerformanceData holds strings such as "load1=0.5;5;10",
+	// or objects with label and value fields.
+	PerformanceData any    `json:"performance_data"`
+	State           int    `json:"state"`
+	Type            string `json:"type"`
+}
+
+// Objects implements API.
+func (c *Client) Objects(ctx context.Context, typ string, params url.Values) ([]Object, error) {
+	path := "/v1/objects/" + url.PathEscape(typ)
+	if len(params) > 0 {
+		// Icinga does not decode "+" in queries as a space.
+		path += "?" + strings.ReplaceAll(params.Encode(), "+", "%20")
+	}
+	var body struct {
+		Results []Object `json:"results"`
+	}
+	if err := c.get(ctx, path, &body); err != nil {
+		return nil, err
+	}
+	return body.Results, nil
+}
+
+// Application is the status of the Icinga application.
+type Application struct {
+	NodeName string `json:"node_name"`
+	Version  string `json:"version"`
+	// ProgramStart is when Icinga was last started or reloaded,
+	// in seconds since the Unix epoch.
+	ProgramStart float64 `json:"program_start"`
+	PID          float64 `json:"pid"`
+}
+
+// Status implements API.
+func (c *Client) Status(ctx context.Context) (Application, error) {
+	var body struct {
+		Results []struct {
+			Status struct {
+				IcingaApplication struct {
+					App Application `json:"app"`
+				} `json:"icingaapplication"`
+			} `json:"status"`
+		} `json:"results"`
+	}
+	if err := c.get(ctx, "/v1/status/IcingaApplication", &body); err != nil {
+		return Application{}, err
+	}
+	if len(body.Results) == 0 {
+		return Application{}, fmt.Errorf("icinga: no application status in response")
+	}
+	return body.Results[0].Status.IcingaApplication.App, nil
+}
+
+// ActionResult is the outcome of an action on one object.
+type ActionResult struct {
+	Code   float64 `json:"code"`
+	Status string  `json:"status"`
+	Name   string  `json:"name,omitempty"`
+}
+
+// Action implements API.
+func (c *Client) Action(ctx context.Context, name string, params any) ([]ActionResult, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/v1/actions/"+url.PathEscape(name), params)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Results []ActionResult `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decode response to action %s: %w", name, err)
+	}
+	return body.Results, nil
+}
+
+// Acknowledgement holds the parameters of the acknowledge-problem action.
+// The object acknowledged is named by Host, or by Service as "host!service".
+type Acknowledgement struct {
+	Type    string `json:"type"`
+	Host    string `json:"host,omitempty"`
+	Service string `json:"service,omitempty"`
+	Author  string `json:"author"`
+	Comment string `json:"comment"`
+	Sticky  bool   `json:"sticky,omitempty"`
+	Notify  bool   `json:"notify,omitempty"`
+	// Expiry is when the acknowledgement is removed,
+	// in seconds since the Unix epoch. Zero means never.
+	Expiry float64 `json:"expiry,omitempty"`
+}
@@ -0,0 +1,127 @@
+package icinga_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/meerkat-dashboard/meerkat/icinga"
+	"github.com/meerkat-dashboard/meerkat/icinga/icingatest"
+)
+
+func newTestClient(t *testing.T) (*icinga.Client, *icingatest.Server) {
+	t.Helper()
+	srv := icingatest.NewServer("meerkat", "secret")
+	t.Cleanup(srv.Close)
+	srv.Add(
+		icinga.Object{Name: "web", Type: "Host", Attrs: icinga.Attrs{Groups: []string{"linux"}}},
+		icinga.Object{Name: "db", Type: "Host", Attrs: icinga.Attrs{State: 1}},
+		icinga.Object{Name: "web!ping", Type: "Service"},
+	)
+	return &icinga.Client{URL: srv.URL, Username: "meerkat", Password: "secret", HTTPClient: srv.Client()}, srv
+}
+
+func TestObjects(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+
+	objects, err := client.Objects(ctx, "hosts", url.Values{"host": {"db"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0].Attrs.Name != "db" || objects[0].Attrs.State != 1 {
+		t.Errorf("got hosts %+v, want db in state 1", objects)
+	}
+	objects, err = client.Objects(ctx, "hosts", url.Values{"filter": {`"linux" in host.groups`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0].Name != "web" {
+		t.Errorf("got hosts %+v in group linux, want web", objects)
+	}
+
+	_, err = client.Objects(ctx, "hosts", url.Values{"host": {"missing"}})
+	var e *icinga.Error
+	if !errors.As(err, &e) || e.StatusCode != http.StatusNotFound {
+		t.Errorf("missing host: got error %v, want status %d", err, http.StatusNotFound)
+	}
+	client.Password = "wrong"
+	if _, err := client.Objects(ctx, "hosts", nil); !errors.As(err, &e) || e.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong password: got error %v, want status %d", err, http.StatusUnauthorized)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	client, srv := newTestClient(t)
+	srv.SetProgramStart(1234.5)
+	app, err := client.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app.ProgramStart != 1234.5 {
+		t.Errorf("got program start %v, want %v", app.ProgramStart, 1234.5)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	client, srv := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	sub := icinga.Subscription{Types: []string{icinga.TypeCheckResult, icinga.TypeAcknowledgementSet}, Queue: "test"}
+	stream, err := client.Events(ctx, sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	go func() {
+		// Not subscribed to, so never received.
+		srv.Send(icinga.Event{Type: icinga.TypeStateChange, Host: "web"})
+		e := icinga.Event{Type: icinga.TypeCheckResult, Host: "web", Service: "ping"}
+		e.CheckResult.State = 2
+		e.CheckResult.Output = "CRITICAL - timed out"
+		srv.Send(e)
+	}()
+	e, err := stream.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Type != icinga.TypeCheckResult || e.ObjectName() != "web!ping" || e.CheckResult.State != 2 {
+		t.Errorf("got event %+v, want critical check result of web!ping", e)
+	}
+	if obj, _ := srv.Object("Service", "web!ping"); obj.Attrs.State != 2 || obj.Attrs.LastCheckResult.Output != "CRITICAL - timed out" {
+		t.Errorf("object not updated by event: %+v", obj)
+	}
+
+	ack := icinga.Acknowledgement{Type: "Service", Service: "web!ping", Author: "alice", Comment: "looking"}
+	results, err := client.Action(ctx, "acknowledge-problem", ack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Code != 200 {
+		t.Errorf("got action results %+v", results)
+	}
+	e, err = stream.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Type != icinga.TypeAcknowledgementSet || e.Author != "alice" {
+		t.Errorf("got event %+v, want acknowledgement by alice", e)
+	}
+	objects, err := client.Objects(ctx, "services", url.Values{"service": {"web!ping"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if objects[0].Attrs.Acknowledgement != 1 {
+		t.Errorf("service not acknowledged: %+v", objects[0])
+	}
+
+	srv.Disconnect()
+	if _, err := stream.Next(); err != io.EOF {
+		t.Errorf("after disconnect: got error %v, want %v", err, io.EOF)
+	}
+}
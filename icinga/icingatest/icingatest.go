@@ -0,0 +1,476 @@
+// Package icingatest provides an in-process stand-in for the Icinga 2 API,
+// serving scripted objects and events, for testing programs using
+// package icinga without a real Icinga server.
+package icingatest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/meerkat-dashboard/meerkat/icinga"
+)
+
+// Server is a minimal Icinga 2 API serving the objects, status,
+// events and actions endpoints.
+//
+// Only simple filters on objects are understood: membership of a
+// group, such as `"web" in host.groups`, and name equality, such as
+// `service.name == "ping"`. The attrs parameter is ignored; all
+// attributes are returned. The actions acknowledge-problem and
+// remove-acknowledgement are supported. Event streams only understand
+// the filters returned by icinga.EventFilter.
+//
+// Requests must use HTTP basic authentication with Username and Password,
+// or, to a server started with NewTLSServer, a client certificate
+// with the common name Username.
+type Server struct {
+	*httptest.Server
+	Username string
+	Password string
+
+	mu      sync.Mutex
+	objects map[string][]icinga.Object
+	app     icinga.Application
+	streams map[*stream]bool
+	closed  chan struct{}
+}
+
+type stream struct {
+	types []string
+	// match reports whether an event is selected by the filter.
+	match  func(icinga.Event) bool
+	events chan icinga.Event
+	// done is closed to end the stream, and exited once it has ended.
+	done   chan struct{}
+	exited chan struct{}
+}
+
+// NewServer starts and returns a new Server with no objects.
+// The caller should call Close when finished.
+func NewServer(username, password string) *Server {
+	s := newServer(username, password)
+	s.Server.Start()
+	return s
+}
+
+func newServer(username, password string) *Server {
+	s := &Server{
+		Username: username,
+		Password: password,
+		objects:  make(map[string][]icinga.Object),
+		app:      icinga.Application{NodeName: "icingatest", Version: "r2.14.0", ProgramStart: 1700000000},
+		streams:  make(map[*stream]bool),
+		closed:   make(chan struct{}),
+	}
+	s.Server = httptest.NewUnstartedServer(s.handler())
+	return s
+}
+
+// NewTLSServer starts and returns a new Server with no objects,
+// serving HTTPS. Requests without basic authentication are authenticated
+// by a client certificate issued by one of clientCAs, as Icinga does for
+// API users with a client_cn.
+// The caller should call Close when finished.
+func NewTLSServer(username, password string, clientCAs *x509.CertPool) *Server {
+	s := newServer(username, password)
+	s.Server.TLS = &tls.Config{ClientAuth: tls.VerifyClientCertIfGiven, ClientCAs: clientCAs}
+	s.Server.StartTLS()
+	return s
+}
+
+// Close ends any event streams then shuts down the server.
+func (s *Server) Close() {
+	s.mu.Lock()
+	select {
+	case <-s.closed:
+	default:
+		close(s.closed)
+	}
+	s.mu.Unlock()
+	s.Server.Close()
+}
+
+// collection returns the name of the URL path listing objects of typ,
+// such as "hosts" for Host.
+func collection(typ string) string {
+	return strings.ToLower(typ) + "s"
+}
+
+// Add adds objects to those served, replacing any with the same type and name.
+// The type of each object should be set, for example to "Host" or "Service".
+func (s *Server) Add(objects ...icinga.Object) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, obj := range objects {
+		if obj.Attrs.Name == "" {
+			obj.Attrs.Name = obj.Name
+		}
+		if obj.Attrs.Type == "" {
+			obj.Attrs.Type = obj.Type
+		}
+		c := collection(obj.Type)
+		i := slices.IndexFunc(s.objects[c], func(o icinga.Object) bool { return o.Name == obj.Name })
+		if i < 0 {
+			s.objects[c] = append(s.objects[c], obj)
+		} else {
+			s.objects[c][i] = obj
+		}
+	}
+}
+
+// Object returns the object of typ named name, such as "Service" and "web!ping".
+func (s *Server) Object(typ, name string) (icinga.Object, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, obj := range s.objects[collection(typ)] {
+		if obj.Name == name {
+			return obj, true
+		}
+	}
+	return icinga.Object{}, false
+}
+
+// SetProgramStart sets the time Icinga was last started, in seconds
+// since the Unix epoch, as if it had been restarted or reloaded.
+func (s *Server) SetProgramStart(t float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.app.ProgramStart = t
+}
+
+// Streams returns the number of event streams open.
+func (s *Server) Streams() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.streams)
+}
+
+// Disconnect ends all open event streams, as Icinga does when it restarts.
+func (s *Server) Disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for st := range s.streams {
+		close(st.done)
+		delete(s.streams, st)
+	}
+}
+
+// Send updates the object an event is about, if it is known,
+// then sends the event on the streams subscribed to its type.
+// It returns once the event has been received by each stream.
+func (s *Server) Send(e icinga.Event) {
+	s.mu.Lock()
+	s.update(e)
+	var streams []*stream
+	for st := range s.streams {
+		if slices.Contains(st.types, e.Type) && st.match(e) {
+			streams = append(streams, st)
+		}
+	}
+	s.mu.Unlock()
+	for _, st := range streams {
+		select {
+		case st.events <- e:
+		case <-st.exited:
+		}
+	}
+}
+
+// update applies the changes reported by e to the object it is about.
+// s.mu must be held.
+func (s *Server) update(e icinga.Event) {
+	typ := "Host"
+	if e.Service != "" {
+		typ = "Service"
+	}
+	objects := s.objects[collection(typ)]
+	i := slices.IndexFunc(objects, func(o icinga.Object) bool { return o.Name == e.ObjectName() })
+	if i < 0 {
+		return
+	}
+	attrs := &objects[i].Attrs
+	switch e.Type {
+	case icinga.TypeCheckResult, icinga.TypeStateChange:
+		attrs.State = e.CheckResult.State
+		attrs.StateType = e.CheckResult.VarsAfter.StateType
+		attrs.LastCheckResult = icinga.LastCheckResult{
+			Output:          e.CheckResult.Output,
+			PerformanceData: e.CheckResult.PerformanceData,
+			State:           e.CheckResult.State,
+			Type:            "CheckResult",
+		}
+	case icinga.TypeAcknowledgementSet:
+		attrs.Acknowledgement = 1
+	case icinga.TypeAcknowledgementCleared:
+		attrs.Acknowledgement = 0
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, icinga.Error{StatusCode: status, Status: msg})
+}
+
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/objects/", s.serveObjects)
+	mux.HandleFunc("/v1/status/IcingaApplication", s.serveStatus)
+	mux.HandleFunc("/v1/events", s.serveEvents)
+	mux.HandleFunc("/v1/actions/", s.serveAction)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !s.authenticated(req) {
+			writeError(w, http.StatusUnauthorized, "Unauthorized. Please check your user credentials.")
+			return
+		}
+		mux.ServeHTTP(w, req)
+	})
+}
+
+// authenticated reports whether req is from the API user, by its basic
+// authentication if any, or otherwise by its client certificate.
+func (s *Server) authenticated(req *http.Request) bool {
+	if user, pass, ok := req.BasicAuth(); ok {
+		return user == s.Username && pass == s.Password
+	}
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 {
+		return false
+	}
+	return req.TLS.VerifiedChains[0][0].Subject.CommonName == s.Username
+}
+
+var (
+	groupFilter = regexp.MustCompile(`^"([^"]*)" in (host|service)\.groups$`)
+	nameFilter  = regexp.MustCompile(`^(host|service)\.name == "([^"]*)"$`)
+)
+
+// match reports whether obj is selected by the filter expression,
+// or false with an error message if the filter is not understood.
+func match(filter string, obj icinga.Object) (bool, string) {
+	filter = strings.TrimSpace(filter)
+	if m := groupFilter.FindStringSubmatch(filter); m != nil {
+		return strings.EqualFold(m[2], obj.Type) && slices.Contains(obj.Attrs.Groups, m[1]), ""
+	}
+	if m := nameFilter.FindStringSubmatch(filter); m != nil {
+		name := obj.Name
+		if strings.EqualFold(obj.Type, "Service") {
+			host, service, _ := strings.Cut(obj.Name, "!")
+			name = service
+			if m[1] == "host" {
+				name = host
+			}
+		}
+		return name == m[2], ""
+	}
+	return false, "Invalid filter expression: " + filter
+}
+
+var (
+	hostsFilter    = regexp.MustCompile(`^\(!event\.service && event\.host in (\[.*\])\)$`)
+	servicesFilter = regexp.MustCompile(`^\(event\.service && \(event\.host \+ "!" \+ event\.service\) in (\[.*\])\)$`)
+)
+
+// eventFilter returns a function reporting whether an event is selected
+// by the filter expression, or false if it is not understood.
+// Only the filters from icinga.EventFilter are understood.
+func eventFilter(filter string) (func(icinga.Event) bool, bool) {
+	switch filter {
+	case "":
+		return func(icinga.Event) bool { return true }, true
+	case "false":
+		return func(icinga.Event) bool { return false }, true
+	}
+	var hosts, services []string
+	for _, clause := range strings.Split(filter, " || ") {
+		var names *[]string
+		m := hostsFilter.FindStringSubmatch(clause)
+		if m != nil {
+			names = &hosts
+		} else if m = servicesFilter.FindStringSubmatch(clause); m != nil {
+			names = &services
+		} else {
+			return nil, false
+		}
+		if err := json.Unmarshal([]byte(m[1]), names); err != nil {
+			return nil, false
+		}
+	}
+	return func(e icinga.Event) bool {
+		if e.Service == "" {
+			return slices.Contains(hosts, e.Host)
+		}
+		return slices.Contains(services, e.ObjectName())
+	}, true
+}
+
+func (s *Server) serveObjects(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Invalid request method.")
+		return
+	}
+	c := strings.TrimPrefix(req.URL.Path, "/v1/objects/")
+	q := req.URL.Query()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	objects, ok := s.objects[c]
+	if !ok && !slices.Contains([]string{"hosts", "services", "hostgroups", "servicegroups"}, c) {
+		writeError(w, http.StatusNotFound, "Object type '"+c+"' does not exist.")
+		return
+	}
+	results := []icinga.Object{}
+	// Objects are named by parameters such as host=a or hosts=a&hosts=b.
+	if names := append(q[strings.TrimSuffix(c, "s")], q[c]...); len(names) > 0 {
+		for _, obj := range objects {
+			if slices.Contains(names, obj.Name) {
+				results = append(results, obj)
+			}
+		}
+		if len(results) == 0 {
+			writeError(w, http.StatusNotFound, "No objects found.")
+			return
+		}
+	} else if filter := q.Get("filter"); filter != "" {
+		for _, obj := range objects {
+			ok, msg := match(filter, obj)
+			if msg != "" {
+				writeError(w, http.StatusBadRequest, msg)
+				return
+			}
+			if ok {
+				results = append(results, obj)
+			}
+		}
+	} else {
+		results = append(results, objects...)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"results": results})
+}
+
+func (s *Server) serveStatus(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	app := s.app
+	s.mu.Unlock()
+	result := map[string]any{
+		"name":     "IcingaApplication",
+		"perfdata": []any{},
+		"status":   map[string]any{"icingaapplication": map[string]any{"app": app}},
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"results": []any{result}})
+}
+
+func (s *Server) serveEvents(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Invalid request method.")
+		return
+	}
+	var sub icinga.Subscription
+	if err := json.NewDecoder(req.Body).Decode(&sub); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	if len(sub.Types) == 0 || sub.Queue == "" {
+		writeError(w, http.StatusBadRequest, "'types' and 'queue' are required.")
+		return
+	}
+	match, ok := eventFilter(sub.Filter)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid filter expression: "+sub.Filter)
+		return
+	}
+	st := &stream{
+		types:  sub.Types,
+		match:  match,
+		events: make(chan icinga.Event),
+		done:   make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+	s.mu.Lock()
+	s.streams[st] = true
+	s.mu.Unlock()
+	defer func() {
+		close(st.exited)
+		s.mu.Lock()
+		if s.streams[st] {
+			close(st.done)
+			delete(s.streams, st)
+		}
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case e := <-st.events:
+			enc.Encode(e)
+			w.(http.Flusher).Flush()
+		case <-st.done:
+			return
+		case <-s.closed:
+			return
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+// actionParams are the parameters of the supported actions.
+type actionParams struct {
+	Type    string `json:"type"`
+	Host    string `json:"host"`
+	Service string `json:"service"`
+	Author  string `json:"author"`
+	Comment string `json:"comment"`
+}
+
+func (s *Server) serveAction(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Invalid request method.")
+		return
+	}
+	name := strings.TrimPrefix(req.URL.Path, "/v1/actions/")
+	var params actionParams
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	event := icinga.Event{Host: params.Host}
+	objectName := params.Host
+	if params.Service != "" {
+		event.Host, event.Service, _ = strings.Cut(params.Service, "!")
+		objectName = params.Service
+	}
+	var status string
+	switch name {
+	case "acknowledge-problem":
+		event.Type = icinga.TypeAcknowledgementSet
+		event.Author, event.Comment = params.Author, params.Comment
+		status = "Successfully acknowledged problem for object '" + objectName + "'."
+	case "remove-acknowledgement":
+		event.Type = icinga.TypeAcknowledgementCleared
+		status = "Successfully removed acknowledgement for object '" + objectName + "'."
+	default:
+		writeError(w, http.StatusNotFound, "Action '"+name+"' does not exist.")
+		return
+	}
+	if _, ok := s.Object(params.Type, objectName); !ok {
+		writeError(w, http.StatusNotFound, "No objects found.")
+		return
+	}
+	s.Send(event)
+	result := icinga.ActionResult{Code: 200, Status: status, Name: objectName}
+	writeJSON(w, http.StatusOK, map[string]any{"results": []icinga.ActionResult{result}})
+}
@@ -0,0 +1,286 @@
+package meerkat
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A PatchOperation is one operation of a JSON Patch document, as
+// described by RFC 6902.
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// PatchError reports a JSON Patch operation which could not be applied
+// to a document, such as one referring to a missing value or a failed test.
+type PatchError struct {
+	Index int
+	Op    PatchOperation
+	Err   error
+}
+
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("patch operation %d (%s %s): %v", e.Index, e.Op.Op, e.Op.Path, e.Err)
+}
+
+func (e *PatchError) Unwrap() error { return e.Err }
+
+// DecodePatch decodes the JSON Patch document in b, checking that
+// each operation is well formed.
+func DecodePatch(b []byte) ([]PatchOperation, error) {
+	var ops []PatchOperation
+	if err := json.Unmarshal(b, &ops); err != nil {
+		return nil, fmt.Errorf("decode patch: %w", err)
+	}
+	for i, op := range ops {
+		if _, err := splitPointer(op.Path); err != nil {
+			return nil, fmt.Errorf("patch operation %d: %w", i, err)
+		}
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return nil, fmt.Errorf("patch operation %d: %s without value", i, op.Op)
+			}
+		case "move", "copy":
+			if _, err := splitPointer(op.From); err != nil {
+				return nil, fmt.Errorf("patch operation %d: from: %w", i, err)
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("patch operation %d: unknown operation %q", i, op.Op)
+		}
+	}
+	return ops, nil
+}
+
+// ApplyPatch applies ops in order to doc, a document decoded from JSON
+// into generic values, returning the patched document.
+// If any operation fails, ApplyPatch returns a *PatchError and
+// the document should be discarded, as it may be partially modified.
+func ApplyPatch(doc any, ops []PatchOperation) (any, error) {
+	for i, op := range ops {
+		var err error
+		doc, err = applyOperation(doc, op)
+		if err != nil {
+			return nil, &PatchError{Index: i, Op: op, Err: err}
+		}
+	}
+	return doc, nil
+}
+
+func applyOperation(doc any, op PatchOperation) (any, error) {
+	path, err := splitPointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	if op.Value != nil {
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return nil, fmt.Errorf("decode value: %w", err)
+		}
+	}
+	switch op.Op {
+	case "add":
+		return addValue(doc, path, value)
+	case "remove":
+		doc, _, err := removeValue(doc, path)
+		return doc, err
+	case "replace":
+		if doc, _, err = removeValue(doc, path); err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, value)
+	case "move":
+		from, err := splitPointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if len(path) > len(from) && reflect.DeepEqual(path[:len(from)], from) {
+			return nil, fmt.Errorf("cannot move %s into itself", op.From)
+		}
+		doc, v, err := removeValue(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, v)
+	case "copy":
+		from, err := splitPointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		v, err := getValue(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		// Copies must not share maps or slices with the original.
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		var c any
+		if err := json.Unmarshal(b, &c); err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, c)
+	case "test":
+		v, err := getValue(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(v, value) {
+			return nil, fmt.Errorf("test failed: value is %s", mustMarshal(v))
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unknown operation %q", op.Op)
+}
+
+// splitPointer splits the JSON Pointer (RFC 6901) p into its unescaped
+// reference tokens.
+func splitPointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(p, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q: must start with /", p)
+	}
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses the reference token t as an index into an array
+// of length n. If end is true, "-" refers to the position after the
+// last element and the index may equal n.
+func arrayIndex(t string, n int, end bool) (int, error) {
+	if t == "-" && end {
+		return n, nil
+	}
+	i, err := strconv.Atoi(t)
+	if err != nil || i < 0 || (t != "0" && strings.HasPrefix(t, "0")) {
+		return 0, fmt.Errorf("invalid array index %q", t)
+	}
+	if i > n || (i == n && !end) {
+		return 0, fmt.Errorf("array index %d out of range", i)
+	}
+	return i, nil
+}
+
+func getValue(doc any, path []string) (any, error) {
+	for i, t := range path {
+		switch v := doc.(type) {
+		case map[string]any:
+			var ok bool
+			if doc, ok = v[t]; !ok {
+				return nil, fmt.Errorf("no value at /%s", strings.Join(path[:i+1], "/"))
+			}
+		case []any:
+			n, err := arrayIndex(t, len(v), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = v[n]
+		default:
+			return nil, fmt.Errorf("no value at /%s", strings.Join(path[:i+1], "/"))
+		}
+	}
+	return doc, nil
+}
+
+// addValue adds value to doc at path, returning the modified document.
+// Arrays are returned as new slices, so the parent of an array is
+// updated to refer to the returned one.
+func addValue(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := getValue(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	switch v := parent.(type) {
+	case map[string]any:
+		v[last] = value
+		return doc, nil
+	case []any:
+		i, err := arrayIndex(last, len(v), true)
+		if err != nil {
+			return nil, err
+		}
+		v = append(v[:i], append([]any{value}, v[i:]...)...)
+		return setValue(doc, path[:len(path)-1], v)
+	}
+	return nil, fmt.Errorf("cannot add to /%s: not an object or array", strings.Join(path[:len(path)-1], "/"))
+}
+
+// removeValue removes the value at path from doc, returning the
+// modified document and the value removed.
+func removeValue(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+	parent, err := getValue(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	last := path[len(path)-1]
+	switch v := parent.(type) {
+	case map[string]any:
+		old, ok := v[last]
+		if !ok {
+			return nil, nil, fmt.Errorf("no value at /%s", strings.Join(path, "/"))
+		}
+		delete(v, last)
+		return doc, old, nil
+	case []any:
+		i, err := arrayIndex(last, len(v), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		old := v[i]
+		v = append(v[:i:i], v[i+1:]...)
+		doc, err := setValue(doc, path[:len(path)-1], v)
+		return doc, old, err
+	}
+	return nil, nil, fmt.Errorf("no value at /%s", strings.Join(path, "/"))
+}
+
+// setValue replaces the existing value at path in doc.
+func setValue(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := getValue(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	switch v := parent.(type) {
+	case map[string]any:
+		v[last] = value
+	case []any:
+		i, err := arrayIndex(last, len(v), false)
+		if err != nil {
+			return nil, err
+		}
+		v[i] = value
+	}
+	return doc, nil
+}
+
+func mustMarshal(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
@@ -0,0 +1,92 @@
+package meerkat
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Examples are from Appendix A of RFC 6902.
+var patchTests = []struct {
+	doc, patch, want string
+}{
+	{`{"foo": "bar"}`, `[{"op": "add", "path": "/baz", "value": "qux"}]`, `{"baz": "qux", "foo": "bar"}`},
+	{`{"foo": ["bar", "baz"]}`, `[{"op": "add", "path": "/foo/1", "value": "qux"}]`, `{"foo": ["bar", "qux", "baz"]}`},
+	{`{"baz": "qux", "foo": "bar"}`, `[{"op": "remove", "path": "/baz"}]`, `{"foo": "bar"}`},
+	{`{"foo": ["bar", "qux", "baz"]}`, `[{"op": "remove", "path": "/foo/1"}]`, `{"foo": ["bar", "baz"]}`},
+	{`{"baz": "qux", "foo": "bar"}`, `[{"op": "replace", "path": "/baz", "value": "boo"}]`, `{"baz": "boo", "foo": "bar"}`},
+	{
+		`{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`,
+		`[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`,
+		`{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}}`,
+	},
+	{`{"foo": ["all", "grass", "cows", "eat"]}`, `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`, `{"foo": ["all", "cows", "eat", "grass"]}`},
+	{`{"baz": "qux", "foo": ["a", 2, "c"]}`, `[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2}]`, `{"baz": "qux", "foo": ["a", 2, "c"]}`},
+	{`{"foo": "bar"}`, `[{"op": "add", "path": "/child", "value": {"grandchild": {}}}]`, `{"foo": "bar", "child": {"grandchild": {}}}`},
+	{`{"foo": ["bar"]}`, `[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`, `{"foo": ["bar", ["abc", "def"]]}`},
+	{`{"/": 9, "~1": 10}`, `[{"op": "test", "path": "/~01", "value": 10}]`, `{"/": 9, "~1": 10}`},
+	{`{"a": {"b": 1}}`, `[{"op": "copy", "from": "/a", "path": "/c"}, {"op": "replace", "path": "/c/b", "value": 2}]`, `{"a": {"b": 1}, "c": {"b": 2}}`},
+}
+
+func TestApplyPatch(t *testing.T) {
+	for _, tt := range patchTests {
+		var doc, want any
+		if err := json.Unmarshal([]byte(tt.doc), &doc); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+			t.Fatal(err)
+		}
+		ops, err := DecodePatch([]byte(tt.patch))
+		if err != nil {
+			t.Errorf("decode %s: %v", tt.patch, err)
+			continue
+		}
+		got, err := ApplyPatch(doc, ops)
+		if err != nil {
+			t.Errorf("apply %s: %v", tt.patch, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("apply %s to %s: got %v, want %s", tt.patch, tt.doc, got, tt.want)
+		}
+	}
+}
+
+func TestApplyPatchErrors(t *testing.T) {
+	tests := []struct {
+		doc, patch string
+	}{
+		{`{"baz": "qux"}`, `[{"op": "test", "path": "/baz", "value": "bar"}]`},
+		{`{"foo": "bar"}`, `[{"op": "add", "path": "/baz/bat", "value": "qux"}]`},
+		{`{"foo": ["bar"]}`, `[{"op": "remove", "path": "/foo/1"}]`},
+		{`{"foo": ["bar"]}`, `[{"op": "replace", "path": "/foo/01", "value": 1}]`},
+		{`{"foo": {}}`, `[{"op": "move", "from": "/foo", "path": "/foo/bar"}]`},
+	}
+	for _, tt := range tests {
+		var doc any
+		if err := json.Unmarshal([]byte(tt.doc), &doc); err != nil {
+			t.Fatal(err)
+		}
+		ops, err := DecodePatch([]byte(tt.patch))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var perr *PatchError
+		if _, err := ApplyPatch(doc, ops); !errors.As(err, &perr) {
+			t.Errorf("apply %s to %s: got error %v, want PatchError", tt.patch, tt.doc, err)
+		}
+	}
+
+	for _, patch := range []string{
+		`{"op": "add"}`,
+		`[{"op": "frob", "path": "/a"}]`,
+		`[{"op": "add", "path": "/a"}]`,
+		`[{"op": "remove", "path": "a"}]`,
+	} {
+		if _, err := DecodePatch([]byte(patch)); err == nil {
+			t.Errorf("decode %s: nil error", patch)
+		}
+	}
+}
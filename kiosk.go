@@ -0,0 +1,169 @@
+package meerkat
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"strings"
+)
+
+// A Kiosk is a screen, such as a wall display, which shows whatever
+// dashboard or playlist it is told to. Kiosks identify themselves with
+// a token issued when they are created.
+type Kiosk struct {
+	// Name identifies the kiosk. It may only contain lower case
+	// letters, digits and dashes, like the slug of a dashboard.
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// TokenHash is the hex-encoded SHA-256 hash of the kiosk's token.
+	TokenHash string `json:"tokenHash,omitempty"`
+	// Dashboard or Playlist is what the kiosk shows, unless Blank.
+	Dashboard string `json:"dashboard,omitempty"`
+	Playlist  string `json:"playlist,omitempty"`
+	Blank     bool   `json:"blank,omitempty"`
+}
+
+// Path returns the path of the page the kiosk shows,
+// or the empty string if it shows nothing.
+func (k *Kiosk) Path() string {
+	switch {
+	case k.Blank:
+		return ""
+	case k.Playlist != "":
+		return "/playlist/" + url.PathEscape(k.Playlist) + "/view"
+	case k.Dashboard != "":
+		return "/" + url.PathEscape(k.Dashboard) + "/view"
+	}
+	return ""
+}
+
+// NewToken issues k a new token, replacing any previous one,
+// and returns it. Only its hash is kept.
+func (k *Kiosk) NewToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate kiosk token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	k.TokenHash = hashToken(token)
+	return token, nil
+}
+
+// CheckToken reports whether token is the token of k.
+func (k *Kiosk) CheckToken(token string) bool {
+	if k.TokenHash == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(k.TokenHash)) == 1
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Validate checks that k has a valid name and shows at most one thing,
+// returning a ValidationError listing every invalid field.
+func (k *Kiosk) Validate() error {
+	var errs ValidationError
+	if k.Name == "" || TitleToSlug(k.Name) != k.Name {
+		errs = append(errs, FieldError{Element: -1, Field: "name", Reason: fmt.Sprintf("%q may only contain lower case letters, digits and dashes", k.Name)})
+	}
+	if k.Dashboard != "" && k.Playlist != "" {
+		errs = append(errs, FieldError{Element: -1, Field: "playlist", Reason: "a kiosk cannot show both a dashboard and a playlist"})
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+const kioskPrefix = "kiosks/"
+
+// KioskKey returns the key of the kiosk with the given name.
+func KioskKey(name string) string {
+	return kioskPrefix + name + ".json"
+}
+
+// LoadKiosk returns the kiosk with the given name from s.
+// If there is no such kiosk, the returned error wraps fs.ErrNotExist.
+func LoadKiosk(s Store, name string) (Kiosk, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return Kiosk{}, fmt.Errorf("load kiosk %q: %w", name, fs.ErrNotExist)
+	}
+	b, err := s.Get(KioskKey(name))
+	if err != nil {
+		return Kiosk{}, fmt.Errorf("load kiosk %s: %w", name, err)
+	}
+	var k Kiosk
+	if err := json.Unmarshal(b, &k); err != nil {
+		return Kiosk{}, fmt.Errorf("decode kiosk %s: %w", name, err)
+	}
+	k.Name = name
+	return k, nil
+}
+
+// LoadKiosks returns all kiosks in s.
+func LoadKiosks(s Store) ([]Kiosk, error) {
+	keys, err := s.List(kioskPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("list kiosks: %w", err)
+	}
+	var kiosks []Kiosk
+	for _, key := range keys {
+		name, ok := strings.CutSuffix(strings.TrimPrefix(key, kioskPrefix), ".json")
+		if !ok || strings.Contains(name, "/") {
+			continue
+		}
+		k, err := LoadKiosk(s, name)
+		if err != nil {
+			return kiosks, err
+		}
+		kiosks = append(kiosks, k)
+	}
+	return kiosks, nil
+}
+
+// SaveKiosk stores k in s.
+func SaveKiosk(s Store, k *Kiosk) error {
+	if err := k.Validate(); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(k, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := s.Put(KioskKey(k.Name), b); err != nil {
+		return fmt.Errorf("save kiosk %s: %w", k.Name, err)
+	}
+	return nil
+}
+
+// DeleteKiosk removes the kiosk with the given name from s.
+func DeleteKiosk(s Store, name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("delete kiosk %q: %w", name, fs.ErrNotExist)
+	}
+	return s.Delete(KioskKey(name))
+}
+
+type kioskKey struct{}
+
+// NewKioskContext returns a copy of ctx carrying k.
+func NewKioskContext(ctx context.Context, k *Kiosk) context.Context {
+	return context.WithValue(ctx, kioskKey{}, k)
+}
+
+// KioskFromContext returns the kiosk stored in ctx by NewKioskContext.
+// It returns nil for requests not made by a kiosk.
+func KioskFromContext(ctx context.Context) *Kiosk {
+	k, _ := ctx.Value(kioskKey{}).(*Kiosk)
+	return k
+}
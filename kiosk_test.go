@@ -0,0 +1,44 @@
+package meerkat
+
+import (
+	"testing"
+)
+
+func TestKiosk(t *testing.T) {
+	s := DirStore(t.TempDir())
+	k := Kiosk{Name: "lobby", Dashboard: "network"}
+	token, err := k.NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveKiosk(s, &k); err != nil {
+		t.Fatal(err)
+	}
+	k, err = LoadKiosk(s, "lobby")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !k.CheckToken(token) {
+		t.Error("kiosk does not accept its token")
+	}
+	if k.CheckToken("") || k.CheckToken(k.TokenHash) {
+		t.Error("kiosk accepts an invalid token")
+	}
+	if p := k.Path(); p != "/network/view" {
+		t.Errorf("got path %q, want /network/view", p)
+	}
+	k.Playlist, k.Dashboard = "noc-wall", ""
+	if p := k.Path(); p != "/playlist/noc-wall/view" {
+		t.Errorf("got path %q, want /playlist/noc-wall/view", p)
+	}
+	k.Blank = true
+	if p := k.Path(); p != "" {
+		t.Errorf("blank kiosk has path %q", p)
+	}
+
+	for _, name := range []string{"", "Lobby", "a/b", "front door"} {
+		if err := SaveKiosk(s, &Kiosk{Name: name}); err == nil {
+			t.Errorf("saved kiosk with invalid name %q", name)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package meerkat
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SchemaVersion is the version of the dashboard format written by this
+// package. Dashboards with older versions are upgraded when read.
+// Documents without a version are either from Meerkat v2, which are
+// recognised by fields since removed, or otherwise version 3.
+const SchemaVersion = 4
+
+// A Migration upgrades a dashboard document from schema version From
+// to From+1. The document is the dashboard decoded as generic JSON.
+// Migrate modifies doc in place and returns a description of each
+// change made, for reporting to administrators.
+type Migration struct {
+	From    int
+	Summary string
+	Migrate func(doc map[string]any) (notes []string, err error)
+}
+
+// migrations holds every migration in order of From.
+// Changes to the dashboard format which are not backwards compatible
+// should increment SchemaVersion and append a Migration here.
+var migrations = []Migration{
+	{From: 2, Summary: "Convert Meerkat v2 dashboards to v3", Migrate: migrateV2},
+	{From: 3, Summary: "Identify elements", Migrate: migrateV3},
+}
+
+// MigrationResult describes the upgrade of one dashboard document.
+type MigrationResult struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+	// Versioned reports whether the original document recorded its version.
+	Versioned bool     `json:"versioned"`
+	Notes     []string `json:"notes"`
+}
+
+// Changed reports whether the upgraded document differs from the original.
+func (r *MigrationResult) Changed() bool {
+	return r.From != r.To || !r.Versioned
+}
+
+// MigrateDashboard upgrades the encoded dashboard in b to SchemaVersion,
+// returning the upgraded dashboard and a description of the changes made.
+func MigrateDashboard(b []byte) (Dashboard, *MigrationResult, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return Dashboard{}, nil, fmt.Errorf("decode dashboard: %w", err)
+	}
+	result, err := migrateDocument(doc)
+	if err != nil {
+		return Dashboard{}, result, err
+	}
+	if !result.Changed() {
+		d, err := decodeCurrentDashboard(b)
+		return d, result, err
+	}
+	b, err = json.Marshal(doc)
+	if err != nil {
+		return Dashboard{}, result, err
+	}
+	d, err := decodeCurrentDashboard(b)
+	return d, result, err
+}
+
+func migrateDocument(doc map[string]any) (*MigrationResult, error) {
+	result := &MigrationResult{Notes: []string{}}
+	switch v := doc["schemaVersion"].(type) {
+	case nil:
+		result.From = 3
+		if isV2(doc) {
+			result.From = 2
+		}
+	case float64:
+		result.From = int(v)
+		result.Versioned = true
+	default:
+		return result, fmt.Errorf("schema version %v is not a number", v)
+	}
+	result.To = result.From
+	if result.From > SchemaVersion {
+		return result, fmt.Errorf("schema version %d is newer than supported version %d", result.From, SchemaVersion)
+	}
+	for _, m := range migrations {
+		if m.From < result.To {
+			continue
+		}
+		if m.From != result.To {
+			return result, fmt.Errorf("no migration from schema version %d", result.To)
+		}
+		notes, err := m.Migrate(doc)
+		result.Notes = append(result.Notes, notes...)
+		if err != nil {
+			return result, fmt.Errorf("migrate from schema version %d: %w", m.From, err)
+		}
+		result.To++
+	}
+	if result.To != SchemaVersion {
+		return result, fmt.Errorf("no migration from schema version %d", result.To)
+	}
+	doc["schemaVersion"] = SchemaVersion
+	return result, nil
+}
+
+// isV2 reports whether doc has any fields or values only used by Meerkat v2.
+func isV2(doc map[string]any) bool {
+	if s, ok := doc["background"].(string); ok && strings.Contains(s, "dashboards-data") {
+		return true
+	}
+	elements, _ := doc["elements"].([]any)
+	for _, e := range elements {
+		element, _ := e.(map[string]any)
+		if t, ok := element["type"].(string); ok && v2Types[t] != "" {
+			return true
+		}
+		options, _ := element["options"].(map[string]any)
+		for _, k := range []string{"id", "filter", "selection", "statusFontSize", "nameFontSize", "checkDataSelection", "checkDataPattern", "checkDataDefault"} {
+			if _, ok := options[k]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var v2Types = map[string]string{
+	"iframe-video": "video",
+	"audio-stream": "audio",
+	"static-image": "image",
+}
+
+var v2Options = map[string]string{
+	"checkDataSelection": "objectAttr",
+	"checkDataPattern":   "objectAttrMatch",
+	"checkDataDefault":   "objectAttrNoMatch",
+}
+
+// migrateV2 converts dashboards from Meerkat v2. Uploaded files moved
+// from dashboards-data to separate directories for backgrounds and
+// sounds, some element types and options were renamed, and host and
+// service filters became object types of their own.
+func migrateV2(doc map[string]any) ([]string, error) {
+	var notes []string
+	if s, ok := doc["background"].(string); ok && strings.Contains(s, "dashboards-data") {
+		doc["background"] = strings.ReplaceAll(s, "dashboards-data", "dashboards-background")
+		notes = append(notes, fmt.Sprintf("moved background %s to dashboards-background", s))
+	}
+	notes = append(notes, moveSounds("", doc)...)
+
+	elements, _ := doc["elements"].([]any)
+	for i, e := range elements {
+		element, ok := e.(map[string]any)
+		if !ok {
+			continue
+		}
+		where := fmt.Sprintf("elements[%d]", i)
+		if t, ok := element["type"].(string); ok && v2Types[t] != "" {
+			element["type"] = v2Types[t]
+			notes = append(notes, fmt.Sprintf("%s: changed type %s to %s", where, t, v2Types[t]))
+		}
+		options, ok := element["options"].(map[string]any)
+		if !ok {
+			continue
+		}
+		for k, v := range options {
+			if v == "" {
+				delete(options, k)
+			}
+		}
+		for _, old := range []string{"checkDataSelection", "checkDataPattern", "checkDataDefault"} {
+			v, ok := options[old]
+			if !ok {
+				continue
+			}
+			if _, ok := options[v2Options[old]]; !ok {
+				options[v2Options[old]] = v
+				notes = append(notes, fmt.Sprintf("%s: renamed option %s to %s", where, old, v2Options[old]))
+			}
+			delete(options, old)
+		}
+		for _, old := range []string{"statusFontSize", "nameFontSize"} {
+			v, ok := options[old]
+			if !ok {
+				continue
+			}
+			if _, ok := options["fontSize"]; !ok {
+				options["fontSize"] = v
+				notes = append(notes, fmt.Sprintf("%s: renamed option %s to fontSize", where, old))
+			}
+			delete(options, old)
+		}
+		n, err := migrateV2Object(options)
+		if err != nil {
+			return notes, fmt.Errorf("%s: %w", where, err)
+		}
+		for _, note := range n {
+			notes = append(notes, where+": "+note)
+		}
+		notes = append(notes, moveSounds(where+".options.", options)...)
+	}
+	return notes, nil
+}
+
+// migrateV2Object converts how an element refers to Icinga objects.
+// Filters are used in preference to single objects, as in the v2 editor.
+func migrateV2Object(options map[string]any) ([]string, error) {
+	var notes []string
+	if filter, ok := options["filter"]; ok && filter != nil {
+		objectType, _ := options["objectType"].(string)
+		switch {
+		case strings.Contains(objectType, "service"):
+			options["objectType"] = "servicefilter"
+		case strings.Contains(objectType, "host"):
+			options["objectType"] = "hostfilter"
+		default:
+			return nil, fmt.Errorf("cannot determine filter type from object type %q", objectType)
+		}
+		options["objectName"] = filter
+		delete(options, "id")
+		notes = append(notes, fmt.Sprintf("replaced object with filter %v of type %s", filter, options["objectType"]))
+	} else if id, ok := options["id"]; ok {
+		if _, ok := options["objectName"]; !ok {
+			options["objectName"] = id
+			notes = append(notes, "renamed option id to objectName")
+		}
+		delete(options, "id")
+	}
+	delete(options, "filter")
+	delete(options, "selection")
+	return notes, nil
+}
+
+// moveSounds rewrites references to sounds under dashboards-data in m.
+func moveSounds(where string, m map[string]any) []string {
+	var notes []string
+	for _, k := range []string{"okSound", "warningSound", "criticalSound", "unknownSound", "upSound", "downSound", "resetSound"} {
+		s, ok := m[k].(string)
+		if !ok || !strings.Contains(s, "dashboards-data") {
+			continue
+		}
+		m[k] = strings.ReplaceAll(s, "dashboards-data", "dashboards-sound")
+		notes = append(notes, fmt.Sprintf("%s%s: moved %s to dashboards-sound", where, k, s))
+	}
+	return notes
+}
+
+// migrateV3 gives every element an ID. IDs are derived from the
+// element's position, so that reading the same unmigrated dashboard
+// twice gives the same IDs.
+func migrateV3(doc map[string]any) ([]string, error) {
+	var notes []string
+	elements, _ := doc["elements"].([]any)
+	used := make(map[string]bool)
+	for _, e := range elements {
+		element, _ := e.(map[string]any)
+		if id, ok := element["id"].(string); ok && id != "" {
+			used[id] = true
+		}
+	}
+	seen := make(map[string]bool)
+	for i, e := range elements {
+		element, ok := e.(map[string]any)
+		if !ok {
+			continue
+		}
+		if id, ok := element["id"].(string); ok && id != "" && !seen[id] {
+			seen[id] = true
+			continue
+		}
+		id := fmt.Sprintf("element-%d", i+1)
+		for n := 2; used[id]; n++ {
+			id = fmt.Sprintf("element-%d-%d", i+1, n)
+		}
+		used[id], seen[id] = true, true
+		element["id"] = id
+		notes = append(notes, fmt.Sprintf("elements[%d]: assigned id %s", i, id))
+	}
+	return notes, nil
+}
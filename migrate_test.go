@@ -0,0 +1,122 @@
+package meerkat
+
+import (
+	"reflect"
+	"testing"
+)
+
+const v2Dashboard = `{
+	"title": "Old",
+	"background": "/dashboards-data/map.png",
+	"criticalSound": "/dashboards-data/alarm.mp3",
+	"elements": [
+		{
+			"type": "check-card",
+			"title": "web",
+			"options": {"filter": "match(\"web*\", host.name)", "objectType": "host", "selection": "", "statusFontSize": 20}
+		},
+		{
+			"type": "static-image",
+			"options": {"image": "/dashboards-background/logo.png", "checkDataSelection": "state"}
+		},
+		{
+			"type": "check-card",
+			"options": {"id": "db!ping", "objectType": "service", "okSound": "/dashboards-data/ok.mp3"}
+		}
+	]
+}`
+
+func TestMigrateV2(t *testing.T) {
+	d, result, err := MigrateDashboard([]byte(v2Dashboard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.From != 2 || result.To != SchemaVersion || !result.Changed() {
+		t.Errorf("migrated from version %d to %d, want 2 to %d", result.From, result.To, SchemaVersion)
+	}
+	if len(result.Notes) == 0 {
+		t.Error("no notes on migration")
+	}
+	if d.SchemaVersion != SchemaVersion {
+		t.Errorf("got schema version %d, want %d", d.SchemaVersion, SchemaVersion)
+	}
+	if d.Background != "/dashboards-background/map.png" || d.CriticalSound != "/dashboards-sound/alarm.mp3" {
+		t.Errorf("assets not moved: background %s, critical sound %s", d.Background, d.CriticalSound)
+	}
+	want := []Options{
+		{ObjectName: `match("web*", host.name)`, ObjectType: "hostfilter", FontSize: "20"},
+		{Image: "/dashboards-background/logo.png", ObjectAttr: "state"},
+		{ObjectName: "db!ping", ObjectType: "service", OkSound: "/dashboards-sound/ok.mp3"},
+	}
+	for i, o := range want {
+		if d.Elements[i].Options != o {
+			t.Errorf("element %d: got options %+v, want %+v", i, d.Elements[i].Options, o)
+		}
+	}
+	if d.Elements[1].Type != "image" {
+		t.Errorf("got element type %s, want image", d.Elements[1].Type)
+	}
+
+	// Dashboards read from storage are upgraded too.
+	decoded, err := decodeDashboard([]byte(v2Dashboard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Elements[0].Options.ObjectType != "hostfilter" {
+		t.Errorf("decoded v2 dashboard not migrated")
+	}
+}
+
+func TestMigrateVersions(t *testing.T) {
+	tests := []struct {
+		doc     string
+		from    int
+		changed bool
+		wantErr bool
+	}{
+		{`{"title": "Unversioned", "elements": [{"type": "check-card", "options": {"objectName": "web"}}]}`, 3, true, false},
+		{`{"schemaVersion": 3, "title": "Previous"}`, 3, true, false},
+		{`{"schemaVersion": 4, "title": "Current"}`, 4, false, false},
+		{`{"schemaVersion": 5, "title": "Future"}`, 5, false, true},
+		{`{"schemaVersion": 1, "title": "Ancient"}`, 1, true, true},
+		{`{"title": "Bad filter", "elements": [{"type": "check-card", "options": {"filter": "x", "objectType": "user"}}]}`, 2, true, true},
+	}
+	for _, tt := range tests {
+		_, result, err := MigrateDashboard([]byte(tt.doc))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v, want error %v", tt.doc, err, tt.wantErr)
+		}
+		if result.From != tt.from {
+			t.Errorf("%s: got version %d, want %d", tt.doc, result.From, tt.from)
+		}
+		if !tt.wantErr && result.Changed() != tt.changed {
+			t.Errorf("%s: got changed %v, want %v", tt.doc, result.Changed(), tt.changed)
+		}
+	}
+}
+
+func TestMigrateV3(t *testing.T) {
+	doc := `{"schemaVersion": 3, "title": "Test", "elements": [
+		{"type": "clock"},
+		{"id": "element-1", "type": "clock"},
+		{"id": "element-1", "type": "clock"},
+		{"type": "clock"}
+	]}`
+	for i := 0; i < 2; i++ {
+		d, _, err := MigrateDashboard([]byte(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, e := range d.Elements {
+			ids = append(ids, e.ID)
+		}
+		want := []string{"element-1-2", "element-1", "element-3", "element-4"}
+		if !reflect.DeepEqual(ids, want) {
+			t.Errorf("got element IDs %v, want %v", ids, want)
+		}
+		if err := d.Validate(); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package meerkat
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// A Playlist is an ordered list of dashboards shown in turn,
+// such as on wall displays.
+type Playlist struct {
+	Title string `json:"title"`
+	// Name identifies the playlist. Like the slug of a dashboard,
+	// it is derived from the title when the playlist is saved.
+	Name string `json:"name"`
+	// Folder determines who may view and edit the playlist,
+	// as for dashboards.
+	Folder  string          `json:"folder"`
+	Entries []PlaylistEntry `json:"entries"`
+}
+
+// A PlaylistEntry is a dashboard in a playlist.
+type PlaylistEntry struct {
+	Slug string `json:"slug"`
+	// Dwell is how many seconds the dashboard is shown for.
+	Dwell int `json:"dwell"`
+	// HoldCritical keeps the dashboard shown past its dwell time
+	// while any of its elements shows a state at least as severe as
+	// critical, as ranked by the dashboard's severity Order.
+	HoldCritical bool `json:"holdCritical,omitempty"`
+}
+
+// Validate checks that p has a title and that every entry names a
+// dashboard and has a positive dwell time, returning a ValidationError
+// listing every invalid field.
+func (p *Playlist) Validate() error {
+	var errs ValidationError
+	if TitleToSlug(p.Title) == "" {
+		errs = append(errs, FieldError{Element: -1, Field: "title", Reason: fmt.Sprintf("%q does not contain a letter or digit", p.Title)})
+	}
+	for i, e := range p.Entries {
+		if e.Slug == "" || strings.ContainsAny(e.Slug, `/\`) {
+			errs = append(errs, FieldError{Element: -1, Field: fmt.Sprintf("entries[%d].slug", i), Reason: fmt.Sprintf("%q is not a dashboard slug", e.Slug)})
+		}
+		if e.Dwell <= 0 {
+			errs = append(errs, FieldError{Element: -1, Field: fmt.Sprintf("entries[%d].dwell", i), Reason: fmt.Sprintf("must be greater than 0, got %d", e.Dwell)})
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+const playlistPrefix = "playlists/"
+
+// PlaylistKey returns the key of the playlist with the given name.
+func PlaylistKey(name string) string {
+	return playlistPrefix + name + ".json"
+}
+
+// LoadPlaylist returns the playlist with the given name from s.
+// If there is no such playlist, the returned error wraps fs.ErrNotExist.
+func LoadPlaylist(s Store, name string) (Playlist, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return Playlist{}, fmt.Errorf("load playlist %q: %w", name, fs.ErrNotExist)
+	}
+	b, err := s.Get(PlaylistKey(name))
+	if err != nil {
+		return Playlist{}, fmt.Errorf("load playlist %s: %w", name, err)
+	}
+	var p Playlist
+	if err := json.Unmarshal(b, &p); err != nil {
+		return Playlist{}, fmt.Errorf("decode playlist %s: %w", name, err)
+	}
+	p.Name = name
+	return p, nil
+}
+
+// LoadPlaylists returns all playlists in s.
+func LoadPlaylists(s Store) ([]Playlist, error) {
+	keys, err := s.List(playlistPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("list playlists: %w", err)
+	}
+	var playlists []Playlist
+	for _, key := range keys {
+		name, ok := strings.CutSuffix(strings.TrimPrefix(key, playlistPrefix), ".json")
+		if !ok || strings.Contains(name, "/") {
+			continue
+		}
+		p, err := LoadPlaylist(s, name)
+		if err != nil {
+			return playlists, err
+		}
+		playlists = append(playlists, p)
+	}
+	return playlists, nil
+}
+
+// SavePlaylist stores p in s under the name derived from its title,
+// returning the encoded playlist as stored.
+func SavePlaylist(s Store, p *Playlist) ([]byte, error) {
+	p.Name = TitleToSlug(p.Title)
+	if p.Name == "" {
+		return nil, fmt.Errorf("save playlist: empty name from title %q", p.Title)
+	}
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Put(PlaylistKey(p.Name), b); err != nil {
+		return nil, fmt.Errorf("save playlist %s: %w", p.Name, err)
+	}
+	return b, nil
+}
+
+// DeletePlaylist removes the playlist with the given name from s.
+func DeletePlaylist(s Store, name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("delete playlist %q: %w", name, fs.ErrNotExist)
+	}
+	return s.Delete(PlaylistKey(name))
+}
@@ -0,0 +1,68 @@
+package meerkat
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+)
+
+func TestPlaylist(t *testing.T) {
+	s := DirStore(t.TempDir())
+	p := Playlist{
+		Title:  "NOC Wall",
+		Folder: "ops",
+		Entries: []PlaylistEntry{
+			{Slug: "network", Dwell: 30},
+			{Slug: "servers", Dwell: 60, HoldCritical: true},
+		},
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SavePlaylist(s, &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "noc-wall" {
+		t.Errorf("got name %q, want noc-wall", p.Name)
+	}
+	got, err := LoadPlaylist(s, "noc-wall")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, p) {
+		t.Errorf("got %+v, want %+v", got, p)
+	}
+	all, err := LoadPlaylists(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 {
+		t.Errorf("got %d playlists, want 1", len(all))
+	}
+	if err := DeletePlaylist(s, "noc-wall"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPlaylist(s, "noc-wall"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("load deleted playlist: got error %v, want %v", err, fs.ErrNotExist)
+	}
+	if _, err := LoadPlaylist(s, "../dashboards/x"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("load playlist outside playlists: got error %v, want %v", err, fs.ErrNotExist)
+	}
+}
+
+func TestValidatePlaylist(t *testing.T) {
+	p := &Playlist{Title: "Wall", Entries: []PlaylistEntry{{Slug: "", Dwell: 10}, {Slug: "a/b", Dwell: 0}}}
+	var verr ValidationError
+	if !errors.As(p.Validate(), &verr) {
+		t.Fatalf("got no ValidationError for invalid playlist")
+	}
+	var got []string
+	for _, f := range verr {
+		got = append(got, f.Field)
+	}
+	want := []string{"entries[0].slug", "entries[1].slug", "entries[1].dwell"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got invalid fields %q, want %q", got, want)
+	}
+}
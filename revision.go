@@ -0,0 +1,270 @@
+package meerkat
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Revision is a saved version of a dashboard.
+type Revision struct {
+	ID      int       `json:"id"`
+	Author  string    `json:"author"`
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+	// Dashboard is the dashboard as it was saved.
+	Dashboard *Dashboard `json:"dashboard,omitempty"`
+}
+
+// History keeps every saved version of each dashboard as a Revision.
+// Revisions of a dashboard are stored as numbered JSON documents
+// under "dashboards-history/<slug>/" in a Store.
+// The nil History records nothing.
+type History struct {
+	mu    sync.Mutex
+	store Store
+}
+
+const historyPrefix = "dashboards-history/"
+
+// NewHistory returns a History storing revisions in s.
+func NewHistory(s Store) *History {
+	return &History{store: s}
+}
+
+func revisionKey(slug string, id int) string {
+	return historyPrefix + slug + "/" + strconv.Itoa(id) + ".json"
+}
+
+// Record stores d as a new revision of the dashboard with the given slug.
+func (h *History) Record(slug string, d *Dashboard, author, message string) (Revision, error) {
+	if h == nil {
+		return Revision{}, nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ids, err := h.ids(slug)
+	if err != nil {
+		return Revision{}, err
+	}
+	rev := Revision{
+		ID:        1,
+		Author:    author,
+		Time:      time.Now().UTC().Truncate(time.Second),
+		Message:   message,
+		Dashboard: d,
+	}
+	if len(ids) > 0 {
+		rev.ID = ids[len(ids)-1] + 1
+	}
+	b, err := json.MarshalIndent(rev, "", "  ")
+	if err != nil {
+		return Revision{}, err
+	}
+	if err := h.store.Put(revisionKey(slug, rev.ID), b); err != nil {
+		return Revision{}, fmt.Errorf("store revision: %w", err)
+	}
+	return rev, nil
+}
+
+// ids returns the IDs of the revisions of slug in ascending order.
+func (h *History) ids(slug string) ([]int, error) {
+	prefix := historyPrefix + slug + "/"
+	keys, err := h.store.List(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("list revisions of %s: %w", slug, err)
+	}
+	var ids []int
+	for _, key := range keys {
+		name, ok := strings.CutSuffix(strings.TrimPrefix(key, prefix), ".json")
+		if !ok {
+			continue
+		}
+		id, err := strconv.Atoi(name)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// Revisions returns the revisions of the dashboard with the given slug,
+// newest first. The dashboards themselves are omitted; use Revision to
+// retrieve them.
+func (h *History) Revisions(slug string) ([]Revision, error) {
+	if h == nil {
+		return nil, nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ids, err := h.ids(slug)
+	if err != nil {
+		return nil, err
+	}
+	revs := make([]Revision, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		rev, err := h.read(slug, ids[i])
+		if err != nil {
+			return nil, err
+		}
+		rev.Dashboard = nil
+		revs = append(revs, rev)
+	}
+	return revs, nil
+}
+
+// Revision returns the revision of the dashboard with the given slug and ID.
+// If there is no such revision, the returned error wraps fs.ErrNotExist.
+func (h *History) Revision(slug string, id int) (Revision, error) {
+	if h == nil {
+		return Revision{}, fmt.Errorf("revision %d of %s: %w", id, slug, fs.ErrNotExist)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.read(slug, id)
+}
+
+func (h *History) read(slug string, id int) (Revision, error) {
+	b, err := h.store.Get(revisionKey(slug, id))
+	if err != nil {
+		return Revision{}, fmt.Errorf("revision %d of %s: %w", id, slug, err)
+	}
+	var rev Revision
+	if err := json.Unmarshal(b, &rev); err != nil {
+		return Revision{}, fmt.Errorf("decode revision %d of %s: %w", id, slug, err)
+	}
+	return rev, nil
+}
+
+// Rename moves the revisions of the dashboard oldSlug to newSlug,
+// for when a dashboard's title changes.
+// If newSlug already has revisions, nothing is moved.
+func (h *History) Rename(oldSlug, newSlug string) error {
+	if h == nil || oldSlug == newSlug {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	existing, err := h.ids(newSlug)
+	if err != nil || len(existing) > 0 {
+		return err
+	}
+	ids, err := h.ids(oldSlug)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		b, err := h.store.Get(revisionKey(oldSlug, id))
+		if err != nil {
+			return err
+		}
+		if err := h.store.Put(revisionKey(newSlug, id), b); err != nil {
+			return err
+		}
+	}
+	for _, id := range ids {
+		if err := h.store.Delete(revisionKey(oldSlug, id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Change is a difference between two dashboards.
+// Path locates the changed value in the dashboard's JSON encoding,
+// for example "elements[2].options.objectName".
+// Old is omitted for added values and New for removed values.
+type Change struct {
+	Path string `json:"path"`
+	Old  any    `json:"old,omitempty"`
+	New  any    `json:"new,omitempty"`
+}
+
+// Diff returns the changes needed to turn dashboard a into dashboard b.
+// Elements are compared by position.
+func Diff(a, b *Dashboard) ([]Change, error) {
+	va, err := generic(a)
+	if err != nil {
+		return nil, err
+	}
+	vb, err := generic(b)
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	diffValues("", va, vb, &changes)
+	return changes, nil
+}
+
+// generic returns v decoded from its JSON encoding into maps, slices and scalars.
+func generic(v any) (any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var g any
+	err = json.Unmarshal(b, &g)
+	return g, err
+}
+
+func diffValues(p string, a, b any, changes *[]Change) {
+	switch va := a.(type) {
+	case map[string]any:
+		vb, ok := b.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make(map[string]bool)
+		for k := range va {
+			keys[k] = true
+		}
+		for k := range vb {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			kp := k
+			if p != "" {
+				kp = p + "." + k
+			}
+			diffValues(kp, va[k], vb[k], changes)
+		}
+		return
+	case []any:
+		vb, ok := b.([]any)
+		if !ok && b != nil {
+			break
+		}
+		for i := 0; i < len(va) || i < len(vb); i++ {
+			var ea, eb any
+			if i < len(va) {
+				ea = va[i]
+			}
+			if i < len(vb) {
+				eb = vb[i]
+			}
+			diffValues(fmt.Sprintf("%s[%d]", p, i), ea, eb, changes)
+		}
+		return
+	case nil:
+		if vb, ok := b.([]any); ok {
+			diffValues(p, []any{}, vb, changes)
+			return
+		}
+	}
+	if !reflect.DeepEqual(a, b) {
+		*changes = append(*changes, Change{Path: p, Old: a, New: b})
+	}
+}
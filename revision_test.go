@@ -0,0 +1,93 @@
+package meerkat
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	h := NewHistory(DirStore(t.TempDir()))
+	d := &Dashboard{Title: "Test", Elements: []Element{{Type: "check-card", Title: "web"}}}
+	if _, err := h.Record("test", d, "alice", "Created dashboard"); err != nil {
+		t.Fatal(err)
+	}
+	d.Elements[0].Rect.X = 50
+	rev, err := h.Record("test", d, "bob", "Moved web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev.ID != 2 {
+		t.Errorf("second revision has id %d, want 2", rev.ID)
+	}
+
+	revs, err := h.Revisions("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 2 || revs[0].ID != 2 || revs[0].Author != "bob" || revs[1].Message != "Created dashboard" {
+		t.Errorf("unexpected revisions %+v", revs)
+	}
+
+	first, err := h.Revision("test", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Dashboard.Elements[0].Rect.X != 0 {
+		t.Errorf("first revision modified by later save: got x %v", first.Dashboard.Elements[0].Rect.X)
+	}
+	if _, err := h.Revision("test", 3); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("read missing revision: got error %v, want %v", err, fs.ErrNotExist)
+	}
+
+	if err := h.Rename("test", "renamed"); err != nil {
+		t.Fatal(err)
+	}
+	if revs, _ := h.Revisions("renamed"); len(revs) != 2 {
+		t.Errorf("got %d revisions after rename, want 2", len(revs))
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := &Dashboard{
+		Title: "Test",
+		Elements: []Element{
+			{Type: "check-card", Options: Options{ObjectName: "web"}},
+			{Type: "static-text", Options: Options{Text: "hello"}},
+		},
+	}
+	b := &Dashboard{
+		Title: "Test",
+		Elements: []Element{
+			{Type: "check-card", Options: Options{ObjectName: "db"}, Rect: Rect{X: 10}},
+		},
+	}
+	changes, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Change{
+		{Path: "elements[0].options.objectName", Old: "web", New: "db"},
+		{Path: "elements[0].rect.x", Old: float64(0), New: float64(10)},
+		{Path: "elements[1]", Old: map[string]any{
+			"id":       "",
+			"type":     "static-text",
+			"title":    "",
+			"rect":     map[string]any{"x": float64(0), "y": float64(0), "w": float64(0), "h": float64(0)},
+			"options":  map[string]any{"text": "hello"},
+			"rotation": float64(0),
+		}},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("got changes %+v, want %+v", changes, want)
+	}
+
+	changes, err = Diff(a, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("got changes %+v comparing dashboard to itself", changes)
+	}
+}
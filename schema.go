@@ -0,0 +1,449 @@
+package meerkat
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A Schema is a JSON Schema (draft 2020-12) or one of its subschemas.
+// Only the keywords needed to describe dashboards are supported.
+type Schema struct {
+	Schema      string             `json:"$schema,omitempty"`
+	ID          string             `json:"$id,omitempty"`
+	Ref         string             `json:"$ref,omitempty"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Const       string             `json:"const,omitempty"`
+	// Bounds are pointers as zero is a valid bound.
+	Minimum          *float64           `json:"minimum,omitempty"`
+	ExclusiveMinimum *float64           `json:"exclusiveMinimum,omitempty"`
+	Maximum          *float64           `json:"maximum,omitempty"`
+	Pattern          string             `json:"pattern,omitempty"`
+	Format           string             `json:"format,omitempty"`
+	AllOf            []*Schema          `json:"allOf,omitempty"`
+	If               *Schema            `json:"if,omitempty"`
+	Then             *Schema            `json:"then,omitempty"`
+	Defs             map[string]*Schema `json:"$defs,omitempty"`
+	// AdditionalProperties and PropertyNames describe objects used
+	// as maps, whose keys are not listed in Properties.
+	AdditionalProperties *Schema `json:"additionalProperties,omitempty"`
+	PropertyNames        *Schema `json:"propertyNames,omitempty"`
+}
+
+// SchemaID identifies the schema of the current dashboard format.
+var SchemaID = fmt.Sprintf("urn:meerkat:schema:dashboard:v%d", SchemaVersion)
+
+// ElementTypes lists the types of element which may be placed on a dashboard.
+var ElementTypes = []string{
+	"check-card",
+	"check-svg",
+	"check-line",
+	"dynamic-text",
+	"static-text",
+	"static-svg",
+	"static-ticker",
+	"image",
+	"video",
+	"audio",
+	"clock",
+}
+
+// ObjectTypes lists the kinds of object an element may show:
+// Icinga hosts and services, and Prometheus alerts and metrics.
+var ObjectTypes = []string{
+	"host",
+	"service",
+	"hostgroup",
+	"servicegroup",
+	"hostfilter",
+	"servicefilter",
+	"alert",
+	"alertfilter",
+	"metric",
+}
+
+var (
+	icingaOptions = []string{"objectType", "objectName", "backend", "linkURL", "okSound", "warningSound", "criticalSound", "unknownSound", "upSound", "downSound"}
+	attrOptions   = []string{"objectAttr", "objectAttrMatch", "objectAttrNoMatch"}
+	textOptions   = []string{"fontSize", "boldText", "fontColor", "backgroundColor", "textAlign", "textVerticalAlign", "linkURL"}
+)
+
+func concat(lists ...[]string) []string {
+	var all []string
+	for _, l := range lists {
+		all = append(all, l...)
+	}
+	return all
+}
+
+// elementOptions lists the options used by each type of element.
+var elementOptions = map[string][]string{
+	"check-card": concat(icingaOptions, attrOptions, []string{
+		"fontSize", "boldText", "textAlign", "textVerticalAlign",
+		"okFontColor", "warningFontColor", "warningAcknowledgedFontColor", "unknownFontColor",
+		"unknownAcknowledgedFontColor", "criticalFontColor", "criticalAcknowledgedFontColor",
+	}),
+	"check-svg": concat(icingaOptions, []string{
+		"okSvg", "warningSvg", "unknownSvg", "criticalSvg",
+		"okStrokeColor", "warningStrokeColor", "warningAcknowledgedStrokeColor", "unknownStrokeColor",
+		"unknownAcknowledgedStrokeColor", "criticalStrokeColor", "criticalAcknowledgedStrokeColor",
+	}),
+	"check-line":    concat(icingaOptions, []string{"strokeWidth", "leftArrow", "rightArrow"}),
+	"dynamic-text":  concat(icingaOptions, attrOptions, textOptions),
+	"static-text":   concat(textOptions, []string{"text"}),
+	"static-svg":    {"svg", "strokeColor", "strokeWidth"},
+	"static-ticker": concat(textOptions, []string{"text", "scrollPeriod"}),
+	"image":         {"image"},
+	"video":         {"source"},
+	"audio":         {"audioSource"},
+	"clock":         {"timeZone", "fontSize"},
+}
+
+func ptr(f float64) *float64 { return &f }
+
+// backendPattern matches the names of backends.
+const backendPattern = "^[a-z0-9_-]*$"
+
+// constraints refines the schemas generated from Go types,
+// keyed by type name and JSON field name.
+// Options ending in "Color" are constrained to colours separately.
+var constraints = map[string]func(s *Schema){
+	"Dashboard.title": func(s *Schema) {
+		s.Pattern = "[A-Za-z0-9]"
+		s.Description = "Titles must contain a letter or digit, as the dashboard's URL is derived from them."
+	},
+	"Dashboard.schemaVersion": func(s *Schema) {
+		s.Minimum, s.Maximum = ptr(0), ptr(SchemaVersion)
+		s.Description = "The version of the format the dashboard is stored in."
+	},
+	"Element.id": func(s *Schema) {
+		s.Pattern = "^[A-Za-z0-9_-]*$"
+		s.Description = "Identifies the element within its dashboard. Elements without one are assigned one when saved."
+	},
+	"Dashboard.template": func(s *Schema) {
+		s.Pattern = "^[a-z0-9-]*$"
+		s.Description = "The slug of the dashboard this dashboard is an instance of. Instances are shown with the elements of their template."
+	},
+	"Dashboard.variables": func(s *Schema) {
+		s.PropertyNames = &Schema{Pattern: variablePattern}
+		s.AdditionalProperties.Pattern = variableValuePattern
+		s.Description = "Values of variables referred to by placeholders such as ${hostgroup} in the objectName, text, linkURL and image options of elements."
+	},
+	"Dashboard.backend": func(s *Schema) {
+		s.Pattern = backendPattern
+		s.Description = "The name of the backend objects are shown from, unless an element names its own. Empty means the default one."
+	},
+	"Options.backend": func(s *Schema) {
+		s.Pattern = backendPattern
+		s.Description = "The name of the backend the object is shown from. Empty means that of the dashboard."
+	},
+	"Element.type":        func(s *Schema) { s.Enum = ElementTypes },
+	"Rect.w":              func(s *Schema) { s.Minimum = ptr(0) },
+	"Rect.h":              func(s *Schema) { s.Minimum = ptr(0) },
+	"Options.objectType":  func(s *Schema) { s.Enum = ObjectTypes },
+	"Options.fontSize":    func(s *Schema) { s.ExclusiveMinimum = ptr(0) },
+	"Options.strokeWidth": func(s *Schema) { s.ExclusiveMinimum = ptr(0) },
+}
+
+var (
+	schemaOnce sync.Once
+	schema     *Schema
+)
+
+// DashboardSchema returns the JSON Schema of dashboards in the current
+// format. It is generated from the Dashboard type and is the schema
+// used by Validate. The returned Schema must not be modified.
+func DashboardSchema() *Schema {
+	schemaOnce.Do(func() {
+		defs := make(map[string]*Schema)
+		schemaFor(reflect.TypeOf(Dashboard{}), defs)
+		schema = defs["Dashboard"]
+		delete(defs, "Dashboard")
+		schema.Schema = "https://json-schema.org/draft/2020-12/schema"
+		schema.ID = SchemaID
+		schema.Title = "Meerkat dashboard"
+		schema.Required = []string{"title"}
+		schema.Defs = defs
+
+		// Describe the options of each element type. Elements of any type
+		// may carry unused options, so these add no constraints of their own.
+		all := defs["Options"]
+		element := defs["Element"]
+		for _, typ := range ElementTypes {
+			opts := &Schema{Type: "object", Title: typ + " options", Properties: make(map[string]*Schema)}
+			for _, name := range elementOptions[typ] {
+				opts.Properties[name] = all.Properties[name]
+			}
+			name := "Options-" + typ
+			defs[name] = opts
+			element.AllOf = append(element.AllOf, &Schema{
+				If:   &Schema{Properties: map[string]*Schema{"type": {Const: typ}}, Required: []string{"type"}},
+				Then: &Schema{Properties: map[string]*Schema{"options": {Ref: "#/$defs/" + name}}},
+			})
+		}
+	})
+	return schema
+}
+
+var numberType = reflect.TypeOf(json.Number(""))
+
+// schemaFor returns the schema of values of type t,
+// adding the schemas of struct types to defs.
+func schemaFor(t reflect.Type, defs map[string]*Schema) *Schema {
+	switch {
+	case t == numberType:
+		return &Schema{Type: "number"}
+	case t.Kind() == reflect.String:
+		return &Schema{Type: "string"}
+	case t.Kind() == reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return &Schema{Type: "number"}
+	case t.Kind() == reflect.Slice:
+		return &Schema{Type: "array", Items: schemaFor(t.Elem(), defs)}
+	case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String:
+		return &Schema{Type: "object", AdditionalProperties: schemaFor(t.Elem(), defs)}
+	case t.Kind() == reflect.Struct:
+		ref := &Schema{Ref: "#/$defs/" + t.Name()}
+		if _, ok := defs[t.Name()]; ok {
+			return ref
+		}
+		s := &Schema{Type: "object", Title: t.Name(), Properties: make(map[string]*Schema)}
+		defs[t.Name()] = s
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			p := schemaFor(f.Type, defs)
+			if c, ok := constraints[t.Name()+"."+name]; ok {
+				c(p)
+			}
+			if t.Name() == "Options" && strings.HasSuffix(name, "Color") {
+				p.Format = "color"
+			}
+			s.Properties[name] = p
+		}
+		return ref
+	}
+	panic("no schema for type " + t.String())
+}
+
+// resolve returns the schema referred to by s.Ref, or s if there is none.
+func (s *Schema) resolve(root *Schema) *Schema {
+	if s.Ref == "" {
+		return s
+	}
+	name, ok := strings.CutPrefix(s.Ref, "#/$defs/")
+	if !ok || root.Defs[name] == nil {
+		panic("unresolvable schema reference " + s.Ref)
+	}
+	return root.Defs[name]
+}
+
+type schemaError struct {
+	path   []string
+	reason string
+}
+
+// validate appends to errs every way v, a value decoded from JSON,
+// fails to match s.
+func (s *Schema) validate(root *Schema, v any, path []string, errs *[]schemaError) {
+	s = s.resolve(root)
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, schemaError{append([]string(nil), path...), fmt.Sprintf(format, args...)})
+	}
+	if s.Type != "" && !hasType(v, s.Type) {
+		if str, ok := v.(string); ok {
+			fail("%q is not a%s %s", str, article(s.Type), s.Type)
+		} else {
+			fail("must be a%s %s", article(s.Type), s.Type)
+		}
+		return
+	}
+	switch v := v.(type) {
+	case string:
+		if len(s.Enum) > 0 && !contains(s.Enum, v) {
+			fail("%q is not one of %s", v, strings.Join(s.Enum, ", "))
+		}
+		if s.Const != "" && v != s.Const {
+			fail("must be %q", s.Const)
+		}
+		if s.Pattern != "" && !compiledPattern(s.Pattern).MatchString(v) {
+			fail("%q does not match pattern %s", v, s.Pattern)
+		}
+		if s.Format == "color" && v != "" && !validColour(v) {
+			fail("%q is not a colour", v)
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			fail("must be at least %v, got %v", *s.Minimum, v)
+		}
+		if s.ExclusiveMinimum != nil && v <= *s.ExclusiveMinimum {
+			fail("must be greater than %v, got %v", *s.ExclusiveMinimum, v)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			fail("must be at most %v, got %v", *s.Maximum, v)
+		}
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				fail("missing required field %s", name)
+			}
+		}
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if pv, ok := v[name]; ok {
+				s.Properties[name].validate(root, pv, append(path, name), errs)
+			}
+		}
+		if s.AdditionalProperties != nil || s.PropertyNames != nil {
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				if _, ok := s.Properties[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if s.PropertyNames != nil {
+					s.PropertyNames.validate(root, k, append(path, k), errs)
+				}
+				if s.AdditionalProperties != nil {
+					s.AdditionalProperties.validate(root, v[k], append(path, k), errs)
+				}
+			}
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(root, item, append(path, fmt.Sprint(i)), errs)
+			}
+		}
+	}
+	for _, sub := range s.AllOf {
+		sub.validate(root, v, path, errs)
+	}
+	if s.If != nil && s.Then != nil {
+		var ifErrs []schemaError
+		s.If.validate(root, v, path, &ifErrs)
+		if len(ifErrs) == 0 {
+			s.Then.validate(root, v, path, errs)
+		}
+	}
+}
+
+var patterns sync.Map // of string to *regexp.Regexp
+
+func compiledPattern(expr string) *regexp.Regexp {
+	if re, ok := patterns.Load(expr); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(expr)
+	patterns.Store(expr, re)
+	return re
+}
+
+// jsonValue returns v as it would be decoded from JSON into an any,
+// following the same struct tags as encoding/json.
+// Unlike encoding/json, invalid json.Numbers are returned as strings
+// rather than failing, so they may be reported as invalid.
+func jsonValue(v reflect.Value) any {
+	switch {
+	case v.Type() == numberType:
+		n, err := strconv.ParseFloat(v.String(), 64)
+		if err != nil {
+			return v.String()
+		}
+		return n
+	case v.Kind() == reflect.String:
+		return v.String()
+	case v.Kind() == reflect.Bool:
+		return v.Bool()
+	case v.CanInt():
+		return float64(v.Int())
+	case v.CanUint():
+		return float64(v.Uint())
+	case v.CanFloat():
+		return v.Float()
+	case v.Kind() == reflect.Slice:
+		a := make([]any, v.Len())
+		for i := range a {
+			a[i] = jsonValue(v.Index(i))
+		}
+		return a
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		m := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = jsonValue(iter.Value())
+		}
+		return m
+	case v.Kind() == reflect.Struct:
+		m := make(map[string]any)
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			fv := v.Field(i)
+			if opts == "omitempty" && fv.Kind() != reflect.Struct && fv.IsZero() {
+				continue
+			}
+			m[name] = jsonValue(fv)
+		}
+		return m
+	}
+	panic("no JSON value for type " + v.Type().String())
+}
+
+func hasType(v any, typ string) bool {
+	switch v := v.(type) {
+	case string:
+		return typ == "string"
+	case bool:
+		return typ == "boolean"
+	case float64:
+		return typ == "number" || typ == "integer" && v == float64(int64(v))
+	case map[string]any:
+		return typ == "object"
+	case []any:
+		return typ == "array"
+	case nil:
+		return typ == "null"
+	}
+	return false
+}
+
+func article(typ string) string {
+	if strings.ContainsAny(typ[:1], "aeiou") {
+		return "n"
+	}
+	return ""
+}
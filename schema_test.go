@@ -0,0 +1,74 @@
+package meerkat
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDashboardSchema(t *testing.T) {
+	s := DashboardSchema()
+	if _, err := json.Marshal(s); err != nil {
+		t.Fatal(err)
+	}
+	options := s.Defs["Options"]
+	typ := reflect.TypeOf(Options{})
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if options.Properties[name] == nil {
+			t.Errorf("option %s missing from schema", name)
+		}
+	}
+	for _, et := range ElementTypes {
+		names, ok := elementOptions[et]
+		if !ok {
+			t.Errorf("no options listed for element type %s", et)
+		}
+		for _, name := range names {
+			if options.Properties[name] == nil {
+				t.Errorf("%s element has unknown option %s", et, name)
+			}
+		}
+	}
+	if options.Properties["okFontColor"].Format != "color" {
+		t.Error("colour option not constrained to colours")
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	root := DashboardSchema()
+	tests := []struct {
+		doc  string
+		want []string
+	}{
+		{`{"title": "Test", "elements": [{"type": "clock", "rect": {"x": 1, "y": 2, "w": 3, "h": 4}, "options": {"fontSize": 12}}]}`, nil},
+		{`{"elements": []}`, []string{"missing required field title"}},
+		{`{"title": 5}`, []string{"title: must be a string"}},
+		{`{"title": "Test", "schemaVersion": 1.5}`, []string{"schemaVersion: must be an integer"}},
+		{`{"title": "Test", "elements": [{"type": "image", "options": {"image": false}}]}`, []string{"elements.0.options.image: must be a string"}},
+	}
+	for _, tt := range tests {
+		var v any
+		if err := json.Unmarshal([]byte(tt.doc), &v); err != nil {
+			t.Fatal(err)
+		}
+		var errs []schemaError
+		root.validate(root, v, nil, &errs)
+		var got []string
+		seen := make(map[string]bool)
+		for _, e := range errs {
+			msg := e.reason
+			if len(e.path) > 0 {
+				msg = strings.Join(e.path, ".") + ": " + msg
+			}
+			if !seen[msg] {
+				seen[msg] = true
+				got = append(got, msg)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got errors %q, want %q", tt.doc, got, tt.want)
+		}
+	}
+}
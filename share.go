@@ -0,0 +1,258 @@
+package meerkat
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// A Share lets anyone holding its token view one dashboard,
+// without logging in, until it expires or is revoked.
+type Share struct {
+	ID        string `json:"id"`
+	Dashboard string `json:"dashboard"`
+	// Note describes who the dashboard is shared with.
+	Note    string    `json:"note,omitempty"`
+	Created time.Time `json:"created"`
+	// CreatedBy is the name of the user who shared the dashboard.
+	CreatedBy string `json:"createdBy,omitempty"`
+	// Expires is when the share stops working. Shares with no
+	// expiry work until they are revoked.
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// Expired reports whether s has expired at time t.
+func (s *Share) Expired(t time.Time) bool {
+	return s.Expires != nil && !t.Before(*s.Expires)
+}
+
+// ErrInvalidShare is returned when verifying a share token which is
+// malformed, wrongly signed, expired or revoked.
+var ErrInvalidShare = errors.New("invalid share token")
+
+// shareClaims are the contents of a share token.
+type shareClaims struct {
+	ID        string `json:"id"`
+	Dashboard string `json:"dashboard"`
+	// Expires is a Unix time, or 0 for never.
+	Expires int64 `json:"exp,omitempty"`
+}
+
+// A ShareSigner issues and verifies share tokens.
+// Tokens are signed with a secret key, so they cannot be forged;
+// shares are also looked up when verified, so deleting one revokes it.
+type ShareSigner struct {
+	key []byte
+}
+
+// NewShareSigner returns a ShareSigner signing tokens with key.
+func NewShareSigner(key []byte) *ShareSigner {
+	return &ShareSigner{key: key}
+}
+
+// shareKeyName is the key the share key is stored under.
+const shareKeyName = "share.key"
+
+// LoadShareKey returns the key for signing share tokens kept in s,
+// so that tokens issued by one Meerkat instance are accepted by all
+// instances sharing s. If there is none, a random key is generated
+// and stored.
+func LoadShareKey(s Store) ([]byte, error) {
+	b, err := s.Get(shareKeyName)
+	if errors.Is(err, fs.ErrNotExist) {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("generate share key: %w", err)
+		}
+		if err := s.Put(shareKeyName, []byte(hex.EncodeToString(key)+"\n")); err != nil {
+			return nil, fmt.Errorf("store share key: %w", err)
+		}
+		// Instances started together may each have stored a key.
+		// The one stored last is used by all.
+		b, err = s.Get(shareKeyName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read share key: %w", err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil || len(key) < 32 {
+		return nil, errors.New("read share key: invalid key")
+	}
+	return key, nil
+}
+
+// Token returns the token for s.
+func (ss *ShareSigner) Token(s *Share) string {
+	claims := shareClaims{ID: s.ID, Dashboard: s.Dashboard}
+	if s.Expires != nil {
+		claims.Expires = s.Expires.Unix()
+	}
+	b, _ := json.Marshal(claims)
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(ss.sign(payload))
+}
+
+func (ss *ShareSigner) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, ss.key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// Verify returns the share in st whose token is token, if it has not
+// expired at time now. Otherwise the returned error wraps ErrInvalidShare.
+func (ss *ShareSigner) Verify(st Store, token string, now time.Time) (Share, error) {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return Share{}, fmt.Errorf("%w: malformed", ErrInvalidShare)
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, ss.sign(payload)) {
+		return Share{}, fmt.Errorf("%w: bad signature", ErrInvalidShare)
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return Share{}, fmt.Errorf("%w: malformed", ErrInvalidShare)
+	}
+	var claims shareClaims
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return Share{}, fmt.Errorf("%w: malformed", ErrInvalidShare)
+	}
+	if claims.Expires != 0 && now.Unix() >= claims.Expires {
+		return Share{}, fmt.Errorf("%w: expired", ErrInvalidShare)
+	}
+	s, err := LoadShare(st, claims.Dashboard, claims.ID)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Share{}, fmt.Errorf("%w: revoked", ErrInvalidShare)
+	} else if err != nil {
+		return Share{}, err
+	}
+	if s.Expired(now) {
+		return Share{}, fmt.Errorf("%w: expired", ErrInvalidShare)
+	}
+	return s, nil
+}
+
+const sharePrefix = "shares/"
+
+// ShareKey returns the key of the share id of the dashboard slug.
+func ShareKey(slug, id string) string {
+	return path.Join(sharePrefix, slug, id+".json")
+}
+
+// NewShare stores a new share of the dashboard slug in s.
+func NewShare(s Store, slug, note, createdBy string, expires *time.Time) (Share, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return Share{}, fmt.Errorf("generate share id: %w", err)
+	}
+	share := Share{
+		ID:        hex.EncodeToString(b),
+		Dashboard: slug,
+		Note:      note,
+		Created:   time.Now().UTC().Truncate(time.Second),
+		CreatedBy: createdBy,
+		Expires:   expires,
+	}
+	buf, err := json.MarshalIndent(share, "", "  ")
+	if err != nil {
+		return Share{}, err
+	}
+	if err := s.Put(ShareKey(slug, share.ID), buf); err != nil {
+		return Share{}, fmt.Errorf("save share of %s: %w", slug, err)
+	}
+	return share, nil
+}
+
+// LoadShare returns the share id of the dashboard slug from s.
+// If there is no such share, the returned error wraps fs.ErrNotExist.
+func LoadShare(s Store, slug, id string) (Share, error) {
+	if !validName(slug) || !validName(id) {
+		return Share{}, fmt.Errorf("load share %s of %s: %w", id, slug, fs.ErrNotExist)
+	}
+	b, err := s.Get(ShareKey(slug, id))
+	if err != nil {
+		return Share{}, fmt.Errorf("load share %s of %s: %w", id, slug, err)
+	}
+	var share Share
+	if err := json.Unmarshal(b, &share); err != nil {
+		return Share{}, fmt.Errorf("decode share %s of %s: %w", id, slug, err)
+	}
+	return share, nil
+}
+
+// LoadShares returns the shares of the dashboard slug in s,
+// including expired shares.
+func LoadShares(s Store, slug string) ([]Share, error) {
+	if !validName(slug) {
+		return nil, nil
+	}
+	prefix := path.Join(sharePrefix, slug) + "/"
+	keys, err := s.List(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("list shares of %s: %w", slug, err)
+	}
+	var shares []Share
+	for _, key := range keys {
+		id, ok := strings.CutSuffix(strings.TrimPrefix(key, prefix), ".json")
+		if !ok || strings.Contains(id, "/") {
+			continue
+		}
+		share, err := LoadShare(s, slug, id)
+		if err != nil {
+			return shares, err
+		}
+		shares = append(shares, share)
+	}
+	return shares, nil
+}
+
+// DeleteShare revokes the share id of the dashboard slug.
+func DeleteShare(s Store, slug, id string) error {
+	if !validName(slug) || !validName(id) {
+		return fmt.Errorf("delete share %s of %s: %w", id, slug, fs.ErrNotExist)
+	}
+	return s.Delete(ShareKey(slug, id))
+}
+
+// DeleteShares revokes all shares of the dashboard slug.
+func DeleteShares(s Store, slug string) error {
+	shares, err := LoadShares(s, slug)
+	if err != nil {
+		return err
+	}
+	for _, share := range shares {
+		if err := DeleteShare(s, slug, share.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validName reports whether s may name one element of a key or path.
+func validName(s string) bool {
+	return s != "" && s != "." && s != ".." && !strings.ContainsAny(s, `/\`)
+}
+
+type shareKey struct{}
+
+// NewShareContext returns a copy of ctx carrying s.
+func NewShareContext(ctx context.Context, s *Share) context.Context {
+	return context.WithValue(ctx, shareKey{}, s)
+}
+
+// ShareFromContext returns the share stored in ctx by NewShareContext.
+// It returns nil for requests not made with a share token.
+func ShareFromContext(ctx context.Context) *Share {
+	s, _ := ctx.Value(shareKey{}).(*Share)
+	return s
+}
@@ -0,0 +1,72 @@
+package meerkat
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShare(t *testing.T) {
+	s := DirStore(t.TempDir())
+	key, err := LoadShareKey(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := NewShareSigner(key)
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	share, err := NewShare(s, "network", "Acme", "admin", &expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := signer.Token(&share)
+
+	got, err := signer.Verify(s, token, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != share.ID || got.Dashboard != "network" || got.Note != "Acme" {
+		t.Errorf("verified share %+v, want %+v", got, share)
+	}
+	if _, err := signer.Verify(s, token, expires.Add(time.Second)); !errors.Is(err, ErrInvalidShare) {
+		t.Errorf("verify expired token: got error %v, want %v", err, ErrInvalidShare)
+	}
+
+	other := NewShareSigner([]byte(strings.Repeat("k", 32)))
+	payload, _, _ := strings.Cut(token, ".")
+	forged := Share{ID: share.ID, Dashboard: "secret"}
+	for _, bad := range []string{"", "garbage", payload, payload + ".AAAA", other.Token(&share), signer.Token(&forged)} {
+		if _, err := signer.Verify(s, bad, time.Now()); !errors.Is(err, ErrInvalidShare) {
+			t.Errorf("verify %q: got error %v, want %v", bad, err, ErrInvalidShare)
+		}
+	}
+
+	shares, err := LoadShares(s, "network")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shares) != 1 {
+		t.Errorf("got %d shares, want 1", len(shares))
+	}
+	if err := DeleteShares(s, "network"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := signer.Verify(s, token, time.Now()); !errors.Is(err, ErrInvalidShare) {
+		t.Errorf("verify revoked token: got error %v, want %v", err, ErrInvalidShare)
+	}
+}
+
+func TestLoadShareKey(t *testing.T) {
+	s := DirStore(t.TempDir())
+	key, err := LoadShareKey(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := LoadShareKey(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(key) != string(again) {
+		t.Error("share key changed when loaded again")
+	}
+}
@@ -0,0 +1,623 @@
+package meerkat
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ObjectState is the state of the objects shown by an element,
+// such as the worst state of the members of a host group.
+type ObjectState struct {
+	// State is the Icinga state of the objects: 0 for OK,
+	// 1 for warning, 2 for critical and 3 for unknown.
+	// Hosts are up when their state is 0 or 1, and down otherwise.
+	State        int  `json:"state"`
+	Acknowledged bool `json:"acknowledged,omitempty"`
+	// Output is the output of the last check.
+	Output string `json:"output,omitempty"`
+	// PerfData holds the performance data of the last check,
+	// keyed by label.
+	PerfData map[string]string `json:"perfdata,omitempty"`
+}
+
+// A Snapshot is a dashboard as seen by a browser at one moment.
+// It is rendered without a browser by WritePNG and WriteSVG.
+//
+// Snapshots approximate what the viewer shows. Text is drawn in a
+// built-in bitmap font in PNGs; the icons of SVG elements are drawn
+// as rings; video and audio elements are left out.
+type Snapshot struct {
+	Dashboard Dashboard
+	// Background is the dashboard's background image, if any.
+	Background image.Image
+	// Images holds the images shown by image elements,
+	// keyed by the elements' Options.Image.
+	Images map[string]image.Image
+	// States holds the states of the objects shown by elements,
+	// keyed by element ID. Elements without one are shown as pending.
+	States map[string]ObjectState
+	// Time is the time shown by clocks.
+	Time time.Time
+}
+
+const (
+	defaultSnapshotWidth  = 1920
+	defaultSnapshotHeight = 1080
+)
+
+// MaxSnapshotSize is the largest width and height of a snapshot in pixels.
+// Images larger than this should not be decoded to be shown in one.
+const MaxSnapshotSize = 8192
+
+// Size returns the size of the snapshot in pixels: that of the
+// background image, or else the dashboard's Width and Height,
+// each at most MaxSnapshotSize.
+func (s *Snapshot) Size() (width, height int) {
+	width, height = defaultSnapshotWidth, defaultSnapshotHeight
+	if s.Background != nil {
+		b := s.Background.Bounds()
+		width, height = b.Dx(), b.Dy()
+	} else {
+		if w, err := strconv.Atoi(strings.TrimSuffix(s.Dashboard.Width, "px")); err == nil && w > 0 {
+			width = w
+		}
+		if h, err := strconv.Atoi(strings.TrimSuffix(s.Dashboard.Height, "px")); err == nil && h > 0 {
+			height = h
+		}
+	}
+	return min(width, MaxSnapshotSize), min(height, MaxSnapshotSize)
+}
+
+// WritePNG writes the snapshot to w as a PNG image.
+func (s *Snapshot) WritePNG(w io.Writer) error {
+	width, height := s.Size()
+	r := &raster{dst: image.NewRGBA(image.Rect(0, 0, width, height))}
+	s.draw(r, float64(width), float64(height))
+	return png.Encode(w, r.dst)
+}
+
+// WriteSVG writes the snapshot to w as an SVG image.
+// Images are embedded, so the document stands alone.
+func (s *Snapshot) WriteSVG(w io.Writer) error {
+	width, height := s.Size()
+	v := &vector{}
+	fmt.Fprintf(&v.buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", width, height, width, height)
+	fmt.Fprintf(&v.buf, "<title>%s</title>\n", escapeXML(s.Dashboard.Title))
+	s.draw(v, float64(width), float64(height))
+	v.buf.WriteString("</svg>\n")
+	if v.err != nil {
+		return v.err
+	}
+	_, err := w.Write(v.buf.Bytes())
+	return err
+}
+
+// A canvas is what snapshots are drawn on.
+// Between begin and end, coordinates are relative to the top left
+// of the element being drawn, and drawing is clipped to it.
+type canvas interface {
+	begin(x, y, w, h, rotation float64)
+	end()
+	fill(x, y, w, h float64, c color.NRGBA)
+	stroke(points [][2]float64, width float64, c color.NRGBA)
+	ring(cx, cy, r, width float64, c color.NRGBA)
+	image(img image.Image, x, y, w, h float64)
+	text(t textBox)
+}
+
+// A textBox is text laid out within a box, as in a flexbox
+// aligned by Options.TextAlign and Options.TextVerticalAlign.
+type textBox struct {
+	text          string
+	x, y, w, h    float64
+	size          float64
+	bold          bool
+	align, valign string
+	color         color.NRGBA
+}
+
+// lines returns the lines of t and the position of the first
+// line's top within the box, given the height of each line.
+func (t textBox) lines(lineHeight float64) ([]string, float64) {
+	lines := strings.Split(t.text, "\n")
+	top := t.y + (t.h-lineHeight*float64(len(lines)))/2
+	switch {
+	case t.valign == "start":
+		top = t.y
+	case strings.HasSuffix(t.valign, "end"):
+		top = t.y + t.h - lineHeight*float64(len(lines))
+	}
+	return lines, top
+}
+
+func (s *Snapshot) draw(c canvas, width, height float64) {
+	if s.Background != nil {
+		c.begin(0, 0, width, height, 0)
+		c.image(s.Background, 0, 0, width, height)
+		c.end()
+	}
+	for _, e := range s.Dashboard.Elements {
+		w, h := e.Rect.W/100*width, e.Rect.H/100*height
+		c.begin(e.Rect.X/100*width, e.Rect.Y/100*height, w, h, e.Rotation)
+		s.drawElement(c, e, w, h)
+		c.end()
+	}
+}
+
+func (s *Snapshot) drawElement(c canvas, e Element, w, h float64) {
+	o := e.Options
+	st, ok := s.States[e.ID]
+	state := "pending"
+	if ok {
+		state = stateName(o.ObjectType, st.State)
+	}
+	switch e.Type {
+	case "check-card":
+		class := state
+		if ok && st.Acknowledged && state != "ok" && state != "up" {
+			class += "-ack"
+		}
+		look := stateColors[class]
+		c.fill(0, 0, w, h, look.background)
+		text := strings.ToUpper(elementText(o, state, st, ok))
+		fontColor := parseColor(cardFontColor(o, state, st.Acknowledged), look.text)
+		// Cards are padded by 6px vertically and 10px horizontally.
+		c.text(textBox{text, 10, 6, w - 20, h - 12, fontSize(o, 48), o.BoldText, o.TextAlign, o.TextVerticalAlign, fontColor})
+	case "dynamic-text":
+		drawText(c, o, elementText(o, state, st, ok), w, h)
+	case "static-text", "static-ticker":
+		drawText(c, o, o.Text, w, h)
+	case "clock":
+		loc, err := time.LoadLocation(o.TimeZone)
+		if err != nil {
+			loc = time.Local
+		}
+		text := s.Time.In(loc).Format("15:04:05")
+		c.text(textBox{text, 0, 0, w, h, fontSize(o, 16), false, "start", "start", color.NRGBA{A: 0xff}})
+	case "check-line":
+		width := number(o.StrokeWidth, 4)
+		look := stateColors[state].background
+		c.stroke([][2]float64{{5, h / 2}, {w - 5, h / 2}}, width, look)
+		if o.LeftArrow {
+			c.stroke([][2]float64{{30, 5}, {5, h / 2}, {30, h - 5}}, width, look)
+		}
+		if o.RightArrow {
+			c.stroke([][2]float64{{w - 30, 5}, {w - 5, h / 2}, {w - 30, h - 5}}, width, look)
+		}
+	case "check-svg":
+		stroke := parseColor(svgStrokeColor(o, state, st.Acknowledged), stateColors[state].background)
+		drawIcon(c, number(o.StrokeWidth, 2), stroke, w, h)
+	case "static-svg":
+		drawIcon(c, number(o.StrokeWidth, 1), parseColor(o.StrokeColor, color.NRGBA{0x00, 0xb6, 0xff, 0xff}), w, h)
+	case "image":
+		if img := s.Images[o.Image]; img != nil {
+			c.image(img, 0, 0, w, h)
+		}
+	}
+}
+
+// drawText draws a text element, using the defaults
+// the editor gives new text elements.
+func drawText(c canvas, o Options, text string, w, h float64) {
+	c.fill(0, 0, w, h, parseColor(o.BackgroundColor, color.NRGBA{0x00, 0x7b, 0xff, 0xff}))
+	align, valign := o.TextAlign, o.TextVerticalAlign
+	if align == "" {
+		align = "center"
+	}
+	c.text(textBox{text, 0, 0, w, h, fontSize(o, 22), o.BoldText, align, valign, parseColor(o.FontColor, color.NRGBA{0xff, 0xff, 0xff, 0xff})})
+}
+
+// drawIcon draws an icon from the 24 pixel square feather icon set,
+// scaled to fit the element, as a ring.
+func drawIcon(c canvas, strokeWidth float64, stroke color.NRGBA, w, h float64) {
+	scale := math.Min(w, h) / 24
+	c.ring(w/2, h/2, 10*scale, strokeWidth*scale, stroke)
+}
+
+// elementText returns the text shown by a check card or dynamic text
+// element: the state, or the attribute of the objects it is set to show.
+func elementText(o Options, state string, st ObjectState, ok bool) string {
+	if !ok {
+		return o.ObjectAttrNoMatch
+	}
+	if o.ObjectAttr == "" || o.ObjectAttr == "state" {
+		if st.Acknowledged {
+			return state + " (ACK)"
+		}
+		return state
+	}
+	var text string
+	var found bool
+	if o.ObjectAttr == "pluginOutput" {
+		text, found = st.Output, true
+	} else {
+		text, found = st.PerfData[o.ObjectAttr]
+	}
+	if found && o.ObjectAttrMatch != "" {
+		if re, err := regexp.Compile("(?im)" + o.ObjectAttrMatch); err == nil {
+			if m := re.FindStringSubmatch(text); m != nil {
+				text = m[len(m)-1]
+			} else if o.ObjectAttrNoMatch != "" {
+				text = o.ObjectAttrNoMatch
+			}
+		}
+	}
+	if !found {
+		if o.ObjectAttrNoMatch != "" {
+			return o.ObjectAttrNoMatch
+		}
+		return state
+	}
+	return text
+}
+
+// stateName returns the name the viewer gives state,
+// the state of objects of type objType.
+func stateName(objType string, state int) string {
+	if strings.Contains(strings.ToLower(objType), "host") {
+		if state < 2 {
+			return "up"
+		}
+		return "down"
+	}
+	switch state {
+	case 0:
+		return "ok"
+	case 1:
+		return "warning"
+	case 2:
+		return "critical"
+	}
+	return "unknown"
+}
+
+// stateColors are the background and text colours of each state,
+// from the viewer's stylesheet.
+var stateColors = map[string]struct{ background, text color.NRGBA }{
+	"ok":           {color.NRGBA{0x0e, 0xe1, 0x6a, 0xff}, color.NRGBA{0x00, 0x00, 0x00, 0xff}},
+	"up":           {color.NRGBA{0x0e, 0xe1, 0x6a, 0xff}, color.NRGBA{0x00, 0x00, 0x00, 0xff}},
+	"warning":      {color.NRGBA{0xff, 0x90, 0x00, 0xff}, color.NRGBA{0x00, 0x00, 0x00, 0xff}},
+	"critical":     {color.NRGBA{0xff, 0x00, 0x19, 0xff}, color.NRGBA{0xff, 0xff, 0xff, 0xff}},
+	"down":         {color.NRGBA{0xff, 0x00, 0x19, 0xff}, color.NRGBA{0xff, 0xff, 0xff, 0xff}},
+	"unknown":      {color.NRGBA{0x97, 0x0e, 0xe1, 0xff}, color.NRGBA{0xff, 0xff, 0xff, 0xff}},
+	"pending":      {color.NRGBA{0x77, 0xaa, 0xff, 0xff}, color.NRGBA{0x00, 0x00, 0x00, 0xff}},
+	"warning-ack":  {color.NRGBA{0xff, 0xca, 0x39, 0xff}, color.NRGBA{0x00, 0x00, 0x00, 0xff}},
+	"critical-ack": {color.NRGBA{0xde, 0x5e, 0x84, 0xff}, color.NRGBA{0x00, 0x00, 0x00, 0xff}},
+	"down-ack":     {color.NRGBA{0xde, 0x5e, 0x84, 0xff}, color.NRGBA{0x00, 0x00, 0x00, 0xff}},
+	"unknown-ack":  {color.NRGBA{0xb5, 0x94, 0xb5, 0xff}, color.NRGBA{0x00, 0x00, 0x00, 0xff}},
+}
+
+func cardFontColor(o Options, state string, acknowledged bool) string {
+	switch state {
+	case "ok", "up":
+		return o.OkFontColor
+	case "warning":
+		if acknowledged {
+			return o.WarningAcknowledgedFontColor
+		}
+		return o.WarningFontColor
+	case "unknown":
+		if acknowledged {
+			return o.UnknownAcknowledgedFontColor
+		}
+		return o.UnknownFontColor
+	case "critical", "down":
+		if acknowledged {
+			return o.CriticalAcknowledgedFontColor
+		}
+		return o.CriticalFontColor
+	}
+	return ""
+}
+
+func svgStrokeColor(o Options, state string, acknowledged bool) string {
+	switch state {
+	case "ok", "up":
+		return o.OkStrokeColor
+	case "warning":
+		if acknowledged {
+			return o.WarningAcknowledgedStrokeColor
+		}
+		return o.WarningStrokeColor
+	case "unknown":
+		if acknowledged {
+			return o.UnknownAcknowledgedStrokeColor
+		}
+		return o.UnknownStrokeColor
+	case "critical", "down":
+		if acknowledged {
+			return o.CriticalAcknowledgedStrokeColor
+		}
+		return o.CriticalStrokeColor
+	}
+	return ""
+}
+
+// parseColor parses a CSS hex colour such as "#ff9000".
+// It returns fallback if s is empty or not such a colour.
+func parseColor(s string, fallback color.NRGBA) color.NRGBA {
+	hex, ok := strings.CutPrefix(strings.TrimSpace(s), "#")
+	if !ok {
+		return fallback
+	}
+	if len(hex) == 3 || len(hex) == 4 {
+		var long strings.Builder
+		for _, r := range hex {
+			long.WriteRune(r)
+			long.WriteRune(r)
+		}
+		hex = long.String()
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return fallback
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return fallback
+	}
+	return color.NRGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}
+}
+
+func fontSize(o Options, fallback float64) float64 {
+	return number(o.FontSize, fallback)
+}
+
+func number(n json.Number, fallback float64) float64 {
+	f, err := n.Float64()
+	if err != nil || f <= 0 {
+		return fallback
+	}
+	return f
+}
+
+// raster is a canvas drawing pixels.
+type raster struct {
+	dst *image.RGBA
+	// The element being drawn: its top left, size,
+	// and the sine and cosine of its rotation.
+	x, y, w, h float64
+	sin, cos   float64
+}
+
+func (r *raster) begin(x, y, w, h, rotation float64) {
+	r.x, r.y, r.w, r.h = x, y, w, h
+	r.sin, r.cos = math.Sincos(rotation)
+}
+
+func (r *raster) end() {}
+
+// toCanvas maps a point from element to canvas coordinates,
+// rotating it clockwise about the element's centre.
+func (r *raster) toCanvas(x, y float64) (float64, float64) {
+	dx, dy := x-r.w/2, y-r.h/2
+	return r.x + r.w/2 + dx*r.cos - dy*r.sin, r.y + r.h/2 + dx*r.sin + dy*r.cos
+}
+
+// toElement is the inverse of toCanvas.
+func (r *raster) toElement(x, y float64) (float64, float64) {
+	dx, dy := x-r.x-r.w/2, y-r.y-r.h/2
+	return r.w/2 + dx*r.cos + dy*r.sin, r.h/2 - dx*r.sin + dy*r.cos
+}
+
+// paint colours the pixels whose centres lie within the rectangle
+// from (x0, y0) to (x1, y1) in element coordinates, as given by shade.
+// Pixels for which shade returns false are left alone.
+func (r *raster) paint(x0, y0, x1, y1 float64, shade func(x, y float64) (color.NRGBA, bool)) {
+	x0, y0 = math.Max(x0, 0), math.Max(y0, 0)
+	x1, y1 = math.Min(x1, r.w), math.Min(y1, r.h)
+	if x0 >= x1 || y0 >= y1 {
+		return
+	}
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range [][2]float64{{x0, y0}, {x1, y0}, {x0, y1}, {x1, y1}} {
+		cx, cy := r.toCanvas(p[0], p[1])
+		minX, maxX = math.Min(minX, cx), math.Max(maxX, cx)
+		minY, maxY = math.Min(minY, cy), math.Max(maxY, cy)
+	}
+	area := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY))).Intersect(r.dst.Bounds())
+	for py := area.Min.Y; py < area.Max.Y; py++ {
+		for px := area.Min.X; px < area.Max.X; px++ {
+			x, y := r.toElement(float64(px)+0.5, float64(py)+0.5)
+			if x < x0 || x >= x1 || y < y0 || y >= y1 {
+				continue
+			}
+			if c, ok := shade(x, y); ok {
+				blend(r.dst, px, py, c)
+			}
+		}
+	}
+}
+
+// blend composites c over the pixel at (x, y) of dst.
+func blend(dst *image.RGBA, x, y int, c color.NRGBA) {
+	if c.A == 0 {
+		return
+	}
+	i := dst.PixOffset(x, y)
+	p := dst.Pix[i : i+4 : i+4]
+	a := uint32(c.A)
+	for j, v := range [3]uint8{c.R, c.G, c.B} {
+		p[j] = uint8((uint32(v)*a + uint32(p[j])*(0xff-a)) / 0xff)
+	}
+	p[3] = uint8(a + uint32(p[3])*(0xff-a)/0xff)
+}
+
+func (r *raster) fill(x, y, w, h float64, c color.NRGBA) {
+	r.paint(x, y, x+w, y+h, func(float64, float64) (color.NRGBA, bool) {
+		return c, true
+	})
+}
+
+func (r *raster) stroke(points [][2]float64, width float64, c color.NRGBA) {
+	half := width / 2
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		r.paint(math.Min(a[0], b[0])-half, math.Min(a[1], b[1])-half, math.Max(a[0], b[0])+half, math.Max(a[1], b[1])+half, func(x, y float64) (color.NRGBA, bool) {
+			return c, distanceToSegment(x, y, a, b) <= half
+		})
+	}
+}
+
+// distanceToSegment returns the distance from (x, y)
+// to the line segment from a to b.
+func distanceToSegment(x, y float64, a, b [2]float64) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	var t float64
+	if l := dx*dx + dy*dy; l > 0 {
+		t = math.Max(0, math.Min(1, ((x-a[0])*dx+(y-a[1])*dy)/l))
+	}
+	return math.Hypot(x-a[0]-t*dx, y-a[1]-t*dy)
+}
+
+func (r *raster) ring(cx, cy, radius, width float64, c color.NRGBA) {
+	outer := radius + width/2
+	r.paint(cx-outer, cy-outer, cx+outer, cy+outer, func(x, y float64) (color.NRGBA, bool) {
+		return c, math.Abs(math.Hypot(x-cx, y-cy)-radius) <= width/2
+	})
+}
+
+func (r *raster) image(img image.Image, x, y, w, h float64) {
+	b := img.Bounds()
+	r.paint(x, y, x+w, y+h, func(px, py float64) (color.NRGBA, bool) {
+		sx := b.Min.X + int((px-x)/w*float64(b.Dx()))
+		sy := b.Min.Y + int((py-y)/h*float64(b.Dy()))
+		return color.NRGBAModel.Convert(img.At(sx, sy)).(color.NRGBA), true
+	})
+}
+
+// text draws t in the bitmap font, scaled so each line
+// is about as tall as the font size.
+func (r *raster) text(t textBox) {
+	scale := math.Max(1, math.Round(t.size/8))
+	lines, top := t.lines(8 * scale)
+	for i, line := range lines {
+		runes := []rune(line)
+		width := (6*float64(len(runes)) - 1) * scale
+		left := t.x + (t.w-width)/2
+		switch {
+		case t.align == "start":
+			left = t.x
+		case strings.HasSuffix(t.align, "end"):
+			left = t.x + t.w - width
+		}
+		y := top + float64(i)*8*scale
+		for j, ch := range runes {
+			x := left + float64(j)*6*scale
+			r.glyph(glyph(ch), x, y, scale, t.color)
+			if t.bold {
+				r.glyph(glyph(ch), x+scale/2, y, scale, t.color)
+			}
+		}
+	}
+}
+
+func (r *raster) glyph(g [5]byte, x, y, scale float64, c color.NRGBA) {
+	for col, bits := range g {
+		for row := 0; row < 7; row++ {
+			if bits&(1<<row) != 0 {
+				px, py := x+float64(col)*scale, y+float64(row)*scale
+				r.fill(px, py, scale, scale, c)
+			}
+		}
+	}
+}
+
+// vector is a canvas writing SVG elements.
+type vector struct {
+	buf bytes.Buffer
+	// clips counts the clip paths defined, to name them.
+	clips int
+	err   error
+}
+
+func (v *vector) begin(x, y, w, h, rotation float64) {
+	v.clips++
+	fmt.Fprintf(&v.buf, `<g transform="translate(%s %s) rotate(%s %s %s)">`, num(x), num(y), num(rotation*180/math.Pi), num(w/2), num(h/2))
+	fmt.Fprintf(&v.buf, `<clipPath id="clip%d"><rect width="%s" height="%s"/></clipPath><g clip-path="url(#clip%d)">`+"\n", v.clips, num(w), num(h), v.clips)
+}
+
+func (v *vector) end() {
+	v.buf.WriteString("</g></g>\n")
+}
+
+func (v *vector) fill(x, y, w, h float64, c color.NRGBA) {
+	fmt.Fprintf(&v.buf, `<rect x="%s" y="%s" width="%s" height="%s" fill=%s/>`+"\n", num(x), num(y), num(w), num(h), paint(c))
+}
+
+func (v *vector) stroke(points [][2]float64, width float64, c color.NRGBA) {
+	var coords []string
+	for _, p := range points {
+		coords = append(coords, num(p[0])+" "+num(p[1]))
+	}
+	fmt.Fprintf(&v.buf, `<polyline points="%s" fill="none" stroke=%s stroke-width="%s" stroke-linecap="round" stroke-linejoin="round"/>`+"\n", strings.Join(coords, " "), paint(c), num(width))
+}
+
+func (v *vector) ring(cx, cy, r, width float64, c color.NRGBA) {
+	fmt.Fprintf(&v.buf, `<circle cx="%s" cy="%s" r="%s" fill="none" stroke=%s stroke-width="%s"/>`+"\n", num(cx), num(cy), num(r), paint(c), num(width))
+}
+
+func (v *vector) image(img image.Image, x, y, w, h float64) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		v.err = fmt.Errorf("encode image: %w", err)
+		return
+	}
+	fmt.Fprintf(&v.buf, `<image x="%s" y="%s" width="%s" height="%s" preserveAspectRatio="none" href="data:image/png;base64,%s"/>`+"\n", num(x), num(y), num(w), num(h), base64.StdEncoding.EncodeToString(buf.Bytes()))
+}
+
+func (v *vector) text(t textBox) {
+	lines, top := t.lines(t.size)
+	anchor, x := "middle", t.x+t.w/2
+	switch {
+	case t.align == "start":
+		anchor, x = "start", t.x
+	case strings.HasSuffix(t.align, "end"):
+		anchor, x = "end", t.x+t.w
+	}
+	weight := "normal"
+	if t.bold {
+		weight = "bold"
+	}
+	fmt.Fprintf(&v.buf, `<text font-family="sans-serif" font-size="%s" font-weight="%s" text-anchor="%s" fill=%s xml:space="preserve">`, num(t.size), weight, anchor, paint(t.color))
+	for i, line := range lines {
+		// Place the baseline of each line near the bottom of its 1em box.
+		y := top + float64(i)*t.size + 0.8*t.size
+		fmt.Fprintf(&v.buf, `<tspan x="%s" y="%s">%s</tspan>`, num(x), num(y), escapeXML(line))
+	}
+	v.buf.WriteString("</text>\n")
+}
+
+// paint returns the quoted SVG paint of c, with its opacity if any.
+func paint(c color.NRGBA) string {
+	s := fmt.Sprintf(`"#%02x%02x%02x"`, c.R, c.G, c.B)
+	if c.A != 0xff {
+		s += fmt.Sprintf(` opacity="%s"`, num(float64(c.A)/0xff))
+	}
+	return s
+}
+
+func num(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
+
+func escapeXML(s string) string {
+	var buf strings.Builder
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
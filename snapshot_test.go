@@ -0,0 +1,144 @@
+package meerkat
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSnapshot() *Snapshot {
+	return &Snapshot{
+		Dashboard: Dashboard{
+			Title:  "Network & servers",
+			Width:  "400",
+			Height: "200",
+			Elements: []Element{
+				{
+					ID:      "card",
+					Type:    "check-card",
+					Rect:    Rect{X: 0, Y: 0, W: 50, H: 50},
+					Options: Options{ObjectType: "service", ObjectName: "www!http"},
+				},
+				{
+					ID:      "acked",
+					Type:    "check-card",
+					Rect:    Rect{X: 50, Y: 0, W: 50, H: 50},
+					Options: Options{ObjectType: "host", ObjectName: "db"},
+				},
+				{
+					ID:       "line",
+					Type:     "check-line",
+					Rect:     Rect{X: 0, Y: 50, W: 50, H: 50},
+					Rotation: math.Pi / 2,
+					Options:  Options{ObjectType: "service", ObjectName: "www!ping", StrokeWidth: "10"},
+				},
+				{
+					ID:      "text",
+					Type:    "static-text",
+					Rect:    Rect{X: 50, Y: 50, W: 50, H: 50},
+					Options: Options{Text: "<core>", BackgroundColor: "#123", FontColor: "#fff"},
+				},
+			},
+		},
+		States: map[string]ObjectState{
+			"card":  {State: 2},
+			"acked": {State: 2, Acknowledged: true},
+		},
+		Time: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestSnapshotPNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testSnapshot().WritePNG(&buf); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 400 || b.Dy() != 200 {
+		t.Fatalf("got size %v, want 400x200", b.Size())
+	}
+	tests := []struct {
+		name string
+		p    image.Point
+		want color.NRGBA
+	}{
+		{"critical card", image.Pt(5, 5), color.NRGBA{0xff, 0x00, 0x19, 0xff}},
+		{"acknowledged down card", image.Pt(395, 5), color.NRGBA{0xde, 0x5e, 0x84, 0xff}},
+		// The line is horizontal until rotated a quarter turn,
+		// so runs down the centre of its element.
+		{"pending line", image.Pt(100, 110), color.NRGBA{0x77, 0xaa, 0xff, 0xff}},
+		{"beside line", image.Pt(20, 150), color.NRGBA{}},
+		{"text background", image.Pt(205, 105), color.NRGBA{0x11, 0x22, 0x33, 0xff}},
+	}
+	for _, tt := range tests {
+		got := color.NRGBAModel.Convert(img.At(tt.p.X, tt.p.Y)).(color.NRGBA)
+		if got != tt.want {
+			t.Errorf("%s: pixel at %v is %v, want %v", tt.name, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestSnapshotSVG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testSnapshot().WriteSVG(&buf); err != nil {
+		t.Fatal(err)
+	}
+	svg := buf.String()
+	for _, want := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg" width="400" height="200"`,
+		"<title>Network &amp; servers</title>",
+		`fill="#ff0019"`,
+		`>CRITICAL</tspan>`,
+		`fill="#de5e84"`,
+		`>DOWN (ACK)</tspan>`,
+		`rotate(90 100 50)`,
+		`stroke="#77aaff" stroke-width="10"`,
+		`>&lt;core&gt;</tspan>`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("svg does not contain %s", want)
+		}
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	fallback := color.NRGBA{1, 2, 3, 4}
+	tests := map[string]color.NRGBA{
+		"#ff9000":   {0xff, 0x90, 0x00, 0xff},
+		"#f90":      {0xff, 0x99, 0x00, 0xff},
+		"#ff900080": {0xff, 0x90, 0x00, 0x80},
+		"":          fallback,
+		"red":       fallback,
+		"#ggg":      fallback,
+	}
+	for s, want := range tests {
+		if got := parseColor(s, fallback); got != want {
+			t.Errorf("parseColor(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestSnapshotSize(t *testing.T) {
+	tests := []struct {
+		snap                  Snapshot
+		wantWidth, wantHeight int
+	}{
+		{Snapshot{}, defaultSnapshotWidth, defaultSnapshotHeight},
+		{Snapshot{Dashboard: Dashboard{Width: "800px", Height: "600"}}, 800, 600},
+		{Snapshot{Dashboard: Dashboard{Width: "100000", Height: "-1"}}, MaxSnapshotSize, defaultSnapshotHeight},
+		{Snapshot{Background: image.NewGray(image.Rect(0, 0, MaxSnapshotSize+1, 10))}, MaxSnapshotSize, 10},
+	}
+	for i, tt := range tests {
+		if w, h := tt.snap.Size(); w != tt.wantWidth || h != tt.wantHeight {
+			t.Errorf("%d: got size %dx%d, want %dx%d", i, w, h, tt.wantWidth, tt.wantHeight)
+		}
+	}
+}
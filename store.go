@@ -0,0 +1,182 @@
+package meerkat
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A Store persists dashboards and their history.
+//
+// Items are addressed by slash-separated keys as accepted by fs.ValidPath,
+// such as "dashboards/example.json". The default Store, DirStore,
+// maps keys directly onto files; other implementations are available
+// in the subdirectories of the store directory.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Get returns the data stored under key.
+	// If there is none, the returned error wraps fs.ErrNotExist.
+	Get(key string) ([]byte, error)
+	// Put stores data under key, replacing any existing data.
+	// Readers never see partially stored data.
+	Put(key string, data []byte) error
+	// Delete removes the data stored under key.
+	// If there is none, the returned error wraps fs.ErrNotExist.
+	Delete(key string) error
+	// List returns all keys starting with prefix in lexical order.
+	List(prefix string) ([]string, error)
+}
+
+// DirStore is a Store keeping each item in a file under the
+// directory named by the string's value.
+type DirStore string
+
+func (d DirStore) name(op, key string) (string, error) {
+	if !fs.ValidPath(key) || key == "." {
+		return "", &fs.PathError{Op: op, Path: key, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(string(d), filepath.FromSlash(key)), nil
+}
+
+func (d DirStore) Get(key string) ([]byte, error) {
+	name, err := d.name("get", key)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(name)
+}
+
+func (d DirStore) Put(key string, data []byte) error {
+	name, err := d.name("put", key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(name, data, 0644)
+}
+
+func (d DirStore) Delete(key string) error {
+	name, err := d.name("delete", key)
+	if err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
+
+// List walks the directory containing prefix.
+// Hidden files, such as those left behind by interrupted writes, are skipped.
+func (d DirStore) List(prefix string) ([]string, error) {
+	root := string(d)
+	// Prefixes without a slash, including the empty one, match keys
+	// at the top of the store.
+	if dir := path.Dir(prefix + "x"); dir != "." {
+		var err error
+		root, err = d.name("list", dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var keys []string
+	err := filepath.WalkDir(root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && name == root {
+				return nil
+			}
+			return err
+		}
+		if strings.HasPrefix(entry.Name(), ".") && name != root {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(string(d), name)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	sort.Strings(keys)
+	return keys, err
+}
+
+const dashboardPrefix = "dashboards/"
+
+// DashboardKey returns the key of the dashboard with the given slug.
+func DashboardKey(slug string) string {
+	return dashboardPrefix + slug + ".json"
+}
+
+// LoadDashboard returns the dashboard with the given slug from s.
+// If there is no such dashboard, the returned error wraps fs.ErrNotExist.
+func LoadDashboard(s Store, slug string) (Dashboard, error) {
+	if slug == "" || strings.ContainsAny(slug, `/\`) {
+		return Dashboard{}, fmt.Errorf("load dashboard %q: %w", slug, fs.ErrNotExist)
+	}
+	b, err := s.Get(DashboardKey(slug))
+	if err != nil {
+		return Dashboard{}, fmt.Errorf("load dashboard %s: %w", slug, err)
+	}
+	return decodeDashboard(b)
+}
+
+// LoadDashboards returns all dashboards in s.
+func LoadDashboards(s Store) ([]Dashboard, error) {
+	keys, err := s.List(dashboardPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("list dashboards: %w", err)
+	}
+	var dashboards []Dashboard
+	for _, key := range keys {
+		slug, ok := strings.CutSuffix(strings.TrimPrefix(key, dashboardPrefix), ".json")
+		if !ok || strings.Contains(slug, "/") {
+			continue
+		}
+		d, err := LoadDashboard(s, slug)
+		if err != nil {
+			return dashboards, err
+		}
+		dashboards = append(dashboards, d)
+	}
+	return dashboards, nil
+}
+
+// SaveDashboard stores dashboard in s under the given slug,
+// returning the encoded dashboard as stored.
+func SaveDashboard(s Store, slug string, dashboard *Dashboard) ([]byte, error) {
+	if slug == "" || strings.ContainsAny(slug, `/\`) {
+		return nil, fmt.Errorf("save dashboard: invalid slug %q", slug)
+	}
+	dashboard.Slug = TitleToSlug(dashboard.Title)
+	b, err := encodeDashboard(dashboard)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Put(DashboardKey(slug), b); err != nil {
+		return nil, fmt.Errorf("save dashboard %s: %w", slug, err)
+	}
+	return b, nil
+}
+
+// DeleteDashboard removes the dashboard with the given slug from s.
+// Its history is kept.
+func DeleteDashboard(s Store, slug string) error {
+	if slug == "" || strings.ContainsAny(slug, `/\`) {
+		return fmt.Errorf("delete dashboard %q: %w", slug, fs.ErrNotExist)
+	}
+	return s.Delete(DashboardKey(slug))
+}
@@ -0,0 +1,285 @@
+// Package s3 implements a meerkat.Store in a bucket of an
+// S3-compatible object store, such as Amazon S3, MinIO or Ceph.
+//
+// Requests are made with path-style addressing
+// (https://endpoint/bucket/key) and signed with AWS Signature Version 4.
+// A stand-in server for testing is available in the s3test package.
+package s3
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Store is a meerkat.Store keeping each item as an object in a bucket.
+type Store struct {
+	// Endpoint is the base URL of the object store,
+	// for example https://s3.eu-west-1.amazonaws.com or http://minio:9000.
+	Endpoint string
+	// Region of the bucket. The default is us-east-1,
+	// which most S3-compatible stores accept.
+	Region string
+	Bucket string
+	// Prefix is prepended to every key, so that several
+	// instances may share a bucket. For example "meerkat/".
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// Client is used to make requests.
+	// If nil, a client with a 30 second timeout is used.
+	Client *http.Client
+}
+
+var defaultClient = &http.Client{Timeout: 30 * time.Second}
+
+func (s *Store) client() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+	return defaultClient
+}
+
+func (s *Store) region() string {
+	if s.Region == "" {
+		return "us-east-1"
+	}
+	return s.Region
+}
+
+func (s *Store) objectURL(key string) string {
+	return strings.TrimSuffix(s.Endpoint, "/") + "/" + s.Bucket + "/" + s.Prefix + key
+}
+
+// do signs and sends a request, returning the response if its status is 2xx.
+// Errors for responses with status 404 wrap fs.ErrNotExist.
+func (s *Store) do(method, u string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body == nil {
+		req.Body = nil
+	}
+	// Send the path exactly as it is signed.
+	req.URL.RawPath = canonicalURI(req.URL)
+	sum := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(sum[:]))
+	sign(req, s.AccessKeyID, s.SecretAccessKey, s.region(), "s3", time.Now())
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	var e struct {
+		Code    string
+		Message string
+	}
+	xml.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&e)
+	err = fmt.Errorf("%s: %s %s", resp.Status, e.Code, e.Message)
+	if resp.StatusCode == http.StatusNotFound {
+		err = fmt.Errorf("%w: %v", fs.ErrNotExist, err)
+	}
+	return nil, err
+}
+
+func (s *Store) Get(key string) ([]byte, error) {
+	resp, err := s.do(http.MethodGet, s.objectURL(key), nil)
+	if err != nil {
+		return nil, fmt.Errorf("get %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func (s *Store) Put(key string, data []byte) error {
+	if data == nil {
+		data = []byte{}
+	}
+	resp, err := s.do(http.MethodPut, s.objectURL(key), data)
+	if err != nil {
+		return fmt.Errorf("put %s: %w", key, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Delete removes the object stored under key.
+// S3 reports success when deleting objects which do not exist,
+// so the object is checked for first.
+func (s *Store) Delete(key string) error {
+	resp, err := s.do(http.MethodHead, s.objectURL(key), nil)
+	if err != nil {
+		return fmt.Errorf("delete %s: %w", key, err)
+	}
+	resp.Body.Close()
+	resp, err = s.do(http.MethodDelete, s.objectURL(key), nil)
+	if err != nil {
+		return fmt.Errorf("delete %s: %w", key, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+type listResult struct {
+	IsTruncated           bool
+	NextContinuationToken string
+	Contents              []struct {
+		Key string
+	}
+}
+
+func (s *Store) List(prefix string) ([]string, error) {
+	var keys []string
+	var token string
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {s.Prefix + prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		u := strings.TrimSuffix(s.Endpoint, "/") + "/" + s.Bucket + "?" + q.Encode()
+		resp, err := s.do(http.MethodGet, u, nil)
+		if err != nil {
+			return nil, fmt.Errorf("list %s: %w", prefix, err)
+		}
+		var result listResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("list %s: decode response: %w", prefix, err)
+		}
+		for _, c := range result.Contents {
+			keys = append(keys, strings.TrimPrefix(c.Key, s.Prefix))
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to req.
+// The host header and any X-Amz-* headers are signed. The payload hash
+// is taken from the X-Amz-Content-Sha256 header if present, otherwise
+// the hash of an empty payload is used.
+func sign(req *http.Request, accessKey, secretKey, region, service string, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format("20060102T150405Z")
+	day := t.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		k = strings.ToLower(k)
+		if strings.HasPrefix(k, "x-amz-") {
+			headers[k] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	payloadHash := req.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		sum := sha256.Sum256(nil)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + region + "/" + service + "/aws4_request"
+	crHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(crHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func canonicalURI(u *url.URL) string {
+	p := u.EscapedPath()
+	if p == "" {
+		return "/"
+	}
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		unescaped, err := url.PathUnescape(seg)
+		if err != nil {
+			unescaped = seg
+		}
+		segments[i] = uriEncode(unescaped)
+	}
+	return strings.Join(segments, "/")
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		values := append([]string(nil), q[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes every byte of s except the
+// unreserved characters, as required for signing.
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
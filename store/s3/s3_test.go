@@ -0,0 +1,56 @@
+package s3
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/meerkat-dashboard/meerkat/store/s3/s3test"
+	"github.com/meerkat-dashboard/meerkat/store/storetest"
+)
+
+func TestStore(t *testing.T) {
+	srv := s3test.NewServer("dashboards", "AKIDEXAMPLE")
+	defer srv.Close()
+	srv.PageSize = 2 // exercise pagination
+
+	s := &Store{
+		Endpoint:        srv.URL,
+		Bucket:          "dashboards",
+		Prefix:          "meerkat/",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "secret",
+	}
+	storetest.Test(t, s)
+
+	s.AccessKeyID = "wrong"
+	if _, err := s.Get("dashboards/a.json"); err == nil {
+		t.Error("no error getting object with wrong credentials")
+	}
+}
+
+// TestSign checks signing against the get-vanilla example
+// from the AWS Signature Version 4 test suite.
+func TestSign(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	sign(req, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "service", date)
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("got authorization header\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestURIEncode(t *testing.T) {
+	got := uriEncode("a b/c~d")
+	if want := "a%20b%2Fc~d"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if strings.Contains(canonicalQuery(map[string][]string{"prefix": {"a/"}}), "/") {
+		t.Error("slash not encoded in canonical query")
+	}
+}
@@ -0,0 +1,166 @@
+// Package s3test provides an in-memory stand-in for an S3-compatible
+// object store, for testing package s3 without a real service.
+package s3test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Server is a minimal S3-compatible object store supporting
+// GetObject, PutObject, HeadObject, DeleteObject and ListObjectsV2
+// with path-style addressing.
+//
+// Requests must carry a Signature Version 4 Authorization header
+// for AccessKeyID and a payload hash matching the request body.
+// Signatures themselves are not verified.
+type Server struct {
+	*httptest.Server
+	Bucket      string
+	AccessKeyID string
+	// PageSize is the maximum number of keys returned
+	// in one ListObjectsV2 response.
+	PageSize int
+
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+// NewServer starts and returns a new Server with an empty bucket.
+// The caller should call Close when finished.
+func NewServer(bucket, accessKeyID string) *Server {
+	s := &Server{
+		Bucket:      bucket,
+		AccessKeyID: accessKeyID,
+		PageSize:    1000,
+		objects:     make(map[string][]byte),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Len returns the number of objects in the bucket.
+func (s *Server) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.objects)
+}
+
+type errorResponse struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string
+	Message string
+}
+
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(errorResponse{Code: code, Message: msg})
+}
+
+func (s *Server) serve(w http.ResponseWriter, req *http.Request) {
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential="+s.AccessKeyID+"/") || !strings.Contains(auth, "Signature=") {
+		writeError(w, http.StatusForbidden, "InvalidAccessKeyId", "missing or unknown credentials")
+		return
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	sum := sha256.Sum256(body)
+	if req.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		writeError(w, http.StatusBadRequest, "XAmzContentSHA256Mismatch", "payload hash does not match body")
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/"), "/")
+	if bucket != s.Bucket {
+		writeError(w, http.StatusNotFound, "NoSuchBucket", "no such bucket "+bucket)
+		return
+	}
+	if key == "" {
+		if req.Method != http.MethodGet || req.URL.Query().Get("list-type") != "2" {
+			writeError(w, http.StatusNotImplemented, "NotImplemented", "unsupported bucket operation")
+			return
+		}
+		s.list(w, req)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		data, ok := s.objects[key]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey", "no such key "+key)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if req.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case http.MethodPut:
+		s.objects[key] = body
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", req.Method)
+	}
+}
+
+type listResult struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	Name                  string
+	Prefix                string
+	KeyCount              int
+	IsTruncated           bool
+	NextContinuationToken string `xml:",omitempty"`
+	Contents              []object
+}
+
+type object struct {
+	Key  string
+	Size int
+}
+
+func (s *Server) list(w http.ResponseWriter, req *http.Request) {
+	prefix := req.URL.Query().Get("prefix")
+	after := req.URL.Query().Get("continuation-token")
+
+	s.mu.Lock()
+	var keys []string
+	for k := range s.objects {
+		if strings.HasPrefix(k, prefix) && k > after {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	result := listResult{Name: s.Bucket, Prefix: prefix}
+	for _, k := range keys {
+		if len(result.Contents) == s.PageSize {
+			result.IsTruncated = true
+			// Real services use opaque tokens; the last key returned will do.
+			result.NextContinuationToken = result.Contents[len(result.Contents)-1].Key
+			break
+		}
+		result.Contents = append(result.Contents, object{Key: k, Size: len(s.objects[k])})
+	}
+	s.mu.Unlock()
+	result.KeyCount = len(result.Contents)
+
+	w.Header().Set("Content-Type", "application/xml")
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(result)
+}
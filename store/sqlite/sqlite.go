@@ -0,0 +1,108 @@
+// Package sqlite implements a meerkat.Store in an SQLite database.
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS items (
+	key TEXT PRIMARY KEY,
+	data BLOB NOT NULL,
+	modified INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+)`
+
+// Store is a meerkat.Store keeping items in an SQLite database.
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens the SQLite database in the named file,
+// creating it if it does not exist.
+func Open(name string) (*Store, error) {
+	db, err := sql.Open("sqlite", name)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows one writer at a time; share a single connection
+	// rather than fail with "database is locked".
+	db.SetMaxOpenConns(1)
+	for _, stmt := range []string{"PRAGMA journal_mode = WAL", "PRAGMA busy_timeout = 5000", schema} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("initialise database %s: %w", name, err)
+		}
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) Get(key string) ([]byte, error) {
+	var data []byte
+	err := s.db.QueryRow("SELECT data FROM items WHERE key = ?", key).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, &fs.PathError{Op: "get", Path: key, Err: fs.ErrNotExist}
+	} else if err != nil {
+		return nil, fmt.Errorf("get %s: %w", key, err)
+	}
+	return data, nil
+}
+
+func (s *Store) Put(key string, data []byte) error {
+	const q = `INSERT INTO items (key, data) VALUES (?, ?)
+		ON CONFLICT (key) DO UPDATE SET data = excluded.data, modified = strftime('%s', 'now')`
+	if data == nil {
+		data = []byte{}
+	}
+	if _, err := s.db.Exec(q, key, data); err != nil {
+		return fmt.Errorf("put %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *Store) Delete(key string) error {
+	res, err := s.db.Exec("DELETE FROM items WHERE key = ?", key)
+	if err != nil {
+		return fmt.Errorf("delete %s: %w", key, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return &fs.PathError{Op: "delete", Path: key, Err: fs.ErrNotExist}
+	}
+	return nil
+}
+
+func (s *Store) List(prefix string) ([]string, error) {
+	const q = `SELECT key FROM items WHERE key LIKE ? ESCAPE '\' ORDER BY key`
+	rows, err := s.db.Query(q, escapeLike(prefix)+"%")
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", prefix, err)
+	}
+	defer rows.Close()
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("list %s: %w", prefix, err)
+		}
+		// LIKE is case-insensitive for ASCII; keys are not.
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, rows.Err()
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
@@ -0,0 +1,29 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/meerkat-dashboard/meerkat/store/storetest"
+)
+
+func TestStore(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "meerkat.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	storetest.Test(t, s)
+
+	// LIKE wildcards in prefixes must match literally.
+	if err := s.Put("dashboards/a_b.json", nil); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := s.List("dashboards/a_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0] != "dashboards/a_b.json" {
+		t.Errorf("list with underscore in prefix: got %v", keys)
+	}
+}
@@ -0,0 +1,109 @@
+// Package storetest checks implementations of meerkat.Store.
+package storetest
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+
+	"github.com/meerkat-dashboard/meerkat"
+)
+
+// Test runs a series of tests against s, which must be empty.
+func Test(t *testing.T, s meerkat.Store) {
+	t.Helper()
+	if _, err := s.Get("dashboards/missing.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("get missing item: got error %v, want %v", err, fs.ErrNotExist)
+	}
+	if err := s.Delete("dashboards/missing.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("delete missing item: got error %v, want %v", err, fs.ErrNotExist)
+	}
+	keys, err := s.List("dashboards/")
+	if err != nil {
+		t.Fatalf("list empty store: %v", err)
+	}
+	if len(keys) > 0 {
+		t.Errorf("list empty store: got keys %v", keys)
+	}
+
+	items := map[string]string{
+		"dashboards/b.json":            `{"title": "B"}`,
+		"dashboards/a.json":            `{"title": "A"}`,
+		"dashboards-history/a/1.json":  `{"id": 1}`,
+		"dashboards-history/a/2.json":  `{"id": 2}`,
+		"dashboards-history/ab/1.json": `{"id": 1}`,
+	}
+	for k, v := range items {
+		if err := s.Put(k, []byte(v)); err != nil {
+			t.Fatalf("put %s: %v", k, err)
+		}
+	}
+	for k, v := range items {
+		got, err := s.Get(k)
+		if err != nil {
+			t.Errorf("get %s: %v", k, err)
+		} else if string(got) != v {
+			t.Errorf("get %s: got %q, want %q", k, got, v)
+		}
+	}
+
+	if err := s.Put("dashboards/a.json", []byte(`{"title": "A2"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Get("dashboards/a.json"); string(got) != `{"title": "A2"}` {
+		t.Errorf("get replaced item: got %q", got)
+	}
+
+	tests := map[string][]string{
+		"dashboards/":           {"dashboards/a.json", "dashboards/b.json"},
+		"dashboards-history/a/": {"dashboards-history/a/1.json", "dashboards-history/a/2.json"},
+		"nothing/":              nil,
+		"dashboards-history/a": {
+			"dashboards-history/a/1.json", "dashboards-history/a/2.json", "dashboards-history/ab/1.json",
+		},
+		"": {
+			"dashboards-history/a/1.json", "dashboards-history/a/2.json", "dashboards-history/ab/1.json",
+			"dashboards/a.json", "dashboards/b.json",
+		},
+	}
+	for prefix, want := range tests {
+		got, err := s.List(prefix)
+		if err != nil {
+			t.Errorf("list %s: %v", prefix, err)
+			continue
+		}
+		if len(got) == 0 && len(want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("list %s: got %v, want %v", prefix, got, want)
+		}
+	}
+
+	if err := s.Delete("dashboards/b.json"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("dashboards/b.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("get deleted item: got error %v, want %v", err, fs.ErrNotExist)
+	}
+
+	d := &meerkat.Dashboard{Title: "Stored"}
+	if _, err := meerkat.SaveDashboard(s, "stored", d); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := meerkat.LoadDashboard(s, "stored")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Title != "Stored" || loaded.Slug != "stored" {
+		t.Errorf("loaded dashboard %q with slug %q, want %q with slug %q", loaded.Title, loaded.Slug, "Stored", "stored")
+	}
+	all, err := meerkat.LoadDashboards(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Errorf("got %d dashboards, want 2", len(all))
+	}
+}
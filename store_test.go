@@ -0,0 +1,40 @@
+package meerkat_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/meerkat-dashboard/meerkat"
+	"github.com/meerkat-dashboard/meerkat/store/storetest"
+)
+
+func TestDirStore(t *testing.T) {
+	dir := t.TempDir()
+	s := meerkat.DirStore(dir)
+	storetest.Test(t, s)
+
+	// Existing installations keep their layout on disk.
+	if _, err := os.Stat(filepath.Join(dir, "dashboards", "a.json")); err != nil {
+		t.Error(err)
+	}
+	// Keys at the top of the store are listed too, unlike hidden files.
+	if err := s.Put("top.json", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".top.json.tmp"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := s.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := keys[len(keys)-1]; last != "top.json" {
+		t.Errorf("list all: got keys %v, want top.json last", keys)
+	}
+	if _, err := s.Get("../outside.json"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("get key outside directory: got error %v, want %v", err, fs.ErrInvalid)
+	}
+}
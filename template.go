@@ -0,0 +1,144 @@
+package meerkat
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// variablePattern matches the names of template variables.
+const variablePattern = "^[A-Za-z_][A-Za-z0-9_]*$"
+
+// variableValuePattern matches the values of template variables:
+// a single line of at most 256 characters.
+const variableValuePattern = "^[^\\x00-\\x1f\\x7f]{0,256}$"
+
+var (
+	variableName = regexp.MustCompile(variablePattern)
+	// placeholder matches a reference to a variable, such as ${hostgroup}.
+	placeholder = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+)
+
+// templateFields returns the options of an element which may
+// contain placeholders.
+func templateFields(o *Options) []*string {
+	return []*string{&o.ObjectName, &o.Text, &o.LinkUrl, &o.Image}
+}
+
+// Placeholders returns the names of the variables referred to by
+// placeholders in the options of d's elements, in order of first use.
+func (d *Dashboard) Placeholders() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for i := range d.Elements {
+		for _, field := range templateFields(&d.Elements[i].Options) {
+			for _, m := range placeholder.FindAllStringSubmatch(*field, -1) {
+				if !seen[m[1]] {
+					seen[m[1]] = true
+					names = append(names, m[1])
+				}
+			}
+		}
+	}
+	return names
+}
+
+// Expand returns a copy of d with each placeholder in the options of
+// its elements replaced by the value of its variable. Values in vars
+// take precedence over those in d.Variables. Placeholders of variables
+// with no value are left as they are.
+func (d Dashboard) Expand(vars map[string]string) Dashboard {
+	values := make(map[string]string)
+	for k, v := range d.Variables {
+		values[k] = v
+	}
+	for k, v := range vars {
+		values[k] = v
+	}
+	d.Elements = append([]Element(nil), d.Elements...)
+	for i := range d.Elements {
+		for _, field := range templateFields(&d.Elements[i].Options) {
+			*field = placeholder.ReplaceAllStringFunc(*field, func(s string) string {
+				if v, ok := values[s[2:len(s)-1]]; ok {
+					return v
+				}
+				return s
+			})
+		}
+	}
+	return d
+}
+
+// ValidateVariables checks the values of variables in vars as Validate
+// checks those stored in a dashboard, returning a ValidationError
+// listing every invalid one.
+func ValidateVariables(vars map[string]string) error {
+	root := DashboardSchema()
+	var found []schemaError
+	root.Properties["variables"].validate(root, jsonValue(reflect.ValueOf(vars)), []string{"variables"}, &found)
+	var errs ValidationError
+	for _, e := range found {
+		errs = append(errs, FieldError{Element: -1, Field: strings.Join(e.path, "."), Reason: e.reason})
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// ResolveDashboard returns the dashboard d is shown as.
+// A dashboard whose Template names another dashboard is an instance of
+// that template, and is shown with the template's elements, background,
+// sounds and severity order in place of its own. Its Variables are those
+// of the template, overridden by those of the instance.
+// Placeholders are not expanded; see Expand.
+func ResolveDashboard(s Store, d Dashboard) (Dashboard, error) {
+	if d.Template == "" {
+		return d, nil
+	}
+	t, err := LoadDashboard(s, d.Template)
+	if err != nil {
+		return Dashboard{}, fmt.Errorf("load template of %s: %w", d.Slug, err)
+	}
+	if t.Template != "" {
+		return Dashboard{}, fmt.Errorf("template %s of %s is itself an instance of %s", t.Slug, d.Slug, t.Template)
+	}
+	vars := make(map[string]string)
+	for k, v := range t.Variables {
+		vars[k] = v
+	}
+	for k, v := range d.Variables {
+		vars[k] = v
+	}
+	t.Title = d.Title
+	t.Slug = d.Slug
+	t.Folder = d.Folder
+	t.Description = d.Description
+	t.Template = d.Template
+	t.Variables = vars
+	// Instances of one template may show objects from different environments.
+	if d.Backend != "" {
+		t.Backend = d.Backend
+	}
+	return t, nil
+}
+
+// ParseVariables parses the values of variables from text with one
+// name=value pair per line, as entered in forms. Blank lines are ignored.
+func ParseVariables(text string) (map[string]string, error) {
+	vars := make(map[string]string)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !variableName.MatchString(name) {
+			return nil, fmt.Errorf("invalid variable %q: want name=value", line)
+		}
+		vars[name] = strings.TrimSpace(value)
+	}
+	return vars, nil
+}
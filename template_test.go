@@ -0,0 +1,107 @@
+package meerkat
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	d := Dashboard{
+		Variables: map[string]string{"site": "syd", "group": "syd-web"},
+		Elements: []Element{
+			{Type: "check-card", Options: Options{ObjectName: "${group}", ObjectType: "hostgroup", LinkUrl: "https://icinga.example.com/hostgroups/${group}"}},
+			{Type: "static-text", Options: Options{Text: "${site} costs $5, ${missing} ${group}", FontColor: "${site}"}},
+		},
+	}
+	if got, want := d.Placeholders(), []string{"group", "site", "missing"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got placeholders %q, want %q", got, want)
+	}
+	e := d.Expand(map[string]string{"group": "mel-web"})
+	if got := e.Elements[0].Options.ObjectName; got != "mel-web" {
+		t.Errorf("got object name %q, want mel-web", got)
+	}
+	if got := e.Elements[0].Options.LinkUrl; got != "https://icinga.example.com/hostgroups/mel-web" {
+		t.Errorf("got link %q", got)
+	}
+	if got, want := e.Elements[1].Options.Text, "syd costs $5, ${missing} mel-web"; got != want {
+		t.Errorf("got text %q, want %q", got, want)
+	}
+	if got := e.Elements[1].Options.FontColor; got != "${site}" {
+		t.Errorf("expanded option %q which may not contain placeholders", got)
+	}
+	if d.Elements[0].Options.ObjectName != "${group}" {
+		t.Errorf("Expand modified the original dashboard")
+	}
+}
+
+func TestResolveDashboard(t *testing.T) {
+	s := DirStore(t.TempDir())
+	template := Dashboard{
+		Title:      "Site",
+		Background: "site.png",
+		Backend:    "prod",
+		Variables:  map[string]string{"group": "all", "site": "none"},
+		Elements:   []Element{{ID: "a", Type: "check-card", Options: Options{ObjectName: "${group}", ObjectType: "hostgroup"}}},
+	}
+	if _, err := SaveDashboard(s, "site", &template); err != nil {
+		t.Fatal(err)
+	}
+	instance := Dashboard{
+		Title:     "Sydney",
+		Slug:      "sydney",
+		Folder:    "sites",
+		Template:  "site",
+		Variables: map[string]string{"group": "syd"},
+		Backend:   "apac",
+	}
+	d, err := ResolveDashboard(s, instance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Title != "Sydney" || d.Slug != "sydney" || d.Folder != "sites" || d.Template != "site" || d.Backend != "apac" {
+		t.Errorf("instance lost its own fields: %+v", d)
+	}
+	if d.Background != "site.png" || len(d.Elements) != 1 {
+		t.Errorf("instance does not have the template's background and elements: %+v", d)
+	}
+	if want := map[string]string{"group": "syd", "site": "none"}; !reflect.DeepEqual(d.Variables, want) {
+		t.Errorf("got variables %v, want %v", d.Variables, want)
+	}
+	if name := d.Expand(nil).Elements[0].Options.ObjectName; name != "syd" {
+		t.Errorf("got object name %q, want syd", name)
+	}
+
+	chained := Dashboard{Title: "Chained", Template: "sydney"}
+	if _, err := SaveDashboard(s, "sydney", &instance); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ResolveDashboard(s, chained); err == nil {
+		t.Error("resolved an instance of an instance")
+	}
+}
+
+func TestValidateInstance(t *testing.T) {
+	d := &Dashboard{
+		Title:     "Site",
+		Template:  "site",
+		Variables: map[string]string{"site": "syd", "bad name": "x"},
+		Elements:  []Element{{ID: "a", Type: "clock"}},
+	}
+	var verr ValidationError
+	if !errors.As(d.Validate(), &verr) {
+		t.Fatalf("got no ValidationError for invalid instance")
+	}
+	var got []string
+	for _, f := range verr {
+		got = append(got, f.Error())
+	}
+	want := []string{
+		`variables.bad name: "bad name" does not match pattern ^[A-Za-z_][A-Za-z0-9_]*$`,
+		"template: a dashboard cannot be an instance of itself",
+		"elements: instances of a template are shown with its elements, so cannot have their own",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got errors\n%q\nwant\n%q", got, want)
+	}
+}
@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"html/template"
@@ -8,10 +9,12 @@ import (
 	"io/fs"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,9 +22,8 @@ import (
 )
 
 func (srv *Server) ViewHandler(w http.ResponseWriter, req *http.Request) {
-	slug := path.Dir(req.URL.Path)
-	fname := path.Join("dashboards", slug+".json")
-	dashboard, err := meerkat.ReadDashboard(fname)
+	slug := dashboardSlug(req.URL.Path)
+	dashboard, err := meerkat.LoadDashboard(srv.Store, slug)
 	if errors.Is(err, fs.ErrNotExist) {
 		http.NotFound(w, req)
 		return
@@ -31,6 +33,10 @@ func (srv *Server) ViewHandler(w http.ResponseWriter, req *http.Request) {
 		http.Error(w, msg, http.StatusInternalServerError)
 		return
 	}
+	if !srv.canView(req, dashboard.Folder) && !sharedWith(req, slug) {
+		denied(w, req)
+		return
+	}
 	tmpl, err := template.ParseFS(srv.fsys, "template/layout.tmpl", "template/view.tmpl")
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -39,7 +45,52 @@ func (srv *Server) ViewHandler(w http.ResponseWriter, req *http.Request) {
 	tmpl.Execute(w, dashboard)
 }
 
+// PlaylistViewHandler serves a page which shows each dashboard of a
+// playlist in turn, following the playlist's position as it advances.
+func (srv *Server) PlaylistViewHandler(w http.ResponseWriter, req *http.Request) {
+	name := dashboardSlug(req.URL.Path)
+	playlist, err := meerkat.LoadPlaylist(srv.Store, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, req)
+		return
+	} else if err != nil {
+		msg := fmt.Sprintf("read playlist %s: %v", name, err)
+		log.Print(msg)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	if !srv.canView(req, playlist.Folder) && !kioskPlaying(req, name) {
+		denied(w, req)
+		return
+	}
+	tmpl, err := template.ParseFS(srv.fsys, "template/layout.tmpl", "template/playlist.tmpl")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tmpl.Execute(w, playlist)
+}
+
 func (srv *Server) EditHandler(w http.ResponseWriter, req *http.Request) {
+	slug := dashboardSlug(req.URL.Path)
+	dashboard, err := meerkat.LoadDashboard(srv.Store, slug)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, req)
+		return
+	} else if err != nil {
+		http.Error(w, "read dashboard: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !srv.canEdit(req, dashboard.Folder) {
+		denied(w, req)
+		return
+	}
+	// Instances are shown with the elements of their template,
+	// so those are edited instead.
+	if dashboard.Template != "" {
+		http.Redirect(w, req, path.Join("/", dashboard.Template, "edit"), http.StatusFound)
+		return
+	}
 	tmpl, err := template.ParseFS(srv.fsys, "template/layout.tmpl", "template/edit.tmpl")
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -55,13 +106,19 @@ func (srv *Server) RootHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	dashboards, err := meerkat.ReadDashboardDir("dashboards")
+	dashboards, err := meerkat.LoadDashboards(srv.Store)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	visible := dashboards[:0]
+	for _, d := range dashboards {
+		if srv.canView(req, d.Folder) {
+			visible = append(visible, d)
+		}
+	}
 
-	groupedDashboards := groupDashboardsByFolder(dashboards)
+	groupedDashboards := groupDashboardsByFolder(visible)
 
 	// Get and sort the folder names
 	folderNames := make([]string, 0, len(groupedDashboards))
@@ -111,17 +168,19 @@ func (srv *Server) CreatePage(w http.ResponseWriter, req *http.Request) {
 }
 
 func (srv *Server) DeletePage(w http.ResponseWriter, req *http.Request) {
-	slug, _ := path.Split(req.URL.Path)
-	slug = path.Clean(slug)
-	fname := path.Join("dashboards", slug+".json")
-	dashboard, err := meerkat.ReadDashboard(fname)
+	slug := dashboardSlug(req.URL.Path)
+	dashboard, err := meerkat.LoadDashboard(srv.Store, slug)
 	if errors.Is(err, fs.ErrNotExist) {
-		http.Error(w, "no such dashboard "+fname, http.StatusNotFound)
+		http.Error(w, "no such dashboard "+slug, http.StatusNotFound)
 		return
 	} else if err != nil {
 		http.Error(w, "read dashboard: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if !srv.canEdit(req, dashboard.Folder) {
+		denied(w, req)
+		return
+	}
 
 	tmpl, err := template.ParseFS(srv.fsys, "template/layout.tmpl", "template/delete.tmpl", "template/nav.tmpl")
 	if err != nil {
@@ -146,13 +205,22 @@ func (srv *Server) CachePage(w http.ResponseWriter, req *http.Request) {
 }
 
 func (srv *Server) ClonePage(w http.ResponseWriter, req *http.Request) {
-	dashboards, err := meerkat.ReadDashboardDir("dashboards")
+	dashboards, err := meerkat.LoadDashboards(srv.Store)
 	if err != nil {
 		msg := fmt.Sprintf("read dashboard dir: %v", err)
 		log.Println(msg)
 		http.Error(w, msg, http.StatusInternalServerError)
 		return
 	}
+	// Clones keep the folder of their source,
+	// so only offer dashboards the user may edit.
+	editable := dashboards[:0]
+	for _, d := range dashboards {
+		if srv.canEdit(req, d.Folder) {
+			editable = append(editable, d)
+		}
+	}
+	dashboards = editable
 	tmpl, err := template.ParseFS(srv.fsys, "template/layout.tmpl", "template/clone.tmpl", "template/nav.tmpl")
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -163,18 +231,72 @@ func (srv *Server) ClonePage(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// ImportPage serves a form for uploading a dashboard bundle.
+// Uploaded bundles are not imported; instead a preview of the
+// changes importing would make is shown, with a form to confirm.
+func (srv *Server) ImportPage(w http.ResponseWriter, req *http.Request) {
+	tmpl, err := template.ParseFS(srv.fsys, "template/layout.tmpl", "template/import.tmpl", "template/nav.tmpl")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var data struct {
+		Plan     *meerkat.ImportPlan
+		Filename string
+		Bundle   string
+	}
+	if req.Method == http.MethodPost {
+		f, header, err := req.FormFile("bundle")
+		if err != nil {
+			http.Error(w, "read bundle: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		b, err := io.ReadAll(f)
+		if err != nil {
+			http.Error(w, "read bundle: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		bundle, err := meerkat.ReadBundle(b)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !srv.canEdit(req, bundle.Dashboard.Folder) {
+			denied(w, req)
+			return
+		}
+		if err := bundle.Dashboard.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		data.Plan, err = meerkat.PlanImport(bundle, srv.Store, srv.DataDir)
+		if err != nil {
+			http.Error(w, "plan import: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data.Filename = header.Filename
+		data.Bundle = base64.StdEncoding.EncodeToString(b)
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Println(err)
+	}
+}
+
 func (srv *Server) InfoPage(w http.ResponseWriter, req *http.Request) {
-	slug, _ := path.Split(req.URL.Path)
-	slug = path.Clean(slug)
-	fname := path.Join("dashboards", slug+".json")
-	dashboard, err := meerkat.ReadDashboard(fname)
+	slug := dashboardSlug(req.URL.Path)
+	dashboard, err := meerkat.LoadDashboard(srv.Store, slug)
 	if errors.Is(err, fs.ErrNotExist) {
-		http.Error(w, "no such dashboard "+fname, http.StatusNotFound)
+		http.Error(w, "no such dashboard "+slug, http.StatusNotFound)
 		return
 	} else if err != nil {
 		http.Error(w, "read dashboard: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if !srv.canEdit(req, dashboard.Folder) {
+		denied(w, req)
+		return
+	}
 
 	tmpl, err := template.ParseFS(srv.fsys, "template/layout.tmpl", "template/info.tmpl", "template/nav.tmpl", "template/filemgr_background.tmpl")
 	if err != nil {
@@ -182,13 +304,13 @@ func (srv *Server) InfoPage(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	backgrounds, err := meerkat.ReadDirectory("dashboards-background")
+	backgrounds, err := meerkat.ReadDirectory(filepath.Join(srv.DataDir, "dashboards-background"))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	sounds, err := meerkat.ReadDirectory("dashboards-sound")
+	sounds, err := meerkat.ReadDirectory(filepath.Join(srv.DataDir, "dashboards-sound"))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -198,10 +320,12 @@ func (srv *Server) InfoPage(w http.ResponseWriter, req *http.Request) {
 		Dashboard   meerkat.Dashboard
 		Backgrounds []string
 		Sounds      []string
+		Backends    []string
 	}{
 		Dashboard:   dashboard,
 		Backgrounds: backgrounds,
 		Sounds:      sounds,
+		Backends:    srv.Backends,
 	}
 
 	if err := tmpl.Execute(w, data); err != nil {
@@ -210,7 +334,7 @@ func (srv *Server) InfoPage(w http.ResponseWriter, req *http.Request) {
 }
 
 func (srv *Server) GetSounds(w http.ResponseWriter, req *http.Request) {
-	sounds, err := meerkat.ReadDirectory("dashboards-sound")
+	sounds, err := meerkat.ReadDirectory(filepath.Join(srv.DataDir, "dashboards-sound"))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -221,6 +345,10 @@ func (srv *Server) GetSounds(w http.ResponseWriter, req *http.Request) {
 
 func (srv *Server) UploadFileHandler(targetPath string, allowFileType string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if !srv.Access.CanEditAny(meerkat.UserFromContext(r.Context())) {
+			denied(w, r)
+			return
+		}
 		// get the file from the request
 		file, header, err := r.FormFile("file")
 		if err != nil {
@@ -266,6 +394,11 @@ func (srv *Server) UploadFileHandler(targetPath string, allowFileType string) ht
 
 func (srv *Server) DeleteFileHandler(targetPath string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// Assets may be used by dashboards in any folder.
+		if u := meerkat.UserFromContext(r.Context()); u == nil || u.Role != meerkat.RoleAdmin {
+			denied(w, r)
+			return
+		}
 		fileName := r.URL.Query().Get("name")
 		log.Println("Deleting file " + filepath.Join(targetPath, fileName))
 		err := os.Remove(filepath.Join(targetPath, fileName))
@@ -277,70 +410,183 @@ func (srv *Server) DeleteFileHandler(targetPath string) http.HandlerFunc {
 	}
 }
 
-func (srv *Server) EditInfoHandler(w http.ResponseWriter, req *http.Request) {
-	slug, _ := path.Split(req.URL.Path)
-	slug = path.Clean(slug)
-	fname := path.Join("dashboards", slug+".json")
-	dashboard, err := meerkat.ReadDashboard(fname)
+// HistoryPage lists the revisions of a dashboard.
+// If the "diff" query parameter names a revision, the changes made
+// by that revision are shown.
+func (srv *Server) HistoryPage(w http.ResponseWriter, req *http.Request) {
+	slug := dashboardSlug(req.URL.Path)
+	dashboard, err := meerkat.LoadDashboard(srv.Store, slug)
 	if errors.Is(err, fs.ErrNotExist) {
-		http.Error(w, "no such dashboard "+fname, http.StatusNotFound)
+		http.NotFound(w, req)
 		return
 	} else if err != nil {
 		http.Error(w, "read dashboard: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	if err := req.ParseForm(); err != nil {
-		http.Error(w, fmt.Sprintf("parse form: %v", err), http.StatusBadRequest)
+	if !srv.canView(req, dashboard.Folder) {
+		denied(w, req)
 		return
 	}
-
-	newdash, err := meerkat.ParseDashboardForm(req.PostForm)
+	revisions, err := srv.History.Revisions(slug)
 	if err != nil {
-		msg := fmt.Sprintf("parse dashboard form: %v", err)
-		log.Println(msg)
-		http.Error(w, msg, http.StatusBadRequest)
-		return
-	}
-	if dashboard.Title != newdash.Title {
-		old := fname
-		slug = newdash.Slug
-		fname = path.Join("dashboards", slug+".json")
-		// Don't rename to a dashboard that already exists.
-		if _, err := meerkat.ReadDashboard(fname); err == nil {
-			msg := fmt.Sprintf("dashboard %s already exists", slug)
-			log.Println(msg)
-			http.Error(w, msg, http.StatusBadRequest)
+		http.Error(w, "list revisions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Dashboard meerkat.Dashboard
+		Revisions []meerkat.Revision
+		CanEdit   bool
+		Diff      *meerkat.Revision
+		Changes   []meerkat.Change
+	}{
+		Dashboard: dashboard,
+		Revisions: revisions,
+		CanEdit:   srv.canEdit(req, dashboard.Folder),
+	}
+	if s := req.URL.Query().Get("diff"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			http.Error(w, "bad revision: "+err.Error(), http.StatusBadRequest)
 			return
 		}
-		defer func() {
-			if err := os.Remove(old); err != nil {
-				log.Printf("remove old dashboard %s after rename: %v", fname, err)
-			}
-		}()
-	}
-
-	dashboard.Title = newdash.Title
-	dashboard.Folder = newdash.Folder
-	dashboard.Background = newdash.Background
-	dashboard.Description = newdash.Description
-	dashboard.GlobalMute = newdash.GlobalMute
-	dashboard.OkSound = newdash.OkSound
-	dashboard.WarningSound = newdash.WarningSound
-	dashboard.CriticalSound = newdash.CriticalSound
-	dashboard.UnknownSound = newdash.UnknownSound
-	dashboard.UpSound = newdash.UpSound
-	dashboard.DownSound = newdash.DownSound
-	dashboard.Order = newdash.Order
-	err = meerkat.CreateDashboard(fname, &dashboard)
-	if err != nil {
-		msg := fmt.Sprintf("edit dashboard info: %v", err)
-		log.Print(msg)
-		http.Error(w, msg, http.StatusInternalServerError)
+		rev, err := srv.History.Revision(slug, id)
+		if errors.Is(err, fs.ErrNotExist) {
+			http.NotFound(w, req)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		previous := &meerkat.Dashboard{}
+		if prev, err := srv.History.Revision(slug, id-1); err == nil {
+			previous = prev.Dashboard
+		}
+		data.Changes, err = meerkat.Diff(previous, rev.Dashboard)
+		if err != nil {
+			http.Error(w, "diff revisions: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data.Diff = &rev
+	}
+
+	tmpl, err := template.ParseFS(srv.fsys, "template/layout.tmpl", "template/history.tmpl", "template/nav.tmpl")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	url := path.Join("/", slug, "update")
-	log.Printf("Dashboard info updated %s\n", dashboard.Title)
-	http.Redirect(w, req, url, http.StatusFound)
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Println(err)
+	}
+}
+
+func (srv *Server) AccessPage(w http.ResponseWriter, req *http.Request) {
+	tmpl, err := template.ParseFS(srv.fsys, "template/layout.tmpl", "template/access.tmpl", "template/nav.tmpl")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	dashboards, err := meerkat.LoadDashboards(srv.Store)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	folders := make(map[string]meerkat.FolderACL)
+	for _, d := range dashboards {
+		folders[d.Folder] = meerkat.FolderACL{Folder: d.Folder}
+	}
+	access := srv.Access.Snapshot()
+	for _, acl := range access.Folders {
+		folders[acl.Folder] = acl
+	}
+	var acls []meerkat.FolderACL
+	for _, acl := range folders {
+		acls = append(acls, acl)
+	}
+	sort.Slice(acls, func(i, j int) bool { return acls[i].Folder < acls[j].Folder })
+
+	data := struct {
+		Users   []meerkat.User
+		Folders []meerkat.FolderACL
+	}{
+		Users:   access.Users,
+		Folders: acls,
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Println(err)
+	}
+}
+
+// KiosksPage lists kiosks, and lets admins control what they show.
+func (srv *Server) KiosksPage(w http.ResponseWriter, req *http.Request) {
+	tmpl, err := template.ParseFS(srv.fsys, "template/layout.tmpl", "template/kiosks.tmpl", "template/nav.tmpl")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	dashboards, err := meerkat.LoadDashboards(srv.Store)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	playlists, err := meerkat.LoadPlaylists(srv.Store)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data := struct {
+		Dashboards []meerkat.Dashboard
+		Playlists  []meerkat.Playlist
+	}{dashboards, playlists}
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Println(err)
+	}
+}
+
+// KioskPage is opened by a kiosk. It shows whatever the kiosk is told
+// to in its heartbeats, which the kiosk authenticates with its token.
+// Heartbeats also give the kiosk a cookie with which it may view what
+// it shows, and nothing else.
+// The page holds nothing but the kiosk's name, so it is served for any
+// name rather than revealing which kiosks exist; kiosks which are not
+// registered learn so from their heartbeats.
+func (srv *Server) KioskPage(w http.ResponseWriter, req *http.Request) {
+	name := path.Base(req.URL.Path)
+	if meerkat.TitleToSlug(name) != name {
+		http.NotFound(w, req)
+		return
+	}
+	tmpl, err := template.ParseFS(srv.fsys, "template/layout.tmpl", "template/kiosk.tmpl")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tmpl.Execute(w, meerkat.Kiosk{Name: name}); err != nil {
+		log.Println(err)
+	}
+}
+
+func (srv *Server) LoginPage(w http.ResponseWriter, req *http.Request) {
+	tmpl, err := template.ParseFS(srv.fsys, "template/layout.tmpl", "template/login.tmpl", "template/nav.tmpl")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data := struct {
+		Next     string
+		Failed   bool
+		SSOLogin string
+	}{
+		Next:   req.URL.Query().Get("next"),
+		Failed: req.URL.Query().Has("failed"),
+	}
+	if srv.SSOLoginURL != "" {
+		q := url.Values{"next": []string{data.Next}}
+		data.SSOLogin = srv.SSOLoginURL + "?" + q.Encode()
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Println(err)
+	}
 }
 
 func (srv *Server) AboutPage(w http.ResponseWriter, req *http.Request) {
@@ -355,7 +601,7 @@ func (srv *Server) AboutPage(w http.ResponseWriter, req *http.Request) {
 		VersionString string
 	}{
 		BuildString:   meerkat.BuildString(),
-		VersionString: meerkat.VersionString(),
+		VersionString: meerkat.VersionString(srv.DataDir),
 	}
 
 	err = tmpl.Execute(w, about)
@@ -371,7 +617,7 @@ func (srv *Server) BackgroundPage(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	backgrounds, err := meerkat.ReadDirectory("dashboards-background")
+	backgrounds, err := meerkat.ReadDirectory(filepath.Join(srv.DataDir, "dashboards-background"))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -400,7 +646,7 @@ func (srv *Server) SoundPage(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	sounds, err := meerkat.ReadDirectory("dashboards-sound")
+	sounds, err := meerkat.ReadDirectory(filepath.Join(srv.DataDir, "dashboards-sound"))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -428,6 +674,45 @@ func (srv *Server) FileServer() http.Handler {
 
 // Helper functions
 
+// dashboardSlug returns the slug of the dashboard from a page's path,
+// such as "example" from "/example/view".
+func dashboardSlug(p string) string {
+	return path.Base(path.Dir(p))
+}
+
+func (srv *Server) canView(req *http.Request, folder string) bool {
+	return srv.Access.CanView(meerkat.UserFromContext(req.Context()), folder)
+}
+
+func (srv *Server) canEdit(req *http.Request, folder string) bool {
+	return srv.Access.CanEdit(meerkat.UserFromContext(req.Context()), folder)
+}
+
+// sharedWith reports whether req was made with a share token
+// for the dashboard slug.
+func sharedWith(req *http.Request, slug string) bool {
+	share := meerkat.ShareFromContext(req.Context())
+	return share != nil && share.Dashboard == slug
+}
+
+// kioskPlaying reports whether req was made by a kiosk showing the
+// playlist name.
+func kioskPlaying(req *http.Request, name string) bool {
+	k := meerkat.KioskFromContext(req.Context())
+	return k != nil && k.Playlist == name
+}
+
+// denied responds to a request for a page the user may not access.
+// Anonymous users are sent to the login page.
+func denied(w http.ResponseWriter, req *http.Request) {
+	if meerkat.UserFromContext(req.Context()) == nil {
+		q := url.Values{"next": []string{req.URL.RequestURI()}}
+		http.Redirect(w, req, "/login?"+q.Encode(), http.StatusFound)
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+}
+
 // Sanitize filenames so they are safe and timestamped
 func SanitizeName(filename string) (string, error) {
 	// Check if filename is empty
@@ -71,15 +71,16 @@ function dashboardReducer(state, action) {
 		case "setDashboard":
 			return action.dashboard;
 		case "addElement":
+			const added = { ...defaultElement, id: meerkat.newElementID() };
 			if (state.elements) {
 				return {
 					...state,
-					elements: state.elements.concat(defaultElement),
+					elements: state.elements.concat(added),
 				};
 			}
 			return {
 				...state,
-				elements: [defaultElement],
+				elements: [added],
 			};
 		case "deleteElement":
 			const nstate = { ...state };
@@ -88,9 +89,10 @@ function dashboardReducer(state, action) {
 		case "duplicateElement":
 			return {
 				...state,
-				elements: state.elements.concat(
-					JSON.parse(JSON.stringify(action.element))
-				),
+				elements: state.elements.concat({
+					...JSON.parse(JSON.stringify(action.element)),
+					id: meerkat.newElementID(),
+				}),
 			};
 		case "updateElement":
 			console.log(
@@ -121,6 +123,7 @@ function Editor({ slug, events }) {
 	const [dashboard, dashboardDispatch] = useReducer(dashboardReducer, null);
 	const [highlightedElementId, setHighlightedElementId] = useState(null);
 	const [selectedElement, setSelectedElement] = useState(null);
+	const [message, setMessage] = useState("");
 
 	useEffect(() => {
 		meerkat.getDashboard(slug).then(async (d) => {
@@ -143,7 +146,7 @@ function Editor({ slug, events }) {
 
 	const saveAndView = async (e) => {
 		try {
-			await meerkat.saveDashboard(slug, dashboard);
+			await meerkat.saveDashboard(slug, dashboard, message);
 			location.href = `/${dashboard.slug}/view`;
 		} catch (e) {
 			alert(`Error saving dashboard: ${e.message}`);
@@ -177,6 +180,13 @@ function Editor({ slug, events }) {
 				<a href="/" class="btn btn-secondary">
 					Home
 				</a>
+				<input
+					class="form-control"
+					type="text"
+					placeholder="Describe your changes"
+					value={message}
+					onInput={(e) => setMessage(e.currentTarget.value)}
+				/>
 				<button onClick={saveAndView} class="btn btn-success">
 					Save & View
 				</button>
@@ -393,10 +403,22 @@ function DashboardElements({
 		let ele;
 		switch (element.type) {
 			case "check-svg":
-				ele = <CheckSVG events={events} options={element.options} />;
+				ele = (
+					<CheckSVG
+						id={element.id}
+						events={events}
+						options={element.options}
+					/>
+				);
 				break;
 			case "check-line":
-				ele = <CheckLine events={events} options={element.options} />;
+				ele = (
+					<CheckLine
+						id={element.id}
+						events={events}
+						options={element.options}
+					/>
+				);
 				break;
 			case "clock":
 				ele = (
@@ -410,7 +432,13 @@ function DashboardElements({
 				ele = <StaticText options={element.options} />;
 				break;
 			case "dynamic-text":
-				ele = <DynamicText events={events} options={element.options} />;
+				ele = (
+					<DynamicText
+						id={element.id}
+						events={events}
+						options={element.options}
+					/>
+				);
 				break;
 			case "static-ticker":
 				ele = <StaticTicker options={element.options} />;
@@ -428,7 +456,13 @@ function DashboardElements({
 				ele = <audio controls src={element.options.audioSource}></audio>;
 				break;
 			case "check-card":
-				ele = <ObjectCard options={element.options} events={events} />;
+				ele = (
+					<ObjectCard
+						id={element.id}
+						options={element.options}
+						events={events}
+					/>
+				);
 				break;
 		}
 
@@ -18,11 +18,13 @@ export function ObjectCardOptions({ options, updateOptions }) {
 			<Icinga.ObjectSelect
 				objectType={options.objectType}
 				objectName={options.objectName}
+				backend={options.backend}
 				updateOptions={updateOptions}
 			/>
 			<Icinga.AttrSelect
 				objectName={options.objectName}
 				objectType={options.objectType}
+				backend={options.backend}
 				selected={options.objectAttr}
 				updateOptions={updateOptions}
 				objectAttrMatch={options.objectAttrMatch}
@@ -243,7 +245,7 @@ export function ObjectCardOptions({ options, updateOptions }) {
 	);
 }
 
-export function ObjectCard({ events, options, dashboard }) {
+export function ObjectCard({ id, events, options, dashboard }) {
 	const [objectState, setObjectState] = useState();
 	const [cardText, setCardText] = useState();
 	const [cardState, setCardState] = useState();
@@ -336,8 +338,7 @@ export function ObjectCard({ events, options, dashboard }) {
 		for (let i = 0; i < objects.length; i++) {
 			if (
 				objectState &&
-				objects[i].element == options.objectName &&
-				options.objectType.includes(objects[i].type.toLowerCase())
+				meerkat.isElementEvent(objects[i], id, options)
 			) {
 				let obj = objects[i];
 				if (
@@ -360,7 +361,11 @@ export function ObjectCard({ events, options, dashboard }) {
 		try {
 			if (options.objectType.endsWith("group")) {
 				meerkat
-					.getAllInGroup(options.objectName, options.objectType)
+					.getAllInGroup(
+						options.objectName,
+						options.objectType,
+						options.backend
+					)
 					.then((data) => {
 						let worst = IcingaJS.worstObject(data);
 						setObjectState(worst);
@@ -368,7 +373,11 @@ export function ObjectCard({ events, options, dashboard }) {
 					});
 			} else if (options.objectType.endsWith("filter")) {
 				meerkat
-					.getAllFilter(options.objectName, options.objectType)
+					.getAllFilter(
+						options.objectName,
+						options.objectType,
+						options.backend
+					)
 					.then((data) => {
 						let worst = IcingaJS.worstObject(data);
 						setObjectState(worst);
@@ -376,7 +385,11 @@ export function ObjectCard({ events, options, dashboard }) {
 					});
 			} else {
 				meerkat
-					.getIcingaObject(options.objectName, options.objectType)
+					.getIcingaObject(
+						options.objectName,
+						options.objectType,
+						options.backend
+					)
 					.then((data) => {
 						setObjectState(data);
 						parseUpdate(data);
@@ -5,8 +5,8 @@ import * as meerkat from "../meerkat";
 import * as IcingaJS from "../icinga/icinga.js";
 import * as flatten from "../icinga/flatten.js";
 
-async function getObjectNames(objectType) {
-	const objects = await meerkat.getAll(objectType);
+async function getObjectNames(objectType, backend) {
+	const objects = await meerkat.getAll(objectType, backend);
 	const names = objects.map((obj) => obj.name);
 	return names.sort();
 }
@@ -16,26 +16,41 @@ export class ObjectSelect extends Component {
 		super(props);
 		this.state = {
 			names: [],
+			backends: [],
 		};
 		// if we're initialised with an object type, get a list of all the names so we're ready to go
 		if (props.objectType) {
-			getObjectNames(props.objectType).then((names) => {
+			getObjectNames(props.objectType, props.backend).then((names) => {
 				this.setState({
 					names: names,
 				});
 			});
 		}
+		meerkat.getBackends().then((backends) => {
+			this.setState({ backends: backends });
+		});
 		this.handleSelect = this.handleSelect.bind(this);
+		this.handleBackendChange = this.handleBackendChange.bind(this);
 		this.handleObjectChange = this.handleObjectChange.bind(this);
 	}
 
 	async handleSelect(event) {
 		const objectType = event.target.value;
 		this.props.updateOptions({ objectType: objectType });
-		const names = await getObjectNames(objectType);
+		const names = await getObjectNames(objectType, this.props.backend);
 		this.setState({ names: names });
 	}
 
+	async handleBackendChange(event) {
+		// An empty backend means the dashboard's.
+		const backend = event.target.value || null;
+		this.props.updateOptions({ backend: backend });
+		if (this.props.objectType) {
+			const names = await getObjectNames(this.props.objectType, backend);
+			this.setState({ names: names });
+		}
+	}
+
 	handleObjectChange(event) {
 		const objectName = event.target.value;
 		this.props.updateOptions({ objectName: objectName });
@@ -48,7 +63,12 @@ export class ObjectSelect extends Component {
 		}
 		return (
 			<fieldset>
-				<legend>Icinga object</legend>
+				<legend>Object</legend>
+				<BackendSelect
+					backends={this.state.backends}
+					selected={this.props.backend}
+					onInput={this.handleBackendChange}
+				/>
 				<ObjectTypeSelect
 					selected={this.props.objectType}
 					onInput={this.handleSelect}
@@ -60,6 +80,7 @@ export class ObjectSelect extends Component {
 					disabled={filterEnabled}
 				/>
 				<FilterInput
+					objectType={this.props.objectType}
 					value={this.props.objectName}
 					onInput={this.handleObjectChange}
 					disabled={!filterEnabled}
@@ -69,6 +90,35 @@ export class ObjectSelect extends Component {
 	}
 }
 
+// BackendSelect chooses the backend an element's object is from.
+// It is only shown when backends other than the default one
+// are configured.
+function BackendSelect({ backends, selected, onInput }) {
+	if (backends.length == 0) {
+		return null;
+	}
+	return (
+		<Fragment>
+			<label class="form-label">Backend</label>
+			<select
+				class="form-select"
+				name="backend"
+				value={selected || ""}
+				onInput={onInput}
+			>
+				<option key="default" value="">
+					Dashboard default
+				</option>
+				{backends.map((name) => (
+					<option key={name} value={name}>
+						{name}
+					</option>
+				))}
+			</select>
+		</Fragment>
+	);
+}
+
 function ObjectTypeSelect({ selected, onInput }) {
 	if (!selected) {
 		selected = "";
@@ -86,24 +136,37 @@ function ObjectTypeSelect({ selected, onInput }) {
 				<option key="default" disabled value="">
 					Choose an object type...
 				</option>
-				<option key="host" value="host">
-					Host
-				</option>
-				<option key="service" value="service">
-					Service
-				</option>
-				<option key="hostgroup" value="hostgroup">
-					Host Group
-				</option>
-				<option key="servicegroup" value="servicegroup">
-					Service Group
-				</option>
-				<option key="hostfilter" value="hostfilter">
-					Host Filter
-				</option>
-				<option key="servicefilter" value="servicefilter">
-					Service Filter
-				</option>
+				<optgroup label="Icinga">
+					<option key="host" value="host">
+						Host
+					</option>
+					<option key="service" value="service">
+						Service
+					</option>
+					<option key="hostgroup" value="hostgroup">
+						Host Group
+					</option>
+					<option key="servicegroup" value="servicegroup">
+						Service Group
+					</option>
+					<option key="hostfilter" value="hostfilter">
+						Host Filter
+					</option>
+					<option key="servicefilter" value="servicefilter">
+						Service Filter
+					</option>
+				</optgroup>
+				<optgroup label="Prometheus">
+					<option key="alert" value="alert">
+						Alert
+					</option>
+					<option key="alertfilter" value="alertfilter">
+						Alert Filter
+					</option>
+					<option key="metric" value="metric">
+						Metric
+					</option>
+				</optgroup>
 			</select>
 		</Fragment>
 	);
@@ -150,7 +213,7 @@ function ObjectList({ names, value, onInput, disabled }) {
 	);
 }
 
-function FilterInput({ value, onInput, disabled }) {
+function FilterInput({ objectType, value, onInput, disabled }) {
 	if (disabled) {
 		return (
 			<DisabledInput
@@ -159,6 +222,23 @@ function FilterInput({ value, onInput, disabled }) {
 			/>
 		);
 	}
+	if (objectType == "alertfilter") {
+		return (
+			<Fragment>
+				<label class="form-label">Label Matchers</label>
+				<input
+					class="form-control"
+					placeholder={`{team="network", severity=~"critical|warning"}`}
+					value={value}
+					onInput={onInput}
+				/>
+				<small class="form-text">
+					Alerts with labels matching all of the matchers are shown, as
+					in Prometheus queries.
+				</small>
+			</Fragment>
+		);
+	}
 	const placeholder = `match("app*.example.com", service.name)`;
 	return (
 		<Fragment>
@@ -180,6 +260,7 @@ function FilterInput({ value, onInput, disabled }) {
 export function AttrSelect({
 	objectName,
 	objectType,
+	backend,
 	selected,
 	updateOptions,
 	objectAttrMatch,
@@ -204,26 +285,32 @@ export function AttrSelect({
 	useEffect(() => {
 		try {
 			if (objectType.endsWith("group")) {
-				meerkat.getAllInGroup(objectName, objectType).then((data) => {
-					let worst = IcingaJS.worstObject(data);
-					parseUpdate(worst);
-				});
+				meerkat
+					.getAllInGroup(objectName, objectType, backend)
+					.then((data) => {
+						let worst = IcingaJS.worstObject(data);
+						parseUpdate(worst);
+					});
 			} else if (objectType.endsWith("filter")) {
-				meerkat.getAllFilter(objectName, objectType).then((data) => {
-					let worst = IcingaJS.worstObject(data);
-					parseUpdate(worst);
-				});
+				meerkat
+					.getAllFilter(objectName, objectType, backend)
+					.then((data) => {
+						let worst = IcingaJS.worstObject(data);
+						parseUpdate(worst);
+					});
 			} else {
-				meerkat.getIcingaObject(objectName, objectType).then((data) => {
-					parseUpdate(data);
-				});
+				meerkat
+					.getIcingaObject(objectName, objectType, backend)
+					.then((data) => {
+						parseUpdate(data);
+					});
 			}
 		} catch (err) {
 			console.error(
 				`fetch ${options.objectType} ${options.objectName}: ${err}`
 			);
 		}
-	}, [objectName, objectType]);
+	}, [objectName, objectType, backend]);
 
 	return (
 		<fieldset>
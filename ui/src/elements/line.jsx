@@ -12,6 +12,7 @@ export function CheckLineOptions({ options, updateOptions }) {
 			<Icinga.ObjectSelect
 				objectType={options.objectType}
 				objectName={options.objectName}
+				backend={options.backend}
 				updateOptions={updateOptions}
 			/>
 
@@ -71,7 +72,7 @@ export function CheckLineOptions({ options, updateOptions }) {
 }
 
 //The rendered view (in the actual dashboard) of the Check SVG
-export function CheckLine({ events, options, dashboard }) {
+export function CheckLine({ id, events, options, dashboard }) {
 	const [objectState, setObjectState] = useState();
 	const [state, setState] = useState();
 	const [soundEvent, setSoundEvent] = useState(false);
@@ -82,7 +83,11 @@ export function CheckLine({ events, options, dashboard }) {
 		try {
 			if (options.objectType.endsWith("group")) {
 				meerkat
-					.getAllInGroup(options.objectName, options.objectType)
+					.getAllInGroup(
+						options.objectName,
+						options.objectType,
+						options.backend
+					)
 					.then((data) => {
 						let worst = icinga.worstObject(data);
 						setObjectState(worst);
@@ -90,7 +95,11 @@ export function CheckLine({ events, options, dashboard }) {
 					});
 			} else if (options.objectType.endsWith("filter")) {
 				meerkat
-					.getAllFilter(options.objectName, options.objectType)
+					.getAllFilter(
+						options.objectName,
+						options.objectType,
+						options.backend
+					)
 					.then((data) => {
 						let worst = icinga.worstObject(data);
 						setObjectState(worst);
@@ -98,7 +107,11 @@ export function CheckLine({ events, options, dashboard }) {
 					});
 			} else {
 				meerkat
-					.getIcingaObject(options.objectName, options.objectType)
+					.getIcingaObject(
+						options.objectName,
+						options.objectType,
+						options.backend
+					)
 					.then((data) => {
 						setObjectState(data);
 						setState(icinga.StateText(data.state, options.objectType));
@@ -116,8 +129,7 @@ export function CheckLine({ events, options, dashboard }) {
 		for (let i = 0; i < objects.length; i++) {
 			if (
 				objectState &&
-				objects[i].element == options.objectName &&
-				options.objectType.includes(objects[i].type.toLowerCase())
+				meerkat.isElementEvent(objects[i], id, options)
 			) {
 				let obj = objects[i];
 				if (
@@ -22,6 +22,7 @@ export function CheckSVGOptions({ options, updateOptions }) {
 			<Icinga.ObjectSelect
 				objectType={options.objectType}
 				objectName={options.objectName}
+				backend={options.backend}
 				updateOptions={updateOptions}
 			/>
 
@@ -277,7 +278,7 @@ export function CheckSVGOptions({ options, updateOptions }) {
 	);
 }
 
-export function CheckSVG({ events, options, dashboard }) {
+export function CheckSVG({ id, events, options, dashboard }) {
 	const [objectState, setObjectState] = useState();
 	const [cardState, setCardState] = useState();
 	const [soundEvent, setSoundEvent] = useState(false);
@@ -334,7 +335,11 @@ export function CheckSVG({ events, options, dashboard }) {
 		try {
 			if (options.objectType.endsWith("group")) {
 				meerkat
-					.getAllInGroup(options.objectName, options.objectType)
+					.getAllInGroup(
+						options.objectName,
+						options.objectType,
+						options.backend
+					)
 					.then((data) => {
 						let worst = IcingaJS.worstObject(data);
 						setObjectState(worst);
@@ -342,7 +347,11 @@ export function CheckSVG({ events, options, dashboard }) {
 					});
 			} else if (options.objectType.endsWith("filter")) {
 				meerkat
-					.getAllFilter(options.objectName, options.objectType)
+					.getAllFilter(
+						options.objectName,
+						options.objectType,
+						options.backend
+					)
 					.then((data) => {
 						let worst = IcingaJS.worstObject(data);
 						setObjectState(worst);
@@ -350,7 +359,11 @@ export function CheckSVG({ events, options, dashboard }) {
 					});
 			} else {
 				meerkat
-					.getIcingaObject(options.objectName, options.objectType)
+					.getIcingaObject(
+						options.objectName,
+						options.objectType,
+						options.backend
+					)
 					.then((data) => {
 						setObjectState(data);
 						parseUpdate(data);
@@ -368,8 +381,7 @@ export function CheckSVG({ events, options, dashboard }) {
 		for (let i = 0; i < objects.length; i++) {
 			if (
 				objectState &&
-				objects[i].element == options.objectName &&
-				options.objectType.includes(objects[i].type.toLowerCase())
+				meerkat.isElementEvent(objects[i], id, options)
 			) {
 				let obj = objects[i];
 				if (
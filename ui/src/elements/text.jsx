@@ -18,11 +18,13 @@ export function DynamicTextOptions({ options, updateOptions }) {
 			<Icinga.ObjectSelect
 				objectType={options.objectType}
 				objectName={options.objectName}
+				backend={options.backend}
 				updateOptions={updateOptions}
 			/>
 			<Icinga.AttrSelect
 				objectName={options.objectName}
 				objectType={options.objectType}
+				backend={options.backend}
 				selected={options.objectAttr}
 				updateOptions={updateOptions}
 				objectAttrMatch={options.objectAttrMatch}
@@ -110,7 +112,7 @@ export function DynamicTextOptions({ options, updateOptions }) {
 	);
 }
 
-export function DynamicText({ events, options }) {
+export function DynamicText({ id, events, options }) {
 	const [objectState, setObjectState] = useState();
 	const [text, setText] = useState("");
 	const [styles, setStyles] = useState("");
@@ -183,8 +185,7 @@ export function DynamicText({ events, options }) {
 		for (let i = 0; i < objects.length; i++) {
 			if (
 				objectState &&
-				objects[i].element == options.objectName &&
-				options.objectType.includes(objects[i].type.toLowerCase())
+				meerkat.isElementEvent(objects[i], id, options)
 			) {
 				let obj = objects[i];
 				if (
@@ -205,7 +206,11 @@ export function DynamicText({ events, options }) {
 		try {
 			if (options.objectType.endsWith("group")) {
 				meerkat
-					.getAllInGroup(options.objectName, options.objectType)
+					.getAllInGroup(
+						options.objectName,
+						options.objectType,
+						options.backend
+					)
 					.then((data) => {
 						let worst = IcingaJS.worstObject(data);
 						setObjectState(worst);
@@ -213,7 +218,11 @@ export function DynamicText({ events, options }) {
 					});
 			} else if (options.objectType.endsWith("filter")) {
 				meerkat
-					.getAllFilter(options.objectName, options.objectType)
+					.getAllFilter(
+						options.objectName,
+						options.objectType,
+						options.backend
+					)
 					.then((data) => {
 						let worst = IcingaJS.worstObject(data);
 						setObjectState(worst);
@@ -221,7 +230,11 @@ export function DynamicText({ events, options }) {
 					});
 			} else {
 				meerkat
-					.getIcingaObject(options.objectName, options.objectType)
+					.getIcingaObject(
+						options.objectName,
+						options.objectType,
+						options.backend
+					)
 					.then((data) => {
 						setObjectState(data);
 						parseUpdate(data);
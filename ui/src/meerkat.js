@@ -1,32 +1,87 @@
 import * as icinga from "./icinga/icinga.js";
 
-export async function getAll(objectType) {
+// viewStream names the event stream of the dashboard being viewed.
+// It differs from the dashboard's slug when the values of its
+// variables are given in the query string.
+let viewStream = "";
+
+// share is the token the dashboard is viewed with, if it was shared.
+const share = new URLSearchParams(window.location.search).get("share");
+
+/**
+ * shared reports whether the dashboard is viewed with a share token.
+ * Shared dashboards may only fetch what they show.
+ */
+export function shared() {
+	return share != null;
+}
+
+/**
+ * shareQuery returns the query parameter passing on the share token,
+ * if any, to requests made for the dashboard.
+ */
+export function shareQuery() {
+	return share ? `&share=${encodeURIComponent(share)}` : "";
+}
+
+// viewQuery returns the query parameters telling the server
+// which dashboard, and which view of it, objects are requested for.
+// backend names the backend objects are from;
+// if empty, the dashboard's is used.
+function viewQuery(backend) {
+	let q = `&title=${window.location.pathname}`;
+	if (viewStream) {
+		q += `&stream=${encodeURIComponent(viewStream)}`;
+	}
+	return q + backendQuery(backend) + shareQuery();
+}
+
+function backendQuery(backend) {
+	return backend ? `&backend=${encodeURIComponent(backend)}` : "";
+}
+
+/**
+ * getBackends returns the names of the backends
+ * dashboards and elements may choose other than the default one.
+ */
+export async function getBackends() {
+	const resp = await fetch("/api/backends");
+	if (!resp.ok) {
+		return [];
+	}
+	return await resp.json();
+}
+
+export async function getAll(objectType, backend) {
 	objectType = pluralise(objectType);
 	const resp = await fetch(
-		`/api/all?type=${objectType}&title=${window.location.pathname}`
+		`/api/all?type=${objectType}&title=${
+			window.location.pathname
+		}${backendQuery(backend)}`
 	);
 	return await readResults(resp);
 }
 
-export async function getAllInGroup(name, objectType) {
+export async function getAllInGroup(name, objectType, backend) {
 	// "example" in service.groups
 	const typ = singular(objectType);
 	const expr = `"${name}" in ${typ}.groups`;
-	return getAllFilter(expr, objectType);
+	return getAllFilter(expr, objectType, backend);
 }
 
-export async function getAllFilter(expr, objectType) {
+export async function getAllFilter(expr, objectType, backend) {
 	// %22example%22%20in%20service.groups
 	const filter = encodeURIComponent(expr);
 	let typ = "service";
 	if (objectType.startsWith("host")) {
 		typ = "host";
+	} else if (objectType.startsWith("alert")) {
+		// Prometheus label matchers, as in {team="network"}
+		typ = "alert";
 	}
 	// /icinga/v1/objects/services?filter=%22example%22%20in%20service.groups
 	const path = `/api/objects?type=${pluralise(typ)}`;
-	const resp = await fetch(
-		path + "&filter=" + filter + "&title=" + window.location.pathname
-	);
+	const resp = await fetch(path + "&filter=" + filter + viewQuery(backend));
 	const results = await readResults(resp);
 	return await handleJSONList(results);
 }
@@ -39,24 +94,38 @@ export async function handleJSONList(obj) {
 	return json;
 }
 
-export async function getIcingaObject(name, typ) {
+export async function getIcingaObject(name, typ, backend) {
 	if (typ.endsWith("filter")) {
-		const results = await getAllFilter(name, typ);
+		const results = await getAllFilter(name, typ, backend);
 		return icinga.objectsToSingle(name, results);
 	}
 	typ = pluralise(typ);
 	let encname = encodeURIComponent(name);
-	let path = `/api/objects?type=${typ}&name=${encname}&title=${window.location.pathname}`;
+	let path = `/api/objects?type=${typ}&name=${encname}${viewQuery(backend)}`;
 	const resp = await fetch(path);
 	const results = await readResults(resp);
 	let obj = results[0];
 	if (typ.endsWith("groups")) {
-		const members = await getAllInGroup(name, typ);
+		const members = await getAllInGroup(name, typ, backend);
 		obj = icinga.groupToObject(obj, members);
 	}
 	return await handleJSON(obj);
 }
 
+// isElementEvent reports whether the object obj from an event stream
+// is for the element with the given id and options.
+// Events naming an element are only for that element; older events
+// are matched by the object the element shows.
+export function isElementEvent(obj, id, options) {
+	if (obj.elementId && obj.elementId != id) {
+		return false;
+	}
+	return (
+		obj.element == options.objectName &&
+		options.objectType.includes(obj.type.toLowerCase())
+	);
+}
+
 export async function handleJSON(obj) {
 	var json = {
 		acknowledged: obj.attrs.acknowledgement,
@@ -66,6 +135,7 @@ export async function handleJSON(obj) {
 		perfdata: {},
 		state: obj.attrs.last_check_result.state,
 		element: obj.element,
+		elementId: obj.element_id,
 	};
 	try {
 		if (obj.attrs.last_check_result.performance_data) {
@@ -95,14 +165,58 @@ export async function handleJSON(obj) {
 	return json;
 }
 
+// etags holds the ETag of each dashboard as last loaded or saved,
+// so that saving fails rather than overwriting someone else's changes.
+const etags = {};
+
+/**
+ * newElementID returns a random identifier for a new element,
+ * like those assigned by the server.
+ */
+export function newElementID() {
+	const b = crypto.getRandomValues(new Uint8Array(6));
+	return Array.from(b, (n) => n.toString(16).padStart(2, "0")).join("");
+}
+
 export async function getDashboard(slug) {
 	const resp = await fetch(`/dashboard/${slug}`);
 	if (!resp.ok) {
 		throw new Error(resp.statusText);
 	}
+	etags[slug] = resp.headers.get("ETag");
 	return await resp.json();
 }
 
+/**
+ * getDashboardView returns the dashboard slug as it is shown, with the
+ * values of variables given in the page's query string, and the name
+ * of the event stream to follow for it.
+ */
+export async function getDashboardView(slug) {
+	const resp = await fetch(
+		`/api/v1/dashboards/${slug}/view${window.location.search}`
+	);
+	if (!resp.ok) {
+		throw new Error(resp.statusText);
+	}
+	viewStream = resp.headers.get("Meerkat-Stream") || slug;
+	return { dashboard: await resp.json(), stream: viewStream };
+}
+
+// getArchive returns the states of the dashboard slug archived
+// between the dates from and to.
+export async function getArchive(slug, from, to) {
+	const q = new URLSearchParams({
+		from: from.toISOString(),
+		to: to.toISOString(),
+	});
+	const resp = await fetch(`/api/v1/dashboards/${slug}/archive?${q}`);
+	if (!resp.ok) {
+		throw new Error(await resp.text());
+	}
+	return resp.json();
+}
+
 export async function getSounds() {
 	const resp = await fetch(`/file/sound`);
 	if (!resp.ok) {
@@ -111,19 +225,47 @@ export async function getSounds() {
 	return await resp.json();
 }
 
-export async function saveDashboard(slug, dashboard) {
-	const resp = await fetch(`/dashboard/${slug}`, {
+export async function saveDashboard(slug, dashboard, message = "") {
+	const q = new URLSearchParams({ message: message });
+	const headers = {};
+	if (etags[slug]) {
+		headers["If-Match"] = etags[slug];
+	}
+	const resp = await fetch(`/dashboard/${slug}?${q}`, {
 		method: "POST",
+		headers: headers,
 		body: JSON.stringify(dashboard),
 	});
+	if (resp.ok) {
+		etags[slug] = resp.headers.get("ETag");
+	}
 	await fetch(`/${slug}/update`, {
 		method: "GET",
 	});
 	if (!resp.ok) {
-		throw new Error(await resp.text());
+		throw new Error(await errorMessage(resp));
 	}
 }
 
+/**
+ * errorMessage returns a description of the error in resp.
+ * Invalid dashboards are described field by field.
+ */
+async function errorMessage(resp) {
+	if (!resp.headers.get("Content-Type")?.startsWith("application/json")) {
+		return await resp.text();
+	}
+	const body = await resp.json();
+	if (!body.fields) {
+		return body.error;
+	}
+	const lines = body.fields.map((f) => {
+		const where = f.element < 0 ? f.field : `element ${f.element + 1} ${f.field}`;
+		return `${where}: ${f.reason}`;
+	});
+	return [body.error, ...lines].join("\n");
+}
+
 function pluralise(str) {
 	if (str.slice(-1) != "s") {
 		return str + "s";
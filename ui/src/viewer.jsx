@@ -36,6 +36,7 @@ function Viewer({ dashboard, events }) {
 		) {
 			ele = (
 				<IcingaElement
+					id={element.id}
 					typ={element.type}
 					options={element.options}
 					events={events}
@@ -97,17 +98,36 @@ function Viewer({ dashboard, events }) {
 	);
 }
 
-function IcingaElement({ typ, options, events, dashboard }) {
+function IcingaElement({ id, typ, options, events, dashboard }) {
 	let ele;
 	if (typ === "check-svg") {
-		ele = <CheckSVG events={events} options={options} dashboard={dashboard} />;
+		ele = (
+			<CheckSVG
+				id={id}
+				events={events}
+				options={options}
+				dashboard={dashboard}
+			/>
+		);
 	} else if (typ === "check-line") {
-		ele = <CheckLine events={events} options={options} dashboard={dashboard} />;
+		ele = (
+			<CheckLine
+				id={id}
+				events={events}
+				options={options}
+				dashboard={dashboard}
+			/>
+		);
 	} else if (typ === "dynamic-text") {
-		ele = <DynamicText events={events} options={options} />;
+		ele = <DynamicText id={id} events={events} options={options} />;
 	} else if (typ === "check-card") {
 		ele = (
-			<ObjectCard events={events} options={options} dashboard={dashboard} />
+			<ObjectCard
+				id={id}
+				events={events}
+				options={options}
+				dashboard={dashboard}
+			/>
 		);
 	}
 	if (options.linkURL) {
@@ -152,6 +172,33 @@ function linkWrap(ele, link) {
 
 const elems = window.location.pathname.split("/");
 const slug = elems[elems.length - 2];
+// template is the slug of the template of the dashboard, if any.
+// Changes to the template are changes to the dashboard.
+let template = "";
+// backends are the names of the backends the dashboard's
+// objects are from. Errors from other backends are not shown.
+let backends = new Set(["icinga"]);
+
+// usedBackends returns the names of the backends
+// the objects shown by dashboard are from.
+function usedBackends(dashboard) {
+	const names = new Set([dashboard.backend || "icinga"]);
+	for (const element of dashboard.elements || []) {
+		if (element.options && element.options.backend) {
+			names.add(element.options.backend);
+		}
+	}
+	return names;
+}
+
+// isBackendMessage reports whether the message data from the updates
+// stream is of kind, such as "icinga-error", about a backend the
+// dashboard uses. Messages about backends other than the default
+// name them, as in "icinga-error:apac".
+function isBackendMessage(data, kind) {
+	const [k, name] = data.split(":");
+	return k == kind && backends.has(name || "icinga");
+}
 
 var reconnectFrequencySeconds = 5;
 var evtSource;
@@ -191,8 +238,9 @@ function setupEventSource() {
 	evtSource.onmessage = function (e) {
 		if (
 			slug == e.data ||
+			(template && template == e.data) ||
 			e.data == "update" ||
-			(e.data == "icinga-success" && backendError) ||
+			(isBackendMessage(e.data, "icinga-success") && backendError) ||
 			(e.data == "heartbeat" &&
 				!backendError &&
 				document.getElementById("error").innerHTML != "" &&
@@ -200,7 +248,7 @@ function setupEventSource() {
 		) {
 			evtSource.close();
 			window.location.reload(true);
-		} else if (e.data == "icinga-error") {
+		} else if (isBackendMessage(e.data, "icinga-error")) {
 			if (!backendError) {
 				errorMessage("backend");
 				backendError = true;
@@ -220,14 +268,163 @@ function setupEventSource() {
 	};
 }
 
-setupEventSource();
+// replayFrom is the time from which to replay the archived states of
+// the dashboard, if any, instead of showing its current state.
+const replayParams = new URLSearchParams(window.location.search);
+const replayFrom = replayParams.get("replay");
 
-// Paths are of the form /my-dashboard/view
-const events = new EventSource("/events?stream=" + slug);
+// Shared dashboards may only follow their own event stream,
+// so are not reloaded when changed. Nor are replayed dashboards.
+if (!meerkat.shared() && !replayFrom) {
+	setupEventSource();
+}
+
+// replayRecord sends the states in an archive record to the elements of
+// dashboard, as if they were events from Icinga.
+function replayRecord(dashboard, record, events) {
+	for (const element of dashboard.elements || []) {
+		const state = record.states[element.id];
+		if (!state || !element.options.objectName) {
+			continue;
+		}
+		const type = element.options.objectType.includes("host")
+			? "Host"
+			: "Service";
+		const obj = {
+			type: type,
+			element: element.options.objectName,
+			element_id: element.id,
+			attrs: {
+				__name: element.options.objectName,
+				type: type,
+				acknowledgement: state.acknowledged ? 1 : 0,
+				state: state.state,
+				last_check_result: {
+					output: state.output || "",
+					state: state.state,
+					performance_data: Object.entries(state.perfdata || {}).map(
+						([label, value]) => ({ label, value })
+					),
+				},
+			},
+		};
+		events.dispatchEvent(
+			new MessageEvent("StateChange", { data: JSON.stringify([obj]) })
+		);
+	}
+}
+
+// Replay steps through the archived states of dashboard, starting at
+// the time from, one record a second while playing.
+function Replay({ dashboard, events, from }) {
+	const [records, setRecords] = useState(null);
+	const [error, setError] = useState("");
+	const [index, setIndex] = useState(0);
+	const [playing, setPlaying] = useState(false);
 
-meerkat.getDashboard(slug).then((d) => {
+	useEffect(() => {
+		const start = new Date(from);
+		const hours = Math.min(Number(replayParams.get("hours")) || 1, 24);
+		const end = new Date(start.getTime() + hours * 60 * 60 * 1000);
+		meerkat
+			.getArchive(dashboard.slug, start, end)
+			.then(setRecords)
+			.catch((err) => setError(err.message));
+	}, [from]);
+
+	// Elements ignore events until they have loaded their object,
+	// so the current record is sent every second, not only when it changes.
+	useEffect(() => {
+		if (!records || records.length == 0) {
+			return;
+		}
+		replayRecord(dashboard, records[index], events);
+		const timer = setInterval(() => {
+			if (playing && index + 1 < records.length) {
+				setIndex(index + 1);
+			} else {
+				setPlaying(false);
+				replayRecord(dashboard, records[index], events);
+			}
+		}, 1000);
+		return () => clearInterval(timer);
+	}, [records, index, playing]);
+
+	let status;
+	if (error) {
+		status = `Error loading archive: ${error}`;
+	} else if (!records) {
+		status = "Loading archive…";
+	} else if (records.length == 0) {
+		status = `Nothing archived from ${new Date(from).toLocaleString()}`;
+	} else {
+		status = new Date(records[index].time).toLocaleString();
+	}
+	const ready = records && records.length > 0;
+	return (
+		<div class="fixed-bottom bg-dark text-white p-2 d-flex align-items-center gap-2">
+			<span class="badge bg-warning text-dark">Replay</span>
+			<button
+				class="btn btn-sm btn-outline-light"
+				disabled={!ready || index == 0}
+				onClick={() => setIndex(index - 1)}
+			>
+				Back
+			</button>
+			<button
+				class="btn btn-sm btn-light"
+				disabled={!ready}
+				onClick={() => setPlaying(!playing)}
+			>
+				{playing ? "Pause" : "Play"}
+			</button>
+			<button
+				class="btn btn-sm btn-outline-light"
+				disabled={!ready || index + 1 >= records.length}
+				onClick={() => setIndex(index + 1)}
+			>
+				Forward
+			</button>
+			<input
+				type="range"
+				class="form-range flex-grow-1"
+				min="0"
+				max={ready ? records.length - 1 : 0}
+				value={index}
+				disabled={!ready}
+				onInput={(e) => setIndex(Number(e.currentTarget.value))}
+			/>
+			<span class="text-nowrap">{status}</span>
+			<a
+				class="btn btn-sm btn-outline-light"
+				href={window.location.pathname}
+			>
+				Live
+			</a>
+		</div>
+	);
+}
+
+// Paths are of the form /my-dashboard/view
+meerkat.getDashboardView(slug).then(({ dashboard, stream }) => {
+	template = dashboard.template;
+	backends = usedBackends(dashboard);
+	if (replayFrom) {
+		const events = new EventTarget();
+		render(
+			<Fragment>
+				<Viewer dashboard={dashboard} events={events} />
+				<Replay dashboard={dashboard} events={events} from={replayFrom} />
+			</Fragment>,
+			document.getElementById("dashboard")
+		);
+		return;
+	}
+	const events = new EventSource(
+		"/events?stream=" + encodeURIComponent(stream) + meerkat.shareQuery()
+	);
 	render(
-		<Viewer dashboard={d} events={events} />,
+		<Viewer dashboard={dashboard} events={events} />,
 		document.getElementById("dashboard")
 	);
 });
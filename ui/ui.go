@@ -8,6 +8,8 @@ package ui
 import (
 	"embed"
 	"io/fs"
+
+	"github.com/meerkat-dashboard/meerkat"
 )
 
 // Fetch third-party style resources like Bootstrap.
@@ -17,6 +19,24 @@ import (
 // It must be created with NewServer.
 type Server struct {
 	fsys fs.FS
+	// Store holds the dashboards served.
+	Store meerkat.Store
+	// Access determines which dashboards users may view and edit.
+	// If nil, anyone may view all dashboards and only admins may
+	// edit them.
+	Access *meerkat.AccessControl
+	// History records each saved version of a dashboard.
+	// If nil, no revisions are kept.
+	History *meerkat.History
+	// DataDir is the directory holding the VERSION file
+	// and uploaded background images and sounds.
+	DataDir string
+	// SSOLoginURL, if set, is linked from the login page
+	// for users to log in with single sign-on.
+	SSOLoginURL string
+	// Backends names the backends dashboards may show
+	// objects from, other than the default one.
+	Backends []string
 }
 
 //go:embed template dist
@@ -25,9 +45,11 @@ var content embed.FS
 // NewServer returns a Server which serves its contents from fsys.
 // fsys may be nil, in which case the returned Server serves the UI
 // from an embedded filesystem created at build time.
+// The returned Server serves dashboards and assets from the current
+// directory; set Store and DataDir to serve them from elsewhere.
 func NewServer(fsys fs.FS) *Server {
 	if fsys == nil {
-		return &Server{content}
+		fsys = content
 	}
-	return &Server{fsys}
+	return &Server{fsys: fsys, Store: meerkat.DirStore("."), DataDir: "."}
 }
@@ -0,0 +1,133 @@
+package meerkat
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A FieldError describes why a field of a dashboard is invalid.
+type FieldError struct {
+	// Element is the index of the element containing the field,
+	// or -1 for fields of the dashboard itself.
+	Element int `json:"element"`
+	// Field is the JSON name of the field, such as "rect.w"
+	// or "options.fontSize".
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+func (e FieldError) Error() string {
+	if e.Element < 0 {
+		return fmt.Sprintf("%s: %s", e.Field, e.Reason)
+	}
+	return fmt.Sprintf("elements[%d].%s: %s", e.Element, e.Field, e.Reason)
+}
+
+// ValidationError lists every invalid field of a dashboard.
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	if len(e) == 1 {
+		return "invalid dashboard: " + e[0].Error()
+	}
+	return fmt.Sprintf("invalid dashboard: %s (and %d more errors)", e[0].Error(), len(e)-1)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks d against DashboardSchema, returning a ValidationError
+// listing every invalid field.
+func (d *Dashboard) Validate() error {
+	root := DashboardSchema()
+	var found []schemaError
+	root.validate(root, jsonValue(reflect.ValueOf(d).Elem()), nil, &found)
+	var errs ValidationError
+	seen := make(map[FieldError]bool)
+	for _, e := range found {
+		fe := FieldError{Element: -1, Field: strings.Join(e.path, "."), Reason: e.reason}
+		if len(e.path) > 1 && e.path[0] == "elements" {
+			fe.Element, _ = strconv.Atoi(e.path[1])
+			fe.Field = strings.Join(e.path[2:], ".")
+		}
+		// Options are checked against the schemas of both all options
+		// and those of the element's type.
+		if !seen[fe] {
+			seen[fe] = true
+			errs = append(errs, fe)
+		}
+	}
+	if d.Template != "" {
+		if d.Template == TitleToSlug(d.Title) {
+			errs = append(errs, FieldError{Element: -1, Field: "template", Reason: "a dashboard cannot be an instance of itself"})
+		}
+		if len(d.Elements) > 0 {
+			errs = append(errs, FieldError{Element: -1, Field: "elements", Reason: "instances of a template are shown with its elements, so cannot have their own"})
+		}
+	}
+	ids := make(map[string]int)
+	for i, e := range d.Elements {
+		if e.ID == "" {
+			continue
+		}
+		if j, ok := ids[e.ID]; ok {
+			errs = append(errs, FieldError{Element: i, Field: "id", Reason: fmt.Sprintf("%q is also the id of element %d", e.ID, j)})
+			continue
+		}
+		ids[e.ID] = i
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Element < errs[j].Element })
+	return errs
+}
+
+var (
+	hexColour = regexp.MustCompile(`^#([[:xdigit:]]{3,4}|[[:xdigit:]]{6}|[[:xdigit:]]{8})$`)
+	// funcColour loosely matches the CSS colour functions,
+	// such as rgb(255, 0, 0) or hsla(0 100% 50% / 0.5).
+	funcColour = regexp.MustCompile(`^(rgb|rgba|hsl|hsla)\(\s*[-+0-9.%deg]+(\s*[,/]?\s*[-+0-9.%]+){2,3}\s*\)$`)
+)
+
+// validColour reports whether s is a CSS colour:
+// a hex colour, an rgb or hsl function, or a named colour.
+func validColour(s string) bool {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return hexColour.MatchString(s) || funcColour.MatchString(s) || namedColours[s]
+}
+
+var namedColours = make(map[string]bool)
+
+func init() {
+	const names = `transparent currentcolor aliceblue antiquewhite aqua aquamarine azure beige bisque black
+		blanchedalmond blue blueviolet brown burlywood cadetblue chartreuse chocolate coral
+		cornflowerblue cornsilk crimson cyan darkblue darkcyan darkgoldenrod darkgray darkgreen
+		darkgrey darkkhaki darkmagenta darkolivegreen darkorange darkorchid darkred darksalmon
+		darkseagreen darkslateblue darkslategray darkslategrey darkturquoise darkviolet deeppink
+		deepskyblue dimgray dimgrey dodgerblue firebrick floralwhite forestgreen fuchsia gainsboro
+		ghostwhite gold goldenrod gray green greenyellow grey honeydew hotpink indianred indigo
+		ivory khaki lavender lavenderblush lawngreen lemonchiffon lightblue lightcoral lightcyan
+		lightgoldenrodyellow lightgray lightgreen lightgrey lightpink lightsalmon lightseagreen
+		lightskyblue lightslategray lightslategrey lightsteelblue lightyellow lime limegreen linen
+		magenta maroon mediumaquamarine mediumblue mediumorchid mediumpurple mediumseagreen
+		mediumslateblue mediumspringgreen mediumturquoise mediumvioletred midnightblue mintcream
+		mistyrose moccasin navajowhite navy oldlace olive olivedrab orange orangered orchid
+		palegoldenrod palegreen paleturquoise palevioletred papayawhip peachpuff peru pink plum
+		powderblue purple rebeccapurple red rosybrown royalblue saddlebrown salmon sandybrown
+		seagreen seashell sienna silver skyblue slateblue slategray slategrey snow springgreen
+		steelblue tan teal thistle tomato turquoise violet wheat white whitesmoke yellow yellowgreen`
+	for _, name := range strings.Fields(names) {
+		namedColours[name] = true
+	}
+}
@@ -0,0 +1,76 @@
+package meerkat
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	valid := Element{
+		ID:   "a",
+		Type: "check-card",
+		Rect: Rect{X: 10, Y: 10, W: 20, H: 5},
+		Options: Options{
+			ObjectType:      "servicefilter",
+			FontSize:        "14",
+			BackgroundColor: "#007bff",
+			OkFontColor:     "rgb(0, 128, 0)",
+			FontColor:       "White",
+		},
+	}
+	d := &Dashboard{Title: "Test", Elements: []Element{valid}}
+	if err := d.Validate(); err != nil {
+		t.Fatalf("valid dashboard: %v", err)
+	}
+
+	invalid := valid
+	invalid.Type = "chart"
+	invalid.Rect.W = -1
+	invalid.Options.ObjectType = "user"
+	invalid.Options.FontSize = "big"
+	invalid.Options.StrokeWidth = "0"
+	invalid.Options.CriticalStrokeColor = "#12345"
+	d = &Dashboard{Title: "!!", Elements: []Element{valid, invalid}}
+	err := d.Validate()
+	var verr ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("got error %v, want ValidationError", err)
+	}
+	var got []string
+	for _, f := range verr {
+		got = append(got, f.Error())
+	}
+	want := []string{
+		`title: "!!" does not match pattern [A-Za-z0-9]`,
+		`elements[1].options.criticalStrokeColor: "#12345" is not a colour`,
+		`elements[1].options.fontSize: "big" is not a number`,
+		`elements[1].options.objectType: "user" is not one of host, service, hostgroup, servicegroup, hostfilter, servicefilter, alert, alertfilter, metric`,
+		"elements[1].options.strokeWidth: must be greater than 0, got 0",
+		"elements[1].rect.w: must be at least 0, got -1",
+		`elements[1].type: "chart" is not one of check-card, check-svg, check-line, dynamic-text, static-text, static-svg, static-ticker, image, video, audio, clock`,
+		`elements[1].id: "a" is also the id of element 0`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got errors\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestValidColour(t *testing.T) {
+	tests := map[string]bool{
+		"#fff":                     true,
+		"#FFFFFF80":                true,
+		"rgba(255, 255, 255, 0.5)": true,
+		"hsl(120deg 100% 50%)":     true,
+		"transparent":              true,
+		"#ggg":                     false,
+		"blurple":                  false,
+		"rgb(1, 2)":                false,
+		"url(x)":                   false,
+	}
+	for s, want := range tests {
+		if got := validColour(s); got != want {
+			t.Errorf("validColour(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
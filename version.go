@@ -3,6 +3,7 @@ package meerkat
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime/debug"
 	"strings"
 )
@@ -33,8 +34,10 @@ func BuildString() string {
 	return s
 }
 
-func VersionString() string {
-	bytes, err := os.ReadFile("VERSION")
+// VersionString returns the release version recorded in the
+// VERSION file in dir, or "development" if there is none.
+func VersionString(dir string) string {
+	bytes, err := os.ReadFile(filepath.Join(dir, "VERSION"))
 	dev := "development"
 
 	if err != nil {